@@ -2,48 +2,291 @@ package main
 
 import (
 	"adflow/internal/adapters"
-	"adflow/internal/adapters/adrepo"
-	"adflow/internal/adapters/aduser"
+	"adflow/internal/ads"
 	"adflow/internal/app"
 	"adflow/internal/app/auth"
+	"adflow/internal/moderation"
+	"adflow/internal/notify"
+	"adflow/internal/outbox"
+	"adflow/internal/pubsub"
+	"adflow/internal/webhook"
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
-	// "adflow/internal/ports/grpc"
-	// grpcApp "adflow/internal/ports/grpc/app"
+	"golang.org/x/sync/errgroup"
+
+	"adflow/internal/ports/grpc"
 	"adflow/internal/ports/httpgin"
 )
 
-func main() {
-	db_uri_users, ok := os.LookupEnv("DB_URI_USERS")
+const shutdownTimeout = 10 * time.Second
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+// Длительность из окружения в формате time.ParseDuration, например 720h
+func getDuration(key string, fallback time.Duration) time.Duration {
+	value, ok := os.LookupEnv(key)
 	if !ok {
-		db_uri_users = "test_users.db"
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Fatalf("%s: invalid duration %q", key, value)
+	}
+	return d
+}
+
+// Режим модерации и правила проверки; запрещённые слова перечисляются через запятую
+func moderationOption() app.Option {
+	mode := app.ModerationMode(getEnv("MODERATION", string(app.ModerationAll)))
+	if mode != app.ModerationAll && mode != app.ModerationFlagged {
+		log.Fatalf("MODERATION: unknown mode %q", mode)
 	}
 
-	db_uri_ads, ok := os.LookupEnv("DB_URI_ADS")
+	rules := moderation.Default()
+	var words []string
+	for _, word := range strings.Split(getEnv("MODERATION_BANNED_WORDS", ""), ",") {
+		if word = strings.TrimSpace(word); word != "" {
+			words = append(words, word)
+		}
+	}
+	if len(words) > 0 {
+		rules = append(rules, moderation.BannedWords(words...))
+	}
+
+	return app.WithModeration(mode, rules...)
+}
+
+// Доставка уведомлений по сохранённым поискам: письмом, если задан SMTP_ADDR, иначе в журнал
+func notifier() app.Notifier {
+	addr, ok := os.LookupEnv("SMTP_ADDR")
 	if !ok {
-		db_uri_ads = "test_ads.db"
+		return notify.Log{}
 	}
 
-	signKey, ok := os.LookupEnv("AUTH_SIGNING_KEY")
-	if ok {
-		auth.JwtKey = []byte(signKey)
+	return notify.NewSMTP(notify.SMTPConfig{
+		Addr:     addr,
+		From:     getEnv("SMTP_FROM", "adflow@localhost"),
+		Username: getEnv("SMTP_USERNAME", ""),
+		Password: getEnv("SMTP_PASSWORD", ""),
+	})
+}
+
+// Дополнительные приёмники событий домена: файл JSON Lines и/или вебхук
+func outboxSinks() []outbox.Sink {
+	var sinks []outbox.Sink
+	if path := getEnv("OUTBOX_FILE", ""); path != "" {
+		sinks = append(sinks, outbox.NewFile(path))
+	}
+	if url := getEnv("OUTBOX_WEBHOOK_URL", ""); url != "" {
+		sinks = append(sinks, outbox.NewWebhook(url, nil))
+	}
+	return sinks
+}
+
+// Ключ подписи и ключи, токены которых ещё принимаются после ротации.
+// Без ключа сервер стартует только в режиме разработки со случайным ключом.
+func signingKeys() (*auth.KeySet, error) {
+	if _, ok := os.LookupEnv("AUTH_SIGNING_KEY"); ok {
+		return nil, errors.New("AUTH_SIGNING_KEY is no longer supported, use AUTH_SIGNING_KEY_FILE")
+	}
+
+	path, ok := os.LookupEnv("AUTH_SIGNING_KEY_FILE")
+	if !ok {
+		if getEnv("AUTH_DEV_MODE", "") != "true" {
+			return nil, errors.New("AUTH_SIGNING_KEY_FILE is not set; set AUTH_DEV_MODE=true to use a temporary key")
+		}
+		log.Println("AUTH_DEV_MODE: signing tokens with a temporary key")
+		return auth.NewDevKeySet()
+	}
+
+	signing, err := auth.LoadPrivateKey(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var previous []*auth.Key
+	for _, path := range strings.Split(getEnv("AUTH_VERIFY_KEY_FILES", ""), ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		key, err := auth.LoadPublicKey(path)
+		if err != nil {
+			return nil, err
+		}
+		previous = append(previous, key)
+	}
+
+	return auth.NewKeySet(signing, previous...)
+}
+
+func main() {
+	db_uri_users := getEnv("DB_URI_USERS", "test_users.db")
+	db_uri_ads := getEnv("DB_URI_ADS", "test_ads.db")
+	blobURI := getEnv("BLOB_URI", "blobs")
+	imageURL := getEnv("IMAGE_URL", app.DefaultImageURL)
+	httpPort := getEnv("HTTP_PORT", "8080")
+	grpcPort := getEnv("GRPC_PORT", "50051")
+	adTTL := getDuration("AD_TTL", ads.PublicationTTL)
+	expireInterval := getDuration("AD_EXPIRE_INTERVAL", time.Minute)
+	outboxInterval := getDuration("OUTBOX_INTERVAL", 5*time.Second)
+	webhookInterval := getDuration("WEBHOOK_INTERVAL", 5*time.Second)
+	notifyInterval := getDuration("NOTIFY_INTERVAL", 30*time.Second)
+
+	keys, err := signingKeys()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	hasher, err := auth.HasherFromEnv()
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	db_users, err := adapters.NewSQLite(db_uri_users)
+	users, tokens, err := adapters.NewUsers(db_uri_users)
 	if err != nil {
 		panic(err)
 	}
 
-	db_ads, err := adapters.NewSQLite(db_uri_ads)
+	repo, index, err := adapters.NewAds(db_uri_ads)
 	if err != nil {
 		panic(err)
 	}
 
-	repo, users := adrepo.NewSQLiteAds(db_ads), aduser.NewSQLiteUsers(db_users)
-	httpServer := httpgin.NewHTTPServer(":8080", app.NewApp(repo, users))
-	// _ = grpc.NewGRPCServer(grpcApp.NewAdService(repo, users))
-	err = httpServer.Listen()
+	favorites, err := adapters.NewFavorites(db_uri_ads)
 	if err != nil {
 		panic(err)
 	}
+
+	searches, err := adapters.NewSavedSearches(db_uri_users)
+	if err != nil {
+		panic(err)
+	}
+
+	chats, err := adapters.NewConversations(db_uri_ads)
+	if err != nil {
+		panic(err)
+	}
+
+	hooks, err := adapters.NewWebhooks(db_uri_ads)
+	if err != nil {
+		panic(err)
+	}
+
+	blobs, err := adapters.NewBlobs(blobURI)
+	if err != nil {
+		panic(err)
+	}
+
+	stores := app.Stores{
+		Ads:           repo,
+		Users:         users,
+		Tokens:        tokens,
+		Favorites:     favorites,
+		Searches:      searches,
+		Conversations: chats,
+		Webhooks:      hooks,
+	}
+	// Шину закрывают при остановке, чтобы открытые подписки не держали серверы
+	hub := pubsub.NewHub(pubsub.DefaultBuffer)
+	a := app.NewApp(stores,
+		app.WithHub(hub),
+		app.WithSearchIndex(index),
+		app.WithBlobStore(blobs),
+		app.WithImageURL(imageURL),
+		app.WithAdTTL(adTTL),
+		moderationOption(),
+		app.WithNotifier(notifier()),
+		app.WithKeys(keys),
+		app.WithPasswordHasher(hasher),
+	)
+	httpServer := httpgin.NewHTTPServer(":"+httpPort, a)
+	grpcServer := grpc.NewGRPCServer(a)
+
+	lis, err := net.Listen("tcp", ":"+grpcPort)
+	if err != nil {
+		panic(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	eg, ctx := errgroup.WithContext(ctx)
+
+	eg.Go(func() error {
+		log.Printf("http server listening on :%s", httpPort)
+		if err := httpServer.Listen(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	})
+
+	eg.Go(func() error {
+		log.Printf("grpc server listening on :%s", grpcPort)
+		return grpcServer.Serve(lis)
+	})
+
+	eg.Go(func() error {
+		return app.RunScheduler(ctx, a, expireInterval)
+	})
+
+	// Вебхуки партнёров и уведомления по сохранённым поискам - ещё два приёмника outbox;
+	// отправляются они отдельно, со своими повторами
+	dispatcher := webhook.NewDispatcher(hooks)
+	relay := outbox.NewRelay([]outbox.Source{{Name: "ads", Outbox: repo}, {Name: "users", Outbox: users}},
+		append(outboxSinks(), dispatcher, notify.NewMatcher(a))...)
+
+	eg.Go(func() error {
+		return relay.Run(ctx, outboxInterval)
+	})
+
+	eg.Go(func() error {
+		return dispatcher.Run(ctx, webhookInterval)
+	})
+
+	eg.Go(func() error {
+		return app.RunNotifications(ctx, a, notifyInterval)
+	})
+
+	// Как только получен сигнал или один из серверов упал, останавливаем оба
+	eg.Go(func() error {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		hub.Close()
+
+		// GracefulStop ждёт все вызовы без срока; если не уложились в shutdownTimeout, рвём соединения
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+
+		err := httpServer.Shutdown(shutdownCtx)
+		select {
+		case <-stopped:
+		case <-shutdownCtx.Done():
+			grpcServer.Stop()
+		}
+		return err
+	})
+
+	if err := eg.Wait(); err != nil {
+		log.Fatal(err)
+	}
 }
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"adflow/internal/adapters"
+	"adflow/internal/adapters/aduser"
+	"adflow/internal/app/auth"
+	"log"
+	"os"
+)
+
+// Одноразовая миграция: хеширует пароли, сохранённые в открытом виде
+func main() {
+	db_uri_users, ok := os.LookupEnv("DB_URI_USERS")
+	if !ok {
+		db_uri_users = "test_users.db"
+	}
+
+	hasher, err := auth.HasherFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db_users, err := adapters.Open(db_uri_users)
+	if err != nil {
+		panic(err)
+	}
+
+	migrated, err := aduser.MigratePasswords(db_users, hasher)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("migrated %d passwords", migrated)
+}
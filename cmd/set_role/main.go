@@ -0,0 +1,42 @@
+package main
+
+import (
+	"adflow/internal/adapters"
+	"adflow/internal/ads"
+	"log"
+	"os"
+)
+
+// Назначает роль пользователю напрямую в базе: так появляется первый администратор,
+// дальше роли раздаются через API
+func main() {
+	if len(os.Args) != 3 {
+		log.Fatalf("usage: %s <nickname> <user|moderator|admin>", os.Args[0])
+	}
+	nickname, role := os.Args[1], ads.Role(os.Args[2])
+
+	if !role.Valid() {
+		log.Fatalf("unknown role %q", role)
+	}
+
+	db_uri_users, ok := os.LookupEnv("DB_URI_USERS")
+	if !ok {
+		db_uri_users = "test_users.db"
+	}
+
+	users, _, err := adapters.NewUsers(db_uri_users)
+	if err != nil {
+		panic(err)
+	}
+
+	user, err := users.GetByNickname(nickname)
+	if err != nil {
+		log.Fatalf("user %q not found", nickname)
+	}
+
+	if err := users.UpdateRole(user.ID, role, ads.UserRoleChanged{UserID: user.ID, Role: role}); err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("user %q is now %s", nickname, role)
+}
@@ -0,0 +1,212 @@
+package adconversation
+
+import (
+	"adflow/internal/ads"
+	"adflow/internal/app"
+	"sort"
+	"sync"
+	"time"
+)
+
+type localConversations struct {
+	conversations map[int64]*ads.Conversation
+	messages      map[int64]*ads.Message
+	cnt           int64
+	messageCnt    int64
+	m             sync.Mutex
+}
+
+func (r *localConversations) StartConversation(conversation *ads.Conversation) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	for _, c := range r.conversations {
+		if c.AdID == conversation.AdID && c.BuyerID == conversation.BuyerID {
+			*conversation = *c
+			return nil
+		}
+	}
+
+	r.cnt += 1
+	conversation.ID = r.cnt
+
+	stored := *conversation
+	r.conversations[stored.ID] = &stored
+	return nil
+}
+
+func (r *localConversations) GetConversation(id, viewerID int64) (*ads.Conversation, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	c, ok := r.conversations[id]
+	if !ok {
+		return nil, ads.ErrBadRequest
+	}
+	return r.view(c, viewerID), nil
+}
+
+// view копирует переписку и заполняет последнее сообщение и непрочитанные для viewerID
+func (r *localConversations) view(c *ads.Conversation, viewerID int64) *ads.Conversation {
+	conversation := *c
+	if last, ok := r.messages[c.LastMessageID]; ok {
+		message := *last
+		conversation.LastMessage = &message
+	}
+	for _, m := range r.messages {
+		if m.ConversationID == c.ID && m.SenderID != viewerID && m.ReadTime == nil {
+			conversation.Unread += 1
+		}
+	}
+	return &conversation
+}
+
+func (r *localConversations) ListConversations(userID, adID, beforeID int64, limit int) ([]*ads.Conversation, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	var list []*ads.Conversation
+	for _, c := range r.conversations {
+		if !c.HasParticipant(userID) || c.LastMessageID == 0 {
+			continue
+		}
+		if (adID != 0 && c.AdID != adID) || (beforeID != 0 && c.LastMessageID >= beforeID) {
+			continue
+		}
+		list = append(list, c)
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].LastMessageID > list[j].LastMessageID })
+	if limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+
+	for i, c := range list {
+		list[i] = r.view(c, userID)
+	}
+	return list, nil
+}
+
+func (r *localConversations) ListInbox(authorID, beforeID int64, limit int) ([]*ads.InboxEntry, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	entries := make(map[int64]*ads.InboxEntry)
+	for _, c := range r.conversations {
+		if c.AuthorID != authorID || c.LastMessageID == 0 {
+			continue
+		}
+
+		entry, ok := entries[c.AdID]
+		if !ok {
+			entry = &ads.InboxEntry{AdID: c.AdID}
+			entries[c.AdID] = entry
+		}
+		if c.LastMessageID > entry.LastMessageID {
+			entry.LastMessageID = c.LastMessageID
+		}
+		entry.Conversations += 1
+		entry.Unread += r.view(c, authorID).Unread
+	}
+
+	var list []*ads.InboxEntry
+	for _, entry := range entries {
+		if beforeID == 0 || entry.LastMessageID < beforeID {
+			list = append(list, entry)
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].LastMessageID > list[j].LastMessageID })
+	if limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	return list, nil
+}
+
+func (r *localConversations) AddMessage(message *ads.Message) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	c, ok := r.conversations[message.ConversationID]
+	if !ok {
+		return ads.ErrBadRequest
+	}
+
+	r.messageCnt += 1
+	message.ID = r.messageCnt
+
+	stored := *message
+	r.messages[stored.ID] = &stored
+	c.LastMessageID = stored.ID
+	return nil
+}
+
+func (r *localConversations) ListMessages(conversationID, beforeID int64, limit int) ([]*ads.Message, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	var list []*ads.Message
+	for _, m := range r.messages {
+		if m.ConversationID == conversationID && (beforeID == 0 || m.ID < beforeID) {
+			message := *m
+			list = append(list, &message)
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].ID > list[j].ID })
+	if limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	return list, nil
+}
+
+func (r *localConversations) MarkRead(conversationID, readerID, upToID int64, readTime time.Time) (int64, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	var n int64
+	for _, m := range r.messages {
+		if m.ConversationID != conversationID || m.SenderID == readerID || m.ReadTime != nil {
+			continue
+		}
+		if upToID != 0 && m.ID > upToID {
+			continue
+		}
+		t := readTime
+		m.ReadTime = &t
+		n += 1
+	}
+	return n, nil
+}
+
+func (r *localConversations) RemoveAdConversations(adID int64) error {
+	return r.remove(func(c *ads.Conversation) bool { return c.AdID == adID })
+}
+
+func (r *localConversations) RemoveUserConversations(userID int64) error {
+	return r.remove(func(c *ads.Conversation) bool { return c.HasParticipant(userID) })
+}
+
+func (r *localConversations) remove(match func(c *ads.Conversation) bool) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	for id, c := range r.conversations {
+		if match(c) {
+			delete(r.conversations, id)
+		}
+	}
+	for id, m := range r.messages {
+		if _, ok := r.conversations[m.ConversationID]; !ok {
+			delete(r.messages, id)
+		}
+	}
+	return nil
+}
+
+func New() app.Conversations {
+	return &localConversations{
+		conversations: make(map[int64]*ads.Conversation),
+		messages:      make(map[int64]*ads.Message),
+	}
+}
@@ -0,0 +1,233 @@
+package adconversation
+
+import (
+	"adflow/internal/ads"
+	"adflow/internal/app"
+	"errors"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type gormConversations struct {
+	db *gorm.DB
+	m  sync.Mutex
+}
+
+func (r *gormConversations) StartConversation(conversation *ads.Conversation) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	// Уникальный индекс по ad_id и buyer_id защищает от дублей и другие процессы
+	conversation.ID = 0
+	return r.db.
+		Where(ads.Conversation{AdID: conversation.AdID, BuyerID: conversation.BuyerID}).
+		Attrs(ads.Conversation{AuthorID: conversation.AuthorID, CreationTime: conversation.CreationTime}).
+		FirstOrCreate(conversation).Error
+}
+
+func (r *gormConversations) GetConversation(id, viewerID int64) (*ads.Conversation, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	var conversation ads.Conversation
+	err := r.db.First(&conversation, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ads.ErrBadRequest
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	list := []*ads.Conversation{&conversation}
+	if err := r.fill(list, viewerID); err != nil {
+		return nil, err
+	}
+	return &conversation, nil
+}
+
+// fill подставляет последние сообщения и число непрочитанных для viewerID
+func (r *gormConversations) fill(list []*ads.Conversation, viewerID int64) error {
+	if len(list) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(list))
+	lastIDs := make([]int64, 0, len(list))
+	for _, c := range list {
+		ids = append(ids, c.ID)
+		lastIDs = append(lastIDs, c.LastMessageID)
+	}
+
+	var messages []*ads.Message
+	if err := r.db.Where("id IN ?", lastIDs).Find(&messages).Error; err != nil {
+		return err
+	}
+	last := make(map[int64]*ads.Message, len(messages))
+	for _, m := range messages {
+		last[m.ConversationID] = m
+	}
+
+	var rows []struct {
+		ConversationID int64
+		Count          int64
+	}
+	err := r.db.Model(&ads.Message{}).
+		Select("conversation_id, COUNT(*) AS count").
+		Where("conversation_id IN ? AND sender_id <> ? AND read_time IS NULL", ids, viewerID).
+		Group("conversation_id").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+	unread := make(map[int64]int64, len(rows))
+	for _, row := range rows {
+		unread[row.ConversationID] = row.Count
+	}
+
+	for _, c := range list {
+		c.LastMessage = last[c.ID]
+		c.Unread = unread[c.ID]
+	}
+	return nil
+}
+
+func (r *gormConversations) ListConversations(userID, adID, beforeID int64, limit int) ([]*ads.Conversation, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	query := r.db.Where("(buyer_id = ? OR author_id = ?) AND last_message_id > 0", userID, userID)
+	if adID != 0 {
+		query = query.Where("ad_id = ?", adID)
+	}
+	if beforeID > 0 {
+		query = query.Where("last_message_id < ?", beforeID)
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	var list []*ads.Conversation
+	if err := query.Order("last_message_id DESC").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	if err := r.fill(list, userID); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *gormConversations) ListInbox(authorID, beforeID int64, limit int) ([]*ads.InboxEntry, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	// Непрочитанные считаются по сообщениям покупателей, поэтому переписки соединяются с ними
+	query := r.db.Table("conversations").
+		Select("conversations.ad_id, MAX(conversations.last_message_id) AS last_message_id, "+
+			"COUNT(DISTINCT conversations.id) AS conversations, COUNT(messages.id) AS unread").
+		Joins("LEFT JOIN messages ON messages.conversation_id = conversations.id "+
+			"AND messages.sender_id <> ? AND messages.read_time IS NULL", authorID).
+		Where("conversations.author_id = ? AND conversations.last_message_id > 0", authorID).
+		Group("conversations.ad_id")
+	if beforeID > 0 {
+		query = query.Having("MAX(conversations.last_message_id) < ?", beforeID)
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	var list []*ads.InboxEntry
+	if err := query.Order("last_message_id DESC").Scan(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *gormConversations) AddMessage(message *ads.Message) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	message.ID = 0
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(message).Error; err != nil {
+			return err
+		}
+
+		result := tx.Model(&ads.Conversation{}).
+			Where("id = ?", message.ConversationID).
+			Update("last_message_id", message.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ads.ErrBadRequest
+		}
+		return nil
+	})
+}
+
+func (r *gormConversations) ListMessages(conversationID, beforeID int64, limit int) ([]*ads.Message, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	query := r.db.Where("conversation_id = ?", conversationID)
+	if beforeID > 0 {
+		query = query.Where("id < ?", beforeID)
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	var list []*ads.Message
+	if err := query.Order("id DESC").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *gormConversations) MarkRead(conversationID, readerID, upToID int64, readTime time.Time) (int64, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	query := r.db.Model(&ads.Message{}).
+		Where("conversation_id = ? AND sender_id <> ? AND read_time IS NULL", conversationID, readerID)
+	if upToID != 0 {
+		query = query.Where("id <= ?", upToID)
+	}
+
+	result := query.Update("read_time", readTime)
+	return result.RowsAffected, result.Error
+}
+
+func (r *gormConversations) RemoveAdConversations(adID int64) error {
+	return r.remove("ad_id = ?", adID)
+}
+
+func (r *gormConversations) RemoveUserConversations(userID int64) error {
+	return r.remove("buyer_id = ? OR author_id = ?", userID, userID)
+}
+
+func (r *gormConversations) remove(where string, args ...any) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		ids := tx.Model(&ads.Conversation{}).Select("id").Where(where, args...)
+		if err := tx.Where("conversation_id IN (?)", ids).Delete(&ads.Message{}).Error; err != nil {
+			return err
+		}
+		return tx.Where(where, args...).Delete(&ads.Conversation{}).Error
+	})
+}
+
+// NewGormConversations работает поверх любой базы GORM: SQLite или PostgreSQL
+func NewGormConversations(db *gorm.DB) app.Conversations {
+	if err := db.AutoMigrate(&ads.Conversation{}, &ads.Message{}); err != nil {
+		panic(err)
+	}
+
+	return &gormConversations{
+		db: db,
+	}
+}
@@ -0,0 +1,100 @@
+package adfavorite
+
+import (
+	"adflow/internal/ads"
+	"adflow/internal/app"
+	"sort"
+	"sync"
+)
+
+type localFavorites struct {
+	favorites map[int64]*ads.Favorite
+	cnt       int64
+	m         sync.Mutex
+}
+
+func (r *localFavorites) AddFavorite(favorite *ads.Favorite) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	for _, f := range r.favorites {
+		if f.UserID == favorite.UserID && f.AdID == favorite.AdID {
+			*favorite = *f
+			return nil
+		}
+	}
+
+	r.cnt += 1
+	favorite.ID = r.cnt
+
+	stored := *favorite
+	r.favorites[stored.ID] = &stored
+	return nil
+}
+
+func (r *localFavorites) RemoveFavorite(userID, adID int64) error {
+	return r.remove(func(f *ads.Favorite) bool { return f.UserID == userID && f.AdID == adID })
+}
+
+func (r *localFavorites) ListFavorites(userID, beforeID int64, limit int) ([]*ads.Favorite, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	var list []*ads.Favorite
+	for _, f := range r.favorites {
+		if f.UserID == userID && (beforeID == 0 || f.ID < beforeID) {
+			favorite := *f
+			list = append(list, &favorite)
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].ID > list[j].ID })
+	if limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	return list, nil
+}
+
+func (r *localFavorites) CountFavorites(adIDs []int64) (map[int64]int64, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	wanted := make(map[int64]struct{}, len(adIDs))
+	for _, id := range adIDs {
+		wanted[id] = struct{}{}
+	}
+
+	counts := make(map[int64]int64)
+	for _, f := range r.favorites {
+		if _, ok := wanted[f.AdID]; ok {
+			counts[f.AdID] += 1
+		}
+	}
+	return counts, nil
+}
+
+func (r *localFavorites) RemoveAdFavorites(adID int64) error {
+	return r.remove(func(f *ads.Favorite) bool { return f.AdID == adID })
+}
+
+func (r *localFavorites) RemoveUserFavorites(userID int64) error {
+	return r.remove(func(f *ads.Favorite) bool { return f.UserID == userID })
+}
+
+func (r *localFavorites) remove(match func(f *ads.Favorite) bool) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	for id, f := range r.favorites {
+		if match(f) {
+			delete(r.favorites, id)
+		}
+	}
+	return nil
+}
+
+func New() app.Favorites {
+	return &localFavorites{
+		favorites: make(map[int64]*ads.Favorite),
+	}
+}
@@ -0,0 +1,105 @@
+package adfavorite
+
+import (
+	"adflow/internal/ads"
+	"adflow/internal/app"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+type gormFavorites struct {
+	db *gorm.DB
+	m  sync.Mutex
+}
+
+func (r *gormFavorites) AddFavorite(favorite *ads.Favorite) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	// Уникальный индекс по user_id и ad_id защищает от дублей и другие процессы
+	favorite.ID = 0
+	return r.db.
+		Where(ads.Favorite{UserID: favorite.UserID, AdID: favorite.AdID}).
+		Attrs(ads.Favorite{CreationTime: favorite.CreationTime}).
+		FirstOrCreate(favorite).Error
+}
+
+func (r *gormFavorites) RemoveFavorite(userID, adID int64) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	return r.db.Where("user_id = ? AND ad_id = ?", userID, adID).Delete(&ads.Favorite{}).Error
+}
+
+func (r *gormFavorites) ListFavorites(userID, beforeID int64, limit int) ([]*ads.Favorite, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	query := r.db.Where("user_id = ?", userID)
+	if beforeID > 0 {
+		query = query.Where("id < ?", beforeID)
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	var list []*ads.Favorite
+	if err := query.Order("id DESC").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *gormFavorites) CountFavorites(adIDs []int64) (map[int64]int64, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	counts := make(map[int64]int64)
+	if len(adIDs) == 0 {
+		return counts, nil
+	}
+
+	var rows []struct {
+		AdID  int64
+		Count int64
+	}
+	err := r.db.Model(&ads.Favorite{}).
+		Select("ad_id, COUNT(*) AS count").
+		Where("ad_id IN ?", adIDs).
+		Group("ad_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		counts[row.AdID] = row.Count
+	}
+	return counts, nil
+}
+
+func (r *gormFavorites) RemoveAdFavorites(adID int64) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	return r.db.Where("ad_id = ?", adID).Delete(&ads.Favorite{}).Error
+}
+
+func (r *gormFavorites) RemoveUserFavorites(userID int64) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	return r.db.Where("user_id = ?", userID).Delete(&ads.Favorite{}).Error
+}
+
+// NewGormFavorites работает поверх любой базы GORM: SQLite или PostgreSQL
+func NewGormFavorites(db *gorm.DB) app.Favorites {
+	if err := db.AutoMigrate(&ads.Favorite{}); err != nil {
+		panic(err)
+	}
+
+	return &gormFavorites{
+		db: db,
+	}
+}
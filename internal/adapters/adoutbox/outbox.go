@@ -0,0 +1,117 @@
+// Package adoutbox хранит outbox событий домена рядом с данными хранилища:
+// хранилища встраивают Memory или Gorm и дописывают события в той же транзакции, что и изменение.
+package adoutbox
+
+import (
+	"adflow/internal/ads"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Memory - outbox хранилищ в памяти. Хранилище вызывает Append под своей блокировкой,
+// поэтому событие видно только вместе с изменением.
+type Memory struct {
+	records map[int64]*ads.OutboxRecord
+	cnt     int64
+	m       sync.Mutex
+}
+
+func NewMemory() Memory {
+	return Memory{records: make(map[int64]*ads.OutboxRecord)}
+}
+
+func (o *Memory) Append(events []ads.DomainEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	records, err := ads.NewOutboxRecords(events, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	o.m.Lock()
+	defer o.m.Unlock()
+
+	for _, record := range records {
+		o.cnt += 1
+		record.ID = o.cnt
+		o.records[record.ID] = record
+	}
+	return nil
+}
+
+func (o *Memory) PendingEvents(afterID int64, limit int) ([]*ads.OutboxRecord, error) {
+	o.m.Lock()
+	defer o.m.Unlock()
+
+	var list []*ads.OutboxRecord
+	for _, record := range o.records {
+		if record.DeliveredAt == nil && record.ID > afterID {
+			copied := *record
+			list = append(list, &copied)
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID < list[j].ID
+	})
+	if len(list) > limit {
+		list = list[:limit]
+	}
+	return list, nil
+}
+
+func (o *Memory) MarkDelivered(ids []int64, at time.Time) error {
+	o.m.Lock()
+	defer o.m.Unlock()
+
+	for _, id := range ids {
+		if record, ok := o.records[id]; ok {
+			t := at
+			record.DeliveredAt = &t
+		}
+	}
+	return nil
+}
+
+// Append дописывает события в outbox транзакции tx
+func Append(tx *gorm.DB, events []ads.DomainEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	records, err := ads.NewOutboxRecords(events, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	return tx.Create(records).Error
+}
+
+// Gorm читает outbox SQL-хранилища; пишут в него сами хранилища через Append
+type Gorm struct {
+	db *gorm.DB
+}
+
+func NewGorm(db *gorm.DB) Gorm {
+	if err := db.AutoMigrate(&ads.OutboxRecord{}); err != nil {
+		panic(err)
+	}
+	return Gorm{db: db}
+}
+
+func (o Gorm) PendingEvents(afterID int64, limit int) ([]*ads.OutboxRecord, error) {
+	var list []*ads.OutboxRecord
+	err := o.db.Where("delivered_at IS NULL AND id > ?", afterID).Order("id").Limit(limit).Find(&list).Error
+	return list, err
+}
+
+func (o Gorm) MarkDelivered(ids []int64, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return o.db.Model(&ads.OutboxRecord{}).Where("id IN ?", ids).Update("delivered_at", at).Error
+}
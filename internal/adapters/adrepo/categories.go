@@ -0,0 +1,92 @@
+package adrepo
+
+import (
+	"adflow/internal/ads"
+	"sort"
+)
+
+func (r *localRepository) CreateCategory(category *ads.Category) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.categoryCnt += 1
+	category.ID = r.categoryCnt
+
+	c := *category
+	r.categories[c.ID] = &c
+
+	return nil
+}
+
+func (r *localRepository) GetCategory(id int64) (*ads.Category, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	c, ok := r.categories[id]
+	if !ok {
+		return nil, ads.ErrBadRequest
+	}
+
+	category := *c
+	return &category, nil
+}
+
+func (r *localRepository) ListCategories() ([]*ads.Category, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	list := make([]*ads.Category, 0, len(r.categories))
+	for _, c := range r.categories {
+		category := *c
+		list = append(list, &category)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID < list[j].ID
+	})
+
+	return list, nil
+}
+
+func (r *localRepository) UpdateCategory(id int64, name string, parentID int64) (*ads.Category, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	c, ok := r.categories[id]
+	if !ok {
+		return nil, ads.ErrBadRequest
+	}
+
+	c.Name = name
+	c.ParentID = parentID
+
+	category := *c
+	return &category, nil
+}
+
+func (r *localRepository) DeleteCategory(id int64) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if _, ok := r.categories[id]; !ok {
+		return ads.ErrBadRequest
+	}
+
+	delete(r.categories, id)
+
+	return nil
+}
+
+func (r *localRepository) CountPublished() (map[int64]int64, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	counts := make(map[int64]int64)
+	for _, ad := range r.ads {
+		if ad.Published {
+			counts[ad.CategoryID]++
+		}
+	}
+
+	return counts, nil
+}
@@ -0,0 +1,118 @@
+package adrepo
+
+import (
+	"adflow/internal/ads"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// Категории в SQL хранятся одинаково для SQLite и PostgreSQL,
+// поэтому обе реализации репозитория встраивают gormCategories
+type gormCategories struct {
+	db *gorm.DB
+}
+
+// Создаёт таблицу категорий и категорию «Другое», в которую переносятся
+// объявления, созданные до появления категорий
+func newGormCategories(db *gorm.DB) gormCategories {
+	if err := db.AutoMigrate(&ads.Category{}); err != nil {
+		panic(err)
+	}
+
+	var count int64
+	if err := db.Model(&ads.Category{}).Count(&count).Error; err != nil {
+		panic(err)
+	}
+	// ID не задаётся явно, иначе счётчик bigserial в PostgreSQL не сдвинется;
+	// первая строка пустой таблицы получает ID 1
+	if count == 0 {
+		other := &ads.Category{Name: ads.DefaultCategoryName}
+		if err := db.Create(other).Error; err != nil {
+			panic(err)
+		}
+		if other.ID != ads.DefaultCategoryID {
+			panic("categories: default category must have ID 1, recreate the categories table")
+		}
+	}
+
+	err := db.Model(&ads.Ad{}).Where("category_id = 0").Update("category_id", ads.DefaultCategoryID).Error
+	if err != nil {
+		panic(err)
+	}
+
+	return gormCategories{db: db}
+}
+
+func (c gormCategories) CreateCategory(category *ads.Category) error {
+	category.ID = 0
+	return c.db.Create(category).Error
+}
+
+func (c gormCategories) GetCategory(id int64) (*ads.Category, error) {
+	var category ads.Category
+
+	err := c.db.Where("id = ?", id).First(&category).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ads.ErrBadRequest
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &category, nil
+}
+
+func (c gormCategories) ListCategories() ([]*ads.Category, error) {
+	var list []*ads.Category
+
+	if err := c.db.Order("id").Find(&list).Error; err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func (c gormCategories) UpdateCategory(id int64, name string, parentID int64) (*ads.Category, error) {
+	result := c.db.Model(&ads.Category{}).Where("id = ?", id).
+		Updates(map[string]any{"name": name, "parent_id": parentID})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ads.ErrBadRequest
+	}
+
+	return c.GetCategory(id)
+}
+
+func (c gormCategories) DeleteCategory(id int64) error {
+	result := c.db.Where("id = ?", id).Delete(&ads.Category{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ads.ErrBadRequest
+	}
+
+	return nil
+}
+
+func (c gormCategories) CountPublished() (map[int64]int64, error) {
+	var rows []struct {
+		CategoryID int64
+		Count      int64
+	}
+
+	err := c.db.Model(&ads.Ad{}).Select("category_id, count(*) AS count").
+		Where("published = ?", true).Group("category_id").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[int64]int64, len(rows))
+	for _, row := range rows {
+		counts[row.CategoryID] = row.Count
+	}
+	return counts, nil
+}
@@ -0,0 +1,173 @@
+package adrepo
+
+import (
+	"adflow/internal/ads"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Условия на заголовок и текст, которые SQLite и PostgreSQL записывают по-разному.
+// Каждая функция возвращает условие WHERE и его параметр.
+type sqlDialect struct {
+	titlePrefix   func(prefix string) (string, any)
+	titleContains func(substr string) (string, any)
+	text          func(words []string) (string, any)
+	// Выражение для сортировки по заголовку в порядке байтов, как strings.Compare
+	titleOrder string
+	// Выражение расстояния в километрах от точки до места объявления, как ads.Distance
+	distance func(origin ads.Point) string
+}
+
+// formatDegrees подставляет координаты в выражение расстояния: ORDER BY не принимает параметры
+func formatDegrees(x float64) string {
+	return strconv.FormatFloat(x, 'g', -1, 64)
+}
+
+// Переводит ads.Filter в один запрос WHERE; результат совпадает с ads.Filter.Match
+func applyFilter(query *gorm.DB, filter ads.Filter, dialect sqlDialect) *gorm.DB {
+	if filter.Published != nil {
+		query = query.Where("published = ?", *filter.Published)
+	}
+	if len(filter.Statuses) > 0 {
+		query = query.Where("status IN ?", filter.Statuses)
+	}
+	if len(filter.AuthorIDs) > 0 {
+		query = query.Where("author_id IN ?", filter.AuthorIDs)
+	}
+	if len(filter.ExcludeAuthorIDs) > 0 {
+		query = query.Where("author_id NOT IN ?", filter.ExcludeAuthorIDs)
+	}
+	if len(filter.CategoryIDs) > 0 {
+		query = query.Where("category_id IN ?", filter.CategoryIDs)
+	}
+	if filter.Currency != "" {
+		query = query.Where("currency = ?", filter.Currency)
+	}
+	if filter.Price.Min != nil {
+		query = query.Where("price >= ?", *filter.Price.Min)
+	}
+	if filter.Price.Max != nil {
+		query = query.Where("price <= ?", *filter.Price.Max)
+	}
+	if filter.TitlePrefix != "" {
+		query = query.Where(dialect.titlePrefix(filter.TitlePrefix))
+	}
+	if filter.TitleContains != "" {
+		query = query.Where(dialect.titleContains(filter.TitleContains))
+	}
+	if words := ads.Tokens(filter.Text); len(words) > 0 {
+		query = query.Where(dialect.text(words))
+	}
+	if near := filter.Near; near != nil {
+		query = query.Where("latitude IS NOT NULL AND longitude IS NOT NULL")
+
+		// Прямоугольники отбирают строки по индексу idx_ads_location, точное расстояние проверяется после
+		if boxes := near.Boxes(); boxes != nil {
+			conditions := make([]string, len(boxes))
+			var args []any
+			for i, box := range boxes {
+				conditions[i] = "(latitude BETWEEN ? AND ? AND longitude BETWEEN ? AND ?)"
+				args = append(args, box.MinLat, box.MaxLat, box.MinLon, box.MaxLon)
+			}
+			query = query.Where("("+strings.Join(conditions, " OR ")+")", args...)
+			query = query.Where(dialect.distance(near.Center)+" <= ?", near.RadiusKm)
+		}
+	}
+
+	// SQLite хранит время строкой в UTC, поэтому границы тоже переводятся в UTC
+	ranges := []struct {
+		column string
+		r      ads.TimeRange
+	}{
+		{"creation_time", filter.Created},
+		{"update_time", filter.Updated},
+		{"expires_at", filter.Expires},
+	}
+	for _, v := range ranges {
+		if v.r.After != nil {
+			query = query.Where(v.column+" >= ?", v.r.After.UTC())
+		}
+		if v.r.Before != nil {
+			query = query.Where(v.column+" < ?", v.r.Before.UTC())
+		}
+	}
+
+	return query
+}
+
+// Объявлениям, созданным до появления цен, достаётся валюта по умолчанию
+func migratePrices(db *gorm.DB) {
+	err := db.Model(&ads.Ad{}).Where("currency = '' OR currency IS NULL").Update("currency", ads.DefaultCurrency).Error
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Статус объявлений, созданных до его появления, выводится из published, как в ads.Ad.AdStatus.
+// Опубликованные получают срок публикации от момента миграции.
+func migrateStatuses(db *gorm.DB) {
+	err := db.Model(&ads.Ad{}).Where("status = '' OR status IS NULL").
+		Update("status", gorm.Expr("CASE WHEN published THEN ? ELSE ? END", ads.StatusPublished, ads.StatusDraft)).Error
+	if err != nil {
+		panic(err)
+	}
+
+	err = db.Model(&ads.Ad{}).Where("status = ? AND expires_at IS NULL", ads.StatusPublished).
+		Update("expires_at", time.Now().UTC().Add(ads.PublicationTTL)).Error
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Keyset-пагинация: строки после курсора в порядке (поле, id) и одна лишняя строка,
+// по которой nextPage понимает, есть ли следующая страница
+func applyPage(query *gorm.DB, page ads.Page, dialect sqlDialect) *gorm.DB {
+	field := page.Sort.Field
+	if !field.Valid() {
+		field = ads.SortCreationTime
+	}
+
+	column := string(field)
+	switch field {
+	case ads.SortTitle:
+		column = dialect.titleOrder
+	case ads.SortDistance:
+		column = dialect.distance(page.Sort.Origin)
+	}
+
+	op, direction := ">", "ASC"
+	if page.Sort.Desc {
+		op, direction = "<", "DESC"
+	}
+
+	if c := page.After; c != nil {
+		var value any = c.Time.UTC()
+		switch field {
+		case ads.SortTitle:
+			value = c.Title
+		case ads.SortPrice:
+			value = c.Price
+		case ads.SortDistance:
+			value = c.Distance
+		}
+		query = query.Where("("+column+" "+op+" ? OR ("+column+" = ? AND id "+op+" ?))", value, value, c.ID)
+	}
+
+	query = query.Order(column + " " + direction).Order("id " + direction)
+	if page.Limit > 0 {
+		query = query.Limit(page.Limit + 1)
+	}
+	return query
+}
+
+// Обрезает выборку до размера страницы и возвращает курсор следующей, если она есть
+func nextPage(list []*ads.Ad, page ads.Page) ([]*ads.Ad, *ads.Cursor) {
+	if page.Limit <= 0 || len(list) <= page.Limit {
+		return list, nil
+	}
+	list = list[:page.Limit]
+	return list, page.Sort.CursorOf(list[len(list)-1])
+}
@@ -0,0 +1,87 @@
+package adrepo
+
+import (
+	"adflow/internal/ads"
+	"math"
+)
+
+// Размер ячейки сетки в градусах: около 55 км по широте
+const gridCell = 0.5
+
+type gridKey struct {
+	lat, lon int
+}
+
+func gridKeyOf(p ads.Point) gridKey {
+	return gridKey{int(math.Floor(p.Latitude / gridCell)), int(math.Floor(p.Longitude / gridCell))}
+}
+
+// geoGrid - пространственный индекс хранилища в памяти: объявления с координатами
+// разложены по ячейкам сетки, поиск рядом с точкой перебирает только ячейки,
+// пересекающие прямоугольники круга (ads.Near.Boxes)
+type geoGrid struct {
+	cells map[gridKey]map[int64]struct{}
+	keys  map[int64]gridKey
+}
+
+func newGeoGrid() *geoGrid {
+	return &geoGrid{
+		cells: make(map[gridKey]map[int64]struct{}),
+		keys:  make(map[int64]gridKey),
+	}
+}
+
+func (g *geoGrid) remove(id int64) {
+	key, ok := g.keys[id]
+	if !ok {
+		return
+	}
+	delete(g.cells[key], id)
+	if len(g.cells[key]) == 0 {
+		delete(g.cells, key)
+	}
+	delete(g.keys, id)
+}
+
+// set переносит объявление в ячейку его места или убирает из сетки, если места нет
+func (g *geoGrid) set(ad *ads.Ad) {
+	g.remove(ad.ID)
+
+	loc := ad.Location()
+	if loc == nil {
+		return
+	}
+
+	key := gridKeyOf(loc.Point)
+	if g.cells[key] == nil {
+		g.cells[key] = make(map[int64]struct{})
+	}
+	g.cells[key][ad.ID] = struct{}{}
+	g.keys[ad.ID] = key
+}
+
+// candidates возвращает объявления из ячеек, пересекающих прямоугольники
+func (g *geoGrid) candidates(boxes []ads.Box) []int64 {
+	var ids []int64
+	seen := make(map[gridKey]struct{})
+
+	for _, box := range boxes {
+		from := gridKeyOf(ads.Point{Latitude: box.MinLat, Longitude: box.MinLon})
+		to := gridKeyOf(ads.Point{Latitude: box.MaxLat, Longitude: box.MaxLon})
+
+		for lat := from.lat; lat <= to.lat; lat++ {
+			for lon := from.lon; lon <= to.lon; lon++ {
+				key := gridKey{lat, lon}
+				if _, ok := seen[key]; ok {
+					continue
+				}
+				seen[key] = struct{}{}
+
+				for id := range g.cells[key] {
+					ids = append(ids, id)
+				}
+			}
+		}
+	}
+	return ids
+}
@@ -0,0 +1,85 @@
+package adrepo
+
+import (
+	"adflow/internal/ads"
+	"sort"
+)
+
+func (r *localRepository) AddImages(adID int64, images []*ads.Image, max int) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if _, ok := r.ads[adID]; !ok {
+		return ads.ErrBadRequest
+	}
+
+	count := 0
+	for _, img := range r.images {
+		if img.AdID == adID {
+			count++
+		}
+	}
+	if count+len(images) > max {
+		return ads.ErrBadRequest
+	}
+
+	for _, image := range images {
+		r.imageCnt += 1
+		image.ID = r.imageCnt
+		image.AdID = adID
+
+		img := *image
+		r.images[img.ID] = &img
+	}
+
+	return nil
+}
+
+func (r *localRepository) GetImage(id int64) (*ads.Image, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	img, ok := r.images[id]
+	if !ok {
+		return nil, ads.ErrBadRequest
+	}
+
+	image := *img
+	return &image, nil
+}
+
+func (r *localRepository) ListImages(adIDs []int64) ([]*ads.Image, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	wanted := make(map[int64]struct{}, len(adIDs))
+	for _, id := range adIDs {
+		wanted[id] = struct{}{}
+	}
+
+	list := []*ads.Image{}
+	for _, img := range r.images {
+		if _, ok := wanted[img.AdID]; ok {
+			image := *img
+			list = append(list, &image)
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID < list[j].ID
+	})
+
+	return list, nil
+}
+
+func (r *localRepository) DeleteImage(id int64) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if _, ok := r.images[id]; !ok {
+		return ads.ErrBadRequest
+	}
+	delete(r.images, id)
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package adrepo
+
+import (
+	"adflow/internal/ads"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// Сведения о картинках в SQL хранятся одинаково для SQLite и PostgreSQL
+type gormImages struct {
+	db *gorm.DB
+}
+
+func newGormImages(db *gorm.DB) gormImages {
+	if err := db.AutoMigrate(&ads.Image{}); err != nil {
+		panic(err)
+	}
+	return gormImages{db: db}
+}
+
+// AddImages сначала блокирует строку объявления пустым UPDATE: параллельные загрузки в одно объявление
+// ждут друг друга и не превышают max вместе, а в удалённое объявление картинки не попадут
+func (i gormImages) AddImages(adID int64, images []*ads.Image, max int) error {
+	return i.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Exec("UPDATE ads SET id = id WHERE id = ?", adID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ads.ErrBadRequest
+		}
+
+		var count int64
+		if err := tx.Model(&ads.Image{}).Where("ad_id = ?", adID).Count(&count).Error; err != nil {
+			return err
+		}
+		if int(count)+len(images) > max {
+			return ads.ErrBadRequest
+		}
+
+		for _, image := range images {
+			image.ID = 0
+			image.AdID = adID
+		}
+		return tx.Create(images).Error
+	})
+}
+
+func (i gormImages) GetImage(id int64) (*ads.Image, error) {
+	var image ads.Image
+
+	err := i.db.Where("id = ?", id).First(&image).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ads.ErrBadRequest
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &image, nil
+}
+
+func (i gormImages) ListImages(adIDs []int64) ([]*ads.Image, error) {
+	var list []*ads.Image
+
+	if len(adIDs) == 0 {
+		return list, nil
+	}
+	if err := i.db.Where("ad_id IN ?", adIDs).Order("id").Find(&list).Error; err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func (i gormImages) DeleteImage(id int64) error {
+	result := i.db.Where("id = ?", id).Delete(&ads.Image{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ads.ErrBadRequest
+	}
+
+	return nil
+}
@@ -1,9 +1,10 @@
 package adrepo
 
 import (
+	"adflow/internal/adapters/adoutbox"
 	"adflow/internal/ads"
 	"adflow/internal/app"
-	"strings"
+	"sort"
 	"sync"
 	"time"
 )
@@ -11,21 +12,43 @@ import (
 type localRepository struct {
 	ads map[int64]*ads.Ad
 	cnt int64
-	m   sync.Mutex
+
+	categories  map[int64]*ads.Category
+	categoryCnt int64
+
+	images   map[int64]*ads.Image
+	imageCnt int64
+
+	reviews   map[int64]*ads.Review
+	reviewCnt int64
+
+	// Ревизии объявления по порядку номеров
+	revisions   map[int64][]*ads.Revision
+	revisionCnt int64
+
+	grid *geoGrid
+
+	adoutbox.Memory
+
+	m sync.Mutex
 }
 
-func (r *localRepository) Create(ad *ads.Ad) error {
+func (r *localRepository) Create(ad *ads.Ad, events ...ads.DomainEvent) error {
 	r.m.Lock()
 	defer r.m.Unlock()
 
+	// Нумерация с 1, как у SQL-бэкендов
+	r.cnt += 1
 	ad.ID = r.cnt
 	ad.CreationTime = time.Now().UTC()
 	ad.UpdateTime = time.Now().UTC()
+	ad.Status = ad.AdStatus()
 
 	r.ads[r.cnt] = ad
-	r.cnt += 1
+	r.grid.set(ad)
+	r.addRevision(ad, &ads.Revision{AuthorID: ad.AuthorID})
 
-	return nil
+	return r.Append(events)
 }
 
 func (r *localRepository) Get(id int64) (*ads.Ad, error) {
@@ -40,7 +63,24 @@ func (r *localRepository) Get(id int64) (*ads.Ad, error) {
 	return r.ads[id], nil
 }
 
-func (r *localRepository) Update(id int64, title, text string) (*ads.Ad, error) {
+func (r *localRepository) GetMany(ids []int64) ([]*ads.Ad, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	list := []*ads.Ad{}
+	for _, id := range ids {
+		if ad, ok := r.ads[id]; ok {
+			list = append(list, ad)
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID < list[j].ID
+	})
+	return list, nil
+}
+
+func (r *localRepository) Update(id int64, draft ads.Draft, revision *ads.Revision, events ...ads.DomainEvent) (*ads.Ad, error) {
 	r.m.Lock()
 	defer r.m.Unlock()
 
@@ -48,25 +88,29 @@ func (r *localRepository) Update(id int64, title, text string) (*ads.Ad, error)
 		return nil, ads.ErrBadRequest
 	}
 
-	r.ads[id].Title = title
-	r.ads[id].Text = text
+	draft.Apply(r.ads[id])
+	r.grid.set(r.ads[id])
 	r.ads[id].UpdateTime = time.Now().UTC()
+	r.addRevision(r.ads[id], revision)
 
-	return r.ads[id], nil
+	return r.ads[id], r.Append(events)
 }
 
-func (r *localRepository) UpdateStatus(id int64, published bool) (*ads.Ad, error) {
+func (r *localRepository) UpdateStatus(id int64, from, to ads.Status, expiresAt *time.Time, events ...ads.DomainEvent) (*ads.Ad, error) {
 	r.m.Lock()
 	defer r.m.Unlock()
 
-	if _, ok := r.ads[id]; !ok {
+	ad, ok := r.ads[id]
+	if !ok || ad.AdStatus() != from {
 		return nil, ads.ErrBadRequest
 	}
 
-	r.ads[id].Published = published
-	r.ads[id].UpdateTime = time.Now().UTC()
+	ad.Status = to
+	ad.Published = to == ads.StatusPublished
+	ad.ExpiresAt = expiresAt
+	ad.UpdateTime = time.Now().UTC()
 
-	return r.ads[id], nil
+	return ad, r.Append(events)
 }
 
 func (r *localRepository) GetAllAds() ([]*ads.Ad, error) {
@@ -84,46 +128,43 @@ func (r *localRepository) GetAllAds() ([]*ads.Ad, error) {
 	return ads, nil
 }
 
-func CheckFilter(ad *ads.Ad, filter ads.Filter) bool {
-	if filter.Published != nil {
-		if filter.Published != ad.Published {
-			return false
-		}
-	}
-	if filter.AuthorID != nil {
-		if filter.AuthorID != ad.AuthorID {
-			return false
-		}
-	}
-	if filter.TitlePrefix != nil {
-		if !strings.HasPrefix(ad.Title, filter.TitlePrefix.(string)) {
-			return false
-		}
-	}
-	if filter.CreationTime != nil {
-		if (filter.CreationTime.(time.Time)).After(ad.CreationTime) {
-			return false
-		}
-	}
-	return true
-}
-
-func (r *localRepository) GetAds(filter ads.Filter) ([]*ads.Ad, error) {
+// Перебор map идёт в случайном порядке, поэтому выборка сортируется так же, как в SQL
+func (r *localRepository) GetAds(filter ads.Filter, page ads.Page) ([]*ads.Ad, *ads.Cursor, error) {
 	r.m.Lock()
 	defer r.m.Unlock()
 
-	var ads []*ads.Ad
+	if !page.Sort.Field.Valid() {
+		page.Sort.Field = ads.SortCreationTime
+	}
 
-	for _, v := range r.ads {
-		if CheckFilter(v, filter) {
-			ads = append(ads, v)
+	var list []*ads.Ad
+
+	// Рядом с точкой проверяются только объявления из подходящих ячеек сетки
+	candidates := r.ads
+	if filter.Near != nil {
+		if boxes := filter.Near.Boxes(); boxes != nil {
+			candidates = make(map[int64]*ads.Ad)
+			for _, id := range r.grid.candidates(boxes) {
+				candidates[id] = r.ads[id]
+			}
 		}
 	}
 
-	return ads, nil
+	for _, v := range candidates {
+		if filter.Match(v) && (page.After == nil || page.After.Precedes(v)) {
+			list = append(list, v)
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return page.Sort.Less(list[i], list[j])
+	})
+
+	list, next := nextPage(list, page)
+	return list, next, nil
 }
 
-func (r *localRepository) DeleteAd(id int64) error {
+func (r *localRepository) DeleteAd(id int64, events ...ads.DomainEvent) error {
 	r.m.Lock()
 	defer r.m.Unlock()
 
@@ -132,13 +173,24 @@ func (r *localRepository) DeleteAd(id int64) error {
 	}
 
 	delete(r.ads, id)
+	delete(r.revisions, id)
+	r.grid.remove(id)
 
-	return nil
+	return r.Append(events)
 }
 
 func New() app.Repository {
 	return &localRepository{
 		ads: make(map[int64]*ads.Ad),
 		cnt: 0,
+		categories: map[int64]*ads.Category{
+			ads.DefaultCategoryID: {ID: ads.DefaultCategoryID, Name: ads.DefaultCategoryName},
+		},
+		categoryCnt: ads.DefaultCategoryID,
+		images:      make(map[int64]*ads.Image),
+		reviews:     make(map[int64]*ads.Review),
+		revisions:   make(map[int64][]*ads.Revision),
+		grid:        newGeoGrid(),
+		Memory:      adoutbox.NewMemory(),
 	}
 }
@@ -0,0 +1,247 @@
+package adrepo
+
+import (
+	"adflow/internal/adapters/adoutbox"
+	"adflow/internal/ads"
+	"adflow/internal/app"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Идентификаторы выдаёт сама база (bigserial), фильтрация идёт в SQL по индексам,
+// поэтому блокировка на уровне процесса не нужна
+type postgresRepository struct {
+	gormCategories
+	gormImages
+	gormReviews
+	gormRevisions
+	adoutbox.Gorm
+	db *gorm.DB
+}
+
+var postgresAdsIndexes = []string{
+	"CREATE INDEX IF NOT EXISTS idx_ads_published_creation_time ON ads (published, creation_time)",
+	"CREATE INDEX IF NOT EXISTS idx_ads_author_id ON ads (author_id)",
+	"CREATE INDEX IF NOT EXISTS idx_ads_category_id ON ads (category_id)",
+	"CREATE INDEX IF NOT EXISTS idx_ads_creation_time ON ads (creation_time)",
+	// text_pattern_ops нужен, чтобы LIKE 'prefix%' использовал индекс при любой локали
+	"CREATE INDEX IF NOT EXISTS idx_ads_title_prefix ON ads (title text_pattern_ops)",
+	"CREATE INDEX IF NOT EXISTS idx_ads_update_time ON ads (update_time, id)",
+	`CREATE INDEX IF NOT EXISTS idx_ads_title_order ON ads (title COLLATE "C", id)`,
+	"CREATE INDEX IF NOT EXISTS idx_ads_text_words ON ads USING gin (" + postgresTextWords + ")",
+	"CREATE INDEX IF NOT EXISTS idx_ads_currency_price ON ads (currency, price, id)",
+	"CREATE INDEX IF NOT EXISTS idx_ads_location ON ads (latitude, longitude)",
+	"CREATE INDEX IF NOT EXISTS idx_ads_status_expires_at ON ads (status, expires_at)",
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+var arrayEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+func (r *postgresRepository) Create(ad *ads.Ad, events ...ads.DomainEvent) error {
+	now := time.Now().UTC()
+
+	ad.ID = 0
+	ad.CreationTime = now
+	ad.UpdateTime = now
+	ad.Status = ad.AdStatus()
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(ad).Error; err != nil {
+			return err
+		}
+		if err := appendRevision(tx, ad, &ads.Revision{AuthorID: ad.AuthorID}); err != nil {
+			return err
+		}
+		return adoutbox.Append(tx, events)
+	})
+}
+
+func (r *postgresRepository) Get(id int64) (*ads.Ad, error) {
+	var ad ads.Ad
+
+	err := r.db.Where("id = ?", id).First(&ad).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ads.ErrBadRequest
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &ad, nil
+}
+
+func (r *postgresRepository) GetMany(ids []int64) ([]*ads.Ad, error) {
+	list := []*ads.Ad{}
+	if len(ids) == 0 {
+		return list, nil
+	}
+	if err := r.db.Where("id IN ?", ids).Order("id").Find(&list).Error; err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// updateWhere меняет поля одной строкой UPDATE ... RETURNING и в той же транзакции пишет ревизию (если она задана)
+// и события; условие может проверять и прежние значения
+func (r *postgresRepository) updateWhere(fields map[string]any, revision *ads.Revision, events []ads.DomainEvent, query string, args ...any) (*ads.Ad, error) {
+	var ad ads.Ad
+
+	fields["update_time"] = time.Now().UTC()
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&ad).Clauses(clause.Returning{}).Where(query, args...).Updates(fields)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ads.ErrBadRequest
+		}
+		if revision != nil {
+			if err := appendRevision(tx, &ad, revision); err != nil {
+				return err
+			}
+		}
+		return adoutbox.Append(tx, events)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ad, nil
+}
+
+// Как и ads.Draft.Apply, меняет только заданные поля черновика
+func (r *postgresRepository) Update(id int64, draft ads.Draft, revision *ads.Revision, events ...ads.DomainEvent) (*ads.Ad, error) {
+	fields := map[string]any{"title": draft.Title, "text": draft.Text}
+	if draft.CategoryID != 0 {
+		fields["category_id"] = draft.CategoryID
+	}
+	if draft.Price != nil {
+		fields["price"] = *draft.Price
+	}
+	if draft.Currency != "" {
+		fields["currency"] = draft.Currency
+	}
+	if draft.Negotiable != nil {
+		fields["negotiable"] = *draft.Negotiable
+	}
+	if loc := draft.Location; loc != nil {
+		fields["latitude"], fields["longitude"], fields["city"] = loc.Latitude, loc.Longitude, loc.City
+	} else if draft.ClearLocation {
+		fields["latitude"], fields["longitude"], fields["city"] = nil, nil, ""
+	}
+	return r.updateWhere(fields, revision, events, "id = ?", id)
+}
+
+// Статус меняется, только если он всё ещё равен from: планировщик и автор не перезапишут друг друга
+func (r *postgresRepository) UpdateStatus(id int64, from, to ads.Status, expiresAt *time.Time, events ...ads.DomainEvent) (*ads.Ad, error) {
+	fields := map[string]any{"status": to, "published": to == ads.StatusPublished, "expires_at": expiresAt}
+	return r.updateWhere(fields, nil, events, "id = ? AND status = ?", id, from)
+}
+
+func (r *postgresRepository) GetAllAds() ([]*ads.Ad, error) {
+	var list []*ads.Ad
+
+	err := r.db.Where("published = ?", true).Order("id").Find(&list).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// Слова текста выделяются так же, как в ads.Tokens: нижний регистр, разделители - всё,
+// кроме букв и цифр. Выражение совпадает с индексом idx_ads_text_words.
+const postgresTextWords = "regexp_split_to_array(lower(text), '[^[:alnum:]]+')"
+
+var postgresDialect = sqlDialect{
+	titlePrefix: func(prefix string) (string, any) {
+		return `title LIKE ? ESCAPE '\'`, likeEscaper.Replace(prefix) + "%"
+	},
+	titleContains: func(substr string) (string, any) {
+		return "strpos(title, ?) > 0", substr
+	},
+	text: func(words []string) (string, any) {
+		return postgresTextWords + " @> ?::text[]", textArray(words)
+	},
+	// Порядок строк в PostgreSQL зависит от локали, COLLATE "C" сравнивает байты
+	titleOrder: `title COLLATE "C"`,
+	// Гаверсинус с тем же округлением, что и ads.Distance, чтобы курсор совпадал со строками
+	distance: func(origin ads.Point) string {
+		lat, lon := formatDegrees(origin.Latitude), formatDegrees(origin.Longitude)
+		h := fmt.Sprintf("power(sin(radians(latitude - %[1]s) / 2), 2) + cos(radians(%[1]s)) * cos(radians(latitude)) * power(sin(radians(longitude - %[2]s) / 2), 2)", lat, lon)
+		return fmt.Sprintf("round((2 * %s * asin(sqrt(least(%s, 1))))::numeric, 6)::float8", formatDegrees(ads.EarthRadiusKm), h)
+	},
+}
+
+// textArray передаётся в PostgreSQL литералом массива: срез GORM развернул бы в список параметров
+type textArray []string
+
+func (a textArray) Value() (driver.Value, error) {
+	quoted := make([]string, len(a))
+	for i, v := range a {
+		quoted[i] = `"` + arrayEscaper.Replace(v) + `"`
+	}
+	return "{" + strings.Join(quoted, ",") + "}", nil
+}
+
+func (r *postgresRepository) GetAds(filter ads.Filter, page ads.Page) ([]*ads.Ad, *ads.Cursor, error) {
+	var list []*ads.Ad
+
+	query := applyFilter(r.db.Model(&ads.Ad{}), filter, postgresDialect)
+	if err := applyPage(query, page, postgresDialect).Find(&list).Error; err != nil {
+		return nil, nil, err
+	}
+
+	list, next := nextPage(list, page)
+	return list, next, nil
+}
+
+func (r *postgresRepository) DeleteAd(id int64, events ...ads.DomainEvent) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ?", id).Delete(&ads.Ad{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ads.ErrBadRequest
+		}
+		if err := tx.Where("ad_id = ?", id).Delete(&ads.Revision{}).Error; err != nil {
+			return err
+		}
+		return adoutbox.Append(tx, events)
+	})
+}
+
+func NewPostgresAds(db *gorm.DB) app.Repository {
+	err := db.AutoMigrate(&ads.Ad{})
+	if err != nil {
+		panic(err)
+	}
+
+	for _, index := range postgresAdsIndexes {
+		if err := db.Exec(index).Error; err != nil {
+			panic(err)
+		}
+	}
+
+	migratePrices(db)
+	migrateStatuses(db)
+
+	return &postgresRepository{
+		gormCategories: newGormCategories(db),
+		gormImages:     newGormImages(db),
+		gormReviews:    newGormReviews(db),
+		gormRevisions:  newGormRevisions(db),
+		Gorm:           adoutbox.NewGorm(db),
+		db:             db,
+	}
+}
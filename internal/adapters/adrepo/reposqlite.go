@@ -1,8 +1,10 @@
 package adrepo
 
 import (
+	"adflow/internal/adapters/adoutbox"
 	"adflow/internal/ads"
 	"adflow/internal/app"
+	"strings"
 	"sync"
 	"time"
 
@@ -10,20 +12,37 @@ import (
 )
 
 type sqliteRepository struct {
+	gormCategories
+	gormImages
+	gormReviews
+	gormRevisions
+	adoutbox.Gorm
 	db  *gorm.DB
 	cnt int64
 	m   sync.Mutex
 }
 
-func (r *sqliteRepository) Create(ad *ads.Ad) error {
+func (r *sqliteRepository) Create(ad *ads.Ad, events ...ads.DomainEvent) error {
 	r.m.Lock()
 	defer r.m.Unlock()
 
 	ad.ID = r.cnt + 1
 	ad.CreationTime = time.Now().UTC()
 	ad.UpdateTime = time.Now().UTC()
+	ad.Status = ad.AdStatus()
 
-	r.db.Create(ad)
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(ad).Error; err != nil {
+			return err
+		}
+		if err := appendRevision(tx, ad, &ads.Revision{AuthorID: ad.AuthorID}); err != nil {
+			return err
+		}
+		return adoutbox.Append(tx, events)
+	})
+	if err != nil {
+		return err
+	}
 	r.cnt += 1
 
 	return nil
@@ -37,14 +56,29 @@ func (r *sqliteRepository) Get(id int64) (*ads.Ad, error) {
 
 	r.db.Where("ID = ?", id).Find(&ad)
 
-	if ad == (ads.Ad{}) {
+	if ad.ID == 0 {
 		return nil, ads.ErrBadRequest
 	}
 
 	return &ad, nil
 }
 
-func (r *sqliteRepository) Update(id int64, title, text string) (*ads.Ad, error) {
+func (r *sqliteRepository) GetMany(ids []int64) ([]*ads.Ad, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	list := []*ads.Ad{}
+	if len(ids) == 0 {
+		return list, nil
+	}
+	if err := r.db.Where("id IN ?", ids).Order("id").Find(&list).Error; err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func (r *sqliteRepository) Update(id int64, draft ads.Draft, revision *ads.Revision, events ...ads.DomainEvent) (*ads.Ad, error) {
 	r.m.Lock()
 	defer r.m.Unlock()
 
@@ -52,19 +86,35 @@ func (r *sqliteRepository) Update(id int64, title, text string) (*ads.Ad, error)
 
 	r.db.Where("ID = ?", id).Find(&ad)
 
-	if ad == (ads.Ad{}) {
+	if ad.ID == 0 {
 		return nil, ads.ErrBadRequest
 	}
 
-	ad.Title = title
-	ad.Text = text
+	draft.Apply(&ad)
 	ad.UpdateTime = time.Now().UTC()
 
-	r.db.Save(&ad)
+	if err := r.save(&ad, revision, events); err != nil {
+		return nil, err
+	}
 	return &ad, nil
 }
 
-func (r *sqliteRepository) UpdateStatus(id int64, published bool) (*ads.Ad, error) {
+// save сохраняет объявление, ревизию (если она задана) и события одной транзакцией
+func (r *sqliteRepository) save(ad *ads.Ad, revision *ads.Revision, events []ads.DomainEvent) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(ad).Error; err != nil {
+			return err
+		}
+		if revision != nil {
+			if err := appendRevision(tx, ad, revision); err != nil {
+				return err
+			}
+		}
+		return adoutbox.Append(tx, events)
+	})
+}
+
+func (r *sqliteRepository) UpdateStatus(id int64, from, to ads.Status, expiresAt *time.Time, events ...ads.DomainEvent) (*ads.Ad, error) {
 	r.m.Lock()
 	defer r.m.Unlock()
 
@@ -72,14 +122,18 @@ func (r *sqliteRepository) UpdateStatus(id int64, published bool) (*ads.Ad, erro
 
 	r.db.Where("ID = ?", id).Find(&ad)
 
-	if ad == (ads.Ad{}) {
+	if ad.ID == 0 || ad.AdStatus() != from {
 		return nil, ads.ErrBadRequest
 	}
 
-	ad.Published = published
+	ad.Status = to
+	ad.Published = to == ads.StatusPublished
+	ad.ExpiresAt = expiresAt
 	ad.UpdateTime = time.Now().UTC()
 
-	r.db.Save(&ad)
+	if err := r.save(&ad, nil, events); err != nil {
+		return nil, err
+	}
 	return &ad, nil
 }
 
@@ -96,43 +150,50 @@ func (r *sqliteRepository) GetAllAds() ([]*ads.Ad, error) {
 		return nil, result.Error
 	}
 
-	for _, v := range current_ads {
-		if v.Published {
-			ads = append(ads, &v)
+	for i := range current_ads {
+		if current_ads[i].Published {
+			ads = append(ads, &current_ads[i])
 		}
 	}
 
 	return ads, nil
 }
 
-func (r *sqliteRepository) GetAds(filter ads.Filter) ([]*ads.Ad, error) {
+// LIKE в SQLite не различает регистр, поэтому заголовок сравнивается через instr.
+// Полнотекстовое условие проверяет функция adflow_match, расстояние - adflow_distance (см. adapters.NewSQLite).
+var sqliteDialect = sqlDialect{
+	titlePrefix: func(prefix string) (string, any) {
+		return "instr(title, ?) = 1", prefix
+	},
+	titleContains: func(substr string) (string, any) {
+		return "instr(title, ?) > 0", substr
+	},
+	text: func(words []string) (string, any) {
+		return "adflow_match(text, ?)", strings.Join(words, " ")
+	},
+	titleOrder: "title",
+	distance: func(origin ads.Point) string {
+		return "adflow_distance(latitude, longitude, " + formatDegrees(origin.Latitude) + ", " + formatDegrees(origin.Longitude) + ")"
+	},
+}
+
+// Фильтр целиком превращается в один запрос WHERE по индексам
+func (r *sqliteRepository) GetAds(filter ads.Filter, page ads.Page) ([]*ads.Ad, *ads.Cursor, error) {
 	r.m.Lock()
 	defer r.m.Unlock()
 
-	var current_ads []ads.Ad
-	var ads_inds []int64
-
-	result := r.db.Find(&current_ads)
-
-	if result.Error != nil {
-		return nil, result.Error
-	}
-
-	for _, v := range current_ads {
-		if CheckFilter(&v, filter) {
-			ads_inds = append(ads_inds, v.ID)
-		}
-	}
+	var list []*ads.Ad
 
-	var ads = make([]*ads.Ad, len(ads_inds))
-	for i := range ads_inds {
-		r.db.Where("ID = ?", ads_inds[i]).Find(&ads[i])
+	query := applyFilter(r.db.Model(&ads.Ad{}), filter, sqliteDialect)
+	if err := applyPage(query, page, sqliteDialect).Find(&list).Error; err != nil {
+		return nil, nil, err
 	}
 
-	return ads, nil
+	list, next := nextPage(list, page)
+	return list, next, nil
 }
 
-func (r *sqliteRepository) DeleteAd(id int64) error {
+func (r *sqliteRepository) DeleteAd(id int64, events ...ads.DomainEvent) error {
 	r.m.Lock()
 	defer r.m.Unlock()
 
@@ -144,19 +205,52 @@ func (r *sqliteRepository) DeleteAd(id int64) error {
 		return ads.ErrBadRequest
 	}
 
-	r.db.Delete(&ad)
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&ad).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("ad_id = ?", ad.ID).Delete(&ads.Revision{}).Error; err != nil {
+			return err
+		}
+		return adoutbox.Append(tx, events)
+	})
+}
 
-	return nil
+var sqliteAdsIndexes = []string{
+	"CREATE INDEX IF NOT EXISTS idx_ads_published_creation_time ON ads (published, creation_time)",
+	"CREATE INDEX IF NOT EXISTS idx_ads_author_id ON ads (author_id)",
+	"CREATE INDEX IF NOT EXISTS idx_ads_category_id ON ads (category_id)",
+	"CREATE INDEX IF NOT EXISTS idx_ads_creation_time ON ads (creation_time)",
+	"CREATE INDEX IF NOT EXISTS idx_ads_update_time ON ads (update_time)",
+	"CREATE INDEX IF NOT EXISTS idx_ads_title ON ads (title)",
+	"CREATE INDEX IF NOT EXISTS idx_ads_currency_price ON ads (currency, price)",
+	"CREATE INDEX IF NOT EXISTS idx_ads_location ON ads (latitude, longitude)",
+	"CREATE INDEX IF NOT EXISTS idx_ads_status_expires_at ON ads (status, expires_at)",
 }
 
+// NewSQLiteAds ожидает базу, открытую через adapters.NewSQLite: там регистрируется adflow_match
 func NewSQLiteAds(db *gorm.DB) app.Repository {
 	err := db.AutoMigrate(&ads.Ad{})
 	if err != nil {
 		panic(err)
 	}
 
+	for _, index := range sqliteAdsIndexes {
+		if err := db.Exec(index).Error; err != nil {
+			panic(err)
+		}
+	}
+
+	migratePrices(db)
+	migrateStatuses(db)
+
 	return &sqliteRepository{
-		db:  db,
-		cnt: 0,
+		gormCategories: newGormCategories(db),
+		gormImages:     newGormImages(db),
+		gormReviews:    newGormReviews(db),
+		gormRevisions:  newGormRevisions(db),
+		Gorm:           adoutbox.NewGorm(db),
+		db:             db,
+		cnt:            0,
 	}
 }
@@ -0,0 +1,71 @@
+package adrepo
+
+import (
+	"adflow/internal/ads"
+	"sort"
+	"time"
+)
+
+func (r *localRepository) CreateReview(review *ads.Review) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.reviewCnt += 1
+	review.ID = r.reviewCnt
+
+	rv := *review
+	r.reviews[rv.ID] = &rv
+
+	return nil
+}
+
+func (r *localRepository) ListReviews(adID int64) ([]*ads.Review, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	var list []*ads.Review
+	for _, rv := range r.reviews {
+		if rv.AdID == adID {
+			review := *rv
+			list = append(list, &review)
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].ID > list[j].ID })
+	return list, nil
+}
+
+func (r *localRepository) ListPendingReviews(afterID int64, limit int) ([]*ads.Review, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	var list []*ads.Review
+	for _, rv := range r.reviews {
+		if rv.Decision == ads.DecisionPending && rv.ID > afterID {
+			review := *rv
+			list = append(list, &review)
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	if limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	return list, nil
+}
+
+func (r *localRepository) DecideReview(id int64, decision ads.Decision, reason string, moderatorID int64) (*ads.Review, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	rv, ok := r.reviews[id]
+	if !ok || rv.Decision != ads.DecisionPending {
+		return nil, ads.ErrBadRequest
+	}
+
+	now := time.Now().UTC()
+	rv.Decision, rv.Reason, rv.ModeratorID, rv.DecisionTime = decision, reason, moderatorID, &now
+
+	review := *rv
+	return &review, nil
+}
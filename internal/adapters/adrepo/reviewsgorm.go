@@ -0,0 +1,66 @@
+package adrepo
+
+import (
+	"adflow/internal/ads"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Заявки на проверку в SQL хранятся одинаково для SQLite и PostgreSQL
+type gormReviews struct {
+	db *gorm.DB
+}
+
+func newGormReviews(db *gorm.DB) gormReviews {
+	if err := db.AutoMigrate(&ads.Review{}); err != nil {
+		panic(err)
+	}
+	return gormReviews{db: db}
+}
+
+func (r gormReviews) CreateReview(review *ads.Review) error {
+	review.ID = 0
+	return r.db.Create(review).Error
+}
+
+func (r gormReviews) ListReviews(adID int64) ([]*ads.Review, error) {
+	var list []*ads.Review
+
+	if err := r.db.Where("ad_id = ?", adID).Order("id DESC").Find(&list).Error; err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func (r gormReviews) ListPendingReviews(afterID int64, limit int) ([]*ads.Review, error) {
+	var list []*ads.Review
+
+	err := r.db.Where("decision = ? AND id > ?", ads.DecisionPending, afterID).Order("id").Limit(limit).Find(&list).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func (r gormReviews) DecideReview(id int64, decision ads.Decision, reason string, moderatorID int64) (*ads.Review, error) {
+	now := time.Now().UTC()
+	fields := map[string]any{"decision": decision, "reason": reason, "moderator_id": moderatorID, "decision_time": now}
+
+	// Условие на decision не даёт двум модераторам решить одну заявку
+	result := r.db.Model(&ads.Review{}).Where("id = ? AND decision = ?", id, ads.DecisionPending).Updates(fields)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ads.ErrBadRequest
+	}
+
+	var review ads.Review
+	if err := r.db.Where("id = ?", id).First(&review).Error; err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
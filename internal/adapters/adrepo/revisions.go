@@ -0,0 +1,46 @@
+package adrepo
+
+import "adflow/internal/ads"
+
+// addRevision дописывает снимок объявления в его историю; вызывается под r.m
+func (r *localRepository) addRevision(ad *ads.Ad, revision *ads.Revision) {
+	r.revisionCnt += 1
+	revision.ID = r.revisionCnt
+	revision.Number = int64(len(r.revisions[ad.ID])) + 1
+	revision.CreationTime = ad.UpdateTime
+	revision.Snapshot(ad)
+
+	rev := *revision
+	r.revisions[ad.ID] = append(r.revisions[ad.ID], &rev)
+}
+
+func (r *localRepository) ListRevisions(adID, before int64, limit int) ([]*ads.Revision, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	history := r.revisions[adID]
+	end := int64(len(history))
+	if before > 0 && before-1 < end {
+		end = before - 1
+	}
+
+	var list []*ads.Revision
+	for i := end - 1; i >= 0 && (limit <= 0 || len(list) < limit); i-- {
+		rev := *history[i]
+		list = append(list, &rev)
+	}
+	return list, nil
+}
+
+func (r *localRepository) GetRevision(adID, number int64) (*ads.Revision, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	history := r.revisions[adID]
+	if number < 1 || number > int64(len(history)) {
+		return nil, ads.ErrBadRequest
+	}
+
+	rev := *history[number-1]
+	return &rev, nil
+}
@@ -0,0 +1,77 @@
+package adrepo
+
+import (
+	"adflow/internal/ads"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// История правок в SQL хранится одинаково для SQLite и PostgreSQL
+type gormRevisions struct {
+	db *gorm.DB
+}
+
+func newGormRevisions(db *gorm.DB) gormRevisions {
+	if err := db.AutoMigrate(&ads.Revision{}); err != nil {
+		panic(err)
+	}
+	migrateRevisions(db)
+	return gormRevisions{db: db}
+}
+
+// Объявления, созданные до появления истории, получают ревизию 1 с текущими полями
+func migrateRevisions(db *gorm.DB) {
+	err := db.Exec(`INSERT INTO revisions (ad_id, number, author_id, restored_from, creation_time,
+		title, text, category_id, price, currency, negotiable, latitude, longitude, city)
+		SELECT id, 1, author_id, 0, update_time, title, text, category_id, price, currency, negotiable, latitude, longitude, city
+		FROM ads WHERE NOT EXISTS (SELECT 1 FROM revisions WHERE revisions.ad_id = ads.id)`).Error
+	if err != nil {
+		panic(err)
+	}
+}
+
+// appendRevision записывает снимок объявления следующим номером в транзакции изменения.
+// В PostgreSQL строку объявления уже заблокировал UPDATE, поэтому номера не совпадут.
+func appendRevision(tx *gorm.DB, ad *ads.Ad, revision *ads.Revision) error {
+	var last int64
+	if err := tx.Model(&ads.Revision{}).Where("ad_id = ?", ad.ID).Select("COALESCE(MAX(number), 0)").Scan(&last).Error; err != nil {
+		return err
+	}
+
+	revision.ID = 0
+	revision.Number = last + 1
+	revision.CreationTime = ad.UpdateTime
+	revision.Snapshot(ad)
+	return tx.Create(revision).Error
+}
+
+func (r gormRevisions) ListRevisions(adID, before int64, limit int) ([]*ads.Revision, error) {
+	var list []*ads.Revision
+
+	query := r.db.Where("ad_id = ?", adID)
+	if before > 0 {
+		query = query.Where("number < ?", before)
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	if err := query.Order("number DESC").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r gormRevisions) GetRevision(adID, number int64) (*ads.Revision, error) {
+	var rev ads.Revision
+
+	err := r.db.Where("ad_id = ? AND number = ?", adID, number).First(&rev).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ads.ErrBadRequest
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
@@ -0,0 +1,192 @@
+package adsaved
+
+import (
+	"adflow/internal/ads"
+	"adflow/internal/app"
+	"sort"
+	"sync"
+	"time"
+)
+
+type localSearches struct {
+	searches        map[int64]*ads.SavedSearch
+	notifications   map[int64]*ads.Notification
+	searchCnt       int64
+	notificationCnt int64
+	m               sync.Mutex
+}
+
+func (r *localSearches) CreateSearch(search *ads.SavedSearch) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.searchCnt += 1
+	search.ID = r.searchCnt
+
+	stored := *search
+	r.searches[stored.ID] = &stored
+	return nil
+}
+
+func (r *localSearches) GetSearch(id int64) (*ads.SavedSearch, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	search, ok := r.searches[id]
+	if !ok {
+		return nil, ads.ErrBadRequest
+	}
+
+	result := *search
+	return &result, nil
+}
+
+func (r *localSearches) list(match func(s *ads.SavedSearch) bool) []*ads.SavedSearch {
+	var list []*ads.SavedSearch
+	for _, s := range r.searches {
+		if match(s) {
+			search := *s
+			list = append(list, &search)
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+func (r *localSearches) ListSearches(userID int64) ([]*ads.SavedSearch, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	return r.list(func(s *ads.SavedSearch) bool { return s.UserID == userID }), nil
+}
+
+func (r *localSearches) ScanSearches(afterID int64, limit int) ([]*ads.SavedSearch, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	list := r.list(func(s *ads.SavedSearch) bool { return s.ID > afterID })
+	if limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	return list, nil
+}
+
+func (r *localSearches) UpdateSearch(id int64, name string, filter ads.Filter) (*ads.SavedSearch, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	search, ok := r.searches[id]
+	if !ok {
+		return nil, ads.ErrBadRequest
+	}
+	search.Name, search.Filter = name, filter
+
+	result := *search
+	return &result, nil
+}
+
+func (r *localSearches) DeleteSearch(id int64) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if _, ok := r.searches[id]; !ok {
+		return ads.ErrBadRequest
+	}
+	delete(r.searches, id)
+	return nil
+}
+
+func (r *localSearches) RemoveUserSearches(userID int64) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	for id, s := range r.searches {
+		if s.UserID == userID {
+			delete(r.searches, id)
+		}
+	}
+	for id, n := range r.notifications {
+		if n.UserID == userID {
+			delete(r.notifications, id)
+		}
+	}
+	return nil
+}
+
+func (r *localSearches) CreateNotification(notification *ads.Notification) (bool, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	for _, n := range r.notifications {
+		if n.SearchID == notification.SearchID && n.AdID == notification.AdID {
+			return false, nil
+		}
+	}
+
+	r.notificationCnt += 1
+	notification.ID = r.notificationCnt
+
+	stored := *notification
+	stored.Search, stored.Ad = nil, nil
+	r.notifications[stored.ID] = &stored
+	return true, nil
+}
+
+func (r *localSearches) DueNotifications(now time.Time, afterID int64, limit int) ([]*ads.Notification, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	var list []*ads.Notification
+	for _, n := range r.notifications {
+		if n.ID > afterID && n.NextAttemptTime != nil && !n.NextAttemptTime.After(now) {
+			notification := *n
+			list = append(list, &notification)
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	if limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	return list, nil
+}
+
+func (r *localSearches) SaveDelivery(notification *ads.Notification) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	n, ok := r.notifications[notification.ID]
+	if !ok {
+		return ads.ErrBadRequest
+	}
+	n.Attempts, n.NextAttemptTime = notification.Attempts, notification.NextAttemptTime
+	n.DeliveryTime, n.Error = notification.DeliveryTime, notification.Error
+	return nil
+}
+
+func (r *localSearches) ListNotifications(userID, beforeID int64, limit int) ([]*ads.Notification, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	var list []*ads.Notification
+	for _, n := range r.notifications {
+		if n.UserID == userID && (beforeID == 0 || n.ID < beforeID) {
+			notification := *n
+			list = append(list, &notification)
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].ID > list[j].ID })
+	if limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	return list, nil
+}
+
+func New() app.SavedSearches {
+	return &localSearches{
+		searches:      make(map[int64]*ads.SavedSearch),
+		notifications: make(map[int64]*ads.Notification),
+	}
+}
@@ -0,0 +1,180 @@
+package adsaved
+
+import (
+	"adflow/internal/ads"
+	"adflow/internal/app"
+	"errors"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormSearches struct {
+	db *gorm.DB
+	m  sync.Mutex
+}
+
+func (r *gormSearches) CreateSearch(search *ads.SavedSearch) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	search.ID = 0
+	return r.db.Create(search).Error
+}
+
+func (r *gormSearches) GetSearch(id int64) (*ads.SavedSearch, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	var search ads.SavedSearch
+	if err := r.db.Where("id = ?", id).First(&search).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ads.ErrBadRequest
+		}
+		return nil, err
+	}
+	return &search, nil
+}
+
+func (r *gormSearches) ListSearches(userID int64) ([]*ads.SavedSearch, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	var list []*ads.SavedSearch
+	if err := r.db.Where("user_id = ?", userID).Order("id").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *gormSearches) ScanSearches(afterID int64, limit int) ([]*ads.SavedSearch, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	var list []*ads.SavedSearch
+	if err := r.db.Where("id > ?", afterID).Order("id").Limit(limit).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *gormSearches) UpdateSearch(id int64, name string, filter ads.Filter) (*ads.SavedSearch, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	var search ads.SavedSearch
+	if err := r.db.Where("id = ?", id).First(&search).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ads.ErrBadRequest
+		}
+		return nil, err
+	}
+
+	search.Name, search.Filter = name, filter
+	if err := r.db.Select("name", "filter").Updates(&search).Error; err != nil {
+		return nil, err
+	}
+	return &search, nil
+}
+
+func (r *gormSearches) DeleteSearch(id int64) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	result := r.db.Where("id = ?", id).Delete(&ads.SavedSearch{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ads.ErrBadRequest
+	}
+	return nil
+}
+
+func (r *gormSearches) RemoveUserSearches(userID int64) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&ads.SavedSearch{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&ads.Notification{}).Error
+	})
+}
+
+func (r *gormSearches) CreateNotification(notification *ads.Notification) (bool, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	// Уникальный индекс по search_id и ad_id не даёт уведомить дважды и при гонке между процессами
+	notification.ID = 0
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(notification)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *gormSearches) DueNotifications(now time.Time, afterID int64, limit int) ([]*ads.Notification, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	query := r.db.Where("next_attempt_time <= ? AND id > ?", now, afterID)
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	var list []*ads.Notification
+	if err := query.Order("id").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *gormSearches) SaveDelivery(notification *ads.Notification) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	fields := []string{"attempts", "next_attempt_time", "delivery_time", "error"}
+	result := r.db.Model(notification).Select(fields).Updates(notification)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ads.ErrBadRequest
+	}
+	return nil
+}
+
+func (r *gormSearches) ListNotifications(userID, beforeID int64, limit int) ([]*ads.Notification, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	query := r.db.Where("user_id = ?", userID)
+	if beforeID > 0 {
+		query = query.Where("id < ?", beforeID)
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	var list []*ads.Notification
+	if err := query.Order("id DESC").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// NewGormSearches работает поверх любой базы GORM: SQLite или PostgreSQL
+func NewGormSearches(db *gorm.DB) app.SavedSearches {
+	if err := db.AutoMigrate(&ads.SavedSearch{}, &ads.Notification{}); err != nil {
+		panic(err)
+	}
+
+	return &gormSearches{
+		db: db,
+	}
+}
@@ -0,0 +1,68 @@
+package adsearch
+
+import (
+	"adflow/internal/ads"
+	"adflow/internal/app"
+	"adflow/internal/search"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Основы слов выделяются в Go и сохраняются в tsvector с конфигурацией simple, чтобы
+// совпадения были те же, что у остальных бэкендов. Вес A у заголовка, B у текста;
+// порядок задаёт ts_rank, поэтому значения релевантности отличаются от BM25.
+type postgresIndex struct {
+	db *gorm.DB
+}
+
+var postgresSearchSchema = []string{
+	"CREATE TABLE IF NOT EXISTS ad_search (ad_id bigint PRIMARY KEY, document tsvector NOT NULL)",
+	"CREATE INDEX IF NOT EXISTS idx_ad_search_document ON ad_search USING gin (document)",
+}
+
+func (idx *postgresIndex) Index(ad *ads.Ad) error {
+	return idx.db.Exec(`INSERT INTO ad_search (ad_id, document)
+		VALUES (?, setweight(to_tsvector('simple', ?), 'A') || setweight(to_tsvector('simple', ?), 'B'))
+		ON CONFLICT (ad_id) DO UPDATE SET document = EXCLUDED.document`,
+		ad.ID, strings.Join(search.Analyze(ad.Title), " "), strings.Join(search.Analyze(ad.Text), " ")).Error
+}
+
+func (idx *postgresIndex) Remove(id int64) error {
+	return idx.db.Exec("DELETE FROM ad_search WHERE ad_id = ?", id).Error
+}
+
+func (idx *postgresIndex) Search(query string, limit, offset int) ([]search.Hit, error) {
+	terms := search.Terms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	q := idx.db.Table("ad_search, plainto_tsquery('simple', ?) AS query", strings.Join(terms, " ")).
+		Select("ad_id AS id, ts_rank(document, query) AS score").
+		Where("document @@ query").
+		Order("score DESC").Order("ad_id").
+		Offset(offset)
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+
+	var hits []search.Hit
+	if err := q.Scan(&hits).Error; err != nil {
+		return nil, err
+	}
+
+	return hits, nil
+}
+
+func NewPostgresIndex(db *gorm.DB) app.SearchIndex {
+	for _, stmt := range postgresSearchSchema {
+		if err := db.Exec(stmt).Error; err != nil {
+			panic(err)
+		}
+	}
+
+	return &postgresIndex{
+		db: db,
+	}
+}
@@ -0,0 +1,100 @@
+package adsearch
+
+import (
+	"adflow/internal/ads"
+	"adflow/internal/app"
+	"adflow/internal/search"
+	"encoding/binary"
+	"strings"
+	"unsafe"
+
+	"gorm.io/gorm"
+)
+
+// matchinfo записывает числа в порядке байтов процессора
+var nativeEndian binary.ByteOrder = binary.LittleEndian
+
+func init() {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 0 {
+		nativeEndian = binary.BigEndian
+	}
+}
+
+// Индекс на FTS4: FTS5 в go-sqlite3 собирается только с тегом sqlite_fts5.
+// В таблицу пишутся уже выделенные основы слов, поэтому достаточно токенизатора simple,
+// а ранжирование считает функция adflow_bm25 (см. adapters.NewSQLite) по matchinfo.
+type sqliteIndex struct {
+	db *gorm.DB
+}
+
+const sqliteSearchTable = "CREATE VIRTUAL TABLE IF NOT EXISTS ad_search USING fts4(title, text, tokenize=simple)"
+
+func (idx *sqliteIndex) Index(ad *ads.Ad) error {
+	return idx.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM ad_search WHERE docid = ?", ad.ID).Error; err != nil {
+			return err
+		}
+		return tx.Exec("INSERT INTO ad_search (docid, title, text) VALUES (?, ?, ?)",
+			ad.ID, strings.Join(search.Analyze(ad.Title), " "), strings.Join(search.Analyze(ad.Text), " ")).Error
+	})
+}
+
+func (idx *sqliteIndex) Remove(id int64) error {
+	return idx.db.Exec("DELETE FROM ad_search WHERE docid = ?", id).Error
+}
+
+func (idx *sqliteIndex) Search(query string, limit, offset int) ([]search.Hit, error) {
+	terms := search.Terms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	if limit <= 0 {
+		limit = -1
+	}
+
+	var hits []search.Hit
+	err := idx.db.Raw(`SELECT docid AS id, adflow_bm25(matchinfo(ad_search, 'pcnalx')) AS score
+		FROM ad_search WHERE ad_search MATCH ?
+		ORDER BY score DESC, docid LIMIT ? OFFSET ?`, strings.Join(terms, " "), limit, offset).Scan(&hits).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return hits, nil
+}
+
+// MatchinfoBM25 считает релевантность по matchinfo(ad_search, 'pcnalx') так же,
+// как search.MemoryIndex: сумма BM25 по словам запроса и полям с весами search.FieldWeights.
+func MatchinfoBM25(info []byte) float64 {
+	values := make([]int, len(info)/4)
+	for i := range values {
+		values[i] = int(nativeEndian.Uint32(info[4*i:]))
+	}
+
+	phrases, columns, total := values[0], values[1], values[2]
+	avgLen := values[3 : 3+columns]
+	docLen := values[3+columns : 3+2*columns]
+	hits := values[3+2*columns:]
+
+	score := 0.0
+	for i := 0; i < phrases; i++ {
+		for j := 0; j < columns && j < len(search.FieldWeights); j++ {
+			x := hits[3*(j+i*columns):]
+			score += search.FieldWeights[j] * search.BM25(x[0], x[2], total, float64(docLen[j]), float64(avgLen[j]))
+		}
+	}
+	return score
+}
+
+// NewSQLiteIndex ожидает базу, открытую через adapters.NewSQLite
+func NewSQLiteIndex(db *gorm.DB) app.SearchIndex {
+	if err := db.Exec(sqliteSearchTable).Error; err != nil {
+		panic(err)
+	}
+
+	return &sqliteIndex{
+		db: db,
+	}
+}
@@ -0,0 +1,98 @@
+package adtoken
+
+import (
+	"adflow/internal/ads"
+	"adflow/internal/app"
+	auth "adflow/internal/app/auth"
+	"sync"
+	"time"
+)
+
+type localTokens struct {
+	refresh map[string]*auth.RefreshToken
+	revoked map[string]time.Time
+	m       sync.Mutex
+}
+
+func (r *localTokens) CreateRefresh(token *auth.RefreshToken) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if _, ok := r.refresh[token.ID]; ok {
+		return ads.ErrBadRequest
+	}
+
+	stored := *token
+	r.refresh[token.ID] = &stored
+	return nil
+}
+
+func (r *localTokens) GetRefresh(id string) (*auth.RefreshToken, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	token, ok := r.refresh[id]
+	if !ok {
+		return nil, ads.ErrBadRequest
+	}
+
+	result := *token
+	return &result, nil
+}
+
+func (r *localTokens) UseRefresh(id string) (*auth.RefreshToken, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	token, ok := r.refresh[id]
+	if !ok {
+		return nil, ads.ErrBadRequest
+	}
+
+	prev := *token
+	token.Used = true
+	return &prev, nil
+}
+
+func (r *localTokens) RevokeFamily(family string) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	for _, token := range r.refresh {
+		if token.Family == family {
+			token.Revoked = true
+		}
+	}
+	return nil
+}
+
+func (r *localTokens) RevokeAccess(token *auth.RevokedToken) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	// Истёкшие токены и так не пройдут проверку, хранить их незачем
+	now := time.Now()
+	for id, expiresAt := range r.revoked {
+		if now.After(expiresAt) {
+			delete(r.revoked, id)
+		}
+	}
+
+	r.revoked[token.ID] = token.ExpiresAt
+	return nil
+}
+
+func (r *localTokens) IsAccessRevoked(id string) (bool, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	_, ok := r.revoked[id]
+	return ok, nil
+}
+
+func New() app.Tokens {
+	return &localTokens{
+		refresh: make(map[string]*auth.RefreshToken),
+		revoked: make(map[string]time.Time),
+	}
+}
@@ -0,0 +1,116 @@
+package adtoken
+
+import (
+	"adflow/internal/ads"
+	"adflow/internal/app"
+	auth "adflow/internal/app/auth"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type gormTokens struct {
+	db *gorm.DB
+	m  sync.Mutex
+}
+
+func (r *gormTokens) CreateRefresh(token *auth.RefreshToken) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	return r.db.Create(token).Error
+}
+
+func (r *gormTokens) GetRefresh(id string) (*auth.RefreshToken, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	var token auth.RefreshToken
+
+	if err := r.db.Where("ID = ?", id).First(&token).Error; err != nil {
+		return nil, ads.ErrBadRequest
+	}
+
+	return &token, nil
+}
+
+func (r *gormTokens) UseRefresh(id string) (*auth.RefreshToken, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	var token auth.RefreshToken
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("ID = ?", id).First(&token).Error; err != nil {
+			return ads.ErrBadRequest
+		}
+
+		// Условие на Used делает пометку атомарной и между процессами:
+		// использовать токен сможет только один из конкурирующих запросов
+		result := tx.Model(&auth.RefreshToken{}).Where("ID = ? AND Used = ?", id, false).Update("Used", true)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			token.Used = true
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+func (r *gormTokens) RevokeFamily(family string) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	return r.db.Model(&auth.RefreshToken{}).Where("Family = ?", family).Update("Revoked", true).Error
+}
+
+func (r *gormTokens) RevokeAccess(token *auth.RevokedToken) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	// Истёкшие токены и так не пройдут проверку, хранить их незачем
+	if err := r.db.Where("expires_at < ?", time.Now()).Delete(&auth.RevokedToken{}).Error; err != nil {
+		return err
+	}
+
+	return r.db.Save(token).Error
+}
+
+func (r *gormTokens) IsAccessRevoked(id string) (bool, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	var count int64
+
+	if err := r.db.Model(&auth.RevokedToken{}).Where("ID = ?", id).Count(&count).Error; err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// NewGormTokens работает поверх любой базы GORM: SQLite или PostgreSQL
+func NewGormTokens(db *gorm.DB) app.Tokens {
+	err := db.AutoMigrate(&auth.RefreshToken{}, &auth.RevokedToken{})
+	if err != nil {
+		panic(err)
+	}
+
+	// Отзыв цепочки ищет токены по family
+	err = db.Exec("CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family ON refresh_tokens (family)").Error
+	if err != nil {
+		panic(err)
+	}
+
+	return &gormTokens{
+		db: db,
+	}
+}
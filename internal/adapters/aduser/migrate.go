@@ -0,0 +1,44 @@
+package aduser
+
+import (
+	"adflow/internal/ads"
+	"adflow/internal/app/auth"
+
+	"gorm.io/gorm"
+)
+
+// MigratePasswords заменяет пароли, хранящиеся в открытом виде, их хешами.
+// Уже захешированные строки не трогает, поэтому повторный запуск безопасен.
+// Возвращает число обновлённых пользователей.
+func MigratePasswords(db *gorm.DB, hasher auth.PasswordHasher) (int, error) {
+	var users []ads.User
+
+	if err := db.Find(&users).Error; err != nil {
+		return 0, err
+	}
+
+	migrated := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, user := range users {
+			if auth.IsHashed(user.Password) {
+				continue
+			}
+
+			hash, err := hasher.Hash(user.Password)
+			if err != nil {
+				return err
+			}
+
+			if err := tx.Model(&ads.User{}).Where("ID = ?", user.ID).Update("Password", hash).Error; err != nil {
+				return err
+			}
+			migrated++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return migrated, nil
+}
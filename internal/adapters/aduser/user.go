@@ -1,6 +1,7 @@
 package aduser
 
 import (
+	"adflow/internal/adapters/adoutbox"
 	"adflow/internal/ads"
 	"adflow/internal/app"
 	"errors"
@@ -10,10 +11,11 @@ import (
 type localUsers struct {
 	users map[int64]*ads.User
 	cnt   int64
-	m     sync.Mutex
+	adoutbox.Memory
+	m sync.Mutex
 }
 
-func (r *localUsers) Create(ad *ads.User) error {
+func (r *localUsers) Create(ad *ads.User, events ...ads.DomainEvent) error {
 	r.m.Lock()
 	defer r.m.Unlock()
 
@@ -23,35 +25,38 @@ func (r *localUsers) Create(ad *ads.User) error {
 		}
 	}
 
-	ad.ID = r.cnt
-	r.users[r.cnt] = ad
+	// Нумерация с 1, как у SQL-бэкендов
 	r.cnt += 1
-	return nil
+	ad.ID = r.cnt
+	stored := *ad
+	r.users[r.cnt] = &stored
+	return r.Append(events)
 }
 
 func (r *localUsers) Get(id int64) (*ads.User, error) {
 	r.m.Lock()
 	defer r.m.Unlock()
 
-	_, ok := r.users[id]
+	user, ok := r.users[id]
 	if !ok {
 		return nil, ads.ErrBadRequest
 	}
-	return r.users[id], nil
+	return copyUser(user), nil
 }
 
 func (r *localUsers) GetByNickname(nickname string) (*ads.User, error) {
 	r.m.Lock()
 	defer r.m.Unlock()
 
-	// _, ok := r.users[id]
-	// if !ok {
-	// 	return nil, ads.ErrBadRequest
-	// }
-	return nil, errors.New("unimplemented")
+	for _, user := range r.users {
+		if user.Nickname == nickname {
+			return copyUser(user), nil
+		}
+	}
+	return nil, ads.ErrBadRequest
 }
 
-func (r *localUsers) Update(UserID int64, first_name, second_name, phone, email string) (*ads.User, error) {
+func (r *localUsers) Update(UserID int64, first_name, second_name, email, phone string, events ...ads.DomainEvent) (*ads.User, error) {
 	r.m.Lock()
 	defer r.m.Unlock()
 
@@ -63,10 +68,34 @@ func (r *localUsers) Update(UserID int64, first_name, second_name, phone, email
 	r.users[UserID].SecondName = second_name
 	r.users[UserID].Phone = phone
 	r.users[UserID].Email = email
-	return r.users[UserID], nil
+	return copyUser(r.users[UserID]), r.Append(events)
 }
 
-func (r *localUsers) Delete(id int64) error {
+func (r *localUsers) UpdatePassword(UserID int64, password string) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if _, ok := r.users[UserID]; !ok {
+		return ads.ErrBadRequest
+	}
+
+	r.users[UserID].Password = password
+	return nil
+}
+
+func (r *localUsers) UpdateRole(UserID int64, role ads.Role, events ...ads.DomainEvent) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if _, ok := r.users[UserID]; !ok {
+		return ads.ErrBadRequest
+	}
+
+	r.users[UserID].Role = role
+	return r.Append(events)
+}
+
+func (r *localUsers) Delete(id int64, events ...ads.DomainEvent) error {
 	r.m.Lock()
 	defer r.m.Unlock()
 
@@ -76,12 +105,19 @@ func (r *localUsers) Delete(id int64) error {
 
 	delete(r.users, id)
 
-	return nil
+	return r.Append(events)
+}
+
+// Наружу отдаются копии, чтобы изменения вызывающего не попадали в хранилище мимо методов
+func copyUser(user *ads.User) *ads.User {
+	c := *user
+	return &c
 }
 
 func New() app.Users {
 	return &localUsers{
-		users: make(map[int64]*ads.User),
-		cnt:   0,
+		users:  make(map[int64]*ads.User),
+		cnt:    0,
+		Memory: adoutbox.NewMemory(),
 	}
 }
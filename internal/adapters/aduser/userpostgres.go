@@ -0,0 +1,125 @@
+package aduser
+
+import (
+	"adflow/internal/adapters/adoutbox"
+	"adflow/internal/ads"
+	"adflow/internal/app"
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Идентификаторы выдаёт база, уникальность никнейма держит уникальный индекс
+type postgresUsers struct {
+	adoutbox.Gorm
+	db *gorm.DB
+}
+
+func (r *postgresUsers) Create(user *ads.User, events ...ads.DomainEvent) error {
+	user.ID = 0
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return adoutbox.Append(tx, events)
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ads.ErrBadRequest
+	}
+
+	return err
+}
+
+func (r *postgresUsers) find(query string, arg any) (*ads.User, error) {
+	var user ads.User
+
+	err := r.db.Where(query, arg).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ads.ErrBadRequest
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (r *postgresUsers) Get(id int64) (*ads.User, error) {
+	return r.find("id = ?", id)
+}
+
+func (r *postgresUsers) GetByNickname(nickname string) (*ads.User, error) {
+	return r.find("nickname = ?", nickname)
+}
+
+// Обновляет поля одним запросом, возвращает запись целиком через RETURNING и в той же транзакции пишет события
+func (r *postgresUsers) update(id int64, fields map[string]any, events []ads.DomainEvent) (*ads.User, error) {
+	var user ads.User
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&user).Clauses(clause.Returning{}).Where("id = ?", id).Updates(fields)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ads.ErrBadRequest
+		}
+		return adoutbox.Append(tx, events)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (r *postgresUsers) Update(UserID int64, first_name, second_name, email, phone string, events ...ads.DomainEvent) (*ads.User, error) {
+	return r.update(UserID, map[string]any{
+		"first_name":  first_name,
+		"second_name": second_name,
+		"email":       email,
+		"phone":       phone,
+	}, events)
+}
+
+func (r *postgresUsers) UpdatePassword(UserID int64, password string) error {
+	_, err := r.update(UserID, map[string]any{"password": password}, nil)
+	return err
+}
+
+func (r *postgresUsers) UpdateRole(UserID int64, role ads.Role, events ...ads.DomainEvent) error {
+	_, err := r.update(UserID, map[string]any{"role": role}, events)
+	return err
+}
+
+func (r *postgresUsers) Delete(id int64, events ...ads.DomainEvent) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ?", id).Delete(&ads.User{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ads.ErrBadRequest
+		}
+		return adoutbox.Append(tx, events)
+	})
+}
+
+func NewPostgresUsers(db *gorm.DB) app.Users {
+	err := db.AutoMigrate(&ads.User{})
+	if err != nil {
+		panic(err)
+	}
+
+	err = db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_users_nickname ON users (nickname)").Error
+	if err != nil {
+		panic(err)
+	}
+
+	return &postgresUsers{
+		Gorm: adoutbox.NewGorm(db),
+		db:   db,
+	}
+}
@@ -1,6 +1,7 @@
 package aduser
 
 import (
+	"adflow/internal/adapters/adoutbox"
 	"adflow/internal/ads"
 	"adflow/internal/app"
 	"sync"
@@ -9,12 +10,13 @@ import (
 )
 
 type sqliteUsers struct {
+	adoutbox.Gorm
 	db  *gorm.DB
 	cnt int64
 	m   sync.Mutex
 }
 
-func (r *sqliteUsers) Create(user *ads.User) error {
+func (r *sqliteUsers) Create(user *ads.User, events ...ads.DomainEvent) error {
 	r.m.Lock()
 	defer r.m.Unlock()
 
@@ -22,14 +24,22 @@ func (r *sqliteUsers) Create(user *ads.User) error {
 
 	r.db.Where("Nickname = ?", user.Nickname).Find(&temp)
 
-	if temp == (ads.User{}) {
-		user.ID = r.cnt + 1
-		r.db.Create(user)
-		r.cnt += 1
-	} else {
+	if temp != (ads.User{}) {
 		return ads.ErrBadRequest
 	}
 
+	user.ID = r.cnt + 1
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return adoutbox.Append(tx, events)
+	})
+	if err != nil {
+		return err
+	}
+	r.cnt += 1
+
 	return nil
 }
 
@@ -63,7 +73,7 @@ func (r *sqliteUsers) GetByNickname(nickname string) (*ads.User, error) {
 	return &user, nil
 }
 
-func (r *sqliteUsers) Update(UserID int64, first_name, second_name, phone, email string) (*ads.User, error) {
+func (r *sqliteUsers) Update(UserID int64, first_name, second_name, email, phone string, events ...ads.DomainEvent) (*ads.User, error) {
 	r.m.Lock()
 	defer r.m.Unlock()
 
@@ -80,12 +90,54 @@ func (r *sqliteUsers) Update(UserID int64, first_name, second_name, phone, email
 	user.Phone = phone
 	user.Email = email
 
-	r.db.Save(&user)
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		return adoutbox.Append(tx, events)
+	})
+	if err != nil {
+		return nil, err
+	}
 
 	return &user, nil
 }
 
-func (r *sqliteUsers) Delete(id int64) error {
+func (r *sqliteUsers) UpdatePassword(UserID int64, password string) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	result := r.db.Model(&ads.User{}).Where("ID = ?", UserID).Update("Password", password)
+
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ads.ErrBadRequest
+	}
+
+	return nil
+}
+
+func (r *sqliteUsers) UpdateRole(UserID int64, role ads.Role, events ...ads.DomainEvent) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&ads.User{}).Where("ID = ?", UserID).Update("Role", role)
+
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ads.ErrBadRequest
+		}
+
+		return adoutbox.Append(tx, events)
+	})
+}
+
+func (r *sqliteUsers) Delete(id int64, events ...ads.DomainEvent) error {
 	r.m.Lock()
 	defer r.m.Unlock()
 
@@ -97,9 +149,12 @@ func (r *sqliteUsers) Delete(id int64) error {
 		return ads.ErrBadRequest
 	}
 
-	r.db.Delete(&user)
-
-	return nil
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&user).Error; err != nil {
+			return err
+		}
+		return adoutbox.Append(tx, events)
+	})
 }
 
 func NewSQLiteUsers(db *gorm.DB) app.Users {
@@ -109,7 +164,8 @@ func NewSQLiteUsers(db *gorm.DB) app.Users {
 	}
 
 	return &sqliteUsers{
-		db:  db,
-		cnt: 0,
+		Gorm: adoutbox.NewGorm(db),
+		db:   db,
+		cnt:  0,
 	}
 }
@@ -0,0 +1,185 @@
+package adwebhook
+
+import (
+	"adflow/internal/ads"
+	"adflow/internal/app"
+	"sort"
+	"sync"
+	"time"
+)
+
+type localWebhooks struct {
+	hooks       map[int64]*ads.Webhook
+	deliveries  map[int64]*ads.WebhookDelivery
+	hookCnt     int64
+	deliveryCnt int64
+	m           sync.Mutex
+}
+
+func copyWebhook(hook *ads.Webhook) *ads.Webhook {
+	result := *hook
+	result.Events = append([]ads.EventType(nil), hook.Events...)
+	return &result
+}
+
+func (r *localWebhooks) CreateWebhook(hook *ads.Webhook) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.hookCnt += 1
+	hook.ID = r.hookCnt
+
+	r.hooks[hook.ID] = copyWebhook(hook)
+	return nil
+}
+
+func (r *localWebhooks) GetWebhook(id int64) (*ads.Webhook, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	hook, ok := r.hooks[id]
+	if !ok {
+		return nil, ads.ErrBadRequest
+	}
+	return copyWebhook(hook), nil
+}
+
+func (r *localWebhooks) ListWebhooks() ([]*ads.Webhook, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	list := make([]*ads.Webhook, 0, len(r.hooks))
+	for _, hook := range r.hooks {
+		list = append(list, copyWebhook(hook))
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list, nil
+}
+
+func (r *localWebhooks) UpdateWebhook(hook *ads.Webhook) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if _, ok := r.hooks[hook.ID]; !ok {
+		return ads.ErrBadRequest
+	}
+	r.hooks[hook.ID] = copyWebhook(hook)
+	return nil
+}
+
+func (r *localWebhooks) DeleteWebhook(id int64) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if _, ok := r.hooks[id]; !ok {
+		return ads.ErrBadRequest
+	}
+	delete(r.hooks, id)
+
+	for deliveryID, d := range r.deliveries {
+		if d.WebhookID == id {
+			delete(r.deliveries, deliveryID)
+		}
+	}
+	return nil
+}
+
+func (r *localWebhooks) EnqueueDeliveries(deliveries []*ads.WebhookDelivery) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	for _, delivery := range deliveries {
+		if r.exists(delivery) {
+			continue
+		}
+
+		r.deliveryCnt += 1
+		delivery.ID = r.deliveryCnt
+
+		stored := *delivery
+		r.deliveries[stored.ID] = &stored
+	}
+	return nil
+}
+
+func (r *localWebhooks) exists(delivery *ads.WebhookDelivery) bool {
+	for _, d := range r.deliveries {
+		if d.WebhookID == delivery.WebhookID && d.Source == delivery.Source && d.EventID == delivery.EventID {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *localWebhooks) GetDelivery(id int64) (*ads.WebhookDelivery, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	delivery, ok := r.deliveries[id]
+	if !ok {
+		return nil, ads.ErrBadRequest
+	}
+
+	result := *delivery
+	return &result, nil
+}
+
+func (r *localWebhooks) DueDeliveries(now time.Time, afterID int64, limit int) ([]*ads.WebhookDelivery, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	var list []*ads.WebhookDelivery
+	for _, d := range r.deliveries {
+		if d.ID > afterID && d.Status == ads.DeliveryPending && d.NextAttemptTime != nil && !d.NextAttemptTime.After(now) {
+			delivery := *d
+			list = append(list, &delivery)
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	if limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	return list, nil
+}
+
+func (r *localWebhooks) SaveDelivery(delivery *ads.WebhookDelivery, status ads.DeliveryStatus, attempts int) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	current, ok := r.deliveries[delivery.ID]
+	if !ok || current.Status != status || current.Attempts != attempts {
+		return ads.ErrBadRequest
+	}
+
+	stored := *delivery
+	r.deliveries[stored.ID] = &stored
+	return nil
+}
+
+func (r *localWebhooks) ListDeliveries(webhookID int64, status ads.DeliveryStatus, beforeID int64, limit int) ([]*ads.WebhookDelivery, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	var list []*ads.WebhookDelivery
+	for _, d := range r.deliveries {
+		if d.WebhookID == webhookID && (status == "" || d.Status == status) && (beforeID == 0 || d.ID < beforeID) {
+			delivery := *d
+			list = append(list, &delivery)
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].ID > list[j].ID })
+	if limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	return list, nil
+}
+
+func New() app.Webhooks {
+	return &localWebhooks{
+		hooks:      make(map[int64]*ads.Webhook),
+		deliveries: make(map[int64]*ads.WebhookDelivery),
+	}
+}
@@ -0,0 +1,173 @@
+package adwebhook
+
+import (
+	"adflow/internal/ads"
+	"adflow/internal/app"
+	"errors"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormWebhooks struct {
+	db *gorm.DB
+	m  sync.Mutex
+}
+
+func (r *gormWebhooks) CreateWebhook(hook *ads.Webhook) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	hook.ID = 0
+	return r.db.Create(hook).Error
+}
+
+func (r *gormWebhooks) GetWebhook(id int64) (*ads.Webhook, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	var hook ads.Webhook
+	if err := r.db.First(&hook, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ads.ErrBadRequest
+		}
+		return nil, err
+	}
+	return &hook, nil
+}
+
+func (r *gormWebhooks) ListWebhooks() ([]*ads.Webhook, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	var list []*ads.Webhook
+	if err := r.db.Order("id").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *gormWebhooks) UpdateWebhook(hook *ads.Webhook) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	result := r.db.Model(hook).Select("url", "events", "active", "update_time").Updates(hook)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ads.ErrBadRequest
+	}
+	return nil
+}
+
+func (r *gormWebhooks) DeleteWebhook(id int64) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&ads.Webhook{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ads.ErrBadRequest
+		}
+		return tx.Where("webhook_id = ?", id).Delete(&ads.WebhookDelivery{}).Error
+	})
+}
+
+func (r *gormWebhooks) EnqueueDeliveries(deliveries []*ads.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	// Relay доставляет события хотя бы один раз; повтор отсекает уникальный индекс
+	for _, delivery := range deliveries {
+		delivery.ID = 0
+	}
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(deliveries).Error
+}
+
+func (r *gormWebhooks) GetDelivery(id int64) (*ads.WebhookDelivery, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	var delivery ads.WebhookDelivery
+	if err := r.db.First(&delivery, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ads.ErrBadRequest
+		}
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func (r *gormWebhooks) DueDeliveries(now time.Time, afterID int64, limit int) ([]*ads.WebhookDelivery, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	query := r.db.Where("status = ? AND next_attempt_time <= ? AND id > ?", ads.DeliveryPending, now, afterID)
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	var list []*ads.WebhookDelivery
+	if err := query.Order("id").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *gormWebhooks) SaveDelivery(delivery *ads.WebhookDelivery, status ads.DeliveryStatus, attempts int) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	fields := []string{"status", "attempts", "next_attempt_time", "last_attempt_time", "response_status", "error", "delivery_time"}
+	result := r.db.Model(delivery).Where("status = ? AND attempts = ?", status, attempts).Select(fields).Updates(delivery)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ads.ErrBadRequest
+	}
+	return nil
+}
+
+func (r *gormWebhooks) ListDeliveries(webhookID int64, status ads.DeliveryStatus, beforeID int64, limit int) ([]*ads.WebhookDelivery, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	query := r.db.Where("webhook_id = ?", webhookID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if beforeID > 0 {
+		query = query.Where("id < ?", beforeID)
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	var list []*ads.WebhookDelivery
+	if err := query.Order("id DESC").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// NewGormWebhooks работает поверх любой базы GORM: SQLite или PostgreSQL
+func NewGormWebhooks(db *gorm.DB) app.Webhooks {
+	if err := db.AutoMigrate(&ads.Webhook{}, &ads.WebhookDelivery{}); err != nil {
+		panic(err)
+	}
+
+	return &gormWebhooks{
+		db: db,
+	}
+}
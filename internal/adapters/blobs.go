@@ -0,0 +1,62 @@
+package adapters
+
+import (
+	"adflow/internal/adapters/adblob"
+	"adflow/internal/app"
+	"adflow/internal/blob"
+	"errors"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// NewBlobs открывает хранилище файлов по DSN:
+//
+//	memory://                                    - в памяти процесса
+//	file://<каталог> или просто <каталог>        - каталог на диске
+//	s3://<бакет>?endpoint=<URL>&region=<регион>  - S3-совместимое хранилище
+//
+// Ключи доступа к S3 берутся из DSN (s3://<ключ>:<секрет>@<бакет>) или из
+// AWS_ACCESS_KEY_ID и AWS_SECRET_ACCESS_KEY.
+func NewBlobs(dsn string) (app.BlobStore, error) {
+	switch {
+	case strings.HasPrefix(dsn, "memory://"):
+		return blob.NewMemoryStore(), nil
+	case strings.HasPrefix(dsn, "s3://"):
+		return newS3Blobs(dsn)
+	default:
+		return adblob.NewFSStore(strings.TrimPrefix(dsn, "file://"))
+	}
+}
+
+func newS3Blobs(dsn string) (app.BlobStore, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	config := adblob.S3Config{
+		Endpoint:  u.Query().Get("endpoint"),
+		Region:    u.Query().Get("region"),
+		Bucket:    u.Host,
+		AccessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+	}
+	if u.User != nil {
+		config.AccessKey = u.User.Username()
+		config.SecretKey, _ = u.User.Password()
+	}
+
+	if config.Bucket == "" {
+		return nil, errors.New("s3: bucket is not set")
+	}
+	if config.Endpoint == "" {
+		region := config.Region
+		if region == "" {
+			region = "us-east-1"
+		}
+		config.Endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+
+	return adblob.NewS3Store(config, nil), nil
+}
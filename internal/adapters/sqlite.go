@@ -1,13 +1,64 @@
 package adapters
 
 import (
+	"adflow/internal/adapters/adsearch"
+	"adflow/internal/ads"
+	"database/sql"
+
+	"github.com/mattn/go-sqlite3"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
+// Драйвер SQLite с функциями:
+//
+//	adflow_match(text, query)             - полнотекстовое условие фильтра, тот же код, что и в памяти (ads.MatchText)
+//	adflow_bm25(matchinfo)                - релевантность для поискового индекса (adsearch.MatchinfoBM25)
+//	adflow_distance(lat, lon, lat0, lon0) - расстояние в километрах (ads.Distance), NULL без координат
+const sqliteDriver = "sqlite3_adflow"
+
+func init() {
+	sql.Register(sqliteDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			if err := conn.RegisterFunc("adflow_match", ads.MatchText, true); err != nil {
+				return err
+			}
+			if err := conn.RegisterFunc("adflow_bm25", adsearch.MatchinfoBM25, true); err != nil {
+				return err
+			}
+			return conn.RegisterFunc("adflow_distance", distance, true)
+		},
+	})
+}
+
+// degrees читает координату из SQLite: целые значения приходят как int64, NULL - как nil
+func degrees(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case int64:
+		return float64(x), true
+	}
+	return 0, false
+}
+
+// distance принимает interface{}, чтобы строки без координат давали NULL, а не ошибку
+func distance(lat, lon, lat0, lon0 interface{}) interface{} {
+	var p, origin ads.Point
+	var ok [4]bool
+	p.Latitude, ok[0] = degrees(lat)
+	p.Longitude, ok[1] = degrees(lon)
+	origin.Latitude, ok[2] = degrees(lat0)
+	origin.Longitude, ok[3] = degrees(lon0)
+	if ok != [4]bool{true, true, true, true} {
+		return nil
+	}
+	return ads.Distance(origin, p)
+}
+
 func NewSQLite(dsn string) (*gorm.DB, error) {
-	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
+	db, err := gorm.Open(sqlite.Dialector{DriverName: sqliteDriver, DSN: dsn}, &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info),
 	})
 	if err != nil {
@@ -0,0 +1,153 @@
+package adapters
+
+import (
+	"adflow/internal/adapters/adconversation"
+	"adflow/internal/adapters/adfavorite"
+	"adflow/internal/adapters/adrepo"
+	"adflow/internal/adapters/adsaved"
+	"adflow/internal/adapters/adsearch"
+	"adflow/internal/adapters/adtoken"
+	"adflow/internal/adapters/aduser"
+	"adflow/internal/adapters/adwebhook"
+	"adflow/internal/app"
+	"adflow/internal/search"
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Бэкенд хранилища выбирается по схеме DSN:
+//
+//	memory://                        - в памяти процесса, данные теряются при перезапуске
+//	sqlite://<файл> или просто <файл> - SQLite
+//	postgres://... и postgresql://... - PostgreSQL
+const (
+	BackendMemory   = "memory"
+	BackendSQLite   = "sqlite"
+	BackendPostgres = "postgres"
+)
+
+var ErrNoDatabase = errors.New("memory backend has no database")
+
+// Backend возвращает бэкенд для DSN и строку подключения для драйвера
+func Backend(dsn string) (string, string) {
+	switch {
+	case strings.HasPrefix(dsn, "memory://"):
+		return BackendMemory, ""
+	case strings.HasPrefix(dsn, "postgres://"), strings.HasPrefix(dsn, "postgresql://"):
+		return BackendPostgres, dsn
+	default:
+		return BackendSQLite, strings.TrimPrefix(dsn, "sqlite://")
+	}
+}
+
+// Open подключается к SQL-базе по DSN
+func Open(dsn string) (*gorm.DB, error) {
+	backend, conn := Backend(dsn)
+
+	switch backend {
+	case BackendPostgres:
+		return NewPostgres(conn)
+	case BackendSQLite:
+		return NewSQLite(conn)
+	default:
+		return nil, ErrNoDatabase
+	}
+}
+
+// NewAds открывает хранилище объявлений; поисковый индекс хранится в той же базе
+func NewAds(dsn string) (app.Repository, app.SearchIndex, error) {
+	backend, _ := Backend(dsn)
+	if backend == BackendMemory {
+		return adrepo.New(), search.NewMemoryIndex(), nil
+	}
+
+	db, err := Open(dsn)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if backend == BackendPostgres {
+		return adrepo.NewPostgresAds(db), adsearch.NewPostgresIndex(db), nil
+	}
+	return adrepo.NewSQLiteAds(db), adsearch.NewSQLiteIndex(db), nil
+}
+
+// NewUsers открывает хранилище пользователей; токены хранятся в той же базе
+func NewUsers(dsn string) (app.Users, app.Tokens, error) {
+	backend, _ := Backend(dsn)
+	if backend == BackendMemory {
+		return aduser.New(), adtoken.New(), nil
+	}
+
+	db, err := Open(dsn)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if backend == BackendPostgres {
+		return aduser.NewPostgresUsers(db), adtoken.NewGormTokens(db), nil
+	}
+	return aduser.NewSQLiteUsers(db), adtoken.NewGormTokens(db), nil
+}
+
+// NewFavorites открывает хранилище избранного; обычно это база объявлений
+func NewFavorites(dsn string) (app.Favorites, error) {
+	backend, _ := Backend(dsn)
+	if backend == BackendMemory {
+		return adfavorite.New(), nil
+	}
+
+	db, err := Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	return adfavorite.NewGormFavorites(db), nil
+}
+
+// NewSavedSearches открывает хранилище сохранённых поисков и уведомлений; обычно это база пользователей
+func NewSavedSearches(dsn string) (app.SavedSearches, error) {
+	backend, _ := Backend(dsn)
+	if backend == BackendMemory {
+		return adsaved.New(), nil
+	}
+
+	db, err := Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	return adsaved.NewGormSearches(db), nil
+}
+
+// NewConversations открывает хранилище переписок; обычно это база объявлений
+func NewConversations(dsn string) (app.Conversations, error) {
+	backend, _ := Backend(dsn)
+	if backend == BackendMemory {
+		return adconversation.New(), nil
+	}
+
+	db, err := Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	return adconversation.NewGormConversations(db), nil
+}
+
+// NewWebhooks открывает хранилище вебхуков и журнала доставок; обычно это база объявлений
+func NewWebhooks(dsn string) (app.Webhooks, error) {
+	backend, _ := Backend(dsn)
+	if backend == BackendMemory {
+		return adwebhook.New(), nil
+	}
+
+	db, err := Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	return adwebhook.NewGormWebhooks(db), nil
+}
@@ -6,13 +6,104 @@ import (
 )
 
 type Ad struct {
-	ID           int64
-	Title        string `validate:"min:1;max:100"`
-	Text         string `validate:"min:1;max:500"`
-	AuthorID     int64
-	Published    bool
+	ID       int64
+	Title    string `validate:"min:1;max:100"`
+	Text     string `validate:"min:1;max:500"`
+	AuthorID int64
+	// Published совпадает с Status == StatusPublished и оставлен для старых клиентов и фильтров
+	Published bool
+	Status    Status
+	// ExpiresAt - когда публикация истекает; задаётся при публикации
+	ExpiresAt    *time.Time
 	CreationTime time.Time
 	UpdateTime   time.Time
+	CategoryID   int64
+	// Price - цена в минимальных единицах валюты Currency (ISO 4217)
+	Price      int64
+	Currency   string
+	Negotiable bool
+	// Место необязательно: без координат Latitude и Longitude равны nil
+	Latitude  *float64
+	Longitude *float64
+	City      string
+	// Images хранятся отдельно от объявления, приложение подставляет их при выдаче
+	Images []Image `gorm:"-"`
+	// FavoriteCount - сколько пользователей добавили объявление в избранное; тоже подставляется при выдаче
+	FavoriteCount int64 `gorm:"-"`
+}
+
+// Draft - поля объявления, которые задаёт автор при создании и изменении.
+// Пустые CategoryID, Price, Currency, Negotiable и Location при создании означают значения
+// по умолчанию, при изменении - прежние значения.
+type Draft struct {
+	Title      string
+	Text       string
+	CategoryID int64
+	Price      *int64
+	Currency   string
+	Negotiable *bool
+	Location   *Location
+	// ClearLocation убирает место объявления; нужен, чтобы вернуть ревизию без места
+	ClearLocation bool
+}
+
+// Draft возвращает текущие поля объявления, заданные автором
+func (ad *Ad) Draft() Draft {
+	price, negotiable := ad.Price, ad.Negotiable
+	return Draft{
+		Title:      ad.Title,
+		Text:       ad.Text,
+		CategoryID: ad.CategoryID,
+		Price:      &price,
+		Currency:   ad.Currency,
+		Negotiable: &negotiable,
+		Location:   ad.Location(),
+	}
+}
+
+// Location возвращает место объявления или nil, если оно не задано
+func (ad *Ad) Location() *Location {
+	if ad.Latitude == nil || ad.Longitude == nil {
+		return nil
+	}
+	return &Location{Point: Point{*ad.Latitude, *ad.Longitude}, City: ad.City}
+}
+
+// Apply переносит в объявление заданные поля черновика, пустые оставляет прежними
+func (d Draft) Apply(ad *Ad) {
+	ad.Title = d.Title
+	ad.Text = d.Text
+	if d.CategoryID != 0 {
+		ad.CategoryID = d.CategoryID
+	}
+	if d.Price != nil {
+		ad.Price = *d.Price
+	}
+	if d.Currency != "" {
+		ad.Currency = d.Currency
+	}
+	if d.Negotiable != nil {
+		ad.Negotiable = *d.Negotiable
+	}
+	if d.Location != nil {
+		lat, lon := d.Location.Latitude, d.Location.Longitude
+		ad.Latitude, ad.Longitude, ad.City = &lat, &lon, d.Location.City
+	} else if d.ClearLocation {
+		ad.Latitude, ad.Longitude, ad.City = nil, nil, ""
+	}
+}
+
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+// Valid сообщает, известна ли роль
+func (r Role) Valid() bool {
+	return r == RoleUser || r == RoleModerator || r == RoleAdmin
 }
 
 type User struct {
@@ -23,14 +114,25 @@ type User struct {
 	Email      string `validate:"min:1;max:100"`
 	Phone      string `validate:"min:1;max:100"`
 	ID         int64
+	Role       Role
 }
 
-type Filter struct {
-	Published    any
-	AuthorID     any
-	TitlePrefix  any
-	CreationTime any
+// UserRole возвращает роль пользователя; у записей, созданных до появления ролей, она пустая
+func (u *User) UserRole() Role {
+	if u.Role == "" {
+		return RoleUser
+	}
+	return u.Role
 }
 
 var ErrBadRequest = errors.New("BadRequest")
 var ErrAccessDenied = errors.New("Forbidden")
+var ErrUnauthorized = errors.New("Unauthorized")
+
+// SearchResult - объявление, найденное полнотекстовым поиском.
+// Snippet - фрагмент текста с совпадениями в тегах <mark>.
+type SearchResult struct {
+	Ad      *Ad
+	Score   float64
+	Snippet string
+}
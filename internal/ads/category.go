@@ -0,0 +1,82 @@
+package ads
+
+import "sort"
+
+type Category struct {
+	ID int64
+	// ParentID - родительская категория, 0 у корневых
+	ParentID int64
+	Name     string `validate:"min:1;max:100"`
+}
+
+// DefaultCategoryID - категория «Другое»: она создаётся вместе с хранилищем,
+// в неё попадают объявления, для которых категория не указана
+const DefaultCategoryID int64 = 1
+
+const DefaultCategoryName = "Другое"
+
+// CategoryNode - категория с подкатегориями и числом опубликованных объявлений во всём поддереве
+type CategoryNode struct {
+	Category
+	Count    int64
+	Children []*CategoryNode
+}
+
+// CategoryTree строит дерево категорий; counts - число опубликованных объявлений в каждой категории
+func CategoryTree(list []*Category, counts map[int64]int64) []*CategoryNode {
+	nodes := make(map[int64]*CategoryNode, len(list))
+	for _, c := range list {
+		nodes[c.ID] = &CategoryNode{Category: *c, Count: counts[c.ID]}
+	}
+
+	sorted := make([]*Category, len(list))
+	copy(sorted, list)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+
+	var roots []*CategoryNode
+	for _, c := range sorted {
+		node := nodes[c.ID]
+		if parent, ok := nodes[c.ParentID]; ok {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+
+	var sum func(node *CategoryNode) int64
+	sum = func(node *CategoryNode) int64 {
+		for _, child := range node.Children {
+			node.Count += sum(child)
+		}
+		return node.Count
+	}
+	for _, root := range roots {
+		sum(root)
+	}
+
+	return roots
+}
+
+// Subtree возвращает категорию id и всех её потомков
+func Subtree(list []*Category, id int64) []int64 {
+	children := make(map[int64][]int64)
+	for _, c := range list {
+		children[c.ParentID] = append(children[c.ParentID], c.ID)
+	}
+
+	ids := []int64{id}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, children[ids[i]]...)
+	}
+	return ids
+}
+
+// IsLeaf сообщает, что у категории нет подкатегорий
+func IsLeaf(list []*Category, id int64) bool {
+	for _, c := range list {
+		if c.ParentID == id {
+			return false
+		}
+	}
+	return true
+}
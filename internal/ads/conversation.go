@@ -0,0 +1,52 @@
+package ads
+
+import "time"
+
+// Conversation - переписка покупателя с автором объявления; пара AdID, BuyerID уникальна
+type Conversation struct {
+	ID       int64
+	AdID     int64 `gorm:"uniqueIndex:idx_conversations_ad_buyer;index"`
+	BuyerID  int64 `gorm:"uniqueIndex:idx_conversations_ad_buyer;index"`
+	AuthorID int64 `gorm:"index"`
+	// LastMessageID - последнее сообщение; по нему переписки упорядочены, новые первыми
+	LastMessageID int64 `gorm:"index"`
+	CreationTime  time.Time
+	// LastMessage и Unread заполняются хранилищем для одного из участников
+	LastMessage *Message `gorm:"-"`
+	Unread      int64    `gorm:"-"`
+	// Ad подставляется приложением при выдаче
+	Ad *Ad `gorm:"-"`
+}
+
+// HasParticipant - пишет ли пользователь в этой переписке
+func (c *Conversation) HasParticipant(userID int64) bool {
+	return userID == c.BuyerID || userID == c.AuthorID
+}
+
+type Message struct {
+	ID             int64
+	ConversationID int64 `gorm:"index"`
+	SenderID       int64
+	Text           string
+	CreationTime   time.Time
+	// ReadTime - когда сообщение прочитал получатель; nil - не прочитано
+	ReadTime *time.Time
+}
+
+// InboxEntry - переписки автора по одному объявлению
+type InboxEntry struct {
+	AdID          int64
+	LastMessageID int64
+	Conversations int64
+	// Unread - сколько сообщений покупателей автор ещё не прочитал
+	Unread int64
+	Ad     *Ad
+}
+
+// ReadReceipt - участник прочитал сообщения собеседника до UpToID включительно
+type ReadReceipt struct {
+	ConversationID int64
+	ReaderID       int64
+	UpToID         int64
+	ReadTime       time.Time
+}
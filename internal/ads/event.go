@@ -0,0 +1,184 @@
+package ads
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type EventType string
+
+const (
+	EventAdCreated       EventType = "ad.created"
+	EventAdUpdated       EventType = "ad.updated"
+	EventAdPublished     EventType = "ad.published"
+	EventAdStatusChanged EventType = "ad.status_changed"
+	EventAdDeleted       EventType = "ad.deleted"
+
+	EventUserCreated     EventType = "user.created"
+	EventUserUpdated     EventType = "user.updated"
+	EventUserRoleChanged EventType = "user.role_changed"
+	EventUserDeleted     EventType = "user.deleted"
+)
+
+// DomainEvent - то, что произошло с объявлением или пользователем. Хранилище сериализует событие
+// в outbox в той же транзакции, что и само изменение, поэтому ID новой записи уже известен.
+type DomainEvent interface {
+	EventType() EventType
+	AggregateID() int64
+}
+
+// AdSnapshot - объявление в событии: без картинок и служебных полей
+type AdSnapshot struct {
+	ID         int64      `json:"id"`
+	AuthorID   int64      `json:"author_id"`
+	Title      string     `json:"title"`
+	Text       string     `json:"text"`
+	CategoryID int64      `json:"category_id"`
+	Price      int64      `json:"price"`
+	Currency   string     `json:"currency"`
+	Negotiable bool       `json:"negotiable"`
+	Status     Status     `json:"status"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
+
+func NewAdSnapshot(ad *Ad) AdSnapshot {
+	return AdSnapshot{
+		ID:         ad.ID,
+		AuthorID:   ad.AuthorID,
+		Title:      ad.Title,
+		Text:       ad.Text,
+		CategoryID: ad.CategoryID,
+		Price:      ad.Price,
+		Currency:   ad.Currency,
+		Negotiable: ad.Negotiable,
+		Status:     ad.AdStatus(),
+		ExpiresAt:  ad.ExpiresAt,
+	}
+}
+
+// AdCreated и AdUpdated ссылаются на объявление и читают его при записи в outbox
+type AdCreated struct {
+	Ad *Ad
+}
+
+func (e AdCreated) EventType() EventType { return EventAdCreated }
+func (e AdCreated) AggregateID() int64   { return e.Ad.ID }
+
+func (e AdCreated) MarshalJSON() ([]byte, error) {
+	return json.Marshal(NewAdSnapshot(e.Ad))
+}
+
+// AdUpdated - объявление после правки
+type AdUpdated struct {
+	Ad *Ad
+}
+
+func (e AdUpdated) EventType() EventType { return EventAdUpdated }
+func (e AdUpdated) AggregateID() int64   { return e.Ad.ID }
+
+func (e AdUpdated) MarshalJSON() ([]byte, error) {
+	return json.Marshal(NewAdSnapshot(e.Ad))
+}
+
+// AdPublished - объявление стало видно всем: после модерации, из черновика или при продлении снятого по сроку
+type AdPublished struct {
+	AdID      int64      `json:"ad_id"`
+	AuthorID  int64      `json:"author_id"`
+	From      Status     `json:"from"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func (e AdPublished) EventType() EventType { return EventAdPublished }
+func (e AdPublished) AggregateID() int64   { return e.AdID }
+
+// AdStatusChanged - любой другой переход; снятие с публикации - это From == StatusPublished
+type AdStatusChanged struct {
+	AdID     int64  `json:"ad_id"`
+	AuthorID int64  `json:"author_id"`
+	From     Status `json:"from"`
+	To       Status `json:"to"`
+}
+
+func (e AdStatusChanged) EventType() EventType { return EventAdStatusChanged }
+func (e AdStatusChanged) AggregateID() int64   { return e.AdID }
+
+type AdDeleted struct {
+	AdID     int64 `json:"ad_id"`
+	AuthorID int64 `json:"author_id"`
+}
+
+func (e AdDeleted) EventType() EventType { return EventAdDeleted }
+func (e AdDeleted) AggregateID() int64   { return e.AdID }
+
+// UserCreated не раскрывает контакты и пароль
+type UserCreated struct {
+	User *User
+}
+
+func (e UserCreated) EventType() EventType { return EventUserCreated }
+func (e UserCreated) AggregateID() int64   { return e.User.ID }
+
+func (e UserCreated) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		ID       int64  `json:"user_id"`
+		Nickname string `json:"nickname"`
+		Role     Role   `json:"role"`
+	}{e.User.ID, e.User.Nickname, e.User.UserRole()})
+}
+
+// UserUpdated - изменились имя или контакты; сами контакты в событие не попадают
+type UserUpdated struct {
+	UserID     int64  `json:"user_id"`
+	FirstName  string `json:"first_name"`
+	SecondName string `json:"second_name"`
+}
+
+func (e UserUpdated) EventType() EventType { return EventUserUpdated }
+func (e UserUpdated) AggregateID() int64   { return e.UserID }
+
+type UserRoleChanged struct {
+	UserID int64 `json:"user_id"`
+	Role   Role  `json:"role"`
+}
+
+func (e UserRoleChanged) EventType() EventType { return EventUserRoleChanged }
+func (e UserRoleChanged) AggregateID() int64   { return e.UserID }
+
+type UserDeleted struct {
+	UserID int64 `json:"user_id"`
+}
+
+func (e UserDeleted) EventType() EventType { return EventUserDeleted }
+func (e UserDeleted) AggregateID() int64   { return e.UserID }
+
+// OutboxRecord - событие, записанное в outbox и ещё не доставленное (DeliveredAt == nil) или уже доставленное
+type OutboxRecord struct {
+	ID          int64 `gorm:"primaryKey"`
+	Type        EventType
+	AggregateID int64
+	Payload     string
+	OccurredAt  time.Time
+	DeliveredAt *time.Time `gorm:"index"`
+}
+
+func (OutboxRecord) TableName() string {
+	return "outbox"
+}
+
+// NewOutboxRecords сериализует события; вызывается хранилищем уже после записи изменения
+func NewOutboxRecords(events []DomainEvent, now time.Time) ([]*OutboxRecord, error) {
+	records := make([]*OutboxRecord, 0, len(events))
+	for _, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, &OutboxRecord{
+			Type:        event.EventType(),
+			AggregateID: event.AggregateID(),
+			Payload:     string(payload),
+			OccurredAt:  now,
+		})
+	}
+	return records, nil
+}
@@ -0,0 +1,13 @@
+package ads
+
+import "time"
+
+// Favorite - объявление в избранном пользователя; пара UserID, AdID уникальна
+type Favorite struct {
+	ID           int64
+	UserID       int64 `gorm:"uniqueIndex:idx_favorites_user_ad"`
+	AdID         int64 `gorm:"uniqueIndex:idx_favorites_user_ad;index"`
+	CreationTime time.Time
+	// Ad подставляется приложением при выдаче
+	Ad *Ad `gorm:"-"`
+}
@@ -0,0 +1,141 @@
+package ads
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+// TimeRange - полуинтервал [After, Before); пустая граница не ограничивает
+type TimeRange struct {
+	After  *time.Time
+	Before *time.Time
+}
+
+func (r TimeRange) Empty() bool {
+	return r.After == nil && r.Before == nil
+}
+
+func (r TimeRange) Contains(t time.Time) bool {
+	if r.After != nil && t.Before(*r.After) {
+		return false
+	}
+	if r.Before != nil && !t.Before(*r.Before) {
+		return false
+	}
+	return true
+}
+
+// Filter - условия выборки объявлений. Пустые поля не ограничивают выборку,
+// заполненные объединяются через И.
+type Filter struct {
+	Published *bool
+	// Statuses - допустимые статусы объявления
+	Statuses []Status
+	// Автор входит в AuthorIDs и не входит в ExcludeAuthorIDs
+	AuthorIDs        []int64
+	ExcludeAuthorIDs []int64
+	// Сравнение заголовка учитывает регистр
+	TitlePrefix   string
+	TitleContains string
+	// Text - полнотекстовый запрос: в тексте должны встретиться все его слова, регистр не важен
+	Text    string
+	Created TimeRange
+	Updated TimeRange
+	// Expires отбирает объявления со сроком публикации в диапазоне; без срока они не подходят
+	Expires TimeRange
+	// CategoryIDs - категории вместе с подкатегориями: приложение раскрывает дерево
+	// до обращения к хранилищу, поэтому здесь это просто множество ID
+	CategoryIDs []int64
+	// Цены разных валют несравнимы, поэтому Price задаётся вместе с Currency
+	Currency string
+	Price    PriceRange
+	// Near оставляет объявления с координатами внутри круга
+	Near *Near
+}
+
+func isWordSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// Tokens разбивает текст на слова в нижнем регистре. SQL-бэкенды разбирают текст так же.
+func Tokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), isWordSeparator)
+}
+
+// MatchText сообщает, встречаются ли в text все слова запроса
+func MatchText(text, query string) bool {
+	words := make(map[string]struct{})
+	for _, word := range Tokens(text) {
+		words[word] = struct{}{}
+	}
+
+	for _, word := range Tokens(query) {
+		if _, ok := words[word]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func containsID(ids []int64, id int64) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+func containsStatus(list []Status, s Status) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Match проверяет объявление по фильтру; SQL-бэкенды строят эквивалентный WHERE
+func (f Filter) Match(ad *Ad) bool {
+	if f.Published != nil && *f.Published != ad.Published {
+		return false
+	}
+	if len(f.Statuses) > 0 && !containsStatus(f.Statuses, ad.AdStatus()) {
+		return false
+	}
+	if !f.Expires.Empty() && (ad.ExpiresAt == nil || !f.Expires.Contains(*ad.ExpiresAt)) {
+		return false
+	}
+	if len(f.AuthorIDs) > 0 && !containsID(f.AuthorIDs, ad.AuthorID) {
+		return false
+	}
+	if containsID(f.ExcludeAuthorIDs, ad.AuthorID) {
+		return false
+	}
+	if len(f.CategoryIDs) > 0 && !containsID(f.CategoryIDs, ad.CategoryID) {
+		return false
+	}
+	if f.Currency != "" && f.Currency != ad.Currency {
+		return false
+	}
+	if !f.Price.Contains(ad.Price) {
+		return false
+	}
+	if f.Near != nil {
+		loc := ad.Location()
+		if loc == nil || !f.Near.Contains(loc.Point) {
+			return false
+		}
+	}
+	if !strings.HasPrefix(ad.Title, f.TitlePrefix) {
+		return false
+	}
+	if !strings.Contains(ad.Title, f.TitleContains) {
+		return false
+	}
+	if f.Text != "" && !MatchText(ad.Text, f.Text) {
+		return false
+	}
+	return f.Created.Contains(ad.CreationTime) && f.Updated.Contains(ad.UpdateTime)
+}
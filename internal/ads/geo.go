@@ -0,0 +1,118 @@
+package ads
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Средний радиус Земли, км
+const EarthRadiusKm = 6371.0088
+
+// Point - координаты в градусах
+type Point struct {
+	Latitude  float64
+	Longitude float64
+}
+
+func (p Point) Valid() bool {
+	return p.Latitude >= -90 && p.Latitude <= 90 && p.Longitude >= -180 && p.Longitude <= 180
+}
+
+// Location - место объявления: координаты и название города для людей
+type Location struct {
+	Point
+	City string `validate:"max:100"`
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func degrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+// Distance - расстояние по большому кругу в километрах (формула гаверсинусов).
+// Округляется до миллиметра, чтобы SQL-бэкенды получали то же значение и курсор не терял строки.
+func Distance(a, b Point) float64 {
+	dLat := radians(b.Latitude - a.Latitude)
+	dLon := radians(b.Longitude - a.Longitude)
+	h := math.Pow(math.Sin(dLat/2), 2) + math.Cos(radians(a.Latitude))*math.Cos(radians(b.Latitude))*math.Pow(math.Sin(dLon/2), 2)
+	d := 2 * EarthRadiusKm * math.Asin(math.Sqrt(math.Min(h, 1)))
+	return math.Round(d*1e6) / 1e6
+}
+
+// Near - круг поиска; RadiusKm = 0 не ограничивает расстояние, а только требует координаты
+type Near struct {
+	Center   Point
+	RadiusKm float64
+}
+
+func (n Near) Contains(p Point) bool {
+	return n.RadiusKm == 0 || Distance(n.Center, p) <= n.RadiusKm
+}
+
+// Box - прямоугольник в градусах, границы включаются
+type Box struct {
+	MinLat, MaxLat float64
+	MinLon, MaxLon float64
+}
+
+// Boxes возвращает прямоугольники, покрывающие круг: по ним хранилища отбирают кандидатов
+// по индексу, а затем проверяют точное расстояние. Круг через линию перемены дат даёт два
+// прямоугольника, круг вокруг полюса - полосу по всем долготам. Без радиуса - nil.
+func (n Near) Boxes() []Box {
+	if n.RadiusKm == 0 {
+		return nil
+	}
+
+	angle := n.RadiusKm / EarthRadiusKm
+	dLat := degrees(angle)
+	minLat, maxLat := n.Center.Latitude-dLat, n.Center.Latitude+dLat
+
+	if minLat <= -90 || maxLat >= 90 || angle >= math.Pi/2 {
+		return []Box{{math.Max(minLat, -90), math.Min(maxLat, 90), -180, 180}}
+	}
+
+	dLon := degrees(math.Asin(math.Sin(angle) / math.Cos(radians(n.Center.Latitude))))
+	minLon, maxLon := n.Center.Longitude-dLon, n.Center.Longitude+dLon
+
+	switch {
+	case minLon < -180:
+		return []Box{{minLat, maxLat, minLon + 360, 180}, {minLat, maxLat, -180, maxLon}}
+	case maxLon > 180:
+		return []Box{{minLat, maxLat, minLon, 180}, {minLat, maxLat, -180, maxLon - 360}}
+	}
+	return []Box{{minLat, maxLat, minLon, maxLon}}
+}
+
+// ParseNear разбирает параметры запроса near=lat,lon и radius_km; пустой near - без фильтра
+func ParseNear(near, radius string) (*Near, error) {
+	if near == "" {
+		if radius != "" {
+			return nil, ErrBadRequest
+		}
+		return nil, nil
+	}
+
+	lat, lon, ok := strings.Cut(near, ",")
+	if !ok {
+		return nil, ErrBadRequest
+	}
+
+	var n Near
+	var err error
+	if n.Center.Latitude, err = strconv.ParseFloat(strings.TrimSpace(lat), 64); err != nil {
+		return nil, ErrBadRequest
+	}
+	if n.Center.Longitude, err = strconv.ParseFloat(strings.TrimSpace(lon), 64); err != nil {
+		return nil, ErrBadRequest
+	}
+	if radius != "" {
+		if n.RadiusKm, err = strconv.ParseFloat(radius, 64); err != nil {
+			return nil, ErrBadRequest
+		}
+	}
+	return &n, nil
+}
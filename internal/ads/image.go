@@ -0,0 +1,20 @@
+package ads
+
+import "time"
+
+// Image - картинка объявления. Сами файлы лежат в хранилище блобов под ключами Key и ThumbnailKey,
+// URL и ThumbnailURL приложение заполняет при выдаче.
+type Image struct {
+	ID           int64
+	AdID         int64 `gorm:"index"`
+	Key          string
+	ThumbnailKey string
+	ContentType  string
+	Size         int64
+	Width        int
+	Height       int
+	CreationTime time.Time
+
+	URL          string `gorm:"-"`
+	ThumbnailURL string `gorm:"-"`
+}
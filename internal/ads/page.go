@@ -0,0 +1,173 @@
+package ads
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+type SortField string
+
+const (
+	SortCreationTime SortField = "creation_time"
+	SortUpdateTime   SortField = "update_time"
+	SortTitle        SortField = "title"
+	SortPrice        SortField = "price"
+	// SortDistance - расстояние от Sort.Origin, только вместе с фильтром Near
+	SortDistance SortField = "distance"
+)
+
+func (f SortField) Valid() bool {
+	return f == SortCreationTime || f == SortUpdateTime || f == SortTitle || f == SortPrice || f == SortDistance
+}
+
+// Sort - порядок выдачи. При равных значениях поля объявления упорядочиваются по ID
+// в том же направлении, поэтому порядок полный и страницы не пересекаются.
+type Sort struct {
+	Field SortField
+	Desc  bool
+	// Origin - точка, от которой считается расстояние при SortDistance
+	Origin Point
+}
+
+// Cursor - позиция последнего выданного объявления в порядке Sort
+type Cursor struct {
+	Sort     Sort
+	Time     time.Time
+	Title    string
+	Price    int64
+	Distance float64
+	ID       int64
+}
+
+// Page - запрос одной страницы: не больше Limit объявлений после After.
+// Limit <= 0 снимает ограничение.
+type Page struct {
+	Sort  Sort
+	Limit int
+	After *Cursor
+}
+
+// CursorOf возвращает позицию объявления в порядке s
+func (s Sort) CursorOf(ad *Ad) *Cursor {
+	c := &Cursor{Sort: s, ID: ad.ID}
+	switch s.Field {
+	case SortUpdateTime:
+		c.Time = ad.UpdateTime
+	case SortTitle:
+		c.Title = ad.Title
+	case SortPrice:
+		c.Price = ad.Price
+	case SortDistance:
+		if loc := ad.Location(); loc != nil {
+			c.Distance = Distance(s.Origin, loc.Point)
+		}
+	default:
+		c.Time = ad.CreationTime
+	}
+	return c
+}
+
+func compareTime(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
+}
+
+// compareInt сравнивает ID и цены
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compare сравнивает две позиции одной сортировки с учётом направления
+func (c *Cursor) compare(o *Cursor) int {
+	var res int
+	switch c.Sort.Field {
+	case SortTitle:
+		res = strings.Compare(c.Title, o.Title)
+	case SortPrice:
+		res = compareInt(c.Price, o.Price)
+	case SortDistance:
+		res = compareFloat(c.Distance, o.Distance)
+	default:
+		res = compareTime(c.Time, o.Time)
+	}
+	if res == 0 {
+		res = compareInt(c.ID, o.ID)
+	}
+	if c.Sort.Desc {
+		res = -res
+	}
+	return res
+}
+
+// Less сообщает, идёт ли a раньше b в порядке s
+func (s Sort) Less(a, b *Ad) bool {
+	return s.CursorOf(a).compare(s.CursorOf(b)) < 0
+}
+
+// Precedes сообщает, что объявление идёт после курсора, то есть попадает на следующие страницы
+func (c *Cursor) Precedes(ad *Ad) bool {
+	return c.compare(c.Sort.CursorOf(ad)) < 0
+}
+
+type cursorJSON struct {
+	Field SortField `json:"f"`
+	Desc  bool      `json:"d,omitempty"`
+	Time  time.Time `json:"t,omitempty"`
+	Title string    `json:"s,omitempty"`
+	Price int64     `json:"p,omitempty"`
+	// Точка отсчёта и расстояние для сортировки по расстоянию
+	Origin   *Point  `json:"o,omitempty"`
+	Distance float64 `json:"g,omitempty"`
+	ID       int64   `json:"i"`
+}
+
+// Encode переводит курсор в непрозрачную строку для клиента
+func (c *Cursor) Encode() string {
+	v := cursorJSON{Field: c.Sort.Field, Desc: c.Sort.Desc, Time: c.Time.UTC(), Title: c.Title, Price: c.Price, ID: c.ID}
+	if c.Sort.Field == SortDistance {
+		v.Origin, v.Distance = &c.Sort.Origin, c.Distance
+	}
+	data, _ := json.Marshal(v)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeCursor(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrBadRequest
+	}
+
+	var c cursorJSON
+	if err := json.Unmarshal(data, &c); err != nil || !c.Field.Valid() {
+		return nil, ErrBadRequest
+	}
+
+	cursor := &Cursor{Sort: Sort{Field: c.Field, Desc: c.Desc}, Time: c.Time, Title: c.Title, Price: c.Price, Distance: c.Distance, ID: c.ID}
+	if c.Origin != nil {
+		cursor.Sort.Origin = *c.Origin
+	}
+	return cursor, nil
+}
@@ -0,0 +1,64 @@
+package ads
+
+import "strings"
+
+// Цена хранится целым числом в минимальных единицах валюты (копейках, центах),
+// чтобы не терять точность на дробях
+const (
+	DefaultCurrency = "RUB"
+	MaxPrice        = 1_000_000_000_000_000
+)
+
+// Действующие коды ISO 4217
+var currencies = map[string]struct{}{}
+
+func init() {
+	for _, code := range strings.Fields(`
+		AED AFN ALL AMD ANG AOA ARS AUD AWG AZN BAM BBD BDT BGN BHD BIF BMD BND BOB BRL BSD BTN BWP BYN BZD
+		CAD CDF CHF CLP CNY COP CRC CUP CVE CZK DJF DKK DOP DZD EGP ERN ETB EUR FJD FKP GBP GEL GHS GIP GMD
+		GNF GTQ GYD HKD HNL HTG HUF IDR ILS INR IQD IRR ISK JMD JOD JPY KES KGS KHR KMF KPW KRW KWD KYD KZT
+		LAK LBP LKR LRD LSL LYD MAD MDL MGA MKD MMK MNT MOP MRU MUR MVR MWK MXN MYR MZN NAD NGN NIO NOK NPR
+		NZD OMR PAB PEN PGK PHP PKR PLN PYG QAR RON RSD RUB RWF SAR SBD SCR SDG SEK SGD SHP SLE SOS SRD SSP
+		STN SVC SYP SZL THB TJS TMT TND TOP TRY TTD TWD TZS UAH UGX USD UYU UZS VES VND VUV WST XAF XCD XOF
+		XPF YER ZAR ZMW ZWL`) {
+		currencies[code] = struct{}{}
+	}
+}
+
+// NormalizeCurrency приводит код валюты к верхнему регистру
+func NormalizeCurrency(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func ValidCurrency(code string) bool {
+	_, ok := currencies[code]
+	return ok
+}
+
+// ValidatePrice проверяет цену в минимальных единицах и код валюты
+func ValidatePrice(price int64, currency string) error {
+	if price < 0 || price > MaxPrice || !ValidCurrency(currency) {
+		return ErrBadRequest
+	}
+	return nil
+}
+
+// PriceRange - отрезок [Min, Max] цен в одной валюте; пустая граница не ограничивает
+type PriceRange struct {
+	Min *int64
+	Max *int64
+}
+
+func (r PriceRange) Empty() bool {
+	return r.Min == nil && r.Max == nil
+}
+
+func (r PriceRange) Contains(price int64) bool {
+	if r.Min != nil && price < *r.Min {
+		return false
+	}
+	if r.Max != nil && price > *r.Max {
+		return false
+	}
+	return true
+}
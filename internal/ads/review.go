@@ -0,0 +1,37 @@
+package ads
+
+import "time"
+
+// Flag - замечание автоматической проверки: правило и найденный фрагмент
+type Flag struct {
+	Rule   string `json:"rule"`
+	Detail string `json:"detail"`
+}
+
+// Decision - итог проверки объявления модератором
+type Decision string
+
+const (
+	DecisionPending  Decision = "pending"
+	DecisionApproved Decision = "approved"
+	DecisionRejected Decision = "rejected"
+	// DecisionWithdrawn - автор вернул объявление в черновики до решения
+	DecisionWithdrawn Decision = "withdrawn"
+)
+
+// Review - заявка на проверку: создаётся, когда объявление попадает в pending_review,
+// и закрывается решением, когда оно оттуда уходит
+type Review struct {
+	ID       int64
+	AdID     int64 `gorm:"index"`
+	AuthorID int64
+	Decision Decision `gorm:"index"`
+	Flags    []Flag   `gorm:"serializer:json"`
+	// Reason - причина отклонения, её видит автор
+	Reason       string `validate:"max:500"`
+	ModeratorID  int64
+	CreationTime time.Time
+	DecisionTime *time.Time
+	// Ad подставляется приложением в очереди модерации
+	Ad *Ad `gorm:"-"`
+}
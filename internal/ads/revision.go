@@ -0,0 +1,106 @@
+package ads
+
+import "time"
+
+// Revision - неизменяемый снимок полей объявления после создания или правки.
+// Номера идут подряд с 1 внутри объявления; ревизия 1 - объявление при создании.
+type Revision struct {
+	ID       int64
+	AdID     int64 `gorm:"uniqueIndex:idx_revisions_ad_number"`
+	Number   int64 `gorm:"uniqueIndex:idx_revisions_ad_number"`
+	AuthorID int64
+	// RestoredFrom - номер ревизии, к которой вернулось объявление; 0 у обычной правки
+	RestoredFrom int64
+	CreationTime time.Time
+
+	Title      string
+	Text       string
+	CategoryID int64
+	Price      int64
+	Currency   string
+	Negotiable bool
+	Latitude   *float64
+	Longitude  *float64
+	City       string
+}
+
+// Snapshot копирует в ревизию поля объявления, заданные автором
+func (rev *Revision) Snapshot(ad *Ad) {
+	rev.AdID = ad.ID
+	rev.Title = ad.Title
+	rev.Text = ad.Text
+	rev.CategoryID = ad.CategoryID
+	rev.Price = ad.Price
+	rev.Currency = ad.Currency
+	rev.Negotiable = ad.Negotiable
+	rev.Latitude, rev.Longitude, rev.City = nil, nil, ""
+	if loc := ad.Location(); loc != nil {
+		lat, lon := loc.Latitude, loc.Longitude
+		rev.Latitude, rev.Longitude, rev.City = &lat, &lon, loc.City
+	}
+}
+
+// Location возвращает место объявления в ревизии или nil, если его не было
+func (rev *Revision) Location() *Location {
+	if rev.Latitude == nil || rev.Longitude == nil {
+		return nil
+	}
+	return &Location{Point: Point{*rev.Latitude, *rev.Longitude}, City: rev.City}
+}
+
+// Draft возвращает черновик, который вернёт объявлению все поля ревизии, в том числе отсутствие места
+func (rev *Revision) Draft() Draft {
+	price, negotiable := rev.Price, rev.Negotiable
+	loc := rev.Location()
+	return Draft{
+		Title:         rev.Title,
+		Text:          rev.Text,
+		CategoryID:    rev.CategoryID,
+		Price:         &price,
+		Currency:      rev.Currency,
+		Negotiable:    &negotiable,
+		Location:      loc,
+		ClearLocation: loc == nil,
+	}
+}
+
+// Change - поле, которое различается в двух ревизиях; место сравнивается целиком как *Location
+type Change struct {
+	Field string
+	From  any
+	To    any
+}
+
+// DiffRevisions возвращает поля, изменившиеся от ревизии from к ревизии to, в порядке полей объявления
+func DiffRevisions(from, to *Revision) []Change {
+	var changes []Change
+	add := func(field string, a, b any) {
+		changes = append(changes, Change{Field: field, From: a, To: b})
+	}
+
+	if from.Title != to.Title {
+		add("title", from.Title, to.Title)
+	}
+	if from.Text != to.Text {
+		add("text", from.Text, to.Text)
+	}
+	if from.CategoryID != to.CategoryID {
+		add("category_id", from.CategoryID, to.CategoryID)
+	}
+	if from.Price != to.Price {
+		add("price", from.Price, to.Price)
+	}
+	if from.Currency != to.Currency {
+		add("currency", from.Currency, to.Currency)
+	}
+	if from.Negotiable != to.Negotiable {
+		add("negotiable", from.Negotiable, to.Negotiable)
+	}
+
+	a, b := from.Location(), to.Location()
+	if (a == nil) != (b == nil) || (a != nil && *a != *b) {
+		add("location", a, b)
+	}
+
+	return changes
+}
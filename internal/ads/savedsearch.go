@@ -0,0 +1,36 @@
+package ads
+
+import "time"
+
+// SavedSearch - сохранённый пользователем фильтр. Когда публикуется подходящее
+// объявление, пользователь получает уведомление.
+type SavedSearch struct {
+	ID     int64
+	UserID int64  `gorm:"index"`
+	Name   string `validate:"min:1;max:100"`
+	// Filter хранится как есть: категории раскрываются в поддеревья при каждой проверке,
+	// поэтому поиск учитывает и добавленные позже подкатегории
+	Filter       Filter `gorm:"serializer:json"`
+	CreationTime time.Time
+}
+
+// Notification - уведомление о новом объявлении по сохранённому поиску.
+// Об одном объявлении по одному поиску уведомление приходит один раз.
+type Notification struct {
+	ID       int64
+	UserID   int64 `gorm:"index"`
+	SearchID int64 `gorm:"uniqueIndex:idx_notifications_search_ad"`
+	AdID     int64 `gorm:"uniqueIndex:idx_notifications_search_ad"`
+	// Title - заголовок объявления на момент уведомления
+	Title        string
+	CreationTime time.Time
+	// DeliveryTime - когда уведомление доставлено; nil и Error - последняя попытка не удалась
+	DeliveryTime *time.Time
+	Error        string
+	Attempts     int
+	// NextAttemptTime - когда пробовать доставить снова; nil - доставлено, попытки кончились или доставлять некуда
+	NextAttemptTime *time.Time `gorm:"index"`
+	// Search и Ad подставляются приложением для доставки
+	Search *SavedSearch `gorm:"-"`
+	Ad     *Ad          `gorm:"-"`
+}
@@ -0,0 +1,90 @@
+package ads
+
+import (
+	"strings"
+	"time"
+)
+
+// Status - стадия жизненного цикла объявления
+type Status string
+
+const (
+	StatusDraft         Status = "draft"
+	StatusPendingReview Status = "pending_review"
+	StatusPublished     Status = "published"
+	StatusExpired       Status = "expired"
+	StatusArchived      Status = "archived"
+	StatusSold          Status = "sold"
+)
+
+const (
+	// PublicationTTL - срок публикации, после которого объявление снимается автоматически
+	PublicationTTL = 30 * 24 * time.Hour
+	// ArchiveAfter - сколько снятое по сроку объявление ждёт продления, прежде чем уйти в архив
+	ArchiveAfter = 30 * 24 * time.Hour
+)
+
+// PublicStatuses - статусы, в которых объявление видно всем. Черновики, объявления на проверке и архив
+// видят только автор и модераторы: в архив может уйти и черновик, который никто не проверял.
+var PublicStatuses = []Status{StatusPublished, StatusExpired, StatusSold}
+
+// Разрешённые переходы. В expired объявление переводит только планировщик,
+// из архива вернуться нельзя.
+var transitions = map[Status][]Status{
+	StatusDraft:         {StatusPendingReview, StatusPublished, StatusArchived},
+	StatusPendingReview: {StatusDraft, StatusPublished},
+	StatusPublished:     {StatusDraft, StatusExpired, StatusSold, StatusArchived},
+	StatusExpired:       {StatusPublished, StatusSold, StatusArchived},
+	StatusSold:          {StatusArchived},
+}
+
+func (s Status) Valid() bool {
+	switch s {
+	case StatusDraft, StatusPendingReview, StatusPublished, StatusExpired, StatusArchived, StatusSold:
+		return true
+	}
+	return false
+}
+
+func (s Status) Public() bool {
+	for _, v := range PublicStatuses {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// CanTransition сообщает, можно ли перевести объявление из s в to
+func (s Status) CanTransition(to Status) bool {
+	for _, v := range transitions[s] {
+		if v == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseStatuses разбирает список статусов через запятую
+func ParseStatuses(value string) ([]Status, error) {
+	var list []Status
+	for _, v := range strings.Split(value, ",") {
+		s := Status(strings.TrimSpace(v))
+		if !s.Valid() {
+			return nil, ErrBadRequest
+		}
+		list = append(list, s)
+	}
+	return list, nil
+}
+
+// AdStatus возвращает статус объявления; у записей, созданных до появления статусов, он выводится из Published
+func (ad *Ad) AdStatus() Status {
+	if ad.Status == "" {
+		if ad.Published {
+			return StatusPublished
+		}
+		return StatusDraft
+	}
+	return ad.Status
+}
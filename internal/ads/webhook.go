@@ -0,0 +1,77 @@
+package ads
+
+import "time"
+
+// EventTypes - события, на которые можно подписать вебхук
+var EventTypes = []EventType{
+	EventAdCreated, EventAdUpdated, EventAdPublished, EventAdStatusChanged, EventAdDeleted,
+	EventUserCreated, EventUserUpdated, EventUserRoleChanged, EventUserDeleted,
+}
+
+func (t EventType) Valid() bool {
+	for _, v := range EventTypes {
+		if t == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Webhook - подписка партнёра на события домена. Каждое событие уходит POST-запросом на URL,
+// тело подписывается HMAC-SHA256 с ключом Secret.
+type Webhook struct {
+	ID     int64
+	URL    string
+	Secret string
+	Events []EventType `gorm:"serializer:json"`
+	// Пока подписка выключена, новые события ей не достаются, а накопленные доставки ждут
+	Active       bool
+	CreatorID    int64
+	CreationTime time.Time
+	UpdateTime   time.Time
+}
+
+// Subscribed сообщает, нужно ли отправлять подписке событие такого типа
+func (w *Webhook) Subscribed(t EventType) bool {
+	for _, v := range w.Events {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+type DeliveryStatus string
+
+const (
+	// DeliveryPending - ждёт отправки; после неудачной попытки - повтора в NextAttemptTime
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	// DeliveryDead - попытки кончились; отправить снова можно только вручную
+	DeliveryDead DeliveryStatus = "dead"
+)
+
+func (s DeliveryStatus) Valid() bool {
+	return s == DeliveryPending || s == DeliveryDelivered || s == DeliveryDead
+}
+
+// WebhookDelivery - доставка одного события одной подписке.
+// Source и EventID - событие в outbox; одно событие одной подписке доставляется один раз.
+type WebhookDelivery struct {
+	ID        int64
+	WebhookID int64  `gorm:"uniqueIndex:idx_webhook_deliveries_event"`
+	Source    string `gorm:"uniqueIndex:idx_webhook_deliveries_event"`
+	EventID   int64  `gorm:"uniqueIndex:idx_webhook_deliveries_event"`
+	EventType EventType
+	// Payload - тело запроса; подписывается заново при каждой попытке
+	Payload         string
+	Status          DeliveryStatus `gorm:"index:idx_webhook_deliveries_due"`
+	Attempts        int
+	NextAttemptTime *time.Time `gorm:"index:idx_webhook_deliveries_due"`
+	LastAttemptTime *time.Time
+	// ResponseStatus и Error - итог последней попытки; 0 - ответа не было
+	ResponseStatus int
+	Error          string
+	CreationTime   time.Time
+	DeliveryTime   *time.Time
+}
@@ -3,101 +3,654 @@ package app
 import (
 	"adflow/internal/ads"
 	"context"
+	"time"
 
 	auth "adflow/internal/app/auth"
+	"adflow/internal/blob"
+	"adflow/internal/moderation"
+	"adflow/internal/pubsub"
+	"adflow/internal/search"
 
 	validator "github.com/skyberg11/args-validator"
 )
 
+// Методы, меняющие объявление, пишут events в outbox в той же транзакции
 type Repository interface {
-	Create(ad *ads.Ad) error
+	Create(ad *ads.Ad, events ...ads.DomainEvent) error
 	Get(id int64) (*ads.Ad, error)
-	Update(id int64, title, text string) (*ads.Ad, error)
-	UpdateStatus(id int64, published bool) (*ads.Ad, error)
+	// GetMany возвращает найденные объявления по возрастанию ID; отсутствующие пропускаются
+	GetMany(ids []int64) ([]*ads.Ad, error)
+	// Update применяет черновик и в той же транзакции записывает ревизию: хранилище заполняет в revision
+	// объявление, номер и поля, а автора и RestoredFrom задаёт приложение. Create записывает ревизию 1.
+	Update(id int64, draft ads.Draft, revision *ads.Revision, events ...ads.DomainEvent) (*ads.Ad, error)
+	UpdateStatus(id int64, from, to ads.Status, expiresAt *time.Time, events ...ads.DomainEvent) (*ads.Ad, error)
 	GetAllAds() ([]*ads.Ad, error)
-	GetAds(filter ads.Filter) ([]*ads.Ad, error)
-	DeleteAd(id int64) error
+	// GetAds возвращает страницу выборки и курсор следующей страницы (nil, если это последняя)
+	GetAds(filter ads.Filter, page ads.Page) ([]*ads.Ad, *ads.Cursor, error)
+	DeleteAd(id int64, events ...ads.DomainEvent) error
+	Categories
+	Images
+	Reviews
+	Revisions
+	Outbox
 }
 
+// Outbox - события домена, записанные хранилищем вместе с изменениями; их забирает outbox.Relay
+type Outbox interface {
+	// PendingEvents возвращает до limit недоставленных событий с ID больше afterID по возрастанию ID
+	PendingEvents(afterID int64, limit int) ([]*ads.OutboxRecord, error)
+	MarkDelivered(ids []int64, at time.Time) error
+}
+
+// Categories - дерево категорий; хранится вместе с объявлениями.
+// Хранилище создаёт категорию ads.DefaultCategoryID при первом запуске.
+type Categories interface {
+	CreateCategory(category *ads.Category) error
+	GetCategory(id int64) (*ads.Category, error)
+	ListCategories() ([]*ads.Category, error)
+	UpdateCategory(id int64, name string, parentID int64) (*ads.Category, error)
+	DeleteCategory(id int64) error
+	// CountPublished возвращает число опубликованных объявлений в каждой категории
+	CountPublished() (map[int64]int64, error)
+}
+
+// Images - сведения о картинках объявлений; сами файлы лежат в BlobStore
+type Images interface {
+	// AddImages записывает картинки объявления одной транзакцией, если их у объявления станет не больше max;
+	// иначе не записывает ни одной и возвращает ads.ErrBadRequest
+	AddImages(adID int64, images []*ads.Image, max int) error
+	GetImage(id int64) (*ads.Image, error)
+	// ListImages возвращает картинки объявлений в порядке загрузки
+	ListImages(adIDs []int64) ([]*ads.Image, error)
+	DeleteImage(id int64) error
+}
+
+// Reviews - заявки на проверку объявлений модераторами
+type Reviews interface {
+	CreateReview(review *ads.Review) error
+	// ListReviews возвращает заявки объявления, последние первыми
+	ListReviews(adID int64) ([]*ads.Review, error)
+	// ListPendingReviews возвращает очередь: заявки без решения с ID больше afterID, старые первыми
+	ListPendingReviews(afterID int64, limit int) ([]*ads.Review, error)
+	// DecideReview закрывает заявку, если она ещё ждёт решения
+	DecideReview(id int64, decision ads.Decision, reason string, moderatorID int64) (*ads.Review, error)
+}
+
+// Revisions - история правок объявлений; записи только добавляются и удаляются вместе с объявлением
+type Revisions interface {
+	// ListRevisions возвращает ревизии объявления с номером меньше before (0 - с последней), последние первыми
+	ListRevisions(adID, before int64, limit int) ([]*ads.Revision, error)
+	GetRevision(adID, number int64) (*ads.Revision, error)
+}
+
+// BlobStore - хранилище файлов по ключу. Чтение отсутствующего ключа - ads.ErrBadRequest,
+// удаление отсутствующего ключа ошибкой не считается.
+type BlobStore interface {
+	Put(ctx context.Context, key, contentType string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, string, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// SearchIndex - полнотекстовый индекс опубликованных объявлений
+type SearchIndex interface {
+	Index(ad *ads.Ad) error
+	Remove(id int64) error
+	// Search возвращает объявления со всеми словами запроса по убыванию релевантности
+	Search(query string, limit, offset int) ([]search.Hit, error)
+}
+
+// Как и в Repository, изменения пишут events в outbox той же транзакцией
 type Users interface {
-	Create(ad *ads.User) error
+	Create(ad *ads.User, events ...ads.DomainEvent) error
 	Get(id int64) (*ads.User, error)
 	GetByNickname(nickname string) (*ads.User, error)
-	Update(id int64, first_name, second_name, email, phone string) (*ads.User, error)
-	Delete(id int64) error
+	Update(id int64, first_name, second_name, email, phone string, events ...ads.DomainEvent) (*ads.User, error)
+	UpdatePassword(id int64, password string) error
+	UpdateRole(id int64, role ads.Role, events ...ads.DomainEvent) error
+	Delete(id int64, events ...ads.DomainEvent) error
+	Outbox
+}
+
+// Favorites - избранные объявления пользователей
+type Favorites interface {
+	// AddFavorite добавляет объявление в избранное; если оно уже там, заполняет favorite прежней записью
+	AddFavorite(favorite *ads.Favorite) error
+	RemoveFavorite(userID, adID int64) error
+	// ListFavorites возвращает избранное пользователя с ID меньше beforeID (0 - с начала), новые первыми
+	ListFavorites(userID, beforeID int64, limit int) ([]*ads.Favorite, error)
+	// CountFavorites возвращает, сколько раз каждое из объявлений добавлено в избранное
+	CountFavorites(adIDs []int64) (map[int64]int64, error)
+	RemoveAdFavorites(adID int64) error
+	RemoveUserFavorites(userID int64) error
+}
+
+// SavedSearches - сохранённые поиски пользователей и уведомления по ним
+type SavedSearches interface {
+	CreateSearch(search *ads.SavedSearch) error
+	GetSearch(id int64) (*ads.SavedSearch, error)
+	// ListSearches возвращает поиски пользователя в порядке создания
+	ListSearches(userID int64) ([]*ads.SavedSearch, error)
+	// ScanSearches обходит поиски всех пользователей: ID больше afterID, по возрастанию
+	ScanSearches(afterID int64, limit int) ([]*ads.SavedSearch, error)
+	UpdateSearch(id int64, name string, filter ads.Filter) (*ads.SavedSearch, error)
+	DeleteSearch(id int64) error
+	// RemoveUserSearches удаляет поиски и уведомления пользователя
+	RemoveUserSearches(userID int64) error
+
+	// CreateNotification сохраняет уведомление; false - по этому поиску об этом объявлении уже уведомляли
+	CreateNotification(notification *ads.Notification) (bool, error)
+	// DueNotifications возвращает уведомления с ID больше afterID, следующая попытка доставки которых не позже now,
+	// по возрастанию ID
+	DueNotifications(now time.Time, afterID int64, limit int) ([]*ads.Notification, error)
+	// SaveDelivery записывает итог попытки доставки: Attempts, NextAttemptTime, DeliveryTime и Error
+	SaveDelivery(notification *ads.Notification) error
+	// ListNotifications возвращает уведомления пользователя с ID меньше beforeID (0 - с начала), новые первыми
+	ListNotifications(userID, beforeID int64, limit int) ([]*ads.Notification, error)
+}
+
+// Conversations - переписки покупателей с авторами объявлений
+type Conversations interface {
+	// StartConversation создаёт переписку; если она уже есть, заполняет conversation прежней записью
+	StartConversation(conversation *ads.Conversation) error
+	// GetConversation возвращает переписку, LastMessage и Unread заполняются для участника viewerID
+	GetConversation(id, viewerID int64) (*ads.Conversation, error)
+	// ListConversations возвращает переписки пользователя с сообщениями и LastMessageID меньше beforeID (0 - с начала),
+	// новые первыми; adID, если не 0, оставляет переписки по одному объявлению
+	ListConversations(userID, adID, beforeID int64, limit int) ([]*ads.Conversation, error)
+	// ListInbox группирует переписки автора по объявлениям; LastMessageID группы меньше beforeID, новые первыми
+	ListInbox(authorID, beforeID int64, limit int) ([]*ads.InboxEntry, error)
+	// AddMessage сохраняет сообщение и делает его последним в переписке
+	AddMessage(message *ads.Message) error
+	// ListMessages возвращает сообщения переписки с ID меньше beforeID (0 - с начала), новые первыми
+	ListMessages(conversationID, beforeID int64, limit int) ([]*ads.Message, error)
+	// MarkRead отмечает прочитанными сообщения собеседника readerID с ID не больше upToID (0 - все)
+	// и возвращает, сколько их было
+	MarkRead(conversationID, readerID, upToID int64, readTime time.Time) (int64, error)
+	RemoveAdConversations(adID int64) error
+	// RemoveUserConversations удаляет переписки, где пользователь покупатель или автор
+	RemoveUserConversations(userID int64) error
+}
+
+// Webhooks - подписки партнёров на события и журнал доставок по ним
+type Webhooks interface {
+	CreateWebhook(hook *ads.Webhook) error
+	GetWebhook(id int64) (*ads.Webhook, error)
+	// ListWebhooks возвращает подписки в порядке создания
+	ListWebhooks() ([]*ads.Webhook, error)
+	UpdateWebhook(hook *ads.Webhook) error
+	// DeleteWebhook удаляет подписку вместе с её доставками
+	DeleteWebhook(id int64) error
+
+	// EnqueueDeliveries сохраняет доставки; если событие этой подписке уже доставляется, новая не создаётся
+	EnqueueDeliveries(deliveries []*ads.WebhookDelivery) error
+	GetDelivery(id int64) (*ads.WebhookDelivery, error)
+	// DueDeliveries возвращает до limit ожидающих доставок с ID больше afterID, срок попытки которых
+	// не позже now, по возрастанию ID
+	DueDeliveries(now time.Time, afterID int64, limit int) ([]*ads.WebhookDelivery, error)
+	// SaveDelivery записывает статус и итог попытки, если статус и число попыток доставки всё ещё status
+	// и attempts - такими, какими их прочитали; иначе ничего не меняет и возвращает ads.ErrBadRequest
+	SaveDelivery(delivery *ads.WebhookDelivery, status ads.DeliveryStatus, attempts int) error
+	// ListDeliveries возвращает доставки подписки с ID меньше beforeID (0 - с начала), новые первыми;
+	// непустой status оставляет доставки в этом статусе
+	ListDeliveries(webhookID int64, status ads.DeliveryStatus, beforeID int64, limit int) ([]*ads.WebhookDelivery, error)
+}
+
+// Tokens хранит refresh-токены и отозванные access-токены
+type Tokens interface {
+	CreateRefresh(token *auth.RefreshToken) error
+	GetRefresh(id string) (*auth.RefreshToken, error)
+	// UseRefresh помечает токен использованным и возвращает его прежнее состояние
+	UseRefresh(id string) (*auth.RefreshToken, error)
+	RevokeFamily(family string) error
+	RevokeAccess(token *auth.RevokedToken) error
+	IsAccessRevoked(id string) (bool, error)
 }
 
 type App interface {
-	ListAds(ctx context.Context, filter ads.Filter) ([]*ads.Ad, error)
-	CreateAd(ctx context.Context, title, text string, authorID int64) (*ads.Ad, error)
+	ListAds(ctx context.Context, filter ads.Filter, page ads.Page) ([]*ads.Ad, *ads.Cursor, error)
+	SearchAds(ctx context.Context, query string, limit, offset int) ([]*ads.SearchResult, error)
+	CreateAd(ctx context.Context, draft ads.Draft) (*ads.Ad, error)
 	GetAd(ctx context.Context, id int64) (*ads.Ad, error)
-	ChangeAdStatus(ctx context.Context, id, UserID int64, published bool) (*ads.Ad, error)
-	UpdateAd(ctx context.Context, id, UserID int64, title, text string) (*ads.Ad, error)
-	DeleteAd(ctx context.Context, id int64, UserID int64) error
+	ChangeAdStatus(ctx context.Context, id int64, published bool) (*ads.Ad, error)
+	TransitionAd(ctx context.Context, id int64, status ads.Status) (*ads.Ad, error)
+	RenewAd(ctx context.Context, id int64) (*ads.Ad, error)
+	ApproveAd(ctx context.Context, id int64) (*ads.Ad, error)
+	RejectAd(ctx context.Context, id int64, reason string) (*ads.Ad, error)
+	ListModerationQueue(ctx context.Context, afterID int64, limit int) ([]*ads.Review, error)
+	ListAdReviews(ctx context.Context, adID int64) ([]*ads.Review, error)
+	// ExpireAds снимает объявления с истёкшим сроком и архивирует давно снятые; вызывается планировщиком
+	ExpireAds(ctx context.Context, now time.Time) (int, error)
+	// MatchSavedSearches заводит уведомления по сохранённым поискам об опубликованном объявлении; вызывается из outbox
+	MatchSavedSearches(ctx context.Context, adID int64) (int, error)
+	// DeliverNotifications отправляет уведомления, срок доставки которых подошёл; вызывается RunNotifications
+	DeliverNotifications(ctx context.Context, now time.Time) (int, error)
+	UpdateAd(ctx context.Context, id int64, draft ads.Draft) (*ads.Ad, error)
+	DeleteAd(ctx context.Context, id int64) error
+	// История правок объявления: ревизии нумеруются с 1, последняя совпадает с объявлением
+	ListAdRevisions(ctx context.Context, adID, before int64, limit int) ([]*ads.Revision, error)
+	GetAdRevision(ctx context.Context, adID, number int64) (*ads.Revision, error)
+	DiffAdRevisions(ctx context.Context, adID, from, to int64) ([]ads.Change, error)
+	// RestoreAdRevision возвращает объявлению поля ревизии; возврат записывается новой ревизией
+	RestoreAdRevision(ctx context.Context, adID, number int64) (*ads.Ad, error)
+	AddAdImages(ctx context.Context, adID int64, files [][]byte) (*ads.Ad, error)
+	DeleteAdImage(ctx context.Context, adID, imageID int64) (*ads.Ad, error)
+	GetImage(ctx context.Context, key string) ([]byte, string, error)
+
+	AddFavorite(ctx context.Context, userID, adID int64) (*ads.Favorite, error)
+	RemoveFavorite(ctx context.Context, userID, adID int64) error
+	ListFavorites(ctx context.Context, userID, beforeID int64, limit int) ([]*ads.Favorite, error)
+
+	CreateSavedSearch(ctx context.Context, userID int64, name string, filter ads.Filter) (*ads.SavedSearch, error)
+	ListSavedSearches(ctx context.Context, userID int64) ([]*ads.SavedSearch, error)
+	UpdateSavedSearch(ctx context.Context, userID, id int64, name string, filter ads.Filter) (*ads.SavedSearch, error)
+	DeleteSavedSearch(ctx context.Context, userID, id int64) error
+	ListNotifications(ctx context.Context, userID, beforeID int64, limit int) ([]*ads.Notification, error)
+
+	// ContactAuthor начинает переписку с автором объявления или продолжает уже начатую
+	ContactAuthor(ctx context.Context, adID int64, text string) (*ads.Message, error)
+	SendMessage(ctx context.Context, conversationID int64, text string) (*ads.Message, error)
+	ListConversations(ctx context.Context, userID, adID, beforeID int64, limit int) ([]*ads.Conversation, error)
+	ListMessages(ctx context.Context, conversationID, beforeID int64, limit int) ([]*ads.Message, error)
+	MarkRead(ctx context.Context, conversationID, upToID int64) (*ads.Conversation, error)
+	ListInbox(ctx context.Context, userID, beforeID int64, limit int) ([]*ads.InboxEntry, error)
+
+	// Вебхуки партнёров; Secret подписки заполнен только в ответе CreateWebhook
+	CreateWebhook(ctx context.Context, url string, events []ads.EventType) (*ads.Webhook, error)
+	GetWebhook(ctx context.Context, id int64) (*ads.Webhook, error)
+	ListWebhooks(ctx context.Context) ([]*ads.Webhook, error)
+	UpdateWebhook(ctx context.Context, id int64, url string, events []ads.EventType, active bool) (*ads.Webhook, error)
+	DeleteWebhook(ctx context.Context, id int64) error
+	ListWebhookDeliveries(ctx context.Context, webhookID int64, status ads.DeliveryStatus, beforeID int64, limit int) ([]*ads.WebhookDelivery, error)
+	// RetryWebhookDelivery ставит недоставленное событие на отправку заново с обнулённым счётчиком попыток
+	RetryWebhookDelivery(ctx context.Context, webhookID, deliveryID int64) (*ads.WebhookDelivery, error)
+
+	// Subscribe подписывает вызывающего на темы событий; подписку нужно закрыть
+	Subscribe(ctx context.Context, topics []string) (*pubsub.Subscription, error)
+
+	ListCategories(ctx context.Context) ([]*ads.CategoryNode, error)
+	CreateCategory(ctx context.Context, name string, parentID int64) (*ads.Category, error)
+	UpdateCategory(ctx context.Context, id int64, name string, parentID int64) (*ads.Category, error)
+	DeleteCategory(ctx context.Context, id int64) error
 
 	CreateUser(ctx context.Context, first_name, second_name, nickname, password, email, phone string) (*ads.User, error)
-	LoginUser(ctx context.Context, nickname, password string) (string, error)
-	UpdateUser(ctx context.Context, first_name, second_name, email, phone string, UserID int64) (*ads.User, error)
+	LoginUser(ctx context.Context, nickname, password string) (*auth.TokenPair, error)
+	RefreshToken(ctx context.Context, refreshToken string) (*auth.TokenPair, error)
+	LogoutUser(ctx context.Context, refreshToken string) error
+	Authenticate(ctx context.Context, token string) (auth.Principal, error)
+	PublicKeys(ctx context.Context) auth.JWKS
+	UpdateUser(ctx context.Context, first_name, second_name, email, phone string, id int64) (*ads.User, error)
 	GetUser(ctx context.Context, UserID int64) (*ads.User, error)
-	DeleteUser(ctx context.Context, id int64, UserID int64) error
+	DeleteUser(ctx context.Context, id int64) error
+	SetUserRole(ctx context.Context, id int64, role ads.Role) (*ads.User, error)
 }
 
 type localApp struct {
-	repo  Repository
-	users Users
+	repo      Repository
+	index     SearchIndex
+	blobs     BlobStore
+	imageURL  string
+	users     Users
+	tokens    Tokens
+	favorites Favorites
+	searches  SavedSearches
+	notifier  Notifier
+	chats     Conversations
+	hooks     Webhooks
+	hub       *pubsub.Hub
+	keys      *auth.KeySet
+	passwords auth.PasswordHasher
+	policy    Policy
+	adTTL     time.Duration
+
+	moderation ModerationMode
+	rules      moderation.Rules
 }
 
-func (a *localApp) DeleteAd(ctx context.Context, id int64, UserID int64) error {
-	if _, err := a.users.Get(UserID); err != nil {
-		return err
+type Option func(a *localApp)
+
+// WithPasswordHasher задаёт параметры хеширования паролей
+func WithPasswordHasher(h auth.PasswordHasher) Option {
+	return func(a *localApp) {
+		a.passwords = h
+	}
+}
+
+// WithKeys задаёт ключи подписи токенов. Без них каждый запуск
+// генерирует свой случайный ключ.
+func WithKeys(keys *auth.KeySet) Option {
+	return func(a *localApp) {
+		a.keys = keys
 	}
+}
+
+// WithSearchIndex задаёт поисковый индекс. Без него индекс строится в памяти при запуске.
+func WithSearchIndex(index SearchIndex) Option {
+	return func(a *localApp) {
+		a.index = index
+	}
+}
 
+// WithPolicy заменяет правила доступа по умолчанию
+func WithPolicy(p Policy) Option {
+	return func(a *localApp) {
+		a.policy = p
+	}
+}
+
+// Возвращает пользователя, от имени которого выполняется запрос, или nil для анонимного запроса
+func (a *localApp) caller(ctx context.Context) (*ads.User, error) {
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return nil, nil
+	}
+
+	user, err := a.users.Get(principal.UserID)
+	if err != nil {
+		return nil, ads.ErrUnauthorized
+	}
+
+	return user, nil
+}
+
+// Проверяет по политике, может ли вызывающий выполнить действие над ресурсом владельца ownerID.
+// Для действий, требующих входа, возвращаемый пользователь не nil.
+func (a *localApp) authorize(ctx context.Context, action Action, ownerID int64) (*ads.User, error) {
+	user, err := a.caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.policy.Authorize(user, action, ownerID); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (a *localApp) DeleteAd(ctx context.Context, id int64) error {
 	ad, err := a.repo.Get(id)
 
 	if err != nil {
 		return err
 	}
 
-	if ad.AuthorID != UserID {
-		return ads.ErrAccessDenied
+	if _, err := a.authorize(ctx, ActionDeleteAd, ad.AuthorID); err != nil {
+		return err
 	}
 
-	err = a.repo.DeleteAd(id)
+	err = a.repo.DeleteAd(id, ads.AdDeleted{AdID: ad.ID, AuthorID: ad.AuthorID})
 
 	if err != nil {
 		return err
 	}
 
+	if err := a.decidePending(id, ads.DecisionWithdrawn, "", 0); err != nil {
+		return err
+	}
+
+	if err := a.removeAdImages(ctx, id); err != nil {
+		return err
+	}
+
+	if err := a.favorites.RemoveAdFavorites(id); err != nil {
+		return err
+	}
+
+	if err := a.chats.RemoveAdConversations(id); err != nil {
+		return err
+	}
+
+	if err := a.index.Remove(id); err != nil {
+		return err
+	}
+
+	a.publishAd(pubsub.EventAdDeleted, &ads.Ad{ID: ad.ID, AuthorID: ad.AuthorID, Status: ad.Status})
 	return nil
 }
 
-func (a *localApp) ListAds(ctx context.Context, filter ads.Filter) ([]*ads.Ad, error) {
-	ads, err := a.repo.GetAds(filter)
+// В индексе только опубликованные объявления
+func (a *localApp) reindex(ad *ads.Ad) error {
+	if ad.Published {
+		return a.index.Index(ad)
+	}
+	return a.index.Remove(ad.ID)
+}
+
+// adsByID читает объявления одним запросом и раскладывает их по ID
+func (a *localApp) adsByID(ids []int64) (map[int64]*ads.Ad, error) {
+	list, err := a.repo.GetMany(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int64]*ads.Ad, len(list))
+	for _, ad := range list {
+		byID[ad.ID] = ad
+	}
+	return byID, nil
+}
+
+func (a *localApp) SearchAds(ctx context.Context, query string, limit, offset int) ([]*ads.SearchResult, error) {
+	if _, err := a.authorize(ctx, ActionListAds, 0); err != nil {
+		return nil, err
+	}
+
+	if limit < 0 || offset < 0 {
+		return nil, ads.ErrBadRequest
+	}
+	if limit == 0 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+
+	hits, err := a.index.Search(query, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.ID
+	}
+	byID, err := a.adsByID(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	// Индекс может ещё помнить удалённое объявление, такие попадания пропускаются
+	results := make([]*ads.SearchResult, 0, len(hits))
+	found := make([]*ads.Ad, 0, len(hits))
+	for _, hit := range hits {
+		ad, ok := byID[hit.ID]
+		if !ok {
+			continue
+		}
+		found = append(found, ad)
+		results = append(results, &ads.SearchResult{
+			Score:   hit.Score,
+			Snippet: search.Snippet(ad.Text, query),
+		})
+	}
 
+	found, err = a.withDetails(found)
 	if err != nil {
 		return nil, err
 	}
+	for i := range results {
+		results[i].Ad = found[i]
+	}
 
-	return ads, nil
+	return results, nil
 }
 
-func (a *localApp) CreateAd(ctx context.Context, title, text string, authorID int64) (*ads.Ad, error) {
-	if _, err := a.users.Get(authorID); err != nil {
+// Размер страницы, если клиент его не указал, и наибольший допустимый
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 100
+)
+
+// pageLimit проверяет курсор и размер страницы списков с ключом по ID; 0 - размер по умолчанию
+func pageLimit(cursorID int64, limit int) (int, error) {
+	if limit < 0 || cursorID < 0 {
+		return 0, ads.ErrBadRequest
+	}
+	if limit == 0 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+	return limit, nil
+}
+
+// checkFilter проверяет условия фильтра и приводит код валюты к виду ISO 4217
+func checkFilter(filter *ads.Filter) error {
+	if near := filter.Near; near != nil && (!near.Center.Valid() || near.RadiusKm < 0) {
+		return ads.ErrBadRequest
+	}
+
+	// Цены сравниваются только в одной валюте
+	filter.Currency = ads.NormalizeCurrency(filter.Currency)
+	if filter.Currency != "" && !ads.ValidCurrency(filter.Currency) {
+		return ads.ErrBadRequest
+	}
+	if !filter.Price.Empty() && filter.Currency == "" {
+		return ads.ErrBadRequest
+	}
+	return nil
+}
+
+// publicOnly оставляет в фильтре статусы, видные всем; без условия на статус остаются только опубликованные.
+// false - ни один из запрошенных статусов не виден.
+func publicOnly(filter *ads.Filter) bool {
+	switch {
+	case len(filter.Statuses) > 0:
+		var public []ads.Status
+		for _, status := range filter.Statuses {
+			if status.Public() {
+				public = append(public, status)
+			}
+		}
+		filter.Statuses = public
+		return len(public) > 0
+	case filter.Published == nil:
+		filter.Statuses = []ads.Status{ads.StatusPublished}
+	default:
+		filter.Statuses = ads.PublicStatuses
+	}
+	return true
+}
+
+func (a *localApp) ListAds(ctx context.Context, filter ads.Filter, page ads.Page) ([]*ads.Ad, *ads.Cursor, error) {
+	user, err := a.authorize(ctx, ActionListAds, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := checkFilter(&filter); err != nil {
+		return nil, nil, err
+	}
+
+	// Скрытые объявления видят модераторы и автор, если он ищет только среди своих
+	var ownerID int64
+	if len(filter.AuthorIDs) == 1 {
+		ownerID = filter.AuthorIDs[0]
+	}
+	if a.policy.Authorize(user, ActionReadHiddenAd, ownerID) != nil && !publicOnly(&filter) {
+		return []*ads.Ad{}, nil, nil
+	}
+
+	// Поиск рядом с точкой по умолчанию упорядочен по расстоянию от неё
+	if near := filter.Near; near != nil {
+		if page.Sort.Field == "" {
+			page.Sort.Field = ads.SortDistance
+		}
+		if page.Sort.Field == ads.SortDistance {
+			page.Sort.Origin = near.Center
+		}
+	} else if page.Sort.Field == ads.SortDistance {
+		return nil, nil, ads.ErrBadRequest
+	}
+
+	if page.Sort.Field == "" {
+		page.Sort.Field = ads.SortCreationTime
+	}
+	if !page.Sort.Field.Valid() || page.Limit < 0 {
+		return nil, nil, ads.ErrBadRequest
+	}
+	// Курсор годится только для той сортировки, в которой он выдан
+	if page.After != nil && page.After.Sort != page.Sort {
+		return nil, nil, ads.ErrBadRequest
+	}
+
+	if page.Limit == 0 {
+		page.Limit = DefaultPageSize
+	}
+	if page.Limit > MaxPageSize {
+		page.Limit = MaxPageSize
+	}
+
+	if err := a.expandCategories(&filter); err != nil {
+		return nil, nil, err
+	}
+
+	list, next, err := a.repo.GetAds(filter, page)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	list, err = a.withDetails(list)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return list, next, nil
+}
+
+func validateLocation(loc *ads.Location) error {
+	if loc == nil {
+		return nil
+	}
+	if !loc.Valid() || validator.Validate(*loc) != nil {
+		return ads.ErrBadRequest
+	}
+	return nil
+}
+
+func (a *localApp) CreateAd(ctx context.Context, draft ads.Draft) (*ads.Ad, error) {
+	user, err := a.authorize(ctx, ActionCreateAd, 0)
+	if err != nil {
 		return nil, err
 	}
 
 	ad := &ads.Ad{
-		Title:     title,
-		Text:      text,
-		AuthorID:  authorID,
-		Published: false,
+		CategoryID: ads.DefaultCategoryID,
+		Currency:   ads.DefaultCurrency,
+		AuthorID:   user.ID,
+		Published:  false,
+		Status:     ads.StatusDraft,
 	}
+	draft.Currency = ads.NormalizeCurrency(draft.Currency)
+	draft.Apply(ad)
 
 	if err := validator.Validate(*ad); err != nil {
 		return nil, ads.ErrBadRequest
 	}
+	if err := ads.ValidatePrice(ad.Price, ad.Currency); err != nil {
+		return nil, err
+	}
+	if err := validateLocation(draft.Location); err != nil {
+		return nil, err
+	}
+
+	if err := a.checkAdCategory(ad.CategoryID); err != nil {
+		return nil, err
+	}
 
-	err := a.repo.Create(ad)
+	err = a.repo.Create(ad, ads.AdCreated{Ad: ad})
 	if err != nil {
 		return nil, err
 	}
@@ -106,63 +659,120 @@ func (a *localApp) CreateAd(ctx context.Context, title, text string, authorID in
 }
 
 func (a *localApp) GetAd(ctx context.Context, id int64) (*ads.Ad, error) {
-	return a.repo.Get(id)
-}
+	ad, err := a.repo.Get(id)
+	if err != nil {
+		return nil, err
+	}
 
-func (a *localApp) ChangeAdStatus(ctx context.Context, id, UserID int64, published bool) (*ads.Ad, error) {
-	if _, err := a.users.Get(UserID); err != nil {
+	if _, err := a.authorize(ctx, ActionReadAd, ad.AuthorID); err != nil {
 		return nil, err
 	}
+	if !ad.AdStatus().Public() {
+		if _, err := a.authorize(ctx, ActionReadHiddenAd, ad.AuthorID); err != nil {
+			return nil, err
+		}
+	}
+
+	return a.withAdDetails(ad)
+}
 
+// ChangeAdStatus - прежний переключатель публикации: true публикует, false возвращает в черновики.
+// Повторный вызов с тем же значением ничего не меняет.
+func (a *localApp) ChangeAdStatus(ctx context.Context, id int64, published bool) (*ads.Ad, error) {
 	ad, err := a.repo.Get(id)
 
 	if err != nil {
 		return nil, err
 	}
 
-	if ad.AuthorID != UserID {
-		return nil, ads.ErrAccessDenied
+	// Снять с публикации может и модератор, опубликовать - только автор
+	action, status := ActionUnpublishAd, ads.StatusDraft
+	if published {
+		action, status = ActionPublishAd, ads.StatusPublished
 	}
 
-	ad, err = a.repo.UpdateStatus(id, published)
-	if err != nil {
-		return nil, err
+	// Опубликовать объявление, которое уже ждёт проверки, - значит ничего не менять
+	if ad.AdStatus() == status || (published && ad.AdStatus() == ads.StatusPendingReview) {
+		if _, err := a.authorize(ctx, action, ad.AuthorID); err != nil {
+			return nil, err
+		}
+		return a.withAdDetails(ad)
 	}
-	return ad, nil
+
+	return a.TransitionAd(ctx, id, status)
 }
 
-func (a *localApp) UpdateAd(ctx context.Context, id, UserID int64, title, text string) (*ads.Ad, error) {
-	if _, err := a.users.Get(UserID); err != nil {
+func (a *localApp) UpdateAd(ctx context.Context, id int64, draft ads.Draft) (*ads.Ad, error) {
+	ad, err := a.repo.Get(id)
+	if err != nil {
 		return nil, err
 	}
 
-	ad, err := a.repo.Get(id)
+	user, err := a.authorize(ctx, ActionUpdateAd, ad.AuthorID)
 	if err != nil {
 		return nil, err
 	}
 
-	if ad.AuthorID != UserID {
-		return nil, ads.ErrAccessDenied
+	return a.editAd(ctx, ad, draft, &ads.Revision{AuthorID: user.ID})
+}
+
+// editAd применяет черновик к объявлению и записывает ревизию. Объявление после правки проверяется
+// целиком до записи, поэтому неудачная правка ничего не меняет в хранилище.
+func (a *localApp) editAd(ctx context.Context, ad *ads.Ad, draft ads.Draft, revision *ads.Revision) (*ads.Ad, error) {
+	// Проданное и архивное объявление уже не меняется
+	if status := ad.AdStatus(); status == ads.StatusSold || status == ads.StatusArchived {
+		return nil, ads.ErrBadRequest
 	}
-	prevTitle, prevText := ad.Title, ad.Text
 
-	ad, err = a.repo.Update(id, title, text)
+	// Незаданные поля остаются прежними
+	draft.Currency = ads.NormalizeCurrency(draft.Currency)
+	next := *ad
+	draft.Apply(&next)
 
-	if err := validator.Validate(*ad); err != nil {
-		if _, err = a.repo.Update(id, prevTitle, prevText); err != nil {
-			panic("something went wrong")
-		}
+	if err := validator.Validate(next); err != nil {
 		return nil, ads.ErrBadRequest
 	}
+	if err := ads.ValidatePrice(next.Price, next.Currency); err != nil {
+		return nil, err
+	}
+	if err := validateLocation(draft.Location); err != nil {
+		return nil, err
+	}
+	if next.CategoryID != ad.CategoryID {
+		if err := a.checkAdCategory(next.CategoryID); err != nil {
+			return nil, err
+		}
+	}
 
+	ad, err := a.repo.Update(ad.ID, draft, revision, ads.AdUpdated{Ad: &next})
 	if err != nil {
 		return nil, err
 	}
 
+	// Правка опубликованного объявления тоже проходит модерацию
+	if ad.AdStatus() == ads.StatusPublished {
+		if flags := a.rules.Check(ad); a.needsReview(flags) {
+			return a.submitForReview(ctx, ad, flags)
+		}
+	}
+
+	if err := a.reindex(ad); err != nil {
+		return nil, err
+	}
+
+	if ad, err = a.withAdDetails(ad); err != nil {
+		return nil, err
+	}
+
+	a.publishAd(pubsub.EventAdUpdated, ad)
 	return ad, nil
 }
 
 func (a *localApp) CreateUser(ctx context.Context, first_name, second_name, nickname, password, email, phone string) (*ads.User, error) {
+	if _, err := a.authorize(ctx, ActionCreateUser, 0); err != nil {
+		return nil, err
+	}
+
 	user := &ads.User{
 		FirstName:  first_name,
 		SecondName: second_name,
@@ -170,13 +780,20 @@ func (a *localApp) CreateUser(ctx context.Context, first_name, second_name, nick
 		Password:   password,
 		Email:      email,
 		Phone:      phone,
+		Role:       ads.RoleUser,
 	}
 
 	if err := validator.Validate(*user); err != nil {
 		return nil, ads.ErrBadRequest
 	}
 
-	err := a.users.Create(user)
+	hash, err := a.passwords.Hash(password)
+	if err != nil {
+		return nil, err
+	}
+	user.Password = hash
+
+	err = a.users.Create(user, ads.UserCreated{User: user})
 	if err != nil {
 		return nil, ads.ErrBadRequest
 	}
@@ -184,62 +801,285 @@ func (a *localApp) CreateUser(ctx context.Context, first_name, second_name, nick
 	return user, nil
 }
 
-func (a *localApp) LoginUser(ctx context.Context, nickname, password string) (string, error) {
+func (a *localApp) LoginUser(ctx context.Context, nickname, password string) (*auth.TokenPair, error) {
 	user, err := a.users.GetByNickname(nickname)
 	if err != nil {
-		return "error", ads.ErrBadRequest
+		return nil, ads.ErrBadRequest
 	}
 
-	if user.Password != password {
-		return "error", ads.ErrAccessDenied
+	ok, rehash := a.passwords.Verify(user.Password, password)
+	if !ok {
+		return nil, ads.ErrAccessDenied
 	}
 
-	return auth.GenerateJWT(user.ID)
+	if rehash {
+		// Ошибка пересчёта хеша не мешает входу: попробуем снова в следующий раз
+		if hash, err := a.passwords.Hash(password); err == nil {
+			_ = a.users.UpdatePassword(user.ID, hash)
+		}
+	}
+
+	family, err := auth.NewFamily()
+	if err != nil {
+		return nil, err
+	}
+
+	return a.issueTokens(user, family)
 }
 
-func (a *localApp) UpdateUser(ctx context.Context, first_name, second_name, email, phone string, UserID int64) (*ads.User, error) {
-	user, err := a.users.Get(UserID)
+// Выпускает access-токен и следующий refresh-токен цепочки
+func (a *localApp) issueTokens(user *ads.User, family string) (*auth.TokenPair, error) {
+	access, err := a.keys.GenerateJWT(user.ID, string(user.UserRole()))
 	if err != nil {
 		return nil, err
 	}
 
-	prevFirst, prevSecond, prevEmail, prevPhone := user.FirstName, user.SecondName, user.Email, user.Phone
+	refresh, record, err := auth.NewRefreshToken(user.ID, family)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.tokens.CreateRefresh(record); err != nil {
+		return nil, err
+	}
 
-	user, err = a.users.Update(UserID, first_name, second_name, email, phone)
+	return &auth.TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		ExpiresIn:    auth.AccessTokenTTL,
+	}, nil
+}
 
+func (a *localApp) RefreshToken(ctx context.Context, refreshToken string) (*auth.TokenPair, error) {
+	record, err := a.tokens.UseRefresh(auth.HashRefreshToken(refreshToken))
 	if err != nil {
-		return nil, ads.ErrBadRequest
+		return nil, ads.ErrUnauthorized
 	}
 
-	if err := validator.Validate(*user); err != nil {
-		if _, err = a.users.Update(UserID, prevFirst, prevSecond, prevEmail, prevPhone); err != nil {
-			panic("something went wrong")
+	if record.Used || record.Revoked {
+		// Токен предъявлен повторно - возможно, его украли. Отзываем всю цепочку,
+		// пользователю придётся войти заново.
+		if err := a.tokens.RevokeFamily(record.Family); err != nil {
+			return nil, err
 		}
-		return nil, ads.ErrBadRequest
+		return nil, ads.ErrUnauthorized
+	}
+
+	if time.Now().After(record.ExpiresAt) {
+		return nil, ads.ErrUnauthorized
 	}
 
+	// Роль берётся из базы: новый access-токен отражает её текущее значение
+	user, err := a.users.Get(record.UserID)
+	if err != nil {
+		return nil, ads.ErrUnauthorized
+	}
+
+	return a.issueTokens(user, record.Family)
+}
+
+func (a *localApp) LogoutUser(ctx context.Context, refreshToken string) error {
+	record, err := a.tokens.GetRefresh(auth.HashRefreshToken(refreshToken))
 	if err != nil {
+		return ads.ErrUnauthorized
+	}
+
+	// Выйти можно и с одним refresh-токеном, но чужой сессией распоряжаться нельзя
+	principal, ok := auth.FromContext(ctx)
+	if ok && principal.UserID != record.UserID {
+		return ads.ErrAccessDenied
+	}
+
+	if err := a.tokens.RevokeFamily(record.Family); err != nil {
+		return err
+	}
+
+	if ok {
+		return a.tokens.RevokeAccess(&auth.RevokedToken{
+			ID:        principal.TokenID,
+			ExpiresAt: principal.ExpiresAt,
+		})
+	}
+
+	return nil
+}
+
+func (a *localApp) Authenticate(ctx context.Context, token string) (auth.Principal, error) {
+	claims, err := a.keys.ParseToken(token)
+	if err != nil {
+		return auth.Principal{}, ads.ErrUnauthorized
+	}
+
+	revoked, err := a.tokens.IsAccessRevoked(claims.RegisteredClaims.ID)
+	if err != nil {
+		return auth.Principal{}, err
+	}
+	if revoked {
+		return auth.Principal{}, ads.ErrUnauthorized
+	}
+
+	return auth.Principal{
+		UserID:    claims.ID,
+		Role:      ads.Role(claims.Role),
+		TokenID:   claims.RegisteredClaims.ID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}, nil
+}
+
+func (a *localApp) PublicKeys(ctx context.Context) auth.JWKS {
+	return a.keys.JWKS()
+}
+
+// userProfile - поля пользователя, которые меняет UpdateUser, с ограничениями ads.User.
+// Пароль не проверяется: в хранилище лежит его хеш, который длиннее ограничения на пароль из запроса.
+type userProfile struct {
+	FirstName  string `validate:"min:1;max:100"`
+	SecondName string `validate:"min:1;max:100"`
+	Email      string `validate:"min:1;max:100"`
+	Phone      string `validate:"min:1;max:100"`
+}
+
+func (a *localApp) UpdateUser(ctx context.Context, first_name, second_name, email, phone string, UserID int64) (*ads.User, error) {
+	if _, err := a.authorize(ctx, ActionUpdateUser, UserID); err != nil {
 		return nil, err
 	}
 
+	// Поля правки проверяются до записи, поэтому неудачная правка ничего не меняет
+	if err := validator.Validate(userProfile{first_name, second_name, email, phone}); err != nil {
+		return nil, ads.ErrBadRequest
+	}
+
+	user, err := a.users.Update(UserID, first_name, second_name, email, phone, ads.UserUpdated{UserID: UserID, FirstName: first_name, SecondName: second_name})
+	if err != nil {
+		return nil, ads.ErrBadRequest
+	}
+
 	return user, nil
 }
 
 func (a *localApp) GetUser(ctx context.Context, UserID int64) (*ads.User, error) {
-	return a.users.Get(UserID)
+	caller, err := a.authorize(ctx, ActionReadUser, UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := a.users.Get(UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Email и телефон видят только сам пользователь и администратор, остальные пишут через переписку
+	if a.policy.Authorize(caller, ActionReadContacts, UserID) != nil {
+		redacted := *user
+		redacted.Email, redacted.Phone = "", ""
+		return &redacted, nil
+	}
+	return user, nil
 }
 
-func (a *localApp) DeleteUser(ctx context.Context, id int64, UserID int64) error {
-	if id != UserID {
-		return ads.ErrAccessDenied
+func (a *localApp) DeleteUser(ctx context.Context, id int64) error {
+	if _, err := a.authorize(ctx, ActionDeleteUser, id); err != nil {
+		return err
+	}
+
+	if err := a.users.Delete(id, ads.UserDeleted{UserID: id}); err != nil {
+		return err
+	}
+
+	if err := a.favorites.RemoveUserFavorites(id); err != nil {
+		return err
+	}
+
+	if err := a.searches.RemoveUserSearches(id); err != nil {
+		return err
 	}
 
-	return a.users.Delete(id)
+	return a.chats.RemoveUserConversations(id)
 }
 
-func NewApp(repo Repository, users Users) App {
-	return &localApp{
-		repo:  repo,
-		users: users,
+func (a *localApp) SetUserRole(ctx context.Context, id int64, role ads.Role) (*ads.User, error) {
+	if _, err := a.authorize(ctx, ActionSetUserRole, id); err != nil {
+		return nil, err
+	}
+
+	if !role.Valid() {
+		return nil, ads.ErrBadRequest
+	}
+
+	if err := a.users.UpdateRole(id, role, ads.UserRoleChanged{UserID: id, Role: role}); err != nil {
+		return nil, err
 	}
+
+	return a.users.Get(id)
+}
+
+// Stores - хранилища приложения; все обязательны. Новое хранилище добавляется полем,
+// поэтому места вызова NewApp, которые его не задают, не меняются.
+type Stores struct {
+	Ads           Repository
+	Users         Users
+	Tokens        Tokens
+	Favorites     Favorites
+	Searches      SavedSearches
+	Conversations Conversations
+	Webhooks      Webhooks
+}
+
+func NewApp(stores Stores, opts ...Option) App {
+	if stores.Ads == nil || stores.Users == nil || stores.Tokens == nil || stores.Favorites == nil ||
+		stores.Searches == nil || stores.Conversations == nil || stores.Webhooks == nil {
+		panic("app: all stores are required")
+	}
+
+	a := &localApp{
+		repo:      stores.Ads,
+		users:     stores.Users,
+		tokens:    stores.Tokens,
+		favorites: stores.Favorites,
+		searches:  stores.Searches,
+		chats:     stores.Conversations,
+		hooks:     stores.Webhooks,
+		passwords: auth.DefaultPasswordHasher,
+		policy:    DefaultPolicy,
+		imageURL:  DefaultImageURL,
+		adTTL:     ads.PublicationTTL,
+
+		moderation: ModerationAll,
+		rules:      moderation.Default(),
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	if a.keys == nil {
+		keys, err := auth.NewDevKeySet()
+		if err != nil {
+			panic(err)
+		}
+		a.keys = keys
+	}
+
+	if a.blobs == nil {
+		a.blobs = blob.NewMemoryStore()
+	}
+
+	if a.hub == nil {
+		a.hub = pubsub.NewHub(pubsub.DefaultBuffer)
+	}
+
+	if a.index == nil {
+		a.index = search.NewMemoryIndex()
+		published, err := a.repo.GetAllAds()
+		if err != nil {
+			panic(err)
+		}
+		for _, ad := range published {
+			if err := a.index.Index(ad); err != nil {
+				panic(err)
+			}
+		}
+	}
+
+	return a
 }
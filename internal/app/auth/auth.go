@@ -1,51 +1,95 @@
 package auth
 
 import (
-	"net/http"
+	"adflow/internal/ads"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"time"
 
 	jwt "github.com/golang-jwt/jwt/v5"
 )
 
 type Claims struct {
-	ID int64 `json:"id"`
+	ID   int64  `json:"id"`
+	Role string `json:"role"`
 	jwt.RegisteredClaims
 }
 
-var JwtKey = []byte("DEFUALT_SECRET")
+// Principal - аутентифицированный пользователь, от имени которого выполняется запрос
+type Principal struct {
+	UserID int64
+	// Role - роль на момент выпуска токена; права проверяются по текущей роли из базы
+	Role ads.Role
+	// TokenID и ExpiresAt нужны, чтобы отозвать токен до истечения срока
+	TokenID   string
+	ExpiresAt time.Time
+}
+
+type principalKey struct{}
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// AccessTokenTTL - время жизни access-токена
+var AccessTokenTTL = 5 * time.Minute
+
+// Случайный идентификатор для jti и refresh-токенов
+func newID(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// GenerateJWT выпускает access-токен, подписанный текущим ключом набора
+func (s *KeySet) GenerateJWT(id int64, role string) (string, error) {
+	jti, err := newID(16)
+	if err != nil {
+		return "", err
+	}
 
-func GenerateJWT(id int64) (string, error) {
-	expirationTime := time.Now().Add(5 * time.Minute)
+	expirationTime := time.Now().Add(AccessTokenTTL)
 	claims := &Claims{
-		ID: id,
+		ID:   id,
+		Role: role,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token := jwt.NewWithClaims(s.signing.Method, claims)
+	token.Header["kid"] = s.signing.ID
 
-	return token.SignedString(JwtKey)
+	return token.SignedString(s.signing.private)
 }
 
-func ValidateToken(reqToken string, id int64) (int, string) {
+// ParseToken проверяет подпись и срок действия токена.
+// Ключ выбирается по заголовку kid, алгоритм должен совпадать с алгоритмом ключа.
+func (s *KeySet) ParseToken(reqToken string) (*Claims, error) {
 	claims := &Claims{}
 	tkn, err := jwt.ParseWithClaims(reqToken, claims, func(token *jwt.Token) (interface{}, error) {
-		return JwtKey, nil
-	})
-	if err != nil {
-		if err == jwt.ErrSignatureInvalid {
-			return http.StatusUnauthorized, "unauthorized"
+		kid, _ := token.Header["kid"].(string)
+		key := s.key(kid)
+		if key == nil || token.Method.Alg() != key.Method.Alg() {
+			return nil, ErrInvalidToken
 		}
-		return http.StatusBadRequest, "bad request"
-	}
-	if !tkn.Valid {
-		return http.StatusUnauthorized, "unauthorized"
+		return key.public, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}), jwt.WithExpirationRequired())
+	if err != nil || !tkn.Valid {
+		return nil, ErrInvalidToken
 	}
 
-	if claims.ID != id {
-		return http.StatusForbidden, "unmatch"
-	}
+	return claims, nil
+}
+
+func NewContext(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
 
-	return 200, "ok"
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
 }
@@ -0,0 +1,260 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+var ErrUnsupportedKey = errors.New("unsupported key: expected RSA (2048 bit or more) or Ed25519")
+
+// Key - ключ подписи токенов. У ключей, оставленных только для проверки
+// (после ротации), закрытой части нет.
+type Key struct {
+	ID      string
+	Method  jwt.SigningMethod
+	private crypto.PrivateKey
+	public  crypto.PublicKey
+}
+
+// JWK - открытый ключ в формате RFC 7517
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func newKey(private crypto.PrivateKey, public crypto.PublicKey) (*Key, error) {
+	key := &Key{private: private, public: public}
+
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < 2048 {
+			return nil, ErrUnsupportedKey
+		}
+		key.Method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, ErrUnsupportedKey
+	}
+
+	id, err := key.thumbprint()
+	if err != nil {
+		return nil, err
+	}
+	key.ID = id
+
+	return key, nil
+}
+
+// NewKey оборачивает закрытый ключ RSA или Ed25519
+func NewKey(private crypto.PrivateKey) (*Key, error) {
+	switch priv := private.(type) {
+	case *rsa.PrivateKey:
+		return newKey(priv, &priv.PublicKey)
+	case ed25519.PrivateKey:
+		return newKey(priv, priv.Public())
+	default:
+		return nil, ErrUnsupportedKey
+	}
+}
+
+// NewPublicKey оборачивает открытый ключ, пригодный только для проверки токенов
+func NewPublicKey(public crypto.PublicKey) (*Key, error) {
+	return newKey(nil, public)
+}
+
+// GenerateKey создаёт случайный ключ Ed25519
+func GenerateKey() (*Key, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return NewKey(private)
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data", path)
+	}
+	return block, nil
+}
+
+func parsePrivateKey(block *pem.Block) (crypto.PrivateKey, error) {
+	switch block.Type {
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, ErrUnsupportedKey
+	}
+}
+
+// LoadPrivateKey читает закрытый ключ подписи из PEM-файла (PKCS#8 или PKCS#1)
+func LoadPrivateKey(path string) (*Key, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	private, err := parsePrivateKey(block)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return NewKey(private)
+}
+
+// LoadPublicKey читает ключ проверки из PEM-файла. Подходит и закрытый ключ:
+// от него используется только открытая часть.
+func LoadPublicKey(path string) (*Key, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if block.Type != "PUBLIC KEY" {
+		private, err := parsePrivateKey(block)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		key, err := NewKey(private)
+		if err != nil {
+			return nil, err
+		}
+		return NewPublicKey(key.public)
+	}
+
+	public, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return NewPublicKey(public)
+}
+
+func (k *Key) JWK() JWK {
+	jwk := JWK{Kid: k.ID, Use: "sig", Alg: k.Method.Alg()}
+
+	switch pub := k.public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	}
+
+	return jwk
+}
+
+// Идентификатор ключа - отпечаток JWK по RFC 7638
+func (k *Key) thumbprint() (string, error) {
+	jwk := k.JWK()
+
+	// Обязательные поля в лексикографическом порядке
+	var members any
+	switch jwk.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	default:
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	}
+
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// KeySet подписывает токены текущим ключом и принимает токены,
+// подписанные любым из активных ключей, что позволяет ротировать ключи без простоя.
+type KeySet struct {
+	signing *Key
+	keys    []*Key
+}
+
+// NewKeySet принимает ключ подписи и ключи, которые ещё нужно принимать при проверке
+func NewKeySet(signing *Key, previous ...*Key) (*KeySet, error) {
+	if signing == nil || signing.private == nil {
+		return nil, errors.New("signing key must have a private part")
+	}
+
+	set := &KeySet{signing: signing, keys: []*Key{signing}}
+	for _, key := range previous {
+		if set.key(key.ID) == nil {
+			set.keys = append(set.keys, key)
+		}
+	}
+
+	return set, nil
+}
+
+// NewDevKeySet создаёт набор из случайного ключа. Токены перестают
+// приниматься после перезапуска, поэтому годится только для разработки и тестов.
+func NewDevKeySet() (*KeySet, error) {
+	key, err := GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	return NewKeySet(key)
+}
+
+func (s *KeySet) key(id string) *Key {
+	for _, key := range s.keys {
+		if key.ID == id {
+			return key
+		}
+	}
+	return nil
+}
+
+func (s *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: make([]JWK, 0, len(s.keys))}
+	for _, key := range s.keys {
+		jwks.Keys = append(jwks.Keys, key.JWK())
+	}
+	return jwks
+}
@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	argon2Prefix  = "$argon2id$"
+	saltLength    = 16
+	passwordKeyLn = 32
+)
+
+var ErrMalformedHash = errors.New("malformed password hash")
+
+// PasswordHasher хеширует пароли алгоритмом argon2id.
+// Параметры задают стоимость хеширования и могут меняться со временем:
+// хеши со старыми параметрами пересчитываются при следующем входе пользователя.
+type PasswordHasher struct {
+	Time    uint32 // число проходов
+	Memory  uint32 // объём памяти в KiB
+	Threads uint8
+}
+
+var DefaultPasswordHasher = PasswordHasher{
+	Time:    1,
+	Memory:  64 * 1024,
+	Threads: 2,
+}
+
+// Границы памяти argon2id в KiB: меньше 8 MiB хеш подбирается слишком дёшево,
+// больше 4 GiB один вход может занять всю память сервера
+const (
+	MinPasswordMemory = 8 * 1024
+	MaxPasswordMemory = 4 * 1024 * 1024
+)
+
+// HasherFromEnv возвращает DefaultPasswordHasher с параметрами из AUTH_PASSWORD_TIME
+// и AUTH_PASSWORD_MEMORY_KB; старые хеши пересчитаются при входе
+func HasherFromEnv() (PasswordHasher, error) {
+	hasher := DefaultPasswordHasher
+
+	if value, ok := os.LookupEnv("AUTH_PASSWORD_TIME"); ok {
+		t, err := strconv.ParseUint(value, 10, 32)
+		if err != nil || t < 1 {
+			return PasswordHasher{}, fmt.Errorf("AUTH_PASSWORD_TIME: expected a positive integer, got %q", value)
+		}
+		hasher.Time = uint32(t)
+	}
+
+	if value, ok := os.LookupEnv("AUTH_PASSWORD_MEMORY_KB"); ok {
+		m, err := strconv.ParseUint(value, 10, 32)
+		if err != nil || m < MinPasswordMemory || m > MaxPasswordMemory {
+			return PasswordHasher{}, fmt.Errorf("AUTH_PASSWORD_MEMORY_KB: expected %d..%d, got %q",
+				MinPasswordMemory, MaxPasswordMemory, value)
+		}
+		hasher.Memory = uint32(m)
+	}
+
+	return hasher, nil
+}
+
+// Hash возвращает хеш пароля в формате PHC со случайной солью
+func (h PasswordHasher) Hash(password string) (string, error) {
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.Time, h.Memory, h.Threads, passwordKeyLn)
+
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2Prefix, argon2.Version, h.Memory, h.Time, h.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify сравнивает пароль с сохранённым значением.
+// rehash = true, если значение получено устаревшим алгоритмом
+// (открытый текст, bcrypt) или с другими параметрами и его стоит пересчитать.
+func (h PasswordHasher) Verify(stored, password string) (ok bool, rehash bool) {
+	switch {
+	case strings.HasPrefix(stored, argon2Prefix):
+		params, salt, key, err := decodeArgon2(stored)
+		if err != nil {
+			return false, false
+		}
+		actual := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+		if subtle.ConstantTimeCompare(actual, key) != 1 {
+			return false, false
+		}
+		return true, params != h
+	case isBcrypt(stored):
+		if bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) != nil {
+			return false, false
+		}
+		return true, true
+	default:
+		// Пароль, сохранённый до появления хеширования
+		if subtle.ConstantTimeCompare([]byte(stored), []byte(password)) != 1 {
+			return false, false
+		}
+		return true, true
+	}
+}
+
+// IsHashed сообщает, является ли значение хешем, а не паролем в открытом виде
+func IsHashed(stored string) bool {
+	return strings.HasPrefix(stored, argon2Prefix) || isBcrypt(stored)
+}
+
+func isBcrypt(stored string) bool {
+	return strings.HasPrefix(stored, "$2a$") || strings.HasPrefix(stored, "$2b$") || strings.HasPrefix(stored, "$2y$")
+}
+
+func decodeArgon2(stored string) (PasswordHasher, []byte, []byte, error) {
+	var params PasswordHasher
+	var version int
+
+	// "", "argon2id", "v=19", "m=65536,t=1,p=2", salt, key
+	parts := strings.Split(stored, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, ErrMalformedHash
+	}
+
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrMalformedHash
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil {
+		return params, nil, nil, ErrMalformedHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrMalformedHash
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, ErrMalformedHash
+	}
+
+	return params, salt, key, nil
+}
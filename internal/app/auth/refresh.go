@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// RefreshTokenTTL - время жизни refresh-токена
+var RefreshTokenTTL = 30 * 24 * time.Hour
+
+// RefreshToken - серверная запись о выданном refresh-токене.
+// Сам токен не хранится, только его SHA-256.
+type RefreshToken struct {
+	ID     string
+	UserID int64
+	// Family объединяет все токены, полученные ротацией от одного входа
+	Family    string
+	ExpiresAt time.Time
+	Used      bool
+	Revoked   bool
+}
+
+// RevokedToken - access-токен, отозванный до истечения срока
+type RevokedToken struct {
+	ID        string
+	ExpiresAt time.Time
+}
+
+// TokenPair - ответ на вход и обновление токенов
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration
+}
+
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewFamily возвращает идентификатор новой цепочки refresh-токенов
+func NewFamily() (string, error) {
+	return newID(16)
+}
+
+// NewRefreshToken выпускает одноразовый refresh-токен в цепочке family
+func NewRefreshToken(userID int64, family string) (string, *RefreshToken, error) {
+	token, err := newID(32)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return token, &RefreshToken{
+		ID:        HashRefreshToken(token),
+		UserID:    userID,
+		Family:    family,
+		ExpiresAt: time.Now().Add(RefreshTokenTTL),
+	}, nil
+}
@@ -0,0 +1,193 @@
+package app
+
+import (
+	"adflow/internal/ads"
+	"context"
+
+	validator "github.com/skyberg11/args-validator"
+)
+
+// Объявление можно поместить только в существующую категорию без подкатегорий
+func (a *localApp) checkAdCategory(id int64) error {
+	list, err := a.repo.ListCategories()
+	if err != nil {
+		return err
+	}
+
+	for _, c := range list {
+		if c.ID == id {
+			if !ads.IsLeaf(list, id) {
+				return ads.ErrBadRequest
+			}
+			return nil
+		}
+	}
+	return ads.ErrBadRequest
+}
+
+// Заменяет категории фильтра их поддеревьями
+func (a *localApp) expandCategories(filter *ads.Filter) error {
+	if len(filter.CategoryIDs) == 0 {
+		return nil
+	}
+
+	list, err := a.repo.ListCategories()
+	if err != nil {
+		return err
+	}
+
+	filter.CategoryIDs = subtrees(list, filter.CategoryIDs)
+	return nil
+}
+
+func subtrees(list []*ads.Category, roots []int64) []int64 {
+	var ids []int64
+	for _, id := range roots {
+		ids = append(ids, ads.Subtree(list, id)...)
+	}
+	return ids
+}
+
+// hasAds сообщает, есть ли в категории объявления, включая черновики
+func (a *localApp) hasAds(categoryID int64) (bool, error) {
+	list, _, err := a.repo.GetAds(ads.Filter{CategoryIDs: []int64{categoryID}}, ads.Page{Limit: 1})
+	if err != nil {
+		return false, err
+	}
+	return len(list) > 0, nil
+}
+
+// Подкатегорию можно добавить только туда, где нет объявлений: иначе они окажутся не в листе.
+// «Другое» всегда остаётся листом, потому что в неё попадают объявления без категории.
+func (a *localApp) checkParent(parentID int64) error {
+	if parentID == 0 {
+		return nil
+	}
+	if parentID == ads.DefaultCategoryID {
+		return ads.ErrBadRequest
+	}
+
+	if _, err := a.repo.GetCategory(parentID); err != nil {
+		return err
+	}
+
+	busy, err := a.hasAds(parentID)
+	if err != nil {
+		return err
+	}
+	if busy {
+		return ads.ErrBadRequest
+	}
+	return nil
+}
+
+func (a *localApp) ListCategories(ctx context.Context) ([]*ads.CategoryNode, error) {
+	if _, err := a.authorize(ctx, ActionListCategories, 0); err != nil {
+		return nil, err
+	}
+
+	list, err := a.repo.ListCategories()
+	if err != nil {
+		return nil, err
+	}
+
+	counts, err := a.repo.CountPublished()
+	if err != nil {
+		return nil, err
+	}
+
+	return ads.CategoryTree(list, counts), nil
+}
+
+func (a *localApp) CreateCategory(ctx context.Context, name string, parentID int64) (*ads.Category, error) {
+	if _, err := a.authorize(ctx, ActionCreateCategory, 0); err != nil {
+		return nil, err
+	}
+
+	category := &ads.Category{
+		ParentID: parentID,
+		Name:     name,
+	}
+
+	if err := validator.Validate(*category); err != nil {
+		return nil, ads.ErrBadRequest
+	}
+
+	if err := a.checkParent(parentID); err != nil {
+		return nil, err
+	}
+
+	if err := a.repo.CreateCategory(category); err != nil {
+		return nil, err
+	}
+
+	return category, nil
+}
+
+// UpdateCategory переименовывает категорию и переносит её под другого родителя
+func (a *localApp) UpdateCategory(ctx context.Context, id int64, name string, parentID int64) (*ads.Category, error) {
+	if _, err := a.authorize(ctx, ActionUpdateCategory, 0); err != nil {
+		return nil, err
+	}
+
+	if err := validator.Validate(ads.Category{ID: id, ParentID: parentID, Name: name}); err != nil {
+		return nil, ads.ErrBadRequest
+	}
+
+	category, err := a.repo.GetCategory(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if parentID != category.ParentID {
+		if id == ads.DefaultCategoryID {
+			return nil, ads.ErrBadRequest
+		}
+
+		list, err := a.repo.ListCategories()
+		if err != nil {
+			return nil, err
+		}
+		// Категорию нельзя перенести в её собственное поддерево
+		for _, v := range ads.Subtree(list, id) {
+			if v == parentID {
+				return nil, ads.ErrBadRequest
+			}
+		}
+
+		if err := a.checkParent(parentID); err != nil {
+			return nil, err
+		}
+	}
+
+	return a.repo.UpdateCategory(id, name, parentID)
+}
+
+// DeleteCategory удаляет пустую категорию без подкатегорий
+func (a *localApp) DeleteCategory(ctx context.Context, id int64) error {
+	if _, err := a.authorize(ctx, ActionDeleteCategory, 0); err != nil {
+		return err
+	}
+
+	if id == ads.DefaultCategoryID {
+		return ads.ErrBadRequest
+	}
+
+	list, err := a.repo.ListCategories()
+	if err != nil {
+		return err
+	}
+	if !ads.IsLeaf(list, id) {
+		return ads.ErrBadRequest
+	}
+
+	busy, err := a.hasAds(id)
+	if err != nil {
+		return err
+	}
+	if busy {
+		return ads.ErrBadRequest
+	}
+
+	return a.repo.DeleteCategory(id)
+}
@@ -0,0 +1,211 @@
+package app
+
+import (
+	"adflow/internal/ads"
+	"adflow/internal/pubsub"
+	"context"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxMessageLength - наибольшая длина сообщения в символах
+const MaxMessageLength = 2000
+
+func checkMessage(text string) (string, error) {
+	text = strings.TrimSpace(text)
+	if text == "" || utf8.RuneCountInString(text) > MaxMessageLength {
+		return "", ads.ErrBadRequest
+	}
+	return text, nil
+}
+
+// participant возвращает вызывающего и переписку, если он в ней участвует.
+// Чужие переписки закрыты и для администраторов.
+func (a *localApp) participant(ctx context.Context, action Action, conversationID int64) (*ads.User, *ads.Conversation, error) {
+	user, err := a.authorize(ctx, action, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conversation, err := a.chats.GetConversation(conversationID, user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !conversation.HasParticipant(user.ID) {
+		return nil, nil, ads.ErrAccessDenied
+	}
+	return user, conversation, nil
+}
+
+func (a *localApp) addMessage(conversation *ads.Conversation, senderID int64, text string) (*ads.Message, error) {
+	message := &ads.Message{
+		ConversationID: conversation.ID,
+		SenderID:       senderID,
+		Text:           text,
+		CreationTime:   time.Now().UTC(),
+	}
+	if err := a.chats.AddMessage(message); err != nil {
+		return nil, err
+	}
+
+	a.publishConversation(conversation, pubsub.Event{Type: pubsub.EventMessage, Time: message.CreationTime, Message: message})
+	return message, nil
+}
+
+// ContactAuthor пишет автору опубликованного объявления; переписка по объявлению у покупателя одна
+func (a *localApp) ContactAuthor(ctx context.Context, adID int64, text string) (*ads.Message, error) {
+	user, err := a.authorize(ctx, ActionSendMessage, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if text, err = checkMessage(text); err != nil {
+		return nil, err
+	}
+
+	ad, err := a.repo.Get(adID)
+	if err != nil {
+		return nil, err
+	}
+	if !ad.Published || ad.AuthorID == user.ID {
+		return nil, ads.ErrBadRequest
+	}
+
+	conversation := &ads.Conversation{
+		AdID:         ad.ID,
+		BuyerID:      user.ID,
+		AuthorID:     ad.AuthorID,
+		CreationTime: time.Now().UTC(),
+	}
+	if err := a.chats.StartConversation(conversation); err != nil {
+		return nil, err
+	}
+
+	return a.addMessage(conversation, user.ID, text)
+}
+
+func (a *localApp) SendMessage(ctx context.Context, conversationID int64, text string) (*ads.Message, error) {
+	user, conversation, err := a.participant(ctx, ActionSendMessage, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if text, err = checkMessage(text); err != nil {
+		return nil, err
+	}
+
+	return a.addMessage(conversation, user.ID, text)
+}
+
+// ListConversations возвращает переписки пользователя - и как покупателя, и как автора, - с последним сообщением
+// и числом непрочитанных. Объявление подставляется, пока оно опубликовано, а автору - всегда.
+func (a *localApp) ListConversations(ctx context.Context, userID, adID, beforeID int64, limit int) ([]*ads.Conversation, error) {
+	if _, err := a.authorize(ctx, ActionManageConversations, userID); err != nil {
+		return nil, err
+	}
+
+	limit, err := pageLimit(beforeID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := a.chats.ListConversations(userID, adID, beforeID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []*ads.Ad
+	var owners []*ads.Conversation
+	for _, conversation := range list {
+		ad, err := a.repo.Get(conversation.AdID)
+		if err != nil || (!ad.Published && ad.AuthorID != userID) {
+			continue
+		}
+		found = append(found, ad)
+		owners = append(owners, conversation)
+	}
+
+	if found, err = a.withDetails(found); err != nil {
+		return nil, err
+	}
+	for i := range owners {
+		owners[i].Ad = found[i]
+	}
+	return list, nil
+}
+
+func (a *localApp) ListMessages(ctx context.Context, conversationID, beforeID int64, limit int) ([]*ads.Message, error) {
+	if _, _, err := a.participant(ctx, ActionReadConversation, conversationID); err != nil {
+		return nil, err
+	}
+
+	limit, err := pageLimit(beforeID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.chats.ListMessages(conversationID, beforeID, limit)
+}
+
+// MarkRead отмечает прочитанными сообщения собеседника до upToID включительно (0 - все)
+func (a *localApp) MarkRead(ctx context.Context, conversationID, upToID int64) (*ads.Conversation, error) {
+	user, conversation, err := a.participant(ctx, ActionReadConversation, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if upToID < 0 {
+		return nil, ads.ErrBadRequest
+	}
+
+	readTime := time.Now().UTC()
+	marked, err := a.chats.MarkRead(conversationID, user.ID, upToID, readTime)
+	if err != nil {
+		return nil, err
+	}
+
+	// Собеседнику квитанция нужна, только если что-то действительно прочитано
+	if marked > 0 {
+		receipt := &ads.ReadReceipt{ConversationID: conversationID, ReaderID: user.ID, UpToID: upToID, ReadTime: readTime}
+		a.publishConversation(conversation, pubsub.Event{Type: pubsub.EventMessagesRead, Time: readTime, Receipt: receipt})
+	}
+
+	return a.chats.GetConversation(conversationID, user.ID)
+}
+
+// ListInbox группирует переписки по объявлениям автора: сначала объявления с самыми свежими сообщениями
+func (a *localApp) ListInbox(ctx context.Context, userID, beforeID int64, limit int) ([]*ads.InboxEntry, error) {
+	if _, err := a.authorize(ctx, ActionManageConversations, userID); err != nil {
+		return nil, err
+	}
+
+	limit, err := pageLimit(beforeID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := a.chats.ListInbox(userID, beforeID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []*ads.Ad
+	var owners []*ads.InboxEntry
+	for _, entry := range entries {
+		ad, err := a.repo.Get(entry.AdID)
+		if err != nil {
+			continue
+		}
+		found = append(found, ad)
+		owners = append(owners, entry)
+	}
+
+	if found, err = a.withDetails(found); err != nil {
+		return nil, err
+	}
+	for i := range owners {
+		owners[i].Ad = found[i]
+	}
+	return entries, nil
+}
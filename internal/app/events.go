@@ -0,0 +1,81 @@
+package app
+
+import (
+	"adflow/internal/ads"
+	"adflow/internal/pubsub"
+	"context"
+)
+
+// MaxSubscriptionTopics - сколько тем можно слушать одной подпиской
+const MaxSubscriptionTopics = 20
+
+// WithHub задаёт шину событий; без неё приложение создаёт свою
+func WithHub(hub *pubsub.Hub) Option {
+	return func(a *localApp) {
+		a.hub = hub
+	}
+}
+
+// Subscribe проверяет права на каждую тему и подписывает на все сразу.
+// Тему опубликованного объявления слушает любой, неопубликованного - автор и модераторы,
+// тему пользователя - только он сам.
+func (a *localApp) Subscribe(ctx context.Context, topics []string) (*pubsub.Subscription, error) {
+	if len(topics) == 0 || len(topics) > MaxSubscriptionTopics {
+		return nil, ads.ErrBadRequest
+	}
+
+	seen := make(map[string]bool, len(topics))
+	var unique []string
+	for _, topic := range topics {
+		kind, id, err := pubsub.ParseTopic(topic)
+		if err != nil {
+			return nil, err
+		}
+
+		switch kind {
+		case pubsub.TopicAds:
+			ad, err := a.repo.Get(id)
+			if err != nil {
+				return nil, err
+			}
+			action := ActionReadAd
+			if !ad.Published {
+				action = ActionSubscribeAd
+			}
+			if _, err := a.authorize(ctx, action, ad.AuthorID); err != nil {
+				return nil, err
+			}
+		case pubsub.TopicUsers:
+			if _, err := a.authorize(ctx, ActionSubscribeUser, id); err != nil {
+				return nil, err
+			}
+		}
+
+		if !seen[topic] {
+			seen[topic] = true
+			unique = append(unique, topic)
+		}
+	}
+
+	return a.hub.Subscribe(unique...), nil
+}
+
+// publishAd рассылает изменение объявления автору и слушателям объявления.
+// Снятое с публикации объявление слушатели видят без содержимого.
+func (a *localApp) publishAd(eventType pubsub.EventType, ad *ads.Ad) {
+	a.hub.Publish(pubsub.Event{Topic: pubsub.UserTopic(ad.AuthorID), Type: eventType, Ad: ad})
+
+	public := ad
+	if !ad.Published {
+		public = &ads.Ad{ID: ad.ID, AuthorID: ad.AuthorID, Status: ad.Status, Published: ad.Published}
+	}
+	a.hub.Publish(pubsub.Event{Topic: pubsub.AdTopic(ad.ID), Type: eventType, Ad: public})
+}
+
+// publishConversation рассылает событие переписки обоим участникам
+func (a *localApp) publishConversation(conversation *ads.Conversation, event pubsub.Event) {
+	for _, userID := range []int64{conversation.BuyerID, conversation.AuthorID} {
+		event.Topic = pubsub.UserTopic(userID)
+		a.hub.Publish(event)
+	}
+}
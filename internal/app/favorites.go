@@ -0,0 +1,102 @@
+package app
+
+import (
+	"adflow/internal/ads"
+	"context"
+	"time"
+)
+
+// AddFavorite добавляет опубликованное объявление в избранное; повторное добавление ничего не меняет
+func (a *localApp) AddFavorite(ctx context.Context, userID, adID int64) (*ads.Favorite, error) {
+	if _, err := a.authorize(ctx, ActionManageFavorites, userID); err != nil {
+		return nil, err
+	}
+
+	ad, err := a.repo.Get(adID)
+	if err != nil {
+		return nil, err
+	}
+	if !ad.Published {
+		return nil, ads.ErrBadRequest
+	}
+
+	favorite := &ads.Favorite{UserID: userID, AdID: adID, CreationTime: time.Now().UTC()}
+	if err := a.favorites.AddFavorite(favorite); err != nil {
+		return nil, err
+	}
+
+	if favorite.Ad, err = a.withAdDetails(ad); err != nil {
+		return nil, err
+	}
+	return favorite, nil
+}
+
+// RemoveFavorite убирает объявление из избранного; отсутствие записи ошибкой не считается
+func (a *localApp) RemoveFavorite(ctx context.Context, userID, adID int64) error {
+	if _, err := a.authorize(ctx, ActionManageFavorites, userID); err != nil {
+		return err
+	}
+
+	return a.favorites.RemoveFavorite(userID, adID)
+}
+
+// ListFavorites возвращает избранное вместе с объявлениями, новые первыми. Записи удалённых объявлений
+// убираются при удалении, а снятые с публикации скрыты, пока объявление не опубликуют снова.
+// За следующей страницей передают beforeID - ID последней полученной записи.
+func (a *localApp) ListFavorites(ctx context.Context, userID, beforeID int64, limit int) ([]*ads.Favorite, error) {
+	if _, err := a.authorize(ctx, ActionManageFavorites, userID); err != nil {
+		return nil, err
+	}
+
+	limit, err := pageLimit(beforeID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]*ads.Favorite, 0, limit)
+	found := make([]*ads.Ad, 0, limit)
+
+	// Скрытые записи не занимают места на странице, поэтому читаем, пока она не заполнится
+	for len(list) < limit {
+		batch, err := a.favorites.ListFavorites(userID, beforeID, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		ids := make([]int64, len(batch))
+		for i, favorite := range batch {
+			ids[i] = favorite.AdID
+		}
+		byID, err := a.adsByID(ids)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, favorite := range batch {
+			if len(list) == limit {
+				break
+			}
+			beforeID = favorite.ID
+
+			ad, ok := byID[favorite.AdID]
+			if !ok || !ad.Published {
+				continue
+			}
+			list = append(list, favorite)
+			found = append(found, ad)
+		}
+
+		if len(batch) < limit {
+			break
+		}
+	}
+
+	found, err = a.withDetails(found)
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		list[i].Ad = found[i]
+	}
+	return list, nil
+}
@@ -0,0 +1,247 @@
+package app
+
+import (
+	"adflow/internal/ads"
+	"adflow/internal/blob"
+	"adflow/internal/imaging"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// Ограничения на картинки одного объявления
+const (
+	MaxImagesPerAd = 10
+	MaxImageSize   = 5 << 20
+)
+
+// DefaultImageURL - префикс ссылок на картинки, которые отдаёт сам сервис
+const DefaultImageURL = "/api/v1/images/"
+
+// WithBlobStore задаёт хранилище файлов картинок. Без него файлы хранятся в памяти процесса.
+func WithBlobStore(store BlobStore) Option {
+	return func(a *localApp) {
+		a.blobs = store
+	}
+}
+
+// WithImageURL задаёт префикс ссылок на картинки, например адрес публичного бакета или CDN
+func WithImageURL(prefix string) Option {
+	return func(a *localApp) {
+		a.imageURL = prefix
+	}
+}
+
+// withDetails возвращает копии объявлений с картинками и числом добавлений в избранное;
+// хранилище в памяти отдаёт свои объекты, поэтому сами объявления не меняются
+func (a *localApp) withDetails(list []*ads.Ad) ([]*ads.Ad, error) {
+	ids := make([]int64, len(list))
+	for i, ad := range list {
+		ids[i] = ad.ID
+	}
+
+	images, err := a.repo.ListImages(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	counts, err := a.favorites.CountFavorites(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	byAd := make(map[int64][]ads.Image)
+	for _, image := range images {
+		image.URL = a.imageURL + image.Key
+		image.ThumbnailURL = a.imageURL + image.ThumbnailKey
+		byAd[image.AdID] = append(byAd[image.AdID], *image)
+	}
+
+	result := make([]*ads.Ad, len(list))
+	for i, ad := range list {
+		c := *ad
+		c.Images = byAd[ad.ID]
+		c.FavoriteCount = counts[ad.ID]
+		result[i] = &c
+	}
+	return result, nil
+}
+
+func (a *localApp) withAdDetails(ad *ads.Ad) (*ads.Ad, error) {
+	list, err := a.withDetails([]*ads.Ad{ad})
+	if err != nil {
+		return nil, err
+	}
+	return list[0], nil
+}
+
+func randomName() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// putImage кладёт картинку и миниатюру в хранилище файлов и возвращает сведения о них для записи.
+// Если что-то не удалось, уже положенные файлы удаляются.
+func (a *localApp) putImage(ctx context.Context, adID int64, data []byte, processed *imaging.Processed) (*ads.Image, error) {
+	name, err := randomName()
+	if err != nil {
+		return nil, err
+	}
+
+	image := &ads.Image{
+		AdID:         adID,
+		Key:          fmt.Sprintf("ads/%d/%s%s", adID, name, processed.Ext),
+		ThumbnailKey: fmt.Sprintf("ads/%d/%s_thumb%s", adID, name, processed.ThumbnailExt),
+		ContentType:  processed.ContentType,
+		Size:         int64(len(data)),
+		Width:        processed.Width,
+		Height:       processed.Height,
+		CreationTime: time.Now().UTC(),
+	}
+
+	if err := a.blobs.Put(ctx, image.Key, image.ContentType, data); err != nil {
+		return nil, err
+	}
+	if err := a.blobs.Put(ctx, image.ThumbnailKey, processed.ThumbnailContentType, processed.Thumbnail); err != nil {
+		a.deleteImageFiles(ctx, image)
+		return nil, err
+	}
+	return image, nil
+}
+
+// deleteImageFiles убирает файлы картинки, сведения о которой не записаны; ошибки не важны,
+// потому что на такие файлы ничто не ссылается
+func (a *localApp) deleteImageFiles(ctx context.Context, image *ads.Image) {
+	_ = a.blobs.Delete(ctx, image.Key)
+	_ = a.blobs.Delete(ctx, image.ThumbnailKey)
+}
+
+// AddAdImages сохраняет все файлы или ни одного: файлы проверяются до записи, сведения о картинках
+// записываются одной транзакцией, а при ошибке уже положенные в хранилище файлы удаляются.
+// Число картинок объявления проверяет хранилище в той же транзакции.
+func (a *localApp) AddAdImages(ctx context.Context, adID int64, files [][]byte) (_ *ads.Ad, err error) {
+	ad, err := a.repo.Get(adID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := a.authorize(ctx, ActionUpdateAd, ad.AuthorID); err != nil {
+		return nil, err
+	}
+
+	if len(files) == 0 || len(files) > MaxImagesPerAd {
+		return nil, ads.ErrBadRequest
+	}
+
+	processed := make([]*imaging.Processed, len(files))
+	for i, data := range files {
+		if len(data) > MaxImageSize {
+			return nil, ads.ErrBadRequest
+		}
+		if processed[i], err = imaging.Process(data); err != nil {
+			return nil, err
+		}
+	}
+
+	images := make([]*ads.Image, 0, len(files))
+	defer func() {
+		if err != nil {
+			for _, image := range images {
+				a.deleteImageFiles(ctx, image)
+			}
+		}
+	}()
+
+	for i, data := range files {
+		image, err := a.putImage(ctx, adID, data, processed[i])
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, image)
+	}
+
+	if err := a.repo.AddImages(adID, images, MaxImagesPerAd); err != nil {
+		return nil, err
+	}
+	// Сведения записаны, и дальше файлы при ошибке не удаляются
+	images = nil
+
+	// Новые картинки опубликованного объявления проходят модерацию, как и правка текста
+	if ad.AdStatus() == ads.StatusPublished {
+		if flags := a.rules.Check(ad); a.needsReview(flags) {
+			if ad, err = a.submitForReview(ctx, ad, flags); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return a.withAdDetails(ad)
+}
+
+func (a *localApp) removeImage(ctx context.Context, image *ads.Image) error {
+	if err := a.repo.DeleteImage(image.ID); err != nil {
+		return err
+	}
+	if err := a.blobs.Delete(ctx, image.Key); err != nil {
+		return err
+	}
+	return a.blobs.Delete(ctx, image.ThumbnailKey)
+}
+
+func (a *localApp) DeleteAdImage(ctx context.Context, adID, imageID int64) (*ads.Ad, error) {
+	ad, err := a.repo.Get(adID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := a.authorize(ctx, ActionUpdateAd, ad.AuthorID); err != nil {
+		return nil, err
+	}
+
+	image, err := a.repo.GetImage(imageID)
+	if err != nil {
+		return nil, err
+	}
+	if image.AdID != adID {
+		return nil, ads.ErrBadRequest
+	}
+
+	if err := a.removeImage(ctx, image); err != nil {
+		return nil, err
+	}
+
+	return a.withAdDetails(ad)
+}
+
+// removeAdImages удаляет картинки удалённого объявления вместе с файлами
+func (a *localApp) removeAdImages(ctx context.Context, adID int64) error {
+	images, err := a.repo.ListImages([]int64{adID})
+	if err != nil {
+		return err
+	}
+
+	for _, image := range images {
+		if err := a.removeImage(ctx, image); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetImage отдаёт файл картинки или миниатюры по ключу из ссылки
+func (a *localApp) GetImage(ctx context.Context, key string) ([]byte, string, error) {
+	if _, err := a.authorize(ctx, ActionReadAd, 0); err != nil {
+		return nil, "", err
+	}
+
+	if !blob.ValidKey(key) {
+		return nil, "", ads.ErrBadRequest
+	}
+
+	return a.blobs.Get(ctx, key)
+}
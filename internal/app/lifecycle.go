@@ -0,0 +1,204 @@
+package app
+
+import (
+	"adflow/internal/ads"
+	"adflow/internal/pubsub"
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+// Сколько объявлений планировщик обрабатывает за один запрос к хранилищу
+const expireBatchSize = 100
+
+// WithAdTTL задаёт срок публикации вместо ads.PublicationTTL
+func WithAdTTL(ttl time.Duration) Option {
+	return func(a *localApp) {
+		a.adTTL = ttl
+	}
+}
+
+// transitionAction возвращает действие, которое нужно разрешить для перехода.
+// В expired объявление переводит только планировщик.
+func transitionAction(from, to ads.Status) (Action, bool) {
+	switch to {
+	case ads.StatusPendingReview:
+		return ActionSubmitAd, true
+	case ads.StatusPublished:
+		switch from {
+		case ads.StatusPendingReview:
+			return ActionModerateAd, true
+		case ads.StatusExpired:
+			return ActionRenewAd, true
+		}
+		return ActionPublishAd, true
+	case ads.StatusDraft:
+		return ActionUnpublishAd, true
+	case ads.StatusSold:
+		return ActionSellAd, true
+	case ads.StatusArchived:
+		return ActionArchiveAd, true
+	}
+	return "", false
+}
+
+// expiresAt - срок публикации после перехода: публикация его начинает, возврат в черновики сбрасывает
+func (a *localApp) expiresAt(ad *ads.Ad, to ads.Status) *time.Time {
+	switch to {
+	case ads.StatusPublished:
+		t := time.Now().UTC().Add(a.adTTL)
+		return &t
+	case ads.StatusDraft, ads.StatusPendingReview:
+		return nil
+	}
+	return ad.ExpiresAt
+}
+
+func (a *localApp) TransitionAd(ctx context.Context, id int64, status ads.Status) (*ads.Ad, error) {
+	ad, err := a.repo.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	from := ad.AdStatus()
+	action, ok := transitionAction(from, status)
+	if !ok || !from.CanTransition(status) {
+		return nil, ads.ErrBadRequest
+	}
+
+	user, err := a.authorize(ctx, action, ad.AuthorID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case status == ads.StatusPendingReview:
+		return a.submitForReview(ctx, ad, a.rules.Check(ad))
+	case from == ads.StatusDraft && status == ads.StatusPublished:
+		return a.publish(ctx, ad)
+	case from == ads.StatusPendingReview:
+		decision := ads.DecisionApproved
+		if status == ads.StatusDraft {
+			// Отклонение без причины запрещено: модератор отклоняет только через RejectAd
+			if user.ID != ad.AuthorID {
+				return nil, ads.ErrBadRequest
+			}
+			decision = ads.DecisionWithdrawn
+		}
+		return a.closeReview(ctx, ad, status, decision, "", user.ID)
+	}
+
+	return a.setStatus(ctx, ad, status, a.expiresAt(ad, status))
+}
+
+// statusEvent - событие домена для перехода: публикация отдельно от остальных смен статуса
+func statusEvent(ad *ads.Ad, from, to ads.Status, expiresAt *time.Time) ads.DomainEvent {
+	if to == ads.StatusPublished {
+		return ads.AdPublished{AdID: ad.ID, AuthorID: ad.AuthorID, From: from, ExpiresAt: expiresAt}
+	}
+	return ads.AdStatusChanged{AdID: ad.ID, AuthorID: ad.AuthorID, From: from, To: to}
+}
+
+// setStatus меняет статус, если его не успели изменить с момента чтения, и обновляет поисковый индекс.
+// Подписчиков сохранённых поисков о публикации уведомляет MatchSavedSearches по событию AdPublished.
+func (a *localApp) setStatus(ctx context.Context, ad *ads.Ad, status ads.Status, expiresAt *time.Time) (*ads.Ad, error) {
+	from := ad.AdStatus()
+
+	ad, err := a.repo.UpdateStatus(ad.ID, from, status, expiresAt, statusEvent(ad, from, status, expiresAt))
+	if err != nil {
+		return nil, err
+	}
+	if err := a.reindex(ad); err != nil {
+		return nil, err
+	}
+
+	if ad, err = a.withAdDetails(ad); err != nil {
+		return nil, err
+	}
+
+	a.publishAd(pubsub.EventAdStatus, ad)
+	return ad, nil
+}
+
+// RenewAd продлевает публикацию на полный срок; снятое по сроку объявление публикуется снова
+func (a *localApp) RenewAd(ctx context.Context, id int64) (*ads.Ad, error) {
+	ad, err := a.repo.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := a.authorize(ctx, ActionRenewAd, ad.AuthorID); err != nil {
+		return nil, err
+	}
+
+	if status := ad.AdStatus(); status != ads.StatusPublished && status != ads.StatusExpired {
+		return nil, ads.ErrBadRequest
+	}
+
+	return a.setStatus(ctx, ad, ads.StatusPublished, a.expiresAt(ad, ads.StatusPublished))
+}
+
+func (a *localApp) ExpireAds(ctx context.Context, now time.Time) (int, error) {
+	archiveBefore := now.Add(-ads.ArchiveAfter)
+
+	steps := []struct {
+		from, to ads.Status
+		before   *time.Time
+	}{
+		{ads.StatusPublished, ads.StatusExpired, &now},
+		{ads.StatusExpired, ads.StatusArchived, &archiveBefore},
+	}
+
+	changed := 0
+	for _, step := range steps {
+		filter := ads.Filter{Statuses: []ads.Status{step.from}, Expires: ads.TimeRange{Before: step.before}}
+		page := ads.Page{Limit: expireBatchSize}
+
+		for {
+			list, next, err := a.repo.GetAds(filter, page)
+			if err != nil {
+				return changed, err
+			}
+
+			for _, ad := range list {
+				_, err := a.setStatus(ctx, ad, step.to, ad.ExpiresAt)
+				// Автор мог успеть продлить или снять объявление - тогда его статус уже другой
+				if errors.Is(err, ads.ErrBadRequest) {
+					continue
+				}
+				if err != nil {
+					return changed, err
+				}
+				changed++
+			}
+
+			if next == nil {
+				break
+			}
+			page.After = next
+		}
+	}
+
+	return changed, nil
+}
+
+// RunScheduler раз в interval снимает истёкшие объявления, пока не отменён ctx
+func RunScheduler(ctx context.Context, a App, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := a.ExpireAds(ctx, time.Now().UTC()); err != nil {
+			log.Printf("scheduler: %v", err)
+		} else if n > 0 {
+			log.Printf("scheduler: %d ads expired or archived", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
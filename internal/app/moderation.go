@@ -0,0 +1,188 @@
+package app
+
+import (
+	"adflow/internal/ads"
+	"adflow/internal/moderation"
+	"context"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ModerationMode решает, какие публикации ждут проверки модератором
+type ModerationMode string
+
+const (
+	// ModerationAll - каждая публикация и правка опубликованного объявления попадает в очередь
+	ModerationAll ModerationMode = "all"
+	// ModerationFlagged - в очередь попадают только объявления с замечаниями правил
+	ModerationFlagged ModerationMode = "flagged"
+)
+
+// MaxRejectReason - наибольшая длина причины отклонения в символах
+const MaxRejectReason = 500
+
+// WithModeration задаёт режим модерации и правила автоматической проверки.
+// Без опции проверяются все публикации правилами moderation.Default.
+func WithModeration(mode ModerationMode, rules ...moderation.Rule) Option {
+	return func(a *localApp) {
+		a.moderation = mode
+		a.rules = rules
+	}
+}
+
+func (a *localApp) needsReview(flags []ads.Flag) bool {
+	return a.moderation != ModerationFlagged || len(flags) > 0
+}
+
+// publish публикует черновик автора сразу или отправляет его на проверку
+func (a *localApp) publish(ctx context.Context, ad *ads.Ad) (*ads.Ad, error) {
+	if flags := a.rules.Check(ad); a.needsReview(flags) {
+		return a.submitForReview(ctx, ad, flags)
+	}
+	return a.setStatus(ctx, ad, ads.StatusPublished, a.expiresAt(ad, ads.StatusPublished))
+}
+
+// submitForReview переводит объявление в pending_review и ставит заявку в очередь.
+// Заявка создаётся после смены статуса, поэтому при гонке вторая не появится.
+func (a *localApp) submitForReview(ctx context.Context, ad *ads.Ad, flags []ads.Flag) (*ads.Ad, error) {
+	authorID := ad.AuthorID
+
+	ad, err := a.setStatus(ctx, ad, ads.StatusPendingReview, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = a.repo.CreateReview(&ads.Review{
+		AdID:         ad.ID,
+		AuthorID:     authorID,
+		Decision:     ads.DecisionPending,
+		Flags:        flags,
+		CreationTime: time.Now().UTC(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ad, nil
+}
+
+// closeReview выводит объявление из pending_review и записывает решение в его заявку
+func (a *localApp) closeReview(ctx context.Context, ad *ads.Ad, status ads.Status, decision ads.Decision, reason string, moderatorID int64) (*ads.Ad, error) {
+	ad, err := a.setStatus(ctx, ad, status, a.expiresAt(ad, status))
+	if err != nil {
+		return nil, err
+	}
+	if err := a.decidePending(ad.ID, decision, reason, moderatorID); err != nil {
+		return nil, err
+	}
+	return ad, nil
+}
+
+// decidePending закрывает открытую заявку объявления, если она есть
+func (a *localApp) decidePending(adID int64, decision ads.Decision, reason string, moderatorID int64) error {
+	reviews, err := a.repo.ListReviews(adID)
+	if err != nil {
+		return err
+	}
+
+	for _, review := range reviews {
+		if review.Decision == ads.DecisionPending {
+			_, err := a.repo.DecideReview(review.ID, decision, reason, moderatorID)
+			return err
+		}
+	}
+	return nil
+}
+
+// pendingAd возвращает объявление из очереди, если вызывающий - модератор
+func (a *localApp) pendingAd(ctx context.Context, id int64) (*ads.Ad, *ads.User, error) {
+	ad, err := a.repo.Get(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := a.authorize(ctx, ActionModerateAd, ad.AuthorID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if ad.AdStatus() != ads.StatusPendingReview {
+		return nil, nil, ads.ErrBadRequest
+	}
+	return ad, user, nil
+}
+
+func (a *localApp) ApproveAd(ctx context.Context, id int64) (*ads.Ad, error) {
+	ad, user, err := a.pendingAd(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.closeReview(ctx, ad, ads.StatusPublished, ads.DecisionApproved, "", user.ID)
+}
+
+// RejectAd возвращает объявление автору в черновики; причину он увидит в истории проверок
+func (a *localApp) RejectAd(ctx context.Context, id int64, reason string) (*ads.Ad, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" || utf8.RuneCountInString(reason) > MaxRejectReason {
+		return nil, ads.ErrBadRequest
+	}
+
+	ad, user, err := a.pendingAd(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.closeReview(ctx, ad, ads.StatusDraft, ads.DecisionRejected, reason, user.ID)
+}
+
+// ListModerationQueue возвращает заявки без решения вместе с объявлениями, старые первыми
+func (a *localApp) ListModerationQueue(ctx context.Context, afterID int64, limit int) ([]*ads.Review, error) {
+	if _, err := a.authorize(ctx, ActionModerateAd, 0); err != nil {
+		return nil, err
+	}
+
+	limit, err := pageLimit(afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	reviews, err := a.repo.ListPendingReviews(afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]*ads.Review, 0, len(reviews))
+	found := make([]*ads.Ad, 0, len(reviews))
+	for _, review := range reviews {
+		ad, err := a.repo.Get(review.AdID)
+		if err != nil {
+			continue
+		}
+		list = append(list, review)
+		found = append(found, ad)
+	}
+
+	found, err = a.withDetails(found)
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		list[i].Ad = found[i]
+	}
+	return list, nil
+}
+
+// ListAdReviews - история проверок объявления для автора и модераторов
+func (a *localApp) ListAdReviews(ctx context.Context, adID int64) ([]*ads.Review, error) {
+	ad, err := a.repo.Get(adID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := a.authorize(ctx, ActionReadReviews, ad.AuthorID); err != nil {
+		return nil, err
+	}
+
+	return a.repo.ListReviews(adID)
+}
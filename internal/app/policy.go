@@ -0,0 +1,146 @@
+package app
+
+import (
+	"adflow/internal/ads"
+)
+
+type Action string
+
+const (
+	ActionReadAd      Action = "ad:read"
+	ActionListAds     Action = "ad:list"
+	ActionCreateAd    Action = "ad:create"
+	ActionUpdateAd    Action = "ad:update"
+	ActionPublishAd   Action = "ad:publish"
+	ActionUnpublishAd Action = "ad:unpublish"
+	ActionDeleteAd    Action = "ad:delete"
+	ActionSubmitAd    Action = "ad:submit"
+	ActionModerateAd  Action = "ad:moderate"
+	ActionReadReviews Action = "ad:read_reviews"
+	ActionSellAd      Action = "ad:sell"
+	ActionArchiveAd   Action = "ad:archive"
+	ActionRenewAd     Action = "ad:renew"
+	// История правок видна автору и тем, кто проверяет объявления
+	ActionReadRevisions Action = "ad:read_revisions"
+	// Объявление не в ads.PublicStatuses, например ещё не проверенное, видят только автор и модераторы
+	ActionReadHiddenAd Action = "ad:read_hidden"
+
+	ActionCreateUser  Action = "user:create"
+	ActionReadUser    Action = "user:read"
+	ActionUpdateUser  Action = "user:update"
+	ActionDeleteUser  Action = "user:delete"
+	ActionSetUserRole Action = "user:set_role"
+	// Избранное, сохранённые поиски и уведомления видит и меняет только сам пользователь
+	ActionManageFavorites Action = "user:favorites"
+	ActionManageSearches  Action = "user:searches"
+	// Список переписок и входящие автора
+	ActionManageConversations Action = "user:conversations"
+	// Email и телефон пользователя видят только он сам и администратор
+	ActionReadContacts Action = "user:read_contacts"
+
+	// Писать и читать может любой вошедший участник переписки; участие проверяет приложение
+	ActionSendMessage      Action = "conversation:write"
+	ActionReadConversation Action = "conversation:read"
+
+	// Подписка на события: тема объявления, пока оно не опубликовано, и тема пользователя
+	ActionSubscribeAd   Action = "stream:ad"
+	ActionSubscribeUser Action = "stream:user"
+
+	// Вебхуки получают события обо всех объявлениях и пользователях, поэтому ими управляет администратор
+	ActionManageWebhooks Action = "webhook:manage"
+
+	ActionListCategories Action = "category:list"
+	ActionCreateCategory Action = "category:create"
+	ActionUpdateCategory Action = "category:update"
+	ActionDeleteCategory Action = "category:delete"
+)
+
+// Policy решает, может ли пользователь выполнить действие над ресурсом,
+// принадлежащим ownerID. Для анонимного запроса user равен nil.
+type Policy interface {
+	Authorize(user *ads.User, action Action, ownerID int64) error
+}
+
+type rule struct {
+	// Действие доступно без входа
+	public bool
+	// Действие доступно любому вошедшему пользователю
+	authenticated bool
+	// Действие доступно владельцу ресурса
+	owner bool
+	// Роли, которым действие доступно над любым ресурсом
+	roles []ads.Role
+}
+
+// RolePolicy - правила доступа по ролям; действие без правила запрещено
+type RolePolicy map[Action]rule
+
+var DefaultPolicy = RolePolicy{
+	ActionReadAd:      {public: true},
+	ActionListAds:     {public: true},
+	ActionCreateAd:    {authenticated: true},
+	ActionUpdateAd:    {owner: true},
+	ActionPublishAd:   {owner: true},
+	ActionUnpublishAd: {owner: true, roles: []ads.Role{ads.RoleModerator, ads.RoleAdmin}},
+	ActionDeleteAd:    {owner: true, roles: []ads.Role{ads.RoleModerator, ads.RoleAdmin}},
+	ActionSubmitAd:    {owner: true},
+	ActionModerateAd:  {roles: []ads.Role{ads.RoleModerator, ads.RoleAdmin}},
+	ActionReadReviews: {owner: true, roles: []ads.Role{ads.RoleModerator, ads.RoleAdmin}},
+	ActionSellAd:      {owner: true},
+	ActionArchiveAd:   {owner: true, roles: []ads.Role{ads.RoleModerator, ads.RoleAdmin}},
+	ActionRenewAd:     {owner: true},
+
+	ActionReadRevisions: {owner: true, roles: []ads.Role{ads.RoleModerator, ads.RoleAdmin}},
+	ActionReadHiddenAd:  {owner: true, roles: []ads.Role{ads.RoleModerator, ads.RoleAdmin}},
+
+	ActionCreateUser:   {public: true},
+	ActionReadUser:     {public: true},
+	ActionReadContacts: {owner: true, roles: []ads.Role{ads.RoleAdmin}},
+	ActionUpdateUser:   {owner: true, roles: []ads.Role{ads.RoleAdmin}},
+	ActionDeleteUser:   {owner: true, roles: []ads.Role{ads.RoleAdmin}},
+	ActionSetUserRole:  {roles: []ads.Role{ads.RoleAdmin}},
+
+	ActionManageFavorites:     {owner: true},
+	ActionManageSearches:      {owner: true},
+	ActionManageConversations: {owner: true},
+
+	ActionSendMessage:      {authenticated: true},
+	ActionReadConversation: {authenticated: true},
+
+	ActionSubscribeAd:   {owner: true, roles: []ads.Role{ads.RoleModerator, ads.RoleAdmin}},
+	ActionSubscribeUser: {owner: true},
+
+	ActionManageWebhooks: {roles: []ads.Role{ads.RoleAdmin}},
+
+	ActionListCategories: {public: true},
+	ActionCreateCategory: {roles: []ads.Role{ads.RoleAdmin}},
+	ActionUpdateCategory: {roles: []ads.Role{ads.RoleAdmin}},
+	ActionDeleteCategory: {roles: []ads.Role{ads.RoleAdmin}},
+}
+
+func (p RolePolicy) Authorize(user *ads.User, action Action, ownerID int64) error {
+	r, ok := p[action]
+	if !ok {
+		return ads.ErrAccessDenied
+	}
+
+	if r.public {
+		return nil
+	}
+
+	if user == nil {
+		return ads.ErrUnauthorized
+	}
+
+	if r.authenticated || (r.owner && user.ID == ownerID) {
+		return nil
+	}
+
+	for _, role := range r.roles {
+		if user.UserRole() == role {
+			return nil
+		}
+	}
+
+	return ads.ErrAccessDenied
+}
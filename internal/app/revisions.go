@@ -0,0 +1,76 @@
+package app
+
+import (
+	"adflow/internal/ads"
+	"context"
+)
+
+// revisionsOf проверяет, что вызывающему видна история объявления
+func (a *localApp) revisionsOf(ctx context.Context, adID int64) error {
+	ad, err := a.repo.Get(adID)
+	if err != nil {
+		return err
+	}
+
+	_, err = a.authorize(ctx, ActionReadRevisions, ad.AuthorID)
+	return err
+}
+
+func (a *localApp) ListAdRevisions(ctx context.Context, adID, before int64, limit int) ([]*ads.Revision, error) {
+	if err := a.revisionsOf(ctx, adID); err != nil {
+		return nil, err
+	}
+
+	limit, err := pageLimit(before, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.repo.ListRevisions(adID, before, limit)
+}
+
+func (a *localApp) GetAdRevision(ctx context.Context, adID, number int64) (*ads.Revision, error) {
+	if err := a.revisionsOf(ctx, adID); err != nil {
+		return nil, err
+	}
+
+	return a.repo.GetRevision(adID, number)
+}
+
+func (a *localApp) DiffAdRevisions(ctx context.Context, adID, from, to int64) ([]ads.Change, error) {
+	if err := a.revisionsOf(ctx, adID); err != nil {
+		return nil, err
+	}
+
+	prev, err := a.repo.GetRevision(adID, from)
+	if err != nil {
+		return nil, err
+	}
+	next, err := a.repo.GetRevision(adID, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return ads.DiffRevisions(prev, next), nil
+}
+
+// RestoreAdRevision - обычная правка автора: проходит те же проверки и модерацию, что и UpdateAd.
+// Категория ревизии могла быть удалена, тогда вернуть её нельзя.
+func (a *localApp) RestoreAdRevision(ctx context.Context, adID, number int64) (*ads.Ad, error) {
+	ad, err := a.repo.Get(adID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := a.authorize(ctx, ActionUpdateAd, ad.AuthorID)
+	if err != nil {
+		return nil, err
+	}
+
+	rev, err := a.repo.GetRevision(adID, number)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.editAd(ctx, ad, rev.Draft(), &ads.Revision{AuthorID: user.ID, RestoredFrom: rev.Number})
+}
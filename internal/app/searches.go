@@ -0,0 +1,312 @@
+package app
+
+import (
+	"adflow/internal/ads"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	validator "github.com/skyberg11/args-validator"
+)
+
+// Notifier доставляет уведомление пользователю: письмом, в лог и т.п. Реализации - в internal/notify.
+type Notifier interface {
+	Notify(ctx context.Context, user *ads.User, notification *ads.Notification) error
+}
+
+// WithNotifier задаёт доставку уведомлений по сохранённым поискам; отправляет их DeliverNotifications.
+// Без опции уведомления только сохраняются и видны в API.
+func WithNotifier(n Notifier) Option {
+	return func(a *localApp) {
+		a.notifier = n
+	}
+}
+
+// MaxSavedSearches - сколько поисков может сохранить один пользователь
+const MaxSavedSearches = 20
+
+// Сколько сохранённых поисков сопоставляется с объявлением за один запрос к хранилищу
+const searchBatchSize = 100
+
+// savedFilter проверяет фильтр сохранённого поиска. Поиск всегда ведётся среди только что
+// опубликованных объявлений, поэтому условия на статус и срок публикации не сохраняются.
+func savedFilter(filter ads.Filter) (ads.Filter, error) {
+	filter.Published = nil
+	filter.Statuses = nil
+	filter.Expires = ads.TimeRange{}
+
+	if err := checkFilter(&filter); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+func checkSearchName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if validator.Validate(ads.SavedSearch{Name: name}) != nil {
+		return "", ads.ErrBadRequest
+	}
+	return name, nil
+}
+
+// userSearch возвращает поиск, если он принадлежит пользователю
+func (a *localApp) userSearch(ctx context.Context, userID, id int64) (*ads.SavedSearch, error) {
+	if _, err := a.authorize(ctx, ActionManageSearches, userID); err != nil {
+		return nil, err
+	}
+
+	search, err := a.searches.GetSearch(id)
+	if err != nil {
+		return nil, err
+	}
+	if search.UserID != userID {
+		return nil, ads.ErrBadRequest
+	}
+	return search, nil
+}
+
+func (a *localApp) CreateSavedSearch(ctx context.Context, userID int64, name string, filter ads.Filter) (*ads.SavedSearch, error) {
+	if _, err := a.authorize(ctx, ActionManageSearches, userID); err != nil {
+		return nil, err
+	}
+
+	name, err := checkSearchName(name)
+	if err != nil {
+		return nil, err
+	}
+	if filter, err = savedFilter(filter); err != nil {
+		return nil, err
+	}
+
+	list, err := a.searches.ListSearches(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) >= MaxSavedSearches {
+		return nil, ads.ErrBadRequest
+	}
+
+	search := &ads.SavedSearch{
+		UserID:       userID,
+		Name:         name,
+		Filter:       filter,
+		CreationTime: time.Now().UTC(),
+	}
+	if err := a.searches.CreateSearch(search); err != nil {
+		return nil, err
+	}
+	return search, nil
+}
+
+func (a *localApp) ListSavedSearches(ctx context.Context, userID int64) ([]*ads.SavedSearch, error) {
+	if _, err := a.authorize(ctx, ActionManageSearches, userID); err != nil {
+		return nil, err
+	}
+
+	return a.searches.ListSearches(userID)
+}
+
+func (a *localApp) UpdateSavedSearch(ctx context.Context, userID, id int64, name string, filter ads.Filter) (*ads.SavedSearch, error) {
+	if _, err := a.userSearch(ctx, userID, id); err != nil {
+		return nil, err
+	}
+
+	name, err := checkSearchName(name)
+	if err != nil {
+		return nil, err
+	}
+	if filter, err = savedFilter(filter); err != nil {
+		return nil, err
+	}
+
+	return a.searches.UpdateSearch(id, name, filter)
+}
+
+func (a *localApp) DeleteSavedSearch(ctx context.Context, userID, id int64) error {
+	if _, err := a.userSearch(ctx, userID, id); err != nil {
+		return err
+	}
+
+	return a.searches.DeleteSearch(id)
+}
+
+func (a *localApp) ListNotifications(ctx context.Context, userID, beforeID int64, limit int) ([]*ads.Notification, error) {
+	if _, err := a.authorize(ctx, ActionManageSearches, userID); err != nil {
+		return nil, err
+	}
+
+	limit, err := pageLimit(beforeID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.searches.ListNotifications(userID, beforeID, limit)
+}
+
+// MatchSavedSearches заводит уведомления владельцам сохранённых поисков, которым подходит опубликованное объявление.
+// Автор о своём объявлении не уведомляется. Вызывается по событию AdPublished из outbox, поэтому может прийти
+// повторно: по одному поиску об одном объявлении уведомление заводится один раз. Если объявление успели снять
+// с публикации, уведомлений нет. Отправляет уведомления DeliverNotifications.
+func (a *localApp) MatchSavedSearches(ctx context.Context, adID int64) (int, error) {
+	ad, err := a.repo.Get(adID)
+	if errors.Is(err, ads.ErrBadRequest) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if ad.AdStatus() != ads.StatusPublished {
+		return 0, nil
+	}
+
+	var categories []*ads.Category
+	var afterID int64
+	created := 0
+
+	for {
+		list, err := a.searches.ScanSearches(afterID, searchBatchSize)
+		if err != nil {
+			return created, err
+		}
+
+		for _, search := range list {
+			afterID = search.ID
+			if search.UserID == ad.AuthorID {
+				continue
+			}
+
+			filter := search.Filter
+			if len(filter.CategoryIDs) > 0 {
+				if categories == nil {
+					if categories, err = a.repo.ListCategories(); err != nil {
+						return created, err
+					}
+				}
+				filter.CategoryIDs = subtrees(categories, filter.CategoryIDs)
+			}
+			if !filter.Match(ad) {
+				continue
+			}
+
+			now := time.Now().UTC()
+			notification := &ads.Notification{
+				UserID:       search.UserID,
+				SearchID:     search.ID,
+				AdID:         ad.ID,
+				Title:        ad.Title,
+				CreationTime: now,
+			}
+			// Без Notifier уведомления только сохраняются, доставлять их некуда
+			if a.notifier != nil {
+				notification.NextAttemptTime = &now
+			}
+
+			ok, err := a.searches.CreateNotification(notification)
+			if err != nil {
+				return created, err
+			}
+			if ok {
+				created++
+			}
+		}
+
+		if len(list) < searchBatchSize {
+			return created, nil
+		}
+	}
+}
+
+// Повторы доставки уведомлений: после n-й неудачи следующая попытка через notifyRetryDelay·2^(n-1)
+const (
+	MaxNotifyAttempts = 5
+	notifyRetryDelay  = time.Minute
+)
+
+// DeliverNotifications делает по одной попытке для уведомлений, срок которых подошёл к now,
+// и возвращает, сколько доставлено. После MaxNotifyAttempts неудач уведомление больше не отправляется.
+func (a *localApp) DeliverNotifications(ctx context.Context, now time.Time) (int, error) {
+	if a.notifier == nil {
+		return 0, nil
+	}
+
+	delivered := 0
+	var afterID int64
+	for {
+		list, err := a.searches.DueNotifications(now, afterID, searchBatchSize)
+		if err != nil {
+			return delivered, err
+		}
+
+		for _, notification := range list {
+			afterID = notification.ID
+
+			err := a.deliver(ctx, notification, now)
+			// Попытка, прерванная остановкой сервиса, не считается: уведомление уйдёт при следующем запуске
+			if ctx.Err() != nil {
+				return delivered, ctx.Err()
+			}
+			if err == nil {
+				delivered++
+			}
+
+			if err := a.searches.SaveDelivery(notification); err != nil {
+				return delivered, err
+			}
+		}
+
+		if len(list) < searchBatchSize {
+			return delivered, nil
+		}
+	}
+}
+
+// deliver отправляет уведомление и записывает итог попытки в notification; повтор отсчитывается от now
+func (a *localApp) deliver(ctx context.Context, notification *ads.Notification, now time.Time) error {
+	user, err := a.users.Get(notification.UserID)
+	if err == nil {
+		// Поиск и объявление могли удалить после того, как завели уведомление; тогда письмо без них
+		notification.Search, _ = a.searches.GetSearch(notification.SearchID)
+		notification.Ad, _ = a.repo.Get(notification.AdID)
+		err = a.notifier.Notify(ctx, user, notification)
+	}
+
+	notification.Attempts++
+
+	if err == nil {
+		notification.DeliveryTime = &now
+		notification.NextAttemptTime = nil
+		notification.Error = ""
+		return nil
+	}
+
+	log.Printf("notification %d: attempt %d: %v", notification.ID, notification.Attempts, err)
+	notification.Error = err.Error()
+	notification.NextAttemptTime = nil
+	if notification.Attempts < MaxNotifyAttempts {
+		next := now.Add(notifyRetryDelay << (notification.Attempts - 1))
+		notification.NextAttemptTime = &next
+	}
+	return err
+}
+
+// RunNotifications раз в interval доставляет уведомления по сохранённым поискам, пока не отменён ctx
+func RunNotifications(ctx context.Context, a App, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := a.DeliverNotifications(ctx, time.Now().UTC()); err != nil && ctx.Err() == nil {
+			log.Printf("notifications: %v", err)
+		} else if n > 0 {
+			log.Printf("notifications: %d delivered", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
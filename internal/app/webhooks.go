@@ -0,0 +1,191 @@
+package app
+
+import (
+	"adflow/internal/ads"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/url"
+	"time"
+)
+
+// MaxWebhookURL - предельная длина адреса вебхука
+const MaxWebhookURL = 2048
+
+// checkWebhook проверяет адрес и события подписки и убирает повторы событий
+func checkWebhook(rawURL string, events []ads.EventType) ([]ads.EventType, error) {
+	if len(rawURL) > MaxWebhookURL {
+		return nil, ads.ErrBadRequest
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ads.ErrBadRequest
+	}
+
+	if len(events) == 0 {
+		return nil, ads.ErrBadRequest
+	}
+	unique := make([]ads.EventType, 0, len(events))
+	for _, event := range events {
+		if !event.Valid() {
+			return nil, ads.ErrBadRequest
+		}
+		seen := false
+		for _, v := range unique {
+			seen = seen || v == event
+		}
+		if !seen {
+			unique = append(unique, event)
+		}
+	}
+	return unique, nil
+}
+
+// newWebhookSecret - 32 случайных байта в hex
+func newWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+func (a *localApp) CreateWebhook(ctx context.Context, rawURL string, events []ads.EventType) (*ads.Webhook, error) {
+	user, err := a.authorize(ctx, ActionManageWebhooks, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err = checkWebhook(rawURL, events)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	hook := &ads.Webhook{
+		URL:          rawURL,
+		Secret:       secret,
+		Events:       events,
+		Active:       true,
+		CreatorID:    user.ID,
+		CreationTime: now,
+		UpdateTime:   now,
+	}
+	if err := a.hooks.CreateWebhook(hook); err != nil {
+		return nil, err
+	}
+	return hook, nil
+}
+
+// Ключ подписи показывается один раз, при создании
+func hideSecret(hook *ads.Webhook) *ads.Webhook {
+	hook.Secret = ""
+	return hook
+}
+
+func (a *localApp) GetWebhook(ctx context.Context, id int64) (*ads.Webhook, error) {
+	if _, err := a.authorize(ctx, ActionManageWebhooks, 0); err != nil {
+		return nil, err
+	}
+
+	hook, err := a.hooks.GetWebhook(id)
+	if err != nil {
+		return nil, err
+	}
+	return hideSecret(hook), nil
+}
+
+func (a *localApp) ListWebhooks(ctx context.Context) ([]*ads.Webhook, error) {
+	if _, err := a.authorize(ctx, ActionManageWebhooks, 0); err != nil {
+		return nil, err
+	}
+
+	list, err := a.hooks.ListWebhooks()
+	if err != nil {
+		return nil, err
+	}
+	for _, hook := range list {
+		hideSecret(hook)
+	}
+	return list, nil
+}
+
+func (a *localApp) UpdateWebhook(ctx context.Context, id int64, rawURL string, events []ads.EventType, active bool) (*ads.Webhook, error) {
+	if _, err := a.authorize(ctx, ActionManageWebhooks, 0); err != nil {
+		return nil, err
+	}
+
+	events, err := checkWebhook(rawURL, events)
+	if err != nil {
+		return nil, err
+	}
+
+	hook, err := a.hooks.GetWebhook(id)
+	if err != nil {
+		return nil, err
+	}
+
+	hook.URL, hook.Events, hook.Active = rawURL, events, active
+	hook.UpdateTime = time.Now().UTC()
+	if err := a.hooks.UpdateWebhook(hook); err != nil {
+		return nil, err
+	}
+	return hideSecret(hook), nil
+}
+
+func (a *localApp) DeleteWebhook(ctx context.Context, id int64) error {
+	if _, err := a.authorize(ctx, ActionManageWebhooks, 0); err != nil {
+		return err
+	}
+
+	return a.hooks.DeleteWebhook(id)
+}
+
+func (a *localApp) ListWebhookDeliveries(ctx context.Context, webhookID int64, status ads.DeliveryStatus, beforeID int64, limit int) ([]*ads.WebhookDelivery, error) {
+	if _, err := a.authorize(ctx, ActionManageWebhooks, 0); err != nil {
+		return nil, err
+	}
+
+	if status != "" && !status.Valid() {
+		return nil, ads.ErrBadRequest
+	}
+	limit, err := pageLimit(beforeID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := a.hooks.GetWebhook(webhookID); err != nil {
+		return nil, err
+	}
+	return a.hooks.ListDeliveries(webhookID, status, beforeID, limit)
+}
+
+func (a *localApp) RetryWebhookDelivery(ctx context.Context, webhookID, deliveryID int64) (*ads.WebhookDelivery, error) {
+	if _, err := a.authorize(ctx, ActionManageWebhooks, 0); err != nil {
+		return nil, err
+	}
+
+	delivery, err := a.hooks.GetDelivery(deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.WebhookID != webhookID || delivery.Status == ads.DeliveryDelivered {
+		return nil, ads.ErrBadRequest
+	}
+
+	// Если диспетчер успел записать попытку после чтения, хранилище откажет и повтор придётся запросить снова
+	status, attempts := delivery.Status, delivery.Attempts
+	now := time.Now().UTC()
+	delivery.Status = ads.DeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptTime = &now
+	if err := a.hooks.SaveDelivery(delivery, status, attempts); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
@@ -0,0 +1,24 @@
+package blob
+
+import "strings"
+
+// ValidKey проверяет ключ, пришедший от клиента: латинские буквы, цифры, «-», «_», «.»
+// и «/» между непустыми частями, без «..». Такой ключ безопасно превращать в путь или URL.
+func ValidKey(key string) bool {
+	if key == "" || len(key) > 512 {
+		return false
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+		for _, r := range part {
+			switch {
+			case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			default:
+				return false
+			}
+		}
+	}
+	return true
+}
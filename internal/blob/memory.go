@@ -0,0 +1,50 @@
+package blob
+
+import (
+	"context"
+	"sync"
+
+	"adflow/internal/ads"
+)
+
+type object struct {
+	data        []byte
+	contentType string
+}
+
+// MemoryStore хранит файлы в памяти процесса
+type MemoryStore struct {
+	objects map[string]object
+	m       sync.RWMutex
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{objects: make(map[string]object)}
+}
+
+func (s *MemoryStore) Put(_ context.Context, key, contentType string, data []byte) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.objects[key] = object{data: append([]byte(nil), data...), contentType: contentType}
+	return nil
+}
+
+func (s *MemoryStore) Get(_ context.Context, key string) ([]byte, string, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	obj, ok := s.objects[key]
+	if !ok {
+		return nil, "", ads.ErrBadRequest
+	}
+	return obj.data, obj.contentType, nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	delete(s.objects, key)
+	return nil
+}
@@ -0,0 +1,125 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+
+	"adflow/internal/ads"
+)
+
+const (
+	// ThumbnailSize - наибольшая сторона миниатюры в пикселях
+	ThumbnailSize = 320
+	// MaxPixels защищает от картинок, которые малы в файле, но огромны после распаковки
+	MaxPixels   = 40_000_000
+	jpegQuality = 85
+)
+
+// Принимаемые форматы: тип, определённый по содержимому, имя формата в пакете image и расширение файла
+var formats = map[string]struct {
+	name string
+	ext  string
+}{
+	"image/jpeg": {"jpeg", ".jpg"},
+	"image/png":  {"png", ".png"},
+	"image/gif":  {"gif", ".gif"},
+	"image/webp": {"webp", ".webp"},
+}
+
+// Processed - проверенная картинка и её миниатюра
+type Processed struct {
+	ContentType string
+	Ext         string
+	Width       int
+	Height      int
+
+	Thumbnail            []byte
+	ThumbnailContentType string
+	ThumbnailExt         string
+}
+
+// Process определяет тип картинки по содержимому, а не по имени файла или заголовкам клиента,
+// проверяет, что она целиком декодируется, и строит миниатюру
+func Process(data []byte) (*Processed, error) {
+	contentType := http.DetectContentType(data)
+	format, ok := formats[contentType]
+	if !ok {
+		return nil, ads.ErrBadRequest
+	}
+
+	config, name, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || name != format.name || config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > MaxPixels {
+		return nil, ads.ErrBadRequest
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ads.ErrBadRequest
+	}
+
+	thumb, thumbType, thumbExt, err := thumbnail(img, contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Processed{
+		ContentType:          contentType,
+		Ext:                  format.ext,
+		Width:                config.Width,
+		Height:               config.Height,
+		Thumbnail:            thumb,
+		ThumbnailContentType: thumbType,
+		ThumbnailExt:         thumbExt,
+	}, nil
+}
+
+// Fit вписывает размеры в квадрат size x size с сохранением пропорций; меньшие картинки не увеличиваются
+func Fit(width, height, size int) (int, int) {
+	if width <= size && height <= size {
+		return width, height
+	}
+	if width >= height {
+		h := height * size / width
+		if h < 1 {
+			h = 1
+		}
+		return size, h
+	}
+	w := width * size / height
+	if w < 1 {
+		w = 1
+	}
+	return w, size
+}
+
+// Фотографии сжимаются в JPEG, остальное - в PNG, чтобы не потерять прозрачность
+func thumbnail(img image.Image, contentType string) ([]byte, string, string, error) {
+	bounds := img.Bounds()
+	width, height := Fit(bounds.Dx(), bounds.Dy(), ThumbnailSize)
+
+	var buf bytes.Buffer
+	if contentType == "image/jpeg" {
+		dst := image.NewRGBA(image.Rect(0, 0, width, height))
+		draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
+		if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, "", "", err
+		}
+		return buf.Bytes(), "image/jpeg", ".jpg", nil
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	if err := png.Encode(&buf, dst); err != nil {
+		return nil, "", "", err
+	}
+	return buf.Bytes(), "image/png", ".png", nil
+}
@@ -0,0 +1,94 @@
+// Package moderation - автоматическая проверка объявлений перед публикацией.
+// Правила только отмечают подозрительные объявления, решение принимает модератор.
+package moderation
+
+import (
+	"regexp"
+	"strings"
+
+	"adflow/internal/ads"
+	"adflow/internal/search"
+)
+
+type Rule interface {
+	Check(ad *ads.Ad) []ads.Flag
+}
+
+// RuleFunc позволяет задать правило функцией
+type RuleFunc func(ad *ads.Ad) []ads.Flag
+
+func (f RuleFunc) Check(ad *ads.Ad) []ads.Flag {
+	return f(ad)
+}
+
+// Rules проверяет объявление всеми правилами по очереди
+type Rules []Rule
+
+func (r Rules) Check(ad *ads.Ad) []ads.Flag {
+	var flags []ads.Flag
+	for _, rule := range r {
+		flags = append(flags, rule.Check(ad)...)
+	}
+	return flags
+}
+
+// Default - ссылки и телефоны; запрещённые слова у каждой площадки свои
+func Default() Rules {
+	return Rules{Links(), PhoneNumbers()}
+}
+
+func content(ad *ads.Ad) string {
+	return ad.Title + "\n" + ad.Text
+}
+
+// BannedWords отмечает объявления с запрещёнными словами. Слова сравниваются по основе,
+// поэтому запрет «оружие» находит и «оружия».
+func BannedWords(words ...string) Rule {
+	banned := make(map[string]string)
+	for _, word := range words {
+		for _, token := range ads.Tokens(word) {
+			if stem := search.Stem(token); stem != "" {
+				banned[stem] = token
+			}
+		}
+	}
+
+	return RuleFunc(func(ad *ads.Ad) []ads.Flag {
+		var flags []ads.Flag
+		seen := make(map[string]struct{})
+		for _, token := range ads.Tokens(content(ad)) {
+			word, ok := banned[search.Stem(token)]
+			if _, dup := seen[word]; !ok || dup {
+				continue
+			}
+			seen[word] = struct{}{}
+			flags = append(flags, ads.Flag{Rule: "banned_word", Detail: token})
+		}
+		return flags
+	})
+}
+
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+|\b[a-z0-9-]+(?:\.[a-z0-9-]+)*\.(?:ru|com|net|org|su|io|me|info|biz)\b(?:/\S*)?`)
+
+// Links отмечает ссылки: покупателей уводят с площадки на сторонние сайты
+func Links() Rule {
+	return matchRule("link", linkPattern)
+}
+
+// Телефон - от 10 до 15 цифр, между которыми допускаются пробелы, дефисы, точки и скобки
+var phonePattern = regexp.MustCompile(`\+?\d(?:[\s\-.()]*\d){9,14}`)
+
+// PhoneNumbers отмечает телефоны в тексте: для связи с продавцом есть сообщения на площадке
+func PhoneNumbers() Rule {
+	return matchRule("phone", phonePattern)
+}
+
+func matchRule(name string, pattern *regexp.Regexp) Rule {
+	return RuleFunc(func(ad *ads.Ad) []ads.Flag {
+		var flags []ads.Flag
+		for _, match := range pattern.FindAllString(content(ad), -1) {
+			flags = append(flags, ads.Flag{Rule: name, Detail: strings.TrimSpace(match)})
+		}
+		return flags
+	})
+}
@@ -0,0 +1,32 @@
+package notify
+
+import (
+	"adflow/internal/ads"
+	"adflow/internal/app"
+	"adflow/internal/outbox"
+	"context"
+)
+
+// Matcher подключается к outbox.Relay как приёмник: по событию публикации объявления заводит уведомления
+// по сохранённым поискам. Отправляет их app.RunNotifications, поэтому медленная почта не задерживает ни запрос
+// публикации, ни relay.
+type Matcher struct {
+	app app.App
+}
+
+func NewMatcher(a app.App) *Matcher {
+	return &Matcher{app: a}
+}
+
+// Deliver реализует outbox.Sink; повторно пришедшее событие уведомлений не дублирует
+func (m *Matcher) Deliver(ctx context.Context, events []outbox.Envelope) error {
+	for _, event := range events {
+		if event.Type != ads.EventAdPublished {
+			continue
+		}
+		if _, err := m.app.MatchSavedSearches(ctx, event.AggregateID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
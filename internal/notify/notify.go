@@ -0,0 +1,40 @@
+// Package notify доставляет уведомления о новых объявлениях по сохранённым поискам.
+package notify
+
+import (
+	"adflow/internal/ads"
+	"context"
+	"fmt"
+	"log"
+)
+
+// Message - тема и текст уведомления, общие для всех способов доставки
+func Message(notification *ads.Notification) (string, string) {
+	name := ""
+	if notification.Search != nil {
+		name = notification.Search.Name
+	}
+
+	subject := fmt.Sprintf("Новое объявление по запросу «%s»", name)
+	body := fmt.Sprintf("%s\n\nОбъявление %d: %s\n", subject, notification.AdID, notification.Title)
+	if ad := notification.Ad; ad != nil {
+		body += fmt.Sprintf("\n%s\n", ad.Text)
+	}
+	return subject, body
+}
+
+// Log пишет уведомления в журнал; подходит для разработки
+type Log struct {
+	Logger *log.Logger
+}
+
+func (l Log) Notify(_ context.Context, user *ads.User, notification *ads.Notification) error {
+	logger := l.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	subject, _ := Message(notification)
+	logger.Printf("notify user %d (%s): %s: ad %d %q", user.ID, user.Email, subject, notification.AdID, notification.Title)
+	return nil
+}
@@ -0,0 +1,193 @@
+// Package outbox доставляет события домена из outbox хранилищ во внешние приёмники.
+// Доставка «хотя бы один раз»: событие отмечается доставленным, только когда его приняли все приёмники.
+// Relay помнит, кто из приёмников уже принял событие, поэтому отказ одного приёмника не задерживает и не
+// повторяет доставку остальным. Эта память не переживает перезапуск, так что приёмник может получить событие
+// повторно и должен отбрасывать дубли по (source, id).
+package outbox
+
+import (
+	"adflow/internal/ads"
+	"adflow/internal/app"
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+)
+
+// DefaultBatchSize - сколько событий relay забирает из хранилища за раз
+const DefaultBatchSize = 100
+
+// Envelope - событие в том виде, в каком его получают приёмники
+type Envelope struct {
+	// Source - хранилище, из outbox которого взято событие; ID уникален только в его пределах
+	Source      string          `json:"source"`
+	ID          int64           `json:"id"`
+	Type        ads.EventType   `json:"type"`
+	AggregateID int64           `json:"aggregate_id"`
+	OccurredAt  time.Time       `json:"occurred_at"`
+	Payload     json.RawMessage `json:"payload"`
+}
+
+func NewEnvelope(source string, record *ads.OutboxRecord) Envelope {
+	return Envelope{
+		Source:      source,
+		ID:          record.ID,
+		Type:        record.Type,
+		AggregateID: record.AggregateID,
+		OccurredAt:  record.OccurredAt,
+		Payload:     json.RawMessage(record.Payload),
+	}
+}
+
+// Sink принимает пачку событий целиком или возвращает ошибку
+type Sink interface {
+	Deliver(ctx context.Context, events []Envelope) error
+}
+
+// Source - outbox одного хранилища под именем, которое попадёт в Envelope.Source
+type Source struct {
+	Name   string
+	Outbox app.Outbox
+}
+
+type Relay struct {
+	sources []Source
+	sinks   []Sink
+	batch   int
+
+	m sync.Mutex
+	// accepted - какие приёмники приняли событие, которое ещё не приняли все; по источнику и ID события
+	accepted map[string]map[int64][]bool
+}
+
+func NewRelay(sources []Source, sinks ...Sink) *Relay {
+	return &Relay{sources: sources, sinks: sinks, batch: DefaultBatchSize, accepted: make(map[string]map[int64][]bool)}
+}
+
+// Flush доставляет все накопившиеся события и возвращает, сколько из них приняли все приёмники.
+// Приёмник, вернувший ошибку, пропускает остаток обхода и получит свои события в следующий раз;
+// остальным он не мешает. Возвращается первая ошибка.
+func (r *Relay) Flush(ctx context.Context) (int, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	delivered := 0
+	var firstErr error
+	for _, source := range r.sources {
+		n, err := r.flushSource(ctx, source)
+		delivered += n
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return delivered, firstErr
+}
+
+func (r *Relay) flushSource(ctx context.Context, source Source) (int, error) {
+	accepted, ok := r.accepted[source.Name]
+	if !ok {
+		accepted = make(map[int64][]bool)
+		r.accepted[source.Name] = accepted
+	}
+
+	failed := make([]bool, len(r.sinks))
+	delivered := 0
+	var firstErr error
+
+	var afterID int64
+	for {
+		records, err := source.Outbox.PendingEvents(afterID, r.batch)
+		if err != nil {
+			return delivered, err
+		}
+		if len(records) == 0 {
+			break
+		}
+		afterID = records[len(records)-1].ID
+
+		events := make([]Envelope, 0, len(records))
+		for _, record := range records {
+			events = append(events, NewEnvelope(source.Name, record))
+			if accepted[record.ID] == nil {
+				accepted[record.ID] = make([]bool, len(r.sinks))
+			}
+		}
+
+		for i, sink := range r.sinks {
+			if failed[i] {
+				continue
+			}
+
+			var batch []Envelope
+			for _, event := range events {
+				if !accepted[event.ID][i] {
+					batch = append(batch, event)
+				}
+			}
+			if len(batch) == 0 {
+				continue
+			}
+
+			if err := sink.Deliver(ctx, batch); err != nil {
+				// Порядок событий приёмника сохраняется: следующие пачки он получит после этой
+				failed[i] = true
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			for _, event := range batch {
+				accepted[event.ID][i] = true
+			}
+		}
+
+		var ids []int64
+		for _, event := range events {
+			if all(accepted[event.ID]) {
+				ids = append(ids, event.ID)
+			}
+		}
+		if err := source.Outbox.MarkDelivered(ids, time.Now().UTC()); err != nil {
+			return delivered, err
+		}
+		for _, id := range ids {
+			delete(accepted, id)
+		}
+		delivered += len(ids)
+
+		if len(records) < r.batch {
+			break
+		}
+	}
+	return delivered, firstErr
+}
+
+func all(flags []bool) bool {
+	for _, flag := range flags {
+		if !flag {
+			return false
+		}
+	}
+	return true
+}
+
+// Run раз в interval вызывает Flush, пока не отменён ctx
+func (r *Relay) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := r.Flush(ctx); err != nil {
+			log.Printf("outbox: %v", err)
+		} else if n > 0 {
+			log.Printf("outbox: %d events delivered", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// File дописывает события в файл JSON Lines: одно событие - одна строка
+type File struct {
+	path string
+	m    sync.Mutex
+}
+
+func NewFile(path string) *File {
+	return &File{path: path}
+}
+
+func (f *File) Deliver(_ context.Context, events []Envelope) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			return err
+		}
+	}
+
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		file.Close()
+		return err
+	}
+	// Принятые события relay больше не отправляет, поэтому они должны быть на диске
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Сколько ждать ответа вебхука, если в контексте нет своего срока
+const webhookTimeout = 10 * time.Second
+
+// Webhook отправляет пачку событий одним POST-запросом с телом {"events": [...]}.
+// Любой ответ, кроме 2xx, считается отказом, и пачка уйдёт снова.
+type Webhook struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhook(url string, client *http.Client) *Webhook {
+	if client == nil {
+		client = &http.Client{Timeout: webhookTimeout}
+	}
+	return &Webhook{url: url, client: client}
+}
+
+func (w *Webhook) Deliver(ctx context.Context, events []Envelope) error {
+	body, err := json.Marshal(struct {
+		Events []Envelope `json:"events"`
+	}{events})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("outbox: webhook responded %s", resp.Status)
+	}
+	return nil
+}
@@ -3,276 +3,1047 @@ package app
 import (
 	"adflow/internal/ads"
 	"adflow/internal/app"
+	auth "adflow/internal/app/auth"
 	service "adflow/internal/ports/grpc/service"
+	"adflow/internal/pubsub"
 	"context"
+	"encoding/json"
+	"errors"
 	"strconv"
 	"time"
 
 	"github.com/golang/protobuf/ptypes/empty"
-	validator "github.com/skyberg11/args-validator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
 type AdService struct {
-	repo  app.Repository
-	users app.Users
+	a app.App
 	service.UnimplementedAdServiceServer
 }
 
-func (a *AdService) DeleteAd(ctx context.Context, req *service.DeleteAdRequest) (*empty.Empty, error) {
-	if _, err := a.users.Get(req.AuthorId); err != nil {
-		return nil, err
+// Приводит ошибки приложения к кодам gRPC
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, ads.ErrUnauthorized):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, ads.ErrAccessDenied):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, ads.ErrBadRequest):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
 	}
+}
 
-	if req.AuthorId != req.AdId {
-		return nil, ads.ErrAccessDenied
+func adResponse(ad *ads.Ad) *service.AdResponse {
+	images := make([]*service.Image, 0, len(ad.Images))
+	for _, image := range ad.Images {
+		images = append(images, &service.Image{
+			Id:           image.ID,
+			Url:          image.URL,
+			ThumbnailUrl: image.ThumbnailURL,
+			ContentType:  image.ContentType,
+			Size:         image.Size,
+			Width:        int32(image.Width),
+			Height:       int32(image.Height),
+		})
 	}
 
-	err := a.repo.DeleteAd(req.AdId)
+	var location *service.Location
+	if loc := ad.Location(); loc != nil {
+		location = &service.Location{Latitude: loc.Latitude, Longitude: loc.Longitude, City: loc.City}
+	}
 
-	if err != nil {
-		return nil, err
+	var expiresAt string
+	if ad.ExpiresAt != nil {
+		expiresAt = ad.ExpiresAt.Format(time.RFC3339)
+	}
+
+	return &service.AdResponse{
+		Id:             ad.ID,
+		Title:          ad.Title,
+		Text:           ad.Text,
+		AuthorId:       ad.AuthorID,
+		Published:      ad.Published,
+		CreationDate:   ad.CreationTime.String(),
+		UpdateDate:     ad.UpdateTime.String(),
+		CategoryId:     ad.CategoryID,
+		Price:          ad.Price,
+		Currency:       ad.Currency,
+		Negotiable:     ad.Negotiable,
+		Images:         images,
+		Location:       location,
+		Status:         string(ad.AdStatus()),
+		ExpiresAt:      expiresAt,
+		FavoritesCount: ad.FavoriteCount,
+	}
+}
+
+// Пустые обёртки цены и признака торга и пустое место означают «не задано»
+func draftFromProto(title, text string, categoryID int64, price *wrapperspb.Int64Value, currency string, negotiable *wrapperspb.BoolValue, location *service.Location) ads.Draft {
+	draft := ads.Draft{Title: title, Text: text, CategoryID: categoryID, Currency: currency}
+	if location != nil {
+		draft.Location = &ads.Location{Point: ads.Point{Latitude: location.Latitude, Longitude: location.Longitude}, City: location.City}
+	}
+	if price != nil {
+		draft.Price = &price.Value
+	}
+	if negotiable != nil {
+		draft.Negotiable = &negotiable.Value
+	}
+	return draft
+}
+
+func userResponse(user *ads.User) *service.UserResponse {
+	return &service.UserResponse{
+		Id:         user.ID,
+		FirstName:  user.FirstName,
+		SecondName: user.SecondName,
+		Email:      user.Email,
+		Phone:      user.Phone,
+		Role:       string(user.UserRole()),
+	}
+}
+
+func (s *AdService) DeleteAd(ctx context.Context, req *service.DeleteAdRequest) (*empty.Empty, error) {
+	if err := s.a.DeleteAd(ctx, req.AdId); err != nil {
+		return nil, toStatus(err)
 	}
 
 	return &empty.Empty{}, nil
 }
 
-func (a *AdService) ListAds(ctx context.Context, filter *service.Filter) (*service.ListAdResponse, error) {
+func parseTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Переводит Filter из protobuf в ads.Filter; author_id и creation_time дополняют новые поля
+func filterFromProto(filter *service.Filter) (ads.Filter, error) {
+	if filter == nil {
+		return ads.Filter{}, nil
+	}
+
 	f := ads.Filter{
-		Published:    nil,
-		AuthorID:     nil,
-		TitlePrefix:  nil,
-		CreationTime: nil,
+		AuthorIDs:        filter.AuthorIds,
+		ExcludeAuthorIDs: filter.ExcludeAuthorIds,
+		TitlePrefix:      filter.Prefix,
+		TitleContains:    filter.TitleContains,
+		Text:             filter.Text,
+		CategoryIDs:      filter.CategoryIds,
+		Currency:         filter.Currency,
 	}
 	var err error
 
-	published := filter.Published
-	if published != "" {
-		f.Published, err = strconv.ParseBool(published)
+	prices := []struct {
+		value string
+		dst   **int64
+	}{
+		{filter.MinPrice, &f.Price.Min},
+		{filter.MaxPrice, &f.Price.Max},
+	}
+	for _, v := range prices {
+		if v.value != "" {
+			price, err := strconv.ParseInt(v.value, 10, 64)
+			if err != nil {
+				return f, err
+			}
+			*v.dst = &price
+		}
+	}
+
+	for _, v := range filter.Statuses {
+		status := ads.Status(v)
+		if !status.Valid() {
+			return f, ads.ErrBadRequest
+		}
+		f.Statuses = append(f.Statuses, status)
+	}
+
+	if f.Near, err = ads.ParseNear(filter.Near, filter.RadiusKm); err != nil {
+		return f, err
+	}
+
+	if published := filter.Published; published != "" {
+		value, err := strconv.ParseBool(published)
 		if err != nil {
-			return nil, err
+			return f, err
 		}
+		f.Published = &value
 	}
 
-	authorID := filter.AuthorId
-	if authorID != "" {
-		f.AuthorID, err = strconv.ParseInt(authorID, 10, 64)
+	if authorID := filter.AuthorId; authorID != "" {
+		id, err := strconv.ParseInt(authorID, 10, 64)
 		if err != nil {
-			return nil, err
+			return f, err
 		}
+		f.AuthorIDs = append(f.AuthorIDs, id)
+	}
+
+	createdAfter := filter.CreatedAfter
+	if createdAfter == "" {
+		createdAfter = filter.CreationTime
+	}
+	if f.Created.After, err = parseTime(createdAfter); err != nil {
+		return f, err
+	}
+	if f.Created.Before, err = parseTime(filter.CreatedBefore); err != nil {
+		return f, err
+	}
+	if f.Updated.After, err = parseTime(filter.UpdatedAfter); err != nil {
+		return f, err
+	}
+	if f.Updated.Before, err = parseTime(filter.UpdatedBefore); err != nil {
+		return f, err
 	}
 
-	f.TitlePrefix = filter.Prefix
+	return f, nil
+}
+
+func pageFromProto(filter *service.Filter) (ads.Page, error) {
+	page := ads.Page{
+		Sort:  ads.Sort{Field: ads.SortField(filter.Sort), Desc: filter.Desc},
+		Limit: int(filter.Limit),
+	}
 
-	creationTime := filter.CreationTime
-	if creationTime != "" {
-		f.CreationTime, err = time.Parse(time.Now().UTC().String(), creationTime)
+	if filter.Cursor != "" {
+		cursor, err := ads.DecodeCursor(filter.Cursor)
 		if err != nil {
-			return nil, err
+			return page, err
 		}
+		page.After = cursor
 	}
 
-	ads, err := a.repo.GetAds(f)
+	return page, nil
+}
 
+func (s *AdService) SearchAds(ctx context.Context, req *service.SearchAdsRequest) (*service.SearchAdsResponse, error) {
+	results, err := s.a.SearchAds(ctx, req.Query, int(req.Limit), int(req.Offset))
 	if err != nil {
-		return nil, err
+		return nil, toStatus(err)
 	}
-	var List []*service.AdResponse
 
-	for _, ad := range ads {
-		List = append(List, &service.AdResponse{
-			Id:           ad.ID,
-			Title:        ad.Title,
-			Text:         ad.Text,
-			AuthorId:     ad.AuthorID,
-			Published:    ad.Published,
-			CreationDate: ad.CreationTime.String(),
-			UpdateDate:   ad.UpdateTime.String(),
+	list := make([]*service.SearchResult, 0, len(results))
+	for _, v := range results {
+		list = append(list, &service.SearchResult{
+			Ad:      adResponse(v.Ad),
+			Score:   v.Score,
+			Snippet: v.Snippet,
 		})
 	}
 
+	return &service.SearchAdsResponse{List: list}, nil
+}
+
+func (s *AdService) ListAds(ctx context.Context, filter *service.Filter) (*service.ListAdResponse, error) {
+	f, err := filterFromProto(filter)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	page, err := pageFromProto(filter)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	list, next, err := s.a.ListAds(ctx, f, page)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	var nextCursor string
+	if next != nil {
+		nextCursor = next.Encode()
+	}
+
+	var List []*service.AdResponse
+	for _, ad := range list {
+		List = append(List, adResponse(ad))
+	}
+
 	return &service.ListAdResponse{
-		List: List,
+		List:       List,
+		NextCursor: nextCursor,
 	}, nil
 }
 
-func (a *AdService) CreateAd(ctx context.Context, req *service.CreateAdRequest) (*service.AdResponse, error) {
-	if _, err := a.users.Get(req.UserId); err != nil {
-		return nil, err
+func (s *AdService) CreateAd(ctx context.Context, req *service.CreateAdRequest) (*service.AdResponse, error) {
+	ad, err := s.a.CreateAd(ctx, draftFromProto(req.Title, req.Text, req.CategoryId, req.Price, req.Currency, req.Negotiable, req.Location))
+	if err != nil {
+		return nil, toStatus(err)
 	}
 
-	ad := &ads.Ad{
-		Title:     req.Title,
-		Text:      req.Text,
-		AuthorID:  req.UserId,
-		Published: false,
+	return adResponse(ad), nil
+}
+
+func (s *AdService) GetAd(ctx context.Context, req *service.GetAdRequest) (*service.AdResponse, error) {
+	ad, err := s.a.GetAd(ctx, req.Id)
+	if err != nil {
+		return nil, toStatus(err)
 	}
 
-	if err := validator.Validate(*ad); err != nil {
-		return nil, ads.ErrBadRequest
+	return adResponse(ad), nil
+}
+
+func (s *AdService) ChangeAdStatus(ctx context.Context, req *service.ChangeAdStatusRequest) (*service.AdResponse, error) {
+	var ad *ads.Ad
+	var err error
+	if req.Status != "" {
+		ad, err = s.a.TransitionAd(ctx, req.AdId, ads.Status(req.Status))
+	} else {
+		ad, err = s.a.ChangeAdStatus(ctx, req.AdId, req.Published)
+	}
+	if err != nil {
+		return nil, toStatus(err)
 	}
 
-	err := a.repo.Create(ad)
+	return adResponse(ad), nil
+}
+
+func (s *AdService) RenewAd(ctx context.Context, req *service.RenewAdRequest) (*service.AdResponse, error) {
+	ad, err := s.a.RenewAd(ctx, req.AdId)
 	if err != nil {
-		return nil, err
+		return nil, toStatus(err)
 	}
 
-	return &service.AdResponse{
-		Id:           ad.ID,
-		Title:        ad.Title,
-		Text:         ad.Text,
-		AuthorId:     ad.AuthorID,
-		Published:    ad.Published,
-		CreationDate: ad.CreationTime.String(),
-		UpdateDate:   ad.UpdateTime.String(),
-	}, nil
+	return adResponse(ad), nil
 }
 
-func (a *AdService) GetAd(ctx context.Context, req *service.GetAdRequest) (*service.AdResponse, error) {
-	ad, err := a.repo.Get(req.Id)
+func reviewResponse(review *ads.Review) *service.Review {
+	flags := make([]*service.Flag, 0, len(review.Flags))
+	for _, flag := range review.Flags {
+		flags = append(flags, &service.Flag{Rule: flag.Rule, Detail: flag.Detail})
+	}
+
+	resp := &service.Review{
+		Id:           review.ID,
+		AdId:         review.AdID,
+		Decision:     string(review.Decision),
+		Flags:        flags,
+		Reason:       review.Reason,
+		ModeratorId:  review.ModeratorID,
+		CreationTime: review.CreationTime.Format(time.RFC3339),
+	}
+	if review.DecisionTime != nil {
+		resp.DecisionTime = review.DecisionTime.Format(time.RFC3339)
+	}
+	if review.Ad != nil {
+		resp.Ad = adResponse(review.Ad)
+	}
+	return resp
+}
+
+func reviewList(reviews []*ads.Review) *service.ReviewList {
+	list := make([]*service.Review, 0, len(reviews))
+	for _, review := range reviews {
+		list = append(list, reviewResponse(review))
+	}
+	return &service.ReviewList{List: list}
+}
+
+func (s *AdService) ListModerationQueue(ctx context.Context, req *service.ModerationQueueRequest) (*service.ReviewList, error) {
+	reviews, err := s.a.ListModerationQueue(ctx, req.AfterId, int(req.Limit))
 	if err != nil {
-		return nil, err
+		return nil, toStatus(err)
 	}
-	return &service.AdResponse{
-		Id:           ad.ID,
-		Title:        ad.Title,
-		Text:         ad.Text,
-		AuthorId:     ad.AuthorID,
-		Published:    ad.Published,
-		CreationDate: ad.CreationTime.String(),
-		UpdateDate:   ad.UpdateTime.String(),
-	}, nil
+
+	return reviewList(reviews), nil
 }
 
-func (a *AdService) ChangeAdStatus(ctx context.Context, req *service.ChangeAdStatusRequest) (*service.AdResponse, error) {
-	if _, err := a.users.Get(req.UserId); err != nil {
-		return nil, err
+func (s *AdService) ApproveAd(ctx context.Context, req *service.ApproveAdRequest) (*service.AdResponse, error) {
+	ad, err := s.a.ApproveAd(ctx, req.AdId)
+	if err != nil {
+		return nil, toStatus(err)
 	}
 
-	ad, err := a.repo.Get(req.AdId)
+	return adResponse(ad), nil
+}
 
+func (s *AdService) RejectAd(ctx context.Context, req *service.RejectAdRequest) (*service.AdResponse, error) {
+	ad, err := s.a.RejectAd(ctx, req.AdId, req.Reason)
 	if err != nil {
-		return nil, err
+		return nil, toStatus(err)
 	}
 
-	if ad.AuthorID != req.UserId {
-		return nil, ads.ErrAccessDenied
+	return adResponse(ad), nil
+}
+
+func (s *AdService) ListAdReviews(ctx context.Context, req *service.ListAdReviewsRequest) (*service.ReviewList, error) {
+	reviews, err := s.a.ListAdReviews(ctx, req.AdId)
+	if err != nil {
+		return nil, toStatus(err)
 	}
 
-	ad, err = a.repo.UpdateStatus(req.AdId, req.Published)
+	return reviewList(reviews), nil
+}
+
+func favoriteResponse(favorite *ads.Favorite) *service.Favorite {
+	return &service.Favorite{
+		Id:           favorite.ID,
+		AdId:         favorite.AdID,
+		CreationTime: favorite.CreationTime.Format(time.RFC3339),
+		Ad:           adResponse(favorite.Ad),
+	}
+}
+
+func (s *AdService) AddFavorite(ctx context.Context, req *service.FavoriteRequest) (*service.Favorite, error) {
+	favorite, err := s.a.AddFavorite(ctx, req.UserId, req.AdId)
 	if err != nil {
-		return nil, err
+		return nil, toStatus(err)
 	}
-	return &service.AdResponse{
-		Id:           ad.ID,
-		Title:        ad.Title,
-		Text:         ad.Text,
-		AuthorId:     ad.AuthorID,
-		Published:    ad.Published,
-		CreationDate: ad.CreationTime.String(),
-		UpdateDate:   ad.UpdateTime.String(),
-	}, nil
+
+	return favoriteResponse(favorite), nil
 }
 
-func (a *AdService) UpdateAd(ctx context.Context, req *service.UpdateAdRequest) (*service.AdResponse, error) {
-	if _, err := a.users.Get(req.UserId); err != nil {
-		return nil, err
+func (s *AdService) RemoveFavorite(ctx context.Context, req *service.FavoriteRequest) (*empty.Empty, error) {
+	if err := s.a.RemoveFavorite(ctx, req.UserId, req.AdId); err != nil {
+		return nil, toStatus(err)
 	}
 
-	ad, err := a.repo.Get(req.AdId)
+	return &empty.Empty{}, nil
+}
+
+func (s *AdService) ListFavorites(ctx context.Context, req *service.ListFavoritesRequest) (*service.FavoriteList, error) {
+	favorites, err := s.a.ListFavorites(ctx, req.UserId, req.BeforeId, int(req.Limit))
 	if err != nil {
-		return nil, err
+		return nil, toStatus(err)
 	}
 
-	if ad.AuthorID != req.UserId {
-		return nil, ads.ErrAccessDenied
+	list := make([]*service.Favorite, 0, len(favorites))
+	for _, favorite := range favorites {
+		list = append(list, favoriteResponse(favorite))
 	}
-	prevTitle, prevText := ad.Title, ad.Text
+	return &service.FavoriteList{List: list}, nil
+}
 
-	ad, err = a.repo.Update(req.AdId, req.Title, req.Text)
+// filterToProto возвращает условия сохранённого поиска в том же виде, в каком они задаются
+func filterToProto(f ads.Filter) *service.Filter {
+	filter := &service.Filter{
+		AuthorIds:        f.AuthorIDs,
+		ExcludeAuthorIds: f.ExcludeAuthorIDs,
+		Prefix:           f.TitlePrefix,
+		TitleContains:    f.TitleContains,
+		Text:             f.Text,
+		CategoryIds:      f.CategoryIDs,
+		Currency:         f.Currency,
+	}
+	if f.Price.Min != nil {
+		filter.MinPrice = strconv.FormatInt(*f.Price.Min, 10)
+	}
+	if f.Price.Max != nil {
+		filter.MaxPrice = strconv.FormatInt(*f.Price.Max, 10)
+	}
+	if near := f.Near; near != nil {
+		filter.Near = strconv.FormatFloat(near.Center.Latitude, 'g', -1, 64) + "," + strconv.FormatFloat(near.Center.Longitude, 'g', -1, 64)
+		if near.RadiusKm != 0 {
+			filter.RadiusKm = strconv.FormatFloat(near.RadiusKm, 'g', -1, 64)
+		}
+	}
+	return filter
+}
+
+func savedSearchResponse(search *ads.SavedSearch) *service.SavedSearch {
+	return &service.SavedSearch{
+		Id:           search.ID,
+		Name:         search.Name,
+		Filter:       filterToProto(search.Filter),
+		CreationTime: search.CreationTime.Format(time.RFC3339),
+	}
+}
 
-	if err := validator.Validate(*ad); err != nil {
-		if _, err = a.repo.Update(req.AdId, prevTitle, prevText); err != nil {
-			panic("something went wrong")
+func (s *AdService) CreateSavedSearch(ctx context.Context, req *service.CreateSavedSearchRequest) (*service.SavedSearch, error) {
+	filter, err := filterFromProto(req.Filter)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	search, err := s.a.CreateSavedSearch(ctx, req.UserId, req.Name, filter)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return savedSearchResponse(search), nil
+}
+
+func (s *AdService) ListSavedSearches(ctx context.Context, req *service.ListSavedSearchesRequest) (*service.SavedSearchList, error) {
+	searches, err := s.a.ListSavedSearches(ctx, req.UserId)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	list := make([]*service.SavedSearch, 0, len(searches))
+	for _, search := range searches {
+		list = append(list, savedSearchResponse(search))
+	}
+	return &service.SavedSearchList{List: list}, nil
+}
+
+func (s *AdService) UpdateSavedSearch(ctx context.Context, req *service.UpdateSavedSearchRequest) (*service.SavedSearch, error) {
+	filter, err := filterFromProto(req.Filter)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	search, err := s.a.UpdateSavedSearch(ctx, req.UserId, req.SearchId, req.Name, filter)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return savedSearchResponse(search), nil
+}
+
+func (s *AdService) DeleteSavedSearch(ctx context.Context, req *service.DeleteSavedSearchRequest) (*empty.Empty, error) {
+	if err := s.a.DeleteSavedSearch(ctx, req.UserId, req.SearchId); err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &empty.Empty{}, nil
+}
+
+func (s *AdService) ListNotifications(ctx context.Context, req *service.ListNotificationsRequest) (*service.NotificationList, error) {
+	notifications, err := s.a.ListNotifications(ctx, req.UserId, req.BeforeId, int(req.Limit))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	list := make([]*service.Notification, 0, len(notifications))
+	for _, n := range notifications {
+		notification := &service.Notification{
+			Id:           n.ID,
+			SearchId:     n.SearchID,
+			AdId:         n.AdID,
+			Title:        n.Title,
+			CreationTime: n.CreationTime.Format(time.RFC3339),
+			Error:        n.Error,
 		}
-		return nil, ads.ErrBadRequest
+		if n.DeliveryTime != nil {
+			notification.DeliveryTime = n.DeliveryTime.Format(time.RFC3339)
+		}
+		list = append(list, notification)
+	}
+	return &service.NotificationList{List: list}, nil
+}
+
+func webhookResponse(hook *ads.Webhook) *service.Webhook {
+	events := make([]string, 0, len(hook.Events))
+	for _, event := range hook.Events {
+		events = append(events, string(event))
+	}
+
+	return &service.Webhook{
+		Id:           hook.ID,
+		Url:          hook.URL,
+		Events:       events,
+		Active:       hook.Active,
+		Secret:       hook.Secret,
+		CreatorId:    hook.CreatorID,
+		CreationTime: hook.CreationTime.Format(time.RFC3339),
+		UpdateTime:   hook.UpdateTime.Format(time.RFC3339),
+	}
+}
+
+func eventTypes(events []string) []ads.EventType {
+	list := make([]ads.EventType, 0, len(events))
+	for _, event := range events {
+		list = append(list, ads.EventType(event))
+	}
+	return list
+}
+
+// Пустая строка вместо времени, которого нет
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func webhookDeliveryResponse(d *ads.WebhookDelivery) *service.WebhookDelivery {
+	return &service.WebhookDelivery{
+		Id:              d.ID,
+		WebhookId:       d.WebhookID,
+		Source:          d.Source,
+		EventId:         d.EventID,
+		EventType:       string(d.EventType),
+		Payload:         d.Payload,
+		Status:          string(d.Status),
+		Attempts:        int32(d.Attempts),
+		NextAttemptTime: formatOptionalTime(d.NextAttemptTime),
+		LastAttemptTime: formatOptionalTime(d.LastAttemptTime),
+		ResponseStatus:  int32(d.ResponseStatus),
+		Error:           d.Error,
+		CreationTime:    d.CreationTime.Format(time.RFC3339),
+		DeliveryTime:    formatOptionalTime(d.DeliveryTime),
 	}
+}
 
+func (s *AdService) CreateWebhook(ctx context.Context, req *service.CreateWebhookRequest) (*service.Webhook, error) {
+	hook, err := s.a.CreateWebhook(ctx, req.Url, eventTypes(req.Events))
 	if err != nil {
-		return nil, err
+		return nil, toStatus(err)
 	}
+	return webhookResponse(hook), nil
+}
 
-	return &service.AdResponse{
-		Id:           ad.ID,
-		Title:        ad.Title,
-		Text:         ad.Text,
-		AuthorId:     ad.AuthorID,
-		Published:    ad.Published,
-		CreationDate: ad.CreationTime.String(),
-		UpdateDate:   ad.UpdateTime.String(),
-	}, nil
+func (s *AdService) GetWebhook(ctx context.Context, req *service.WebhookRequest) (*service.Webhook, error) {
+	hook, err := s.a.GetWebhook(ctx, req.Id)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return webhookResponse(hook), nil
 }
 
-func (a *AdService) CreateUser(ctx context.Context, req *service.CreateUserRequest) (*service.UserResponse, error) {
-	user := &ads.User{
-		FirstName:  req.FirstName,
-		SecondName: req.SecondName,
-		Nickname:   req.Nickname,
-		Password:   req.Password,
-		Email:      req.Email,
-		Phone:      req.Phone,
+func (s *AdService) ListWebhooks(ctx context.Context, _ *empty.Empty) (*service.WebhookList, error) {
+	hooks, err := s.a.ListWebhooks(ctx)
+	if err != nil {
+		return nil, toStatus(err)
 	}
 
-	if err := validator.Validate(*user); err != nil {
-		return nil, ads.ErrBadRequest
+	list := make([]*service.Webhook, 0, len(hooks))
+	for _, hook := range hooks {
+		list = append(list, webhookResponse(hook))
 	}
+	return &service.WebhookList{List: list}, nil
+}
 
-	err := a.users.Create(user)
+func (s *AdService) UpdateWebhook(ctx context.Context, req *service.UpdateWebhookRequest) (*service.Webhook, error) {
+	active := req.Active == nil || req.Active.Value
+	hook, err := s.a.UpdateWebhook(ctx, req.Id, req.Url, eventTypes(req.Events), active)
 	if err != nil {
-		return nil, err
+		return nil, toStatus(err)
 	}
+	return webhookResponse(hook), nil
+}
 
-	return &service.UserResponse{
-		Id:         user.ID,
-		FirstName:  user.FirstName,
-		SecondName: user.SecondName,
-		Email:      user.Email,
-		Phone:      user.Phone,
-	}, nil
+func (s *AdService) DeleteWebhook(ctx context.Context, req *service.WebhookRequest) (*empty.Empty, error) {
+	if err := s.a.DeleteWebhook(ctx, req.Id); err != nil {
+		return nil, toStatus(err)
+	}
+	return &empty.Empty{}, nil
 }
 
-func (a *AdService) GetUser(ctx context.Context, req *service.GetUserRequest) (*service.UserResponse, error) {
-	user, err := a.users.Get(req.Id)
+func (s *AdService) ListWebhookDeliveries(ctx context.Context, req *service.ListWebhookDeliveriesRequest) (*service.WebhookDeliveryList, error) {
+	deliveries, err := s.a.ListWebhookDeliveries(ctx, req.WebhookId, ads.DeliveryStatus(req.Status), req.BeforeId, int(req.Limit))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	list := make([]*service.WebhookDelivery, 0, len(deliveries))
+	for _, d := range deliveries {
+		list = append(list, webhookDeliveryResponse(d))
+	}
+	return &service.WebhookDeliveryList{List: list}, nil
+}
 
+func (s *AdService) RetryWebhookDelivery(ctx context.Context, req *service.RetryWebhookDeliveryRequest) (*service.WebhookDelivery, error) {
+	delivery, err := s.a.RetryWebhookDelivery(ctx, req.WebhookId, req.DeliveryId)
 	if err != nil {
-		return nil, err
+		return nil, toStatus(err)
 	}
+	return webhookDeliveryResponse(delivery), nil
+}
 
-	return &service.UserResponse{
-		Id:         user.ID,
-		FirstName:  user.FirstName,
-		SecondName: user.SecondName,
-		Email:      user.Email,
-		Phone:      user.Phone,
-	}, nil
+func eventResponse(event pubsub.Event) *service.Event {
+	resp := &service.Event{
+		Id:    event.ID,
+		Topic: event.Topic,
+		Type:  string(event.Type),
+		Time:  event.Time.Format(time.RFC3339),
+	}
+	if event.Ad != nil {
+		resp.Ad = adResponse(event.Ad)
+	}
+	if event.Message != nil {
+		resp.Message = messageResponse(event.Message)
+	}
+	if r := event.Receipt; r != nil {
+		resp.Receipt = &service.ReadReceipt{
+			ConversationId: r.ConversationID,
+			ReaderId:       r.ReaderID,
+			UpToId:         r.UpToID,
+			ReadTime:       r.ReadTime.Format(time.RFC3339),
+		}
+	}
+	return resp
 }
 
-func (a *AdService) DeleteUser(ctx context.Context, req *service.DeleteUserRequest) (*empty.Empty, error) {
-	if req.Id != req.UserId {
-		return nil, ads.ErrAccessDenied
+func messageResponse(message *ads.Message) *service.Message {
+	resp := &service.Message{
+		Id:             message.ID,
+		ConversationId: message.ConversationID,
+		SenderId:       message.SenderID,
+		Text:           message.Text,
+		CreationTime:   message.CreationTime.Format(time.RFC3339),
 	}
+	if message.ReadTime != nil {
+		resp.ReadTime = message.ReadTime.Format(time.RFC3339)
+	}
+	return resp
+}
 
-	err := a.users.Delete(req.Id)
+func conversationResponse(conversation *ads.Conversation) *service.Conversation {
+	resp := &service.Conversation{
+		Id:           conversation.ID,
+		AdId:         conversation.AdID,
+		AuthorId:     conversation.AuthorID,
+		BuyerId:      conversation.BuyerID,
+		CreationTime: conversation.CreationTime.Format(time.RFC3339),
+		Unread:       conversation.Unread,
+	}
+	if conversation.LastMessage != nil {
+		resp.LastMessage = messageResponse(conversation.LastMessage)
+	}
+	if conversation.Ad != nil {
+		resp.Ad = adResponse(conversation.Ad)
+	}
+	return resp
+}
 
+func (s *AdService) ContactAuthor(ctx context.Context, req *service.ContactAuthorRequest) (*service.Message, error) {
+	message, err := s.a.ContactAuthor(ctx, req.AdId, req.Text)
 	if err != nil {
-		return nil, err
+		return nil, toStatus(err)
+	}
+
+	return messageResponse(message), nil
+}
+
+func (s *AdService) SendMessage(ctx context.Context, req *service.SendMessageRequest) (*service.Message, error) {
+	message, err := s.a.SendMessage(ctx, req.ConversationId, req.Text)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return messageResponse(message), nil
+}
+
+func (s *AdService) ListConversations(ctx context.Context, req *service.ListConversationsRequest) (*service.ConversationList, error) {
+	conversations, err := s.a.ListConversations(ctx, req.UserId, req.AdId, req.BeforeId, int(req.Limit))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	list := make([]*service.Conversation, 0, len(conversations))
+	for _, conversation := range conversations {
+		list = append(list, conversationResponse(conversation))
+	}
+	return &service.ConversationList{List: list}, nil
+}
+
+func (s *AdService) ListMessages(ctx context.Context, req *service.ListMessagesRequest) (*service.MessageList, error) {
+	messages, err := s.a.ListMessages(ctx, req.ConversationId, req.BeforeId, int(req.Limit))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	list := make([]*service.Message, 0, len(messages))
+	for _, message := range messages {
+		list = append(list, messageResponse(message))
+	}
+	return &service.MessageList{List: list}, nil
+}
+
+func (s *AdService) MarkRead(ctx context.Context, req *service.MarkReadRequest) (*service.Conversation, error) {
+	conversation, err := s.a.MarkRead(ctx, req.ConversationId, req.MessageId)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return conversationResponse(conversation), nil
+}
+
+func (s *AdService) ListInbox(ctx context.Context, req *service.ListInboxRequest) (*service.InboxList, error) {
+	entries, err := s.a.ListInbox(ctx, req.UserId, req.BeforeId, int(req.Limit))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	list := make([]*service.InboxEntry, 0, len(entries))
+	for _, v := range entries {
+		entry := &service.InboxEntry{
+			AdId:          v.AdID,
+			LastMessageId: v.LastMessageID,
+			Conversations: v.Conversations,
+			Unread:        v.Unread,
+		}
+		if v.Ad != nil {
+			entry.Ad = adResponse(v.Ad)
+		}
+		list = append(list, entry)
+	}
+	return &service.InboxList{List: list}, nil
+}
+
+// Subscribe отдаёт события тем, пока клиент не отменит вызов.
+// Подписка действует с момента, когда клиент получил заголовки ответа.
+// Медленного подписчика шина отключает, и поток завершается с ResourceExhausted;
+// при остановке сервиса шину закрывают, и поток завершается с Unavailable.
+func (s *AdService) Subscribe(req *service.SubscribeRequest, stream service.AdService_SubscribeServer) error {
+	ctx := stream.Context()
+	sub, err := s.a.Subscribe(ctx, req.Topics)
+	if err != nil {
+		return toStatus(err)
+	}
+	defer sub.Close()
+
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case event := <-sub.Events():
+			if err := stream.Send(eventResponse(event)); err != nil {
+				return err
+			}
+		case <-sub.Done():
+			switch err := sub.Err(); {
+			case errors.Is(err, pubsub.ErrSlowConsumer):
+				return status.Error(codes.ResourceExhausted, err.Error())
+			case errors.Is(err, pubsub.ErrClosed):
+				return status.Error(codes.Unavailable, err.Error())
+			}
+			return nil
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (s *AdService) UpdateAd(ctx context.Context, req *service.UpdateAdRequest) (*service.AdResponse, error) {
+	ad, err := s.a.UpdateAd(ctx, req.AdId, draftFromProto(req.Title, req.Text, req.CategoryId, req.Price, req.Currency, req.Negotiable, req.Location))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return adResponse(ad), nil
+}
+
+func revisionResponse(rev *ads.Revision) *service.Revision {
+	resp := &service.Revision{
+		Number:       rev.Number,
+		AdId:         rev.AdID,
+		AuthorId:     rev.AuthorID,
+		RestoredFrom: rev.RestoredFrom,
+		CreationTime: rev.CreationTime.Format(time.RFC3339),
+		Title:        rev.Title,
+		Text:         rev.Text,
+		CategoryId:   rev.CategoryID,
+		Price:        rev.Price,
+		Currency:     rev.Currency,
+		Negotiable:   rev.Negotiable,
+	}
+	if loc := rev.Location(); loc != nil {
+		resp.Location = &service.Location{Latitude: loc.Latitude, Longitude: loc.Longitude, City: loc.City}
+	}
+	return resp
+}
+
+// changeValue кодирует значение поля в JSON; место - с теми же ключами, что и в HTTP API
+func changeValue(value any) string {
+	if loc, ok := value.(*ads.Location); ok {
+		if loc == nil {
+			return "null"
+		}
+		value = struct {
+			Latitude  float64 `json:"latitude"`
+			Longitude float64 `json:"longitude"`
+			City      string  `json:"city"`
+		}{loc.Latitude, loc.Longitude, loc.City}
+	}
+
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+func (s *AdService) ListAdRevisions(ctx context.Context, req *service.ListAdRevisionsRequest) (*service.RevisionList, error) {
+	revisions, err := s.a.ListAdRevisions(ctx, req.AdId, req.Before, int(req.Limit))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	list := make([]*service.Revision, 0, len(revisions))
+	for _, rev := range revisions {
+		list = append(list, revisionResponse(rev))
+	}
+	return &service.RevisionList{List: list}, nil
+}
+
+func (s *AdService) GetAdRevision(ctx context.Context, req *service.AdRevisionRequest) (*service.Revision, error) {
+	rev, err := s.a.GetAdRevision(ctx, req.AdId, req.Number)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return revisionResponse(rev), nil
+}
+
+func (s *AdService) DiffAdRevisions(ctx context.Context, req *service.DiffAdRevisionsRequest) (*service.RevisionDiff, error) {
+	changes, err := s.a.DiffAdRevisions(ctx, req.AdId, req.From, req.To)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	list := make([]*service.Change, 0, len(changes))
+	for _, change := range changes {
+		list = append(list, &service.Change{Field: change.Field, From: changeValue(change.From), To: changeValue(change.To)})
+	}
+	return &service.RevisionDiff{From: req.From, To: req.To, Changes: list}, nil
+}
+
+func (s *AdService) RestoreAdRevision(ctx context.Context, req *service.AdRevisionRequest) (*service.AdResponse, error) {
+	ad, err := s.a.RestoreAdRevision(ctx, req.AdId, req.Number)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return adResponse(ad), nil
+}
+
+func (s *AdService) CreateUser(ctx context.Context, req *service.CreateUserRequest) (*service.UserResponse, error) {
+	user, err := s.a.CreateUser(ctx, req.FirstName, req.SecondName, req.Nickname, req.Password, req.Email, req.Phone)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return userResponse(user), nil
+}
+
+func loginResponse(pair *auth.TokenPair) *service.LoginResponse {
+	return &service.LoginResponse{
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresIn:    int64(pair.ExpiresIn.Seconds()),
+	}
+}
+
+func (s *AdService) Login(ctx context.Context, req *service.LoginRequest) (*service.LoginResponse, error) {
+	pair, err := s.a.LoginUser(ctx, req.Nickname, req.Password)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return loginResponse(pair), nil
+}
+
+func (s *AdService) Refresh(ctx context.Context, req *service.RefreshRequest) (*service.LoginResponse, error) {
+	pair, err := s.a.RefreshToken(ctx, req.RefreshToken)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return loginResponse(pair), nil
+}
+
+func (s *AdService) Logout(ctx context.Context, req *service.RefreshRequest) (*empty.Empty, error) {
+	if err := s.a.LogoutUser(ctx, req.RefreshToken); err != nil {
+		return nil, toStatus(err)
 	}
 
 	return &empty.Empty{}, nil
 }
 
-func NewAdService(repo app.Repository, users app.Users) service.AdServiceServer {
+func (s *AdService) GetUser(ctx context.Context, req *service.GetUserRequest) (*service.UserResponse, error) {
+	user, err := s.a.GetUser(ctx, req.Id)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return userResponse(user), nil
+}
+
+func (s *AdService) UpdateUser(ctx context.Context, req *service.UpdateUserRequest) (*service.UserResponse, error) {
+	user, err := s.a.UpdateUser(ctx, req.FirstName, req.SecondName, req.Email, req.Phone, req.Id)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return userResponse(user), nil
+}
+
+func (s *AdService) SetUserRole(ctx context.Context, req *service.SetUserRoleRequest) (*service.UserResponse, error) {
+	user, err := s.a.SetUserRole(ctx, req.Id, ads.Role(req.Role))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return userResponse(user), nil
+}
+
+func (s *AdService) DeleteUser(ctx context.Context, req *service.DeleteUserRequest) (*empty.Empty, error) {
+	if err := s.a.DeleteUser(ctx, req.Id); err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &empty.Empty{}, nil
+}
+
+func NewAdService(a app.App) service.AdServiceServer {
 	return &AdService{
-		repo:  repo,
-		users: users,
+		a: a,
 	}
 }
+
+func categoryResponse(c *ads.Category) *service.Category {
+	return &service.Category{
+		Id:       c.ID,
+		ParentId: c.ParentID,
+		Name:     c.Name,
+	}
+}
+
+func categoryNodes(nodes []*ads.CategoryNode) []*service.CategoryNode {
+	list := make([]*service.CategoryNode, 0, len(nodes))
+	for _, node := range nodes {
+		list = append(list, &service.CategoryNode{
+			Category: categoryResponse(&node.Category),
+			AdCount:  node.Count,
+			Children: categoryNodes(node.Children),
+		})
+	}
+	return list
+}
+
+func (s *AdService) ListCategories(ctx context.Context, _ *empty.Empty) (*service.CategoryTree, error) {
+	tree, err := s.a.ListCategories(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &service.CategoryTree{Roots: categoryNodes(tree)}, nil
+}
+
+func (s *AdService) CreateCategory(ctx context.Context, req *service.CreateCategoryRequest) (*service.Category, error) {
+	category, err := s.a.CreateCategory(ctx, req.Name, req.ParentId)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return categoryResponse(category), nil
+}
+
+func (s *AdService) UpdateCategory(ctx context.Context, req *service.UpdateCategoryRequest) (*service.Category, error) {
+	category, err := s.a.UpdateCategory(ctx, req.Id, req.Name, req.ParentId)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return categoryResponse(category), nil
+}
+
+func (s *AdService) DeleteCategory(ctx context.Context, req *service.DeleteCategoryRequest) (*empty.Empty, error) {
+	if err := s.a.DeleteCategory(ctx, req.Id); err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &empty.Empty{}, nil
+}
@@ -0,0 +1,66 @@
+package app
+
+import (
+	"adflow/internal/ads"
+	"adflow/internal/app"
+	auth "adflow/internal/app/auth"
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// authenticate разбирает метаданные "authorization: Bearer <token>" и кладёт пользователя в контекст.
+// Без метаданных контекст возвращается как есть: решение о доступе принимает приложение.
+func authenticate(ctx context.Context, a app.App) (context.Context, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok || len(md.Get("authorization")) == 0 {
+		return ctx, nil
+	}
+
+	header := md.Get("authorization")[0]
+	if !strings.HasPrefix(header, "Bearer ") {
+		return nil, toStatus(ads.ErrUnauthorized)
+	}
+
+	principal, err := a.Authenticate(ctx, strings.TrimPrefix(header, "Bearer "))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return auth.NewContext(ctx, principal), nil
+}
+
+// AuthInterceptor - аналог middleware Authenticate из httpgin
+func AuthInterceptor(a app.App) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := authenticate(ctx, a)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamAuthInterceptor - то же для потоковых вызовов
+func StreamAuthInterceptor(a app.App) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(ss.Context(), a)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &authStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+type authStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authStream) Context() context.Context {
+	return s.ctx
+}
@@ -1,13 +1,18 @@
 package grpc
 
 import (
+	"adflow/internal/app"
+	grpcApp "adflow/internal/ports/grpc/app"
 	service "adflow/internal/ports/grpc/service"
 
 	"google.golang.org/grpc"
 )
 
-func NewGRPCServer(a service.AdServiceServer) *grpc.Server {
-	s := grpc.NewServer()
-	service.RegisterAdServiceServer(s, a)
+func NewGRPCServer(a app.App) *grpc.Server {
+	s := grpc.NewServer(
+		grpc.UnaryInterceptor(grpcApp.AuthInterceptor(a)),
+		grpc.StreamInterceptor(grpcApp.StreamAuthInterceptor(a)),
+	)
+	service.RegisterAdServiceServer(s, grpcApp.NewAdService(a))
 	return s
 }
@@ -2,17 +2,32 @@ package httpgin
 
 import (
 	"errors"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
 	"adflow/internal/ads"
 	"adflow/internal/app"
-	auth "adflow/internal/app/auth"
 )
 
+// Сопоставляет ошибку приложения HTTP-статусу
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, ads.ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, ads.ErrAccessDenied):
+		return http.StatusForbidden
+	case errors.Is(err, ads.ErrBadRequest):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 // Метод для получения объявления
 func getAd(a app.App) func(c *gin.Context) {
 	return func(c *gin.Context) {
@@ -26,15 +41,7 @@ func getAd(a app.App) func(c *gin.Context) {
 		ad, err := a.GetAd(c, int64(adID))
 
 		if err != nil {
-			var status int
-			if errors.Is(err, ads.ErrAccessDenied) {
-				status = http.StatusForbidden
-			} else if errors.Is(err, ads.ErrBadRequest) {
-				status = http.StatusBadRequest
-			} else {
-				status = http.StatusInternalServerError
-			}
-			c.JSON(status, ErrorResponse(err))
+			c.JSON(errorStatus(err), ErrorResponse(err))
 			return
 		}
 
@@ -55,15 +62,7 @@ func getUser(a app.App) func(c *gin.Context) {
 		user, err := a.GetUser(c, int64(UserID))
 
 		if err != nil {
-			var status int
-			if errors.Is(err, ads.ErrAccessDenied) {
-				status = http.StatusForbidden
-			} else if errors.Is(err, ads.ErrBadRequest) {
-				status = http.StatusBadRequest
-			} else {
-				status = http.StatusInternalServerError
-			}
-			c.JSON(status, ErrorResponse(err))
+			c.JSON(errorStatus(err), ErrorResponse(err))
 			return
 		}
 
@@ -83,15 +82,7 @@ func createUser(a app.App) func(c *gin.Context) {
 			reqBody.Email, reqBody.Phone)
 
 		if err != nil {
-			var status int
-			if errors.Is(err, ads.ErrAccessDenied) {
-				status = http.StatusForbidden
-			} else if errors.Is(err, ads.ErrBadRequest) {
-				status = http.StatusBadRequest
-			} else {
-				status = http.StatusInternalServerError
-			}
-			c.JSON(status, ErrorResponse(err))
+			c.JSON(errorStatus(err), ErrorResponse(err))
 			return
 		}
 		c.JSON(http.StatusOK, UserSuccessResponse(user))
@@ -107,80 +98,252 @@ func loginUser(a app.App) func(c *gin.Context) {
 			return
 		}
 
-		token, err := a.LoginUser(c, reqBody.Nickname, reqBody.Password)
+		pair, err := a.LoginUser(c, reqBody.Nickname, reqBody.Password)
 
 		if err != nil {
-			var status int
-			if errors.Is(err, ads.ErrAccessDenied) {
-				status = http.StatusForbidden
-			} else if errors.Is(err, ads.ErrBadRequest) {
-				status = http.StatusBadRequest
-			} else {
-				status = http.StatusInternalServerError
-			}
-			c.JSON(status, ErrorResponse(err))
+			c.JSON(errorStatus(err), ErrorResponse(err))
 			return
 		}
-		c.JSON(http.StatusOK, LoginSuccessResponse(token))
+		c.JSON(http.StatusOK, LoginSuccessResponse(pair))
 	}
 }
 
-// Метод для получения фильтрованных
-func listAds(a app.App) func(c *gin.Context) {
+// Метод для обновления пары токенов по refresh-токену
+func refreshToken(a app.App) func(c *gin.Context) {
 	return func(c *gin.Context) {
-		filter := ads.Filter{
-			Published:    nil,
-			AuthorID:     nil,
-			TitlePrefix:  nil,
-			CreationTime: nil,
+		var reqBody refreshRequest
+		if err := c.BindJSON(&reqBody); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
 		}
-		var err error
 
-		published := c.Query("published")
-		if published != "" {
-			tmp, err := strconv.ParseInt(published, 10, 64)
-			filter.Published = (tmp != 0)
+		pair, err := a.RefreshToken(c, reqBody.RefreshToken)
+
+		if err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
+			return
+		}
+		c.JSON(http.StatusOK, LoginSuccessResponse(pair))
+	}
+}
+
+// Метод для выхода: отзывает refresh-токен и текущий access-токен
+func logoutUser(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var reqBody refreshRequest
+		if err := c.BindJSON(&reqBody); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		err := a.LogoutUser(c, reqBody.RefreshToken)
+
+		if err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
+			return
+		}
+		c.JSON(http.StatusOK, LogoutSuccessResponse())
+	}
+}
+
+// Метод для получения открытых ключей подписи токенов
+func jwks(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, a.PublicKeys(c))
+	}
+}
+
+func parseIDs(value string) ([]int64, error) {
+	var ids []int64
+	for _, v := range strings.Split(value, ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func parseTime(value string) (*time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Фильтр собирается из query-параметров; creation оставлен как синоним created_after
+func parseFilter(c *gin.Context) (ads.Filter, error) {
+	var filter ads.Filter
+	var err error
+
+	if published := c.Query("published"); published != "" {
+		tmp, err := strconv.ParseInt(published, 10, 64)
+		if err != nil {
+			return filter, err
+		}
+		value := tmp != 0
+		filter.Published = &value
+	}
+
+	// Статусы через запятую: status=published,sold
+	if statuses := c.Query("status"); statuses != "" {
+		if filter.Statuses, err = ads.ParseStatuses(statuses); err != nil {
+			return filter, err
+		}
+	}
+
+	if authorIDs := c.Query("author"); authorIDs != "" {
+		if filter.AuthorIDs, err = parseIDs(authorIDs); err != nil {
+			return filter, err
+		}
+	}
+
+	if excludeIDs := c.Query("exclude_author"); excludeIDs != "" {
+		if filter.ExcludeAuthorIDs, err = parseIDs(excludeIDs); err != nil {
+			return filter, err
+		}
+	}
+
+	// Категория выбирается вместе с подкатегориями
+	if categoryIDs := c.Query("category"); categoryIDs != "" {
+		if filter.CategoryIDs, err = parseIDs(categoryIDs); err != nil {
+			return filter, err
+		}
+	}
+
+	// Цены в минимальных единицах валюты; диапазон задаётся вместе с currency
+	filter.Currency = c.Query("currency")
+	prices := []struct {
+		name string
+		dst  **int64
+	}{
+		{"min_price", &filter.Price.Min},
+		{"max_price", &filter.Price.Max},
+	}
+	for _, v := range prices {
+		if value := c.Query(v.name); value != "" {
+			price, err := strconv.ParseInt(value, 10, 64)
 			if err != nil {
-				c.JSON(http.StatusBadRequest, ErrorResponse(err))
-				return
+				return filter, err
 			}
+			*v.dst = &price
 		}
+	}
 
-		authorID := c.Query("author")
-		if authorID != "" {
-			filter.AuthorID, err = strconv.ParseInt(authorID, 10, 64)
-			if err != nil {
-				c.JSON(http.StatusBadRequest, ErrorResponse(err))
-				return
+	// Поиск рядом с точкой: near=lat,lon и необязательный radius_km
+	if filter.Near, err = ads.ParseNear(c.Query("near"), c.Query("radius_km")); err != nil {
+		return filter, err
+	}
+
+	filter.TitlePrefix = c.Query("title")
+	filter.TitleContains = c.Query("title_contains")
+	filter.Text = c.Query("text")
+
+	times := []struct {
+		name string
+		dst  **time.Time
+	}{
+		{"creation", &filter.Created.After},
+		{"created_after", &filter.Created.After},
+		{"created_before", &filter.Created.Before},
+		{"updated_after", &filter.Updated.After},
+		{"updated_before", &filter.Updated.Before},
+	}
+	for _, v := range times {
+		if value := c.Query(v.name); value != "" {
+			if *v.dst, err = parseTime(value); err != nil {
+				return filter, err
 			}
 		}
+	}
+
+	return filter, nil
+}
 
-		filter.TitlePrefix = c.Query("title")
+// Страница задаётся параметрами limit, cursor, sort и order (asc или desc)
+func parsePage(c *gin.Context) (ads.Page, error) {
+	var page ads.Page
+	var err error
 
-		creationTime := c.Query("creation")
-		if creationTime != "" {
-			filter.CreationTime, err = time.Parse(time.Now().UTC().String(), creationTime)
-			if err != nil {
+	if limit := c.Query("limit"); limit != "" {
+		if page.Limit, err = strconv.Atoi(limit); err != nil {
+			return page, err
+		}
+	}
+
+	page.Sort.Field = ads.SortField(c.Query("sort"))
+
+	switch c.Query("order") {
+	case "", "asc":
+	case "desc":
+		page.Sort.Desc = true
+	default:
+		return page, ads.ErrBadRequest
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		if page.After, err = ads.DecodeCursor(cursor); err != nil {
+			return page, err
+		}
+	}
+
+	return page, nil
+}
+
+// Метод для получения фильтрованных
+func listAds(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		filter, err := parseFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		page, err := parsePage(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		ad, next, err := a.ListAds(c, filter, page)
+
+		if err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
+			return
+		}
+		c.JSON(http.StatusOK, ListAdsSuccessResponse(ad, next))
+	}
+}
+
+// Метод для полнотекстового поиска объявлений по запросу q
+func searchAds(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var limit, offset int
+		var err error
+
+		if value := c.Query("limit"); value != "" {
+			if limit, err = strconv.Atoi(value); err != nil {
 				c.JSON(http.StatusBadRequest, ErrorResponse(err))
 				return
 			}
 		}
 
-		ad, err := a.ListAds(c, filter)
+		if value := c.Query("offset"); value != "" {
+			if offset, err = strconv.Atoi(value); err != nil {
+				c.JSON(http.StatusBadRequest, ErrorResponse(err))
+				return
+			}
+		}
 
+		results, err := a.SearchAds(c, c.Query("q"), limit, offset)
 		if err != nil {
-			var status int
-			if errors.Is(err, ads.ErrAccessDenied) {
-				status = http.StatusForbidden
-			} else if errors.Is(err, ads.ErrBadRequest) {
-				status = http.StatusBadRequest
-			} else {
-				status = http.StatusInternalServerError
-			}
-			c.JSON(status, ErrorResponse(err))
+			c.JSON(errorStatus(err), ErrorResponse(err))
 			return
 		}
-		c.JSON(http.StatusOK, ListAdsSuccessResponse(ad))
+		c.JSON(http.StatusOK, SearchAdsSuccessResponse(results))
 	}
 }
 
@@ -193,28 +356,10 @@ func createAd(a app.App) func(c *gin.Context) {
 			return
 		}
 
-		bearerToken := c.Request.Header.Get("Authorization")
-		code, msg := auth.ValidateToken(bearerToken, reqBody.UserID)
-
-		if code != 200 {
-			c.JSON(code, gin.H{
-				"message": msg,
-			})
-			return
-		}
-
-		ad, err := a.CreateAd(c, reqBody.Title, reqBody.Text, reqBody.UserID)
+		ad, err := a.CreateAd(c, reqBody.draft())
 
 		if err != nil {
-			var status int
-			if errors.Is(err, ads.ErrAccessDenied) {
-				status = http.StatusForbidden
-			} else if errors.Is(err, ads.ErrBadRequest) {
-				status = http.StatusBadRequest
-			} else {
-				status = http.StatusInternalServerError
-			}
-			c.JSON(status, ErrorResponse(err))
+			c.JSON(errorStatus(err), ErrorResponse(err))
 			return
 		}
 
@@ -222,7 +367,8 @@ func createAd(a app.App) func(c *gin.Context) {
 	}
 }
 
-// Метод для изменения статуса объявления (опубликовано - Published = true или снято с публикации Published = false)
+// Метод для изменения статуса объявления: status - новая стадия (draft, pending_review, published, sold, archived),
+// без status - прежний переключатель (опубликовано - Published = true или снято с публикации Published = false)
 func changeAdStatus(a app.App) func(c *gin.Context) {
 	return func(c *gin.Context) {
 		var reqBody changeAdStatusRequest
@@ -237,28 +383,34 @@ func changeAdStatus(a app.App) func(c *gin.Context) {
 			return
 		}
 
-		bearerToken := c.Request.Header.Get("Authorization")
-		code, msg := auth.ValidateToken(bearerToken, reqBody.UserID)
+		var ad *ads.Ad
+		if reqBody.Status != "" {
+			ad, err = a.TransitionAd(c, int64(adID), ads.Status(reqBody.Status))
+		} else {
+			ad, err = a.ChangeAdStatus(c, int64(adID), reqBody.Published)
+		}
 
-		if code != 200 {
-			c.JSON(code, gin.H{
-				"message": msg,
-			})
+		if err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
 			return
 		}
 
-		ad, err := a.ChangeAdStatus(c, int64(adID), reqBody.UserID, reqBody.Published)
+		c.JSON(http.StatusOK, AdSuccessResponse(ad))
+	}
+}
 
+// Метод для продления публикации объявления
+func renewAd(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		adID, err := strconv.Atoi(c.Param("ad_id"))
 		if err != nil {
-			var status int
-			if errors.Is(err, ads.ErrAccessDenied) {
-				status = http.StatusForbidden
-			} else if errors.Is(err, ads.ErrBadRequest) {
-				status = http.StatusBadRequest
-			} else {
-				status = http.StatusInternalServerError
-			}
-			c.JSON(status, ErrorResponse(err))
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		ad, err := a.RenewAd(c, int64(adID))
+		if err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
 			return
 		}
 
@@ -266,43 +418,58 @@ func changeAdStatus(a app.App) func(c *gin.Context) {
 	}
 }
 
-// Метод для обновления текста(Text) или заголовка(Title) объявления
-func updateAd(a app.App) func(c *gin.Context) {
+// pageQuery разбирает курсор страницы (ID из параметра cursor) и limit; незаданные остаются нулями
+func pageQuery(c *gin.Context, cursor string) (int64, int, error) {
+	var cursorID int64
+	var limit int
+	var err error
+
+	if value := c.Query(cursor); value != "" {
+		if cursorID, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if value := c.Query("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return cursorID, limit, nil
+}
+
+// Метод для получения очереди модерации: заявки без решения, after - ID последней полученной заявки
+func listModerationQueue(a app.App) func(c *gin.Context) {
 	return func(c *gin.Context) {
-		var reqBody updateAdRequest
-		if err := c.BindJSON(&reqBody); err != nil {
+		afterID, limit, err := pageQuery(c, "after")
+		if err != nil {
 			c.JSON(http.StatusBadRequest, ErrorResponse(err))
 			return
 		}
 
-		adID, err := strconv.Atoi(c.Param("ad_id"))
+		reviews, err := a.ListModerationQueue(c, afterID, limit)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			c.JSON(errorStatus(err), ErrorResponse(err))
 			return
 		}
 
-		bearerToken := c.Request.Header.Get("Authorization")
-		code, msg := auth.ValidateToken(bearerToken, reqBody.UserID)
+		c.JSON(http.StatusOK, ReviewsSuccessResponse(reviews))
+	}
+}
 
-		if code != 200 {
-			c.JSON(code, gin.H{
-				"message": msg,
-			})
+// Метод для одобрения объявления модератором
+func approveAd(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		adID, err := strconv.Atoi(c.Param("ad_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
 			return
 		}
 
-		ad, err := a.UpdateAd(c, int64(adID), reqBody.UserID, reqBody.Title, reqBody.Text)
-
+		ad, err := a.ApproveAd(c, int64(adID))
 		if err != nil {
-			var status int
-			if errors.Is(err, ads.ErrAccessDenied) {
-				status = http.StatusForbidden
-			} else if errors.Is(err, ads.ErrBadRequest) {
-				status = http.StatusBadRequest
-			} else {
-				status = http.StatusInternalServerError
-			}
-			c.JSON(status, ErrorResponse(err))
+			c.JSON(errorStatus(err), ErrorResponse(err))
 			return
 		}
 
@@ -310,10 +477,10 @@ func updateAd(a app.App) func(c *gin.Context) {
 	}
 }
 
-// Метод для удаления объявления
-func deleteAd(a app.App) func(c *gin.Context) {
+// Метод для отклонения объявления модератором с причиной
+func rejectAd(a app.App) func(c *gin.Context) {
 	return func(c *gin.Context) {
-		var reqBody deleteRequest
+		var reqBody rejectAdRequest
 		if err := c.BindJSON(&reqBody); err != nil {
 			c.JSON(http.StatusBadRequest, ErrorResponse(err))
 			return
@@ -325,28 +492,80 @@ func deleteAd(a app.App) func(c *gin.Context) {
 			return
 		}
 
-		bearerToken := c.Request.Header.Get("Authorization")
-		code, msg := auth.ValidateToken(bearerToken, reqBody.UserID)
+		ad, err := a.RejectAd(c, int64(adID), reqBody.Reason)
+		if err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, AdSuccessResponse(ad))
+	}
+}
+
+// Метод для получения истории проверок объявления
+func listAdReviews(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		adID, err := strconv.Atoi(c.Param("ad_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
 
-		if code != 200 {
-			c.JSON(code, gin.H{
-				"message": msg,
-			})
+		reviews, err := a.ListAdReviews(c, int64(adID))
+		if err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
 			return
 		}
 
-		err = a.DeleteAd(c, int64(adID), reqBody.UserID)
+		c.JSON(http.StatusOK, ReviewsSuccessResponse(reviews))
+	}
+}
+
+// Параметры избранного: user_id и ad_id из пути
+func favoriteParams(c *gin.Context) (int64, int64, error) {
+	userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	adID, err := strconv.ParseInt(c.Param("ad_id"), 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return userID, adID, nil
+}
 
+// Метод для добавления объявления в избранное
+func addFavorite(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		userID, adID, err := favoriteParams(c)
 		if err != nil {
-			var status int
-			if errors.Is(err, ads.ErrAccessDenied) {
-				status = http.StatusForbidden
-			} else if errors.Is(err, ads.ErrBadRequest) {
-				status = http.StatusBadRequest
-			} else {
-				status = http.StatusInternalServerError
-			}
-			c.JSON(status, ErrorResponse(err))
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		favorite, err := a.AddFavorite(c, userID, adID)
+		if err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, FavoriteSuccessResponse(favorite))
+	}
+}
+
+// Метод для удаления объявления из избранного
+func removeFavorite(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		userID, adID, err := favoriteParams(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		if err := a.RemoveFavorite(c, userID, adID); err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
 			return
 		}
 
@@ -354,90 +573,858 @@ func deleteAd(a app.App) func(c *gin.Context) {
 	}
 }
 
-// Метод для удаления пользователя
-func deleteUser(a app.App) func(c *gin.Context) {
+// Метод для получения избранного: новые первыми, before - ID последней полученной записи
+func listFavorites(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		beforeID, limit, err := pageQuery(c, "before")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		favorites, err := a.ListFavorites(c, userID, beforeID, limit)
+		if err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, FavoritesSuccessResponse(favorites))
+	}
+}
+
+// Метод для сохранения поиска
+func createSavedSearch(a app.App) func(c *gin.Context) {
 	return func(c *gin.Context) {
-		var reqBody deleteRequest
+		var reqBody savedSearchRequest
 		if err := c.BindJSON(&reqBody); err != nil {
 			c.JSON(http.StatusBadRequest, ErrorResponse(err))
 			return
 		}
 
-		id, err := strconv.Atoi(c.Param("user_id"))
+		userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, ErrorResponse(err))
 			return
 		}
 
-		bearerToken := c.Request.Header.Get("Authorization")
-		code, msg := auth.ValidateToken(bearerToken, reqBody.UserID)
+		filter, err := reqBody.Filter.filter()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
 
-		if code != 200 {
-			c.JSON(code, gin.H{
-				"message": msg,
-			})
+		search, err := a.CreateSavedSearch(c, userID, reqBody.Name, filter)
+		if err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
 			return
 		}
 
-		err = a.DeleteUser(c, int64(id), reqBody.UserID)
+		c.JSON(http.StatusOK, SavedSearchSuccessResponse(search))
+	}
+}
 
+// Метод для получения сохранённых поисков пользователя
+func listSavedSearches(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
 		if err != nil {
-			var status int
-			if errors.Is(err, ads.ErrAccessDenied) {
-				status = http.StatusForbidden
-			} else if errors.Is(err, ads.ErrBadRequest) {
-				status = http.StatusBadRequest
-			} else {
-				status = http.StatusInternalServerError
-			}
-			c.JSON(status, ErrorResponse(err))
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
 			return
 		}
 
-		c.JSON(http.StatusOK, DeleteSuccessResponse())
+		searches, err := a.ListSavedSearches(c, userID)
+		if err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, SavedSearchesSuccessResponse(searches))
 	}
 }
 
-// Метод для обновления пользователя
-func updateUser(a app.App) func(c *gin.Context) {
+// Параметры сохранённого поиска: user_id и search_id из пути
+func searchParams(c *gin.Context) (int64, int64, error) {
+	userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	searchID, err := strconv.ParseInt(c.Param("search_id"), 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return userID, searchID, nil
+}
+
+// Метод для изменения названия и условий сохранённого поиска
+func updateSavedSearch(a app.App) func(c *gin.Context) {
 	return func(c *gin.Context) {
-		var reqBody updateUserRequest
+		var reqBody savedSearchRequest
 		if err := c.BindJSON(&reqBody); err != nil {
 			c.JSON(http.StatusBadRequest, ErrorResponse(err))
 			return
 		}
 
-		userID, err := strconv.Atoi(c.Param("user_id"))
+		userID, searchID, err := searchParams(c)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, ErrorResponse(err))
 			return
 		}
 
-		bearerToken := c.Request.Header.Get("Authorization")
-		code, msg := auth.ValidateToken(bearerToken, int64(userID))
-
-		if code != 200 {
-			c.JSON(code, gin.H{
-				"message": msg,
-			})
+		filter, err := reqBody.Filter.filter()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
 			return
 		}
 
-		user, err := a.UpdateUser(c, reqBody.FirstName, reqBody.SecondName, reqBody.Email, reqBody.Phone, int64(userID))
-
+		search, err := a.UpdateSavedSearch(c, userID, searchID, reqBody.Name, filter)
 		if err != nil {
-			var status int
-			if errors.Is(err, ads.ErrAccessDenied) {
-				status = http.StatusForbidden
-			} else if errors.Is(err, ads.ErrBadRequest) {
-				status = http.StatusBadRequest
-			} else {
-				status = http.StatusInternalServerError
-			}
-			c.JSON(status, ErrorResponse(err))
+			c.JSON(errorStatus(err), ErrorResponse(err))
 			return
 		}
 
-		c.JSON(http.StatusOK, UserSuccessResponse(user))
+		c.JSON(http.StatusOK, SavedSearchSuccessResponse(search))
+	}
+}
+
+// Метод для удаления сохранённого поиска
+func deleteSavedSearch(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		userID, searchID, err := searchParams(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		if err := a.DeleteSavedSearch(c, userID, searchID); err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, DeleteSuccessResponse())
+	}
+}
+
+// Метод для получения уведомлений по сохранённым поискам: новые первыми, before - ID последнего полученного
+func listNotifications(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		beforeID, limit, err := pageQuery(c, "before")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		notifications, err := a.ListNotifications(c, userID, beforeID, limit)
+		if err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, NotificationsSuccessResponse(notifications))
+	}
+}
+
+// Метод для создания вебхука; ключ подписи secret приходит только в этом ответе
+func createWebhook(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var reqBody webhookRequest
+		if err := c.BindJSON(&reqBody); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		hook, err := a.CreateWebhook(c, reqBody.URL, reqBody.Events)
+		if err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, WebhookSuccessResponse(hook))
+	}
+}
+
+// Метод для получения всех вебхуков
+func listWebhooks(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		hooks, err := a.ListWebhooks(c)
+		if err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, WebhooksSuccessResponse(hooks))
+	}
+}
+
+// Метод для получения вебхука
+func getWebhook(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		hookID, err := strconv.ParseInt(c.Param("webhook_id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		hook, err := a.GetWebhook(c, hookID)
+		if err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, WebhookSuccessResponse(hook))
+	}
+}
+
+// Метод для изменения адреса и событий вебхука, а также для его выключения
+func updateWebhook(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var reqBody webhookRequest
+		if err := c.BindJSON(&reqBody); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		hookID, err := strconv.ParseInt(c.Param("webhook_id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		active := reqBody.Active == nil || *reqBody.Active
+		hook, err := a.UpdateWebhook(c, hookID, reqBody.URL, reqBody.Events, active)
+		if err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, WebhookSuccessResponse(hook))
+	}
+}
+
+// Метод для удаления вебхука вместе с журналом доставок
+func deleteWebhook(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		hookID, err := strconv.ParseInt(c.Param("webhook_id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		if err := a.DeleteWebhook(c, hookID); err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, DeleteSuccessResponse())
+	}
+}
+
+// Метод для получения журнала доставок вебхука: новые первыми, before - ID последней полученной, status - фильтр
+func listWebhookDeliveries(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		hookID, err := strconv.ParseInt(c.Param("webhook_id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		beforeID, limit, err := pageQuery(c, "before")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		status := ads.DeliveryStatus(c.Query("status"))
+		deliveries, err := a.ListWebhookDeliveries(c, hookID, status, beforeID, limit)
+		if err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, WebhookDeliveriesSuccessResponse(deliveries))
+	}
+}
+
+// Метод для повторной отправки недоставленного события
+func retryWebhookDelivery(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		hookID, err := strconv.ParseInt(c.Param("webhook_id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		deliveryID, err := strconv.ParseInt(c.Param("delivery_id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		delivery, err := a.RetryWebhookDelivery(c, hookID, deliveryID)
+		if err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, WebhookDeliverySuccessResponse(delivery))
+	}
+}
+
+// Метод для первого сообщения автору объявления; повторные попадают в ту же переписку
+func contactAuthor(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var reqBody messageRequest
+		if err := c.BindJSON(&reqBody); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		adID, err := strconv.ParseInt(c.Param("ad_id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		message, err := a.ContactAuthor(c, adID, reqBody.Text)
+		if err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, MessageSuccessResponse(message))
+	}
+}
+
+// Метод для сообщения в переписку
+func sendMessage(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var reqBody messageRequest
+		if err := c.BindJSON(&reqBody); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		conversationID, err := strconv.ParseInt(c.Param("conversation_id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		message, err := a.SendMessage(c, conversationID, reqBody.Text)
+		if err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, MessageSuccessResponse(message))
+	}
+}
+
+// Метод для получения сообщений переписки: новые первыми, before - ID последнего полученного
+func listMessages(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		conversationID, err := strconv.ParseInt(c.Param("conversation_id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		beforeID, limit, err := pageQuery(c, "before")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		messages, err := a.ListMessages(c, conversationID, beforeID, limit)
+		if err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, MessagesSuccessResponse(messages))
+	}
+}
+
+// Метод для отметки сообщений собеседника прочитанными: до message_id включительно, без него - все
+func markRead(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var reqBody markReadRequest
+		if err := c.ShouldBindJSON(&reqBody); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		conversationID, err := strconv.ParseInt(c.Param("conversation_id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		conversation, err := a.MarkRead(c, conversationID, reqBody.MessageID)
+		if err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, ConversationSuccessResponse(conversation))
+	}
+}
+
+// Метод для получения переписок пользователя: новые первыми, before - last_message_id последней полученной
+func listConversations(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		var adID int64
+		if value := c.Query("ad_id"); value != "" {
+			if adID, err = strconv.ParseInt(value, 10, 64); err != nil {
+				c.JSON(http.StatusBadRequest, ErrorResponse(err))
+				return
+			}
+		}
+
+		beforeID, limit, err := pageQuery(c, "before")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		conversations, err := a.ListConversations(c, userID, adID, beforeID, limit)
+		if err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, ConversationsSuccessResponse(conversations))
+	}
+}
+
+// Метод для получения входящих автора по объявлениям: before - last_message_id последней полученной группы
+func listInbox(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		beforeID, limit, err := pageQuery(c, "before")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		entries, err := a.ListInbox(c, userID, beforeID, limit)
+		if err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, InboxSuccessResponse(entries))
+	}
+}
+
+// Метод для обновления текста(Text) или заголовка(Title) объявления
+func updateAd(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var reqBody updateAdRequest
+		if err := c.BindJSON(&reqBody); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		adID, err := strconv.Atoi(c.Param("ad_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		ad, err := a.UpdateAd(c, int64(adID), createAdRequest(reqBody).draft())
+
+		if err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, AdSuccessResponse(ad))
+	}
+}
+
+// Запрос с картинками не может быть больше, чем все картинки объявления и служебные части multipart
+const maxUploadSize = app.MaxImagesPerAd*app.MaxImageSize + 1<<20
+
+// Метод для загрузки картинок объявления: multipart/form-data, файлы в полях image
+func uploadAdImages(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		adID, err := strconv.Atoi(c.Param("ad_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxUploadSize)
+		form, err := c.MultipartForm()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		var files [][]byte
+		for _, header := range form.File["image"] {
+			if header.Size > app.MaxImageSize {
+				c.JSON(http.StatusBadRequest, ErrorResponse(ads.ErrBadRequest))
+				return
+			}
+
+			file, err := header.Open()
+			if err != nil {
+				c.JSON(http.StatusBadRequest, ErrorResponse(err))
+				return
+			}
+			data, err := io.ReadAll(io.LimitReader(file, app.MaxImageSize+1))
+			file.Close()
+			if err != nil {
+				c.JSON(http.StatusBadRequest, ErrorResponse(err))
+				return
+			}
+			files = append(files, data)
+		}
+
+		ad, err := a.AddAdImages(c, int64(adID), files)
+		if err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, AdSuccessResponse(ad))
+	}
+}
+
+// Метод для удаления картинки объявления
+func deleteAdImage(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		adID, err := strconv.Atoi(c.Param("ad_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		imageID, err := strconv.Atoi(c.Param("image_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		ad, err := a.DeleteAdImage(c, int64(adID), int64(imageID))
+		if err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, AdSuccessResponse(ad))
+	}
+}
+
+// Метод для получения файла картинки. Ключи случайные и не переиспользуются, поэтому ответ кешируется навсегда.
+func getImage(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		data, contentType, err := a.GetImage(c, strings.TrimPrefix(c.Param("key"), "/"))
+		if err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
+			return
+		}
+
+		c.Header("Cache-Control", "public, max-age=31536000, immutable")
+		c.Header("X-Content-Type-Options", "nosniff")
+		c.Data(http.StatusOK, contentType, data)
+	}
+}
+
+// Метод для удаления объявления
+func deleteAd(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		adID, err := strconv.Atoi(c.Param("ad_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		err = a.DeleteAd(c, int64(adID))
+
+		if err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, DeleteSuccessResponse())
+	}
+}
+
+// Метод для удаления пользователя
+func deleteUser(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("user_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		err = a.DeleteUser(c, int64(id))
+
+		if err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, DeleteSuccessResponse())
+	}
+}
+
+// Метод для обновления пользователя
+func updateUser(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var reqBody updateUserRequest
+		if err := c.BindJSON(&reqBody); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		userID, err := strconv.Atoi(c.Param("user_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		user, err := a.UpdateUser(c, reqBody.FirstName, reqBody.SecondName, reqBody.Email, reqBody.Phone, int64(userID))
+
+		if err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, UserSuccessResponse(user))
+	}
+}
+
+// Метод для назначения роли пользователю (только для администраторов)
+func setUserRole(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var reqBody setUserRoleRequest
+		if err := c.BindJSON(&reqBody); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		userID, err := strconv.Atoi(c.Param("user_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		user, err := a.SetUserRole(c, int64(userID), ads.Role(reqBody.Role))
+
+		if err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, UserSuccessResponse(user))
+	}
+}
+
+// Метод для получения дерева категорий
+func listCategories(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		tree, err := a.ListCategories(c)
+		if err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
+			return
+		}
+		c.JSON(http.StatusOK, CategoryTreeSuccessResponse(tree))
+	}
+}
+
+// Метод для создания категории
+func createCategory(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var reqBody categoryRequest
+		if err := c.BindJSON(&reqBody); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		category, err := a.CreateCategory(c, reqBody.Name, reqBody.ParentID)
+		if err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
+			return
+		}
+		c.JSON(http.StatusOK, CategorySuccessResponse(category))
+	}
+}
+
+// Метод для переименования и переноса категории
+func updateCategory(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var reqBody categoryRequest
+		if err := c.BindJSON(&reqBody); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		categoryID, err := strconv.ParseInt(c.Param("category_id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		category, err := a.UpdateCategory(c, categoryID, reqBody.Name, reqBody.ParentID)
+		if err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
+			return
+		}
+		c.JSON(http.StatusOK, CategorySuccessResponse(category))
+	}
+}
+
+// Метод для удаления категории
+func deleteCategory(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		categoryID, err := strconv.ParseInt(c.Param("category_id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		if err := a.DeleteCategory(c, categoryID); err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
+			return
+		}
+		c.JSON(http.StatusOK, DeleteSuccessResponse())
+	}
+}
+
+// Параметры ревизии: ad_id и номер ревизии из пути
+func revisionParams(c *gin.Context) (int64, int64, error) {
+	adID, err := strconv.ParseInt(c.Param("ad_id"), 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	number, err := strconv.ParseInt(c.Param("revision"), 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return adID, number, nil
+}
+
+// Метод для получения истории правок объявления: before - номер последней полученной ревизии
+func listAdRevisions(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		adID, err := strconv.ParseInt(c.Param("ad_id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		before, limit, err := pageQuery(c, "before")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		revisions, err := a.ListAdRevisions(c, adID, before, limit)
+		if err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, RevisionsSuccessResponse(revisions))
+	}
+}
+
+// Метод для получения одной ревизии объявления
+func getAdRevision(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		adID, number, err := revisionParams(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		rev, err := a.GetAdRevision(c, adID, number)
+		if err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, RevisionSuccessResponse(rev))
+	}
+}
+
+// Метод для сравнения двух ревизий: номера from и to в строке запроса
+func diffAdRevisions(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		adID, err := strconv.ParseInt(c.Param("ad_id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		from, err := strconv.ParseInt(c.Query("from"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		to, err := strconv.ParseInt(c.Query("to"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		changes, err := a.DiffAdRevisions(c, adID, from, to)
+		if err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, RevisionDiffSuccessResponse(from, to, changes))
+	}
+}
+
+// Метод для возврата объявления к ревизии; возврат записывается новой ревизией
+func restoreAdRevision(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		adID, number, err := revisionParams(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			return
+		}
+
+		ad, err := a.RestoreAdRevision(c, adID, number)
+		if err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, AdSuccessResponse(ad))
 	}
 }
@@ -0,0 +1,39 @@
+package httpgin
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"adflow/internal/ads"
+	"adflow/internal/app"
+	auth "adflow/internal/app/auth"
+)
+
+// Authenticate разбирает заголовок "Authorization: Bearer <token>" и кладёт
+// пользователя в контекст запроса. Запросы без заголовка проходят анонимно,
+// а решение о доступе принимает приложение.
+func Authenticate(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		if header == "" {
+			c.Next()
+			return
+		}
+
+		if !strings.HasPrefix(header, "Bearer ") {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse(ads.ErrUnauthorized))
+			return
+		}
+
+		principal, err := a.Authenticate(c, strings.TrimPrefix(header, "Bearer "))
+		if err != nil {
+			c.AbortWithStatusJSON(errorStatus(err), ErrorResponse(err))
+			return
+		}
+
+		c.Request = c.Request.WithContext(auth.NewContext(c.Request.Context(), principal))
+		c.Next()
+	}
+}
@@ -4,38 +4,523 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"adflow/internal/ads"
+	auth "adflow/internal/app/auth"
+	"adflow/internal/pubsub"
+	"encoding/json"
+	"strconv"
 	"time"
 )
 
+// price - цена в минимальных единицах валюты (копейках, центах)
 type createAdRequest struct {
-	Title  string `json:"title"`
-	Text   string `json:"text"`
-	UserID int64  `json:"user_id"`
+	Title      string `json:"title"`
+	Text       string `json:"text"`
+	CategoryID int64  `json:"category_id"`
+	Price      *int64 `json:"price"`
+	Currency   string `json:"currency"`
+	Negotiable *bool  `json:"negotiable"`
+	// Место необязательно
+	Location *locationRequest `json:"location"`
+}
+
+type locationRequest struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	City      string  `json:"city"`
+}
+
+func (r createAdRequest) draft() ads.Draft {
+	draft := ads.Draft{
+		Title:      r.Title,
+		Text:       r.Text,
+		CategoryID: r.CategoryID,
+		Price:      r.Price,
+		Currency:   r.Currency,
+		Negotiable: r.Negotiable,
+	}
+	if loc := r.Location; loc != nil {
+		draft.Location = &ads.Location{Point: ads.Point{Latitude: loc.Latitude, Longitude: loc.Longitude}, City: loc.City}
+	}
+	return draft
 }
 
 type adResponse struct {
-	ID           int64     `json:"id"`
-	Title        string    `json:"title"`
-	Text         string    `json:"text"`
-	AuthorID     int64     `json:"author_id"`
-	Published    bool      `json:"published"`
-	CreationTime time.Time `json:"creation_time"`
-	UpdateTime   time.Time `json:"update_time"`
+	ID             int64            `json:"id"`
+	Title          string           `json:"title"`
+	Text           string           `json:"text"`
+	AuthorID       int64            `json:"author_id"`
+	Published      bool             `json:"published"`
+	Status         string           `json:"status"`
+	ExpiresAt      *time.Time       `json:"expires_at"`
+	CreationTime   time.Time        `json:"creation_time"`
+	UpdateTime     time.Time        `json:"update_time"`
+	CategoryID     int64            `json:"category_id"`
+	Price          int64            `json:"price"`
+	Currency       string           `json:"currency"`
+	Negotiable     bool             `json:"negotiable"`
+	Location       *locationRequest `json:"location"`
+	Images         []imageResponse  `json:"images"`
+	FavoritesCount int64            `json:"favorites_count"`
+}
+
+type imageResponse struct {
+	ID           int64  `json:"id"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+	ContentType  string `json:"content_type"`
+	Size         int64  `json:"size"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+}
+
+func newAdResponse(ad *ads.Ad) adResponse {
+	images := make([]imageResponse, 0, len(ad.Images))
+	for _, image := range ad.Images {
+		images = append(images, imageResponse{
+			ID:           image.ID,
+			URL:          image.URL,
+			ThumbnailURL: image.ThumbnailURL,
+			ContentType:  image.ContentType,
+			Size:         image.Size,
+			Width:        image.Width,
+			Height:       image.Height,
+		})
+	}
+
+	return adResponse{
+		ID:             ad.ID,
+		Title:          ad.Title,
+		Text:           ad.Text,
+		AuthorID:       ad.AuthorID,
+		Published:      ad.Published,
+		Status:         string(ad.AdStatus()),
+		ExpiresAt:      ad.ExpiresAt,
+		CreationTime:   ad.CreationTime,
+		UpdateTime:     ad.UpdateTime,
+		CategoryID:     ad.CategoryID,
+		Price:          ad.Price,
+		Currency:       ad.Currency,
+		Negotiable:     ad.Negotiable,
+		Location:       newLocationResponse(ad.Location()),
+		Images:         images,
+		FavoritesCount: ad.FavoriteCount,
+	}
+}
+
+func newLocationResponse(loc *ads.Location) *locationRequest {
+	if loc == nil {
+		return nil
+	}
+	return &locationRequest{Latitude: loc.Latitude, Longitude: loc.Longitude, City: loc.City}
+}
+
+type searchResultResponse struct {
+	adResponse
+	Score   float64 `json:"score"`
+	Snippet string  `json:"snippet"`
 }
 
 type changeAdStatusRequest struct {
-	Published bool  `json:"published"`
-	UserID    int64 `json:"user_id"`
+	Published bool   `json:"published"`
+	Status    string `json:"status"`
+}
+
+// Незаданные category_id, price, currency, negotiable и location остаются прежними
+type updateAdRequest createAdRequest
+
+type rejectAdRequest struct {
+	Reason string `json:"reason"`
+}
+
+type flagResponse struct {
+	Rule   string `json:"rule"`
+	Detail string `json:"detail"`
+}
+
+// ad заполнено только в очереди модерации
+type reviewResponse struct {
+	ID           int64          `json:"id"`
+	AdID         int64          `json:"ad_id"`
+	Decision     string         `json:"decision"`
+	Flags        []flagResponse `json:"flags"`
+	Reason       string         `json:"reason"`
+	ModeratorID  int64          `json:"moderator_id"`
+	CreationTime time.Time      `json:"creation_time"`
+	DecisionTime *time.Time     `json:"decision_time"`
+	Ad           *adResponse    `json:"ad,omitempty"`
+}
+
+func newReviewResponse(review *ads.Review) reviewResponse {
+	flags := make([]flagResponse, 0, len(review.Flags))
+	for _, flag := range review.Flags {
+		flags = append(flags, flagResponse{Rule: flag.Rule, Detail: flag.Detail})
+	}
+
+	var ad *adResponse
+	if review.Ad != nil {
+		resp := newAdResponse(review.Ad)
+		ad = &resp
+	}
+
+	return reviewResponse{
+		ID:           review.ID,
+		AdID:         review.AdID,
+		Decision:     string(review.Decision),
+		Flags:        flags,
+		Reason:       review.Reason,
+		ModeratorID:  review.ModeratorID,
+		CreationTime: review.CreationTime,
+		DecisionTime: review.DecisionTime,
+		Ad:           ad,
+	}
+}
+
+type favoriteResponse struct {
+	ID           int64      `json:"id"`
+	AdID         int64      `json:"ad_id"`
+	CreationTime time.Time  `json:"creation_time"`
+	Ad           adResponse `json:"ad"`
+}
+
+func newFavoriteResponse(favorite *ads.Favorite) favoriteResponse {
+	return favoriteResponse{
+		ID:           favorite.ID,
+		AdID:         favorite.AdID,
+		CreationTime: favorite.CreationTime,
+		Ad:           newAdResponse(favorite.Ad),
+	}
+}
+
+type messageRequest struct {
+	Text string `json:"text"`
+}
+
+// active по умолчанию true
+type webhookRequest struct {
+	URL    string          `json:"url"`
+	Events []ads.EventType `json:"events"`
+	Active *bool           `json:"active"`
+}
+
+// secret заполнен только в ответе на создание подписки
+type webhookResponse struct {
+	ID           int64           `json:"id"`
+	URL          string          `json:"url"`
+	Events       []ads.EventType `json:"events"`
+	Active       bool            `json:"active"`
+	Secret       string          `json:"secret,omitempty"`
+	CreatorID    int64           `json:"creator_id"`
+	CreationTime time.Time       `json:"creation_time"`
+	UpdateTime   time.Time       `json:"update_time"`
+}
+
+func newWebhookResponse(hook *ads.Webhook) webhookResponse {
+	return webhookResponse{
+		ID:           hook.ID,
+		URL:          hook.URL,
+		Events:       hook.Events,
+		Active:       hook.Active,
+		Secret:       hook.Secret,
+		CreatorID:    hook.CreatorID,
+		CreationTime: hook.CreationTime,
+		UpdateTime:   hook.UpdateTime,
+	}
+}
+
+// payload - тело, которое получает партнёр; response_status и error - итог последней попытки
+type webhookDeliveryResponse struct {
+	ID              int64              `json:"id"`
+	WebhookID       int64              `json:"webhook_id"`
+	Source          string             `json:"source"`
+	EventID         int64              `json:"event_id"`
+	EventType       ads.EventType      `json:"event_type"`
+	Payload         json.RawMessage    `json:"payload"`
+	Status          ads.DeliveryStatus `json:"status"`
+	Attempts        int                `json:"attempts"`
+	NextAttemptTime *time.Time         `json:"next_attempt_time"`
+	LastAttemptTime *time.Time         `json:"last_attempt_time"`
+	ResponseStatus  int                `json:"response_status"`
+	Error           string             `json:"error"`
+	CreationTime    time.Time          `json:"creation_time"`
+	DeliveryTime    *time.Time         `json:"delivery_time"`
+}
+
+func newWebhookDeliveryResponse(d *ads.WebhookDelivery) webhookDeliveryResponse {
+	return webhookDeliveryResponse{
+		ID:              d.ID,
+		WebhookID:       d.WebhookID,
+		Source:          d.Source,
+		EventID:         d.EventID,
+		EventType:       d.EventType,
+		Payload:         json.RawMessage(d.Payload),
+		Status:          d.Status,
+		Attempts:        d.Attempts,
+		NextAttemptTime: d.NextAttemptTime,
+		LastAttemptTime: d.LastAttemptTime,
+		ResponseStatus:  d.ResponseStatus,
+		Error:           d.Error,
+		CreationTime:    d.CreationTime,
+		DeliveryTime:    d.DeliveryTime,
+	}
+}
+
+// restored_from - номер ревизии, к которой вернули объявление; 0 у обычной правки
+type revisionResponse struct {
+	Number       int64            `json:"number"`
+	AdID         int64            `json:"ad_id"`
+	AuthorID     int64            `json:"author_id"`
+	RestoredFrom int64            `json:"restored_from"`
+	CreationTime time.Time        `json:"creation_time"`
+	Title        string           `json:"title"`
+	Text         string           `json:"text"`
+	CategoryID   int64            `json:"category_id"`
+	Price        int64            `json:"price"`
+	Currency     string           `json:"currency"`
+	Negotiable   bool             `json:"negotiable"`
+	Location     *locationRequest `json:"location"`
+}
+
+func newRevisionResponse(rev *ads.Revision) revisionResponse {
+	return revisionResponse{
+		Number:       rev.Number,
+		AdID:         rev.AdID,
+		AuthorID:     rev.AuthorID,
+		RestoredFrom: rev.RestoredFrom,
+		CreationTime: rev.CreationTime,
+		Title:        rev.Title,
+		Text:         rev.Text,
+		CategoryID:   rev.CategoryID,
+		Price:        rev.Price,
+		Currency:     rev.Currency,
+		Negotiable:   rev.Negotiable,
+		Location:     newLocationResponse(rev.Location()),
+	}
+}
+
+// from и to - значения поля в двух ревизиях; место передаётся так же, как в объявлении
+type changeResponse struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+func newChangeResponse(change ads.Change) changeResponse {
+	res := changeResponse{Field: change.Field, From: change.From, To: change.To}
+	if from, ok := change.From.(*ads.Location); ok {
+		res.From = newLocationResponse(from)
+	}
+	if to, ok := change.To.(*ads.Location); ok {
+		res.To = newLocationResponse(to)
+	}
+	return res
+}
+
+type markReadRequest struct {
+	MessageID int64 `json:"message_id"`
+}
+
+// read_time - когда сообщение прочитал получатель; null - не прочитано
+type messageResponse struct {
+	ID             int64      `json:"id"`
+	ConversationID int64      `json:"conversation_id"`
+	SenderID       int64      `json:"sender_id"`
+	Text           string     `json:"text"`
+	CreationTime   time.Time  `json:"creation_time"`
+	ReadTime       *time.Time `json:"read_time"`
+}
+
+func newMessageResponse(message *ads.Message) messageResponse {
+	return messageResponse{
+		ID:             message.ID,
+		ConversationID: message.ConversationID,
+		SenderID:       message.SenderID,
+		Text:           message.Text,
+		CreationTime:   message.CreationTime,
+		ReadTime:       message.ReadTime,
+	}
+}
+
+// unread - сколько сообщений собеседника запросивший ещё не прочитал
+type conversationResponse struct {
+	ID           int64            `json:"id"`
+	AdID         int64            `json:"ad_id"`
+	AuthorID     int64            `json:"author_id"`
+	BuyerID      int64            `json:"buyer_id"`
+	CreationTime time.Time        `json:"creation_time"`
+	LastMessage  *messageResponse `json:"last_message"`
+	Unread       int64            `json:"unread"`
+	Ad           *adResponse      `json:"ad,omitempty"`
+}
+
+func newConversationResponse(conversation *ads.Conversation) conversationResponse {
+	resp := conversationResponse{
+		ID:           conversation.ID,
+		AdID:         conversation.AdID,
+		AuthorID:     conversation.AuthorID,
+		BuyerID:      conversation.BuyerID,
+		CreationTime: conversation.CreationTime,
+		Unread:       conversation.Unread,
+	}
+	if conversation.LastMessage != nil {
+		message := newMessageResponse(conversation.LastMessage)
+		resp.LastMessage = &message
+	}
+	if conversation.Ad != nil {
+		ad := newAdResponse(conversation.Ad)
+		resp.Ad = &ad
+	}
+	return resp
+}
+
+type inboxEntryResponse struct {
+	AdID          int64       `json:"ad_id"`
+	LastMessageID int64       `json:"last_message_id"`
+	Conversations int64       `json:"conversations"`
+	Unread        int64       `json:"unread"`
+	Ad            *adResponse `json:"ad,omitempty"`
+}
+
+// Условия сохранённого поиска называются так же, как параметры GET /ads; near - "широта,долгота"
+// Событие подписки: заполнено одно из полей ad, message, receipt в зависимости от type
+type eventResponse struct {
+	ID      int64                `json:"id"`
+	Topic   string               `json:"topic"`
+	Type    string               `json:"type"`
+	Time    time.Time            `json:"time"`
+	Ad      *adResponse          `json:"ad,omitempty"`
+	Message *messageResponse     `json:"message,omitempty"`
+	Receipt *readReceiptResponse `json:"receipt,omitempty"`
+}
+
+type readReceiptResponse struct {
+	ConversationID int64     `json:"conversation_id"`
+	ReaderID       int64     `json:"reader_id"`
+	UpToID         int64     `json:"up_to_id"`
+	ReadTime       time.Time `json:"read_time"`
 }
 
-type deleteRequest struct {
-	UserID int64 `json:"user_id"`
+func newEventResponse(event pubsub.Event) eventResponse {
+	resp := eventResponse{ID: event.ID, Topic: event.Topic, Type: string(event.Type), Time: event.Time}
+	if event.Ad != nil {
+		ad := newAdResponse(event.Ad)
+		resp.Ad = &ad
+	}
+	if event.Message != nil {
+		message := newMessageResponse(event.Message)
+		resp.Message = &message
+	}
+	if r := event.Receipt; r != nil {
+		resp.Receipt = &readReceiptResponse{ConversationID: r.ConversationID, ReaderID: r.ReaderID, UpToID: r.UpToID, ReadTime: r.ReadTime}
+	}
+	return resp
+}
+
+type searchFilterRequest struct {
+	AuthorIDs        []int64 `json:"author"`
+	ExcludeAuthorIDs []int64 `json:"exclude_author"`
+	CategoryIDs      []int64 `json:"category"`
+	Title            string  `json:"title"`
+	TitleContains    string  `json:"title_contains"`
+	Text             string  `json:"text"`
+	Currency         string  `json:"currency"`
+	MinPrice         *int64  `json:"min_price"`
+	MaxPrice         *int64  `json:"max_price"`
+	Near             string  `json:"near"`
+	RadiusKm         float64 `json:"radius_km"`
+}
+
+func (r searchFilterRequest) filter() (ads.Filter, error) {
+	filter := ads.Filter{
+		AuthorIDs:        r.AuthorIDs,
+		ExcludeAuthorIDs: r.ExcludeAuthorIDs,
+		CategoryIDs:      r.CategoryIDs,
+		TitlePrefix:      r.Title,
+		TitleContains:    r.TitleContains,
+		Text:             r.Text,
+		Currency:         r.Currency,
+		Price:            ads.PriceRange{Min: r.MinPrice, Max: r.MaxPrice},
+	}
+
+	near, err := ads.ParseNear(r.Near, "")
+	if err != nil {
+		return filter, err
+	}
+	if near != nil {
+		near.RadiusKm = r.RadiusKm
+	} else if r.RadiusKm != 0 {
+		return filter, ads.ErrBadRequest
+	}
+	filter.Near = near
+
+	return filter, nil
+}
+
+func newSearchFilterResponse(filter ads.Filter) searchFilterRequest {
+	resp := searchFilterRequest{
+		AuthorIDs:        filter.AuthorIDs,
+		ExcludeAuthorIDs: filter.ExcludeAuthorIDs,
+		CategoryIDs:      filter.CategoryIDs,
+		Title:            filter.TitlePrefix,
+		TitleContains:    filter.TitleContains,
+		Text:             filter.Text,
+		Currency:         filter.Currency,
+		MinPrice:         filter.Price.Min,
+		MaxPrice:         filter.Price.Max,
+	}
+	if near := filter.Near; near != nil {
+		resp.Near = strconv.FormatFloat(near.Center.Latitude, 'g', -1, 64) + "," + strconv.FormatFloat(near.Center.Longitude, 'g', -1, 64)
+		resp.RadiusKm = near.RadiusKm
+	}
+	return resp
 }
 
-type updateAdRequest struct {
-	Title  string `json:"title"`
-	Text   string `json:"text"`
-	UserID int64  `json:"user_id"`
+type savedSearchRequest struct {
+	Name   string              `json:"name"`
+	Filter searchFilterRequest `json:"filter"`
+}
+
+type savedSearchResponse struct {
+	ID           int64               `json:"id"`
+	Name         string              `json:"name"`
+	Filter       searchFilterRequest `json:"filter"`
+	CreationTime time.Time           `json:"creation_time"`
+}
+
+func newSavedSearchResponse(search *ads.SavedSearch) savedSearchResponse {
+	return savedSearchResponse{
+		ID:           search.ID,
+		Name:         search.Name,
+		Filter:       newSearchFilterResponse(search.Filter),
+		CreationTime: search.CreationTime,
+	}
+}
+
+// delivery_time - когда уведомление доставлено; null и error - доставить не удалось
+type notificationResponse struct {
+	ID           int64      `json:"id"`
+	SearchID     int64      `json:"search_id"`
+	AdID         int64      `json:"ad_id"`
+	Title        string     `json:"title"`
+	CreationTime time.Time  `json:"creation_time"`
+	DeliveryTime *time.Time `json:"delivery_time"`
+	Error        string     `json:"error"`
+}
+
+type categoryRequest struct {
+	Name     string `json:"name"`
+	ParentID int64  `json:"parent_id"`
+}
+
+type categoryResponse struct {
+	ID       int64  `json:"id"`
+	ParentID int64  `json:"parent_id"`
+	Name     string `json:"name"`
+}
+
+type categoryNodeResponse struct {
+	categoryResponse
+	AdCount  int64                  `json:"ad_count"`
+	Children []categoryNodeResponse `json:"children"`
 }
 
 type createUserRequest struct {
@@ -47,20 +532,28 @@ type createUserRequest struct {
 	Phone      string `json:"phone"`
 }
 
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type setUserRoleRequest struct {
+	Role string `json:"role"`
+}
+
 type updateUserRequest struct {
 	FirstName  string `json:"first_name"`
 	SecondName string `json:"second_name"`
 	Email      string `json:"email"`
 	Phone      string `json:"phone"`
-	UserID     int64  `json:"user_id"`
 }
 
 type userResponse struct {
 	FirstName  string `json:"first_name"`
 	SecondName string `json:"second_name"`
-	Email      string `json:"email"`
-	Phone      string `json:"phone"`
+	Email      string `json:"email,omitempty"`
+	Phone      string `json:"phone,omitempty"`
 	UserID     int64  `json:"user_id"`
+	Role       string `json:"role"`
 }
 
 func DeleteSuccessResponse() *gin.H {
@@ -70,15 +563,242 @@ func DeleteSuccessResponse() *gin.H {
 	}
 }
 
-func ListAdsSuccessResponse(ad []*ads.Ad) *gin.H {
+// next_cursor передаётся в параметре cursor за следующей страницей; null - страниц больше нет
+func ListAdsSuccessResponse(ad []*ads.Ad, next *ads.Cursor) *gin.H {
 	var copy []adResponse
 
 	for _, v := range ad {
-		copy = append(copy, adResponse{v.ID, v.Title, v.Text, v.AuthorID, v.Published, v.CreationTime, v.UpdateTime})
+		copy = append(copy, newAdResponse(v))
+	}
+
+	var cursor any
+	if next != nil {
+		cursor = next.Encode()
+	}
+
+	return &gin.H{
+		"data":        copy,
+		"next_cursor": cursor,
+		"error":       nil,
+	}
+}
+
+func SearchAdsSuccessResponse(results []*ads.SearchResult) *gin.H {
+	list := make([]searchResultResponse, 0, len(results))
+
+	for _, v := range results {
+		list = append(list, searchResultResponse{
+			adResponse: newAdResponse(v.Ad),
+			Score:      v.Score,
+			Snippet:    v.Snippet,
+		})
+	}
+
+	return &gin.H{
+		"data":  list,
+		"error": nil,
+	}
+}
+
+func ReviewsSuccessResponse(reviews []*ads.Review) *gin.H {
+	list := make([]reviewResponse, 0, len(reviews))
+	for _, v := range reviews {
+		list = append(list, newReviewResponse(v))
+	}
+
+	return &gin.H{
+		"data":  list,
+		"error": nil,
+	}
+}
+
+func FavoriteSuccessResponse(favorite *ads.Favorite) *gin.H {
+	return &gin.H{
+		"data":  newFavoriteResponse(favorite),
+		"error": nil,
+	}
+}
+
+func FavoritesSuccessResponse(favorites []*ads.Favorite) *gin.H {
+	list := make([]favoriteResponse, 0, len(favorites))
+	for _, v := range favorites {
+		list = append(list, newFavoriteResponse(v))
+	}
+
+	return &gin.H{
+		"data":  list,
+		"error": nil,
+	}
+}
+
+func MessageSuccessResponse(message *ads.Message) *gin.H {
+	return &gin.H{
+		"data":  newMessageResponse(message),
+		"error": nil,
+	}
+}
+
+func MessagesSuccessResponse(messages []*ads.Message) *gin.H {
+	list := make([]messageResponse, 0, len(messages))
+	for _, v := range messages {
+		list = append(list, newMessageResponse(v))
+	}
+
+	return &gin.H{
+		"data":  list,
+		"error": nil,
+	}
+}
+
+func ConversationSuccessResponse(conversation *ads.Conversation) *gin.H {
+	return &gin.H{
+		"data":  newConversationResponse(conversation),
+		"error": nil,
+	}
+}
+
+func ConversationsSuccessResponse(conversations []*ads.Conversation) *gin.H {
+	list := make([]conversationResponse, 0, len(conversations))
+	for _, v := range conversations {
+		list = append(list, newConversationResponse(v))
+	}
+
+	return &gin.H{
+		"data":  list,
+		"error": nil,
+	}
+}
+
+func InboxSuccessResponse(entries []*ads.InboxEntry) *gin.H {
+	list := make([]inboxEntryResponse, 0, len(entries))
+	for _, v := range entries {
+		entry := inboxEntryResponse{
+			AdID:          v.AdID,
+			LastMessageID: v.LastMessageID,
+			Conversations: v.Conversations,
+			Unread:        v.Unread,
+		}
+		if v.Ad != nil {
+			ad := newAdResponse(v.Ad)
+			entry.Ad = &ad
+		}
+		list = append(list, entry)
+	}
+
+	return &gin.H{
+		"data":  list,
+		"error": nil,
+	}
+}
+
+func SavedSearchSuccessResponse(search *ads.SavedSearch) *gin.H {
+	return &gin.H{
+		"data":  newSavedSearchResponse(search),
+		"error": nil,
+	}
+}
+
+func SavedSearchesSuccessResponse(searches []*ads.SavedSearch) *gin.H {
+	list := make([]savedSearchResponse, 0, len(searches))
+	for _, v := range searches {
+		list = append(list, newSavedSearchResponse(v))
+	}
+
+	return &gin.H{
+		"data":  list,
+		"error": nil,
+	}
+}
+
+func NotificationsSuccessResponse(notifications []*ads.Notification) *gin.H {
+	list := make([]notificationResponse, 0, len(notifications))
+	for _, v := range notifications {
+		list = append(list, notificationResponse{
+			ID:           v.ID,
+			SearchID:     v.SearchID,
+			AdID:         v.AdID,
+			Title:        v.Title,
+			CreationTime: v.CreationTime,
+			DeliveryTime: v.DeliveryTime,
+			Error:        v.Error,
+		})
 	}
 
 	return &gin.H{
-		"data":  copy,
+		"data":  list,
+		"error": nil,
+	}
+}
+
+func WebhookSuccessResponse(hook *ads.Webhook) *gin.H {
+	return &gin.H{
+		"data":  newWebhookResponse(hook),
+		"error": nil,
+	}
+}
+
+func WebhooksSuccessResponse(hooks []*ads.Webhook) *gin.H {
+	list := make([]webhookResponse, 0, len(hooks))
+	for _, v := range hooks {
+		list = append(list, newWebhookResponse(v))
+	}
+
+	return &gin.H{
+		"data":  list,
+		"error": nil,
+	}
+}
+
+func WebhookDeliverySuccessResponse(delivery *ads.WebhookDelivery) *gin.H {
+	return &gin.H{
+		"data":  newWebhookDeliveryResponse(delivery),
+		"error": nil,
+	}
+}
+
+func WebhookDeliveriesSuccessResponse(deliveries []*ads.WebhookDelivery) *gin.H {
+	list := make([]webhookDeliveryResponse, 0, len(deliveries))
+	for _, v := range deliveries {
+		list = append(list, newWebhookDeliveryResponse(v))
+	}
+
+	return &gin.H{
+		"data":  list,
+		"error": nil,
+	}
+}
+
+func RevisionSuccessResponse(rev *ads.Revision) *gin.H {
+	return &gin.H{
+		"data":  newRevisionResponse(rev),
+		"error": nil,
+	}
+}
+
+func RevisionsSuccessResponse(revisions []*ads.Revision) *gin.H {
+	list := make([]revisionResponse, 0, len(revisions))
+	for _, v := range revisions {
+		list = append(list, newRevisionResponse(v))
+	}
+
+	return &gin.H{
+		"data":  list,
+		"error": nil,
+	}
+}
+
+func RevisionDiffSuccessResponse(from, to int64, changes []ads.Change) *gin.H {
+	list := make([]changeResponse, 0, len(changes))
+	for _, v := range changes {
+		list = append(list, newChangeResponse(v))
+	}
+
+	return &gin.H{
+		"data": gin.H{
+			"from":    from,
+			"to":      to,
+			"changes": list,
+		},
 		"error": nil,
 	}
 }
@@ -91,29 +811,31 @@ func UserSuccessResponse(user *ads.User) *gin.H {
 			Email:      user.Email,
 			Phone:      user.Phone,
 			UserID:     user.ID,
+			Role:       string(user.UserRole()),
 		},
 		"error": nil,
 	}
 }
 
-func LoginSuccessResponse(token string) *gin.H {
+func LoginSuccessResponse(pair *auth.TokenPair) *gin.H {
+	return &gin.H{
+		"token":         pair.AccessToken,
+		"refresh_token": pair.RefreshToken,
+		"expires_in":    int64(pair.ExpiresIn.Seconds()),
+		"error":         nil,
+	}
+}
+
+func LogoutSuccessResponse() *gin.H {
 	return &gin.H{
-		"token": token,
+		"data":  "logout success",
 		"error": nil,
 	}
 }
 
 func AdSuccessResponse(ad *ads.Ad) *gin.H {
 	return &gin.H{
-		"data": adResponse{
-			ID:           ad.ID,
-			Title:        ad.Title,
-			Text:         ad.Text,
-			AuthorID:     ad.AuthorID,
-			Published:    ad.Published,
-			CreationTime: ad.CreationTime,
-			UpdateTime:   ad.UpdateTime,
-		},
+		"data":  newAdResponse(ad),
 		"error": nil,
 	}
 }
@@ -124,3 +846,34 @@ func ErrorResponse(err error) *gin.H {
 		"error": err.Error(),
 	}
 }
+
+func newCategoryResponse(c *ads.Category) categoryResponse {
+	return categoryResponse{ID: c.ID, ParentID: c.ParentID, Name: c.Name}
+}
+
+func newCategoryNodes(nodes []*ads.CategoryNode) []categoryNodeResponse {
+	list := make([]categoryNodeResponse, 0, len(nodes))
+	for _, node := range nodes {
+		list = append(list, categoryNodeResponse{
+			categoryResponse: newCategoryResponse(&node.Category),
+			AdCount:          node.Count,
+			Children:         newCategoryNodes(node.Children),
+		})
+	}
+	return list
+}
+
+// В ad_count входят опубликованные объявления категории и всех её подкатегорий
+func CategoryTreeSuccessResponse(nodes []*ads.CategoryNode) *gin.H {
+	return &gin.H{
+		"data":  newCategoryNodes(nodes),
+		"error": nil,
+	}
+}
+
+func CategorySuccessResponse(c *ads.Category) *gin.H {
+	return &gin.H{
+		"data":  newCategoryResponse(c),
+		"error": nil,
+	}
+}
@@ -7,15 +7,59 @@ import (
 )
 
 func AppRouter(r *gin.RouterGroup, a app.App) {
-	r.DELETE("/ads/:ad_id", deleteAd(a))           // Метод для удаления объявления
-	r.DELETE("/users/:user_id", deleteUser(a))     // Метод для удаления объявления
-	r.GET("/ads/:ad_id", getAd(a))                 // Метод для получения объявления
-	r.GET("/users/:user_id", getUser(a))           // Метод для получения пользователя
-	r.GET("/ads", listAds(a))                      // Метод для получения отфильтр. об.
-	r.POST("/users", createUser(a))                // Метод для создания пользователей
-	r.POST("/users/login", loginUser(a))           // Метод для логирования пользователей
-	r.POST("/ads", createAd(a))                    // Метод для создания объявления (ad)
-	r.PUT("/ads/:ad_id/status", changeAdStatus(a)) // Метод для изменения статуса объявления (опубликовано - Published = true или снято с публикации Published = false)
-	r.PUT("/ads/:ad_id", updateAd(a))              // Метод для обновления текста(Text) или заголовка(Title) объявления
-	r.PUT("/users/:user_id", updateUser(a))        // Метод для обновления текста(Text) или заголовка(Title) объявления
+	r.DELETE("/ads/:ad_id", deleteAd(a))                                  // Метод для удаления объявления
+	r.DELETE("/users/:user_id", deleteUser(a))                            // Метод для удаления объявления
+	r.GET("/ads/:ad_id", getAd(a))                                        // Метод для получения объявления
+	r.GET("/users/:user_id", getUser(a))                                  // Метод для получения пользователя
+	r.GET("/ads", listAds(a))                                             // Метод для получения отфильтр. об.
+	r.GET("/ads/search", searchAds(a))                                    // Метод для полнотекстового поиска объявлений
+	r.POST("/users", createUser(a))                                       // Метод для создания пользователей
+	r.POST("/users/login", loginUser(a))                                  // Метод для логирования пользователей
+	r.POST("/users/refresh", refreshToken(a))                             // Метод для обновления пары токенов
+	r.POST("/users/logout", logoutUser(a))                                // Метод для выхода пользователя
+	r.POST("/ads", createAd(a))                                           // Метод для создания объявления (ad)
+	r.PUT("/ads/:ad_id/status", changeAdStatus(a))                        // Метод для изменения статуса объявления (опубликовано - Published = true или снято с публикации Published = false)
+	r.POST("/ads/:ad_id/renew", renewAd(a))                               // Метод для продления публикации объявления
+	r.PUT("/ads/:ad_id", updateAd(a))                                     // Метод для обновления текста(Text) или заголовка(Title) объявления
+	r.PUT("/users/:user_id/role", setUserRole(a))                         // Метод для назначения роли пользователю
+	r.GET("/categories", listCategories(a))                               // Метод для получения дерева категорий
+	r.POST("/categories", createCategory(a))                              // Метод для создания категории
+	r.PUT("/categories/:category_id", updateCategory(a))                  // Метод для переименования и переноса категории
+	r.DELETE("/categories/:category_id", deleteCategory(a))               // Метод для удаления категории
+	r.POST("/ads/:ad_id/images", uploadAdImages(a))                       // Метод для загрузки картинок объявления
+	r.DELETE("/ads/:ad_id/images/:image_id", deleteAdImage(a))            // Метод для удаления картинки объявления
+	r.GET("/images/*key", getImage(a))                                    // Метод для получения картинки или миниатюры
+	r.GET("/moderation/queue", listModerationQueue(a))                    // Метод для получения очереди модерации
+	r.POST("/moderation/ads/:ad_id/approve", approveAd(a))                // Метод для одобрения объявления модератором
+	r.POST("/moderation/ads/:ad_id/reject", rejectAd(a))                  // Метод для отклонения объявления модератором с причиной
+	r.GET("/ads/:ad_id/reviews", listAdReviews(a))                        // Метод для получения истории проверок объявления
+	r.GET("/users/:user_id/favorites", listFavorites(a))                  // Метод для получения избранного пользователя
+	r.POST("/users/:user_id/favorites/:ad_id", addFavorite(a))            // Метод для добавления объявления в избранное
+	r.DELETE("/users/:user_id/favorites/:ad_id", removeFavorite(a))       // Метод для удаления объявления из избранного
+	r.GET("/users/:user_id/searches", listSavedSearches(a))               // Метод для получения сохранённых поисков
+	r.POST("/users/:user_id/searches", createSavedSearch(a))              // Метод для сохранения поиска
+	r.PUT("/users/:user_id/searches/:search_id", updateSavedSearch(a))    // Метод для изменения сохранённого поиска
+	r.DELETE("/users/:user_id/searches/:search_id", deleteSavedSearch(a)) // Метод для удаления сохранённого поиска
+	r.GET("/users/:user_id/notifications", listNotifications(a))          // Метод для получения уведомлений по сохранённым поискам
+	r.POST("/ads/:ad_id/messages", contactAuthor(a))                      // Метод для сообщения автору объявления
+	r.GET("/users/:user_id/conversations", listConversations(a))          // Метод для получения переписок пользователя
+	r.GET("/users/:user_id/inbox", listInbox(a))                          // Метод для получения входящих автора по объявлениям
+	r.GET("/conversations/:conversation_id/messages", listMessages(a))    // Метод для получения сообщений переписки
+	r.POST("/conversations/:conversation_id/messages", sendMessage(a))    // Метод для сообщения в переписку
+	r.POST("/conversations/:conversation_id/read", markRead(a))           // Метод для отметки сообщений прочитанными
+	r.GET("/stream", subscribe(a))                                        // Метод для подписки на события по WebSocket
+	r.PUT("/users/:user_id", updateUser(a))                               // Метод для обновления текста(Text) или заголовка(Title) объявления
+
+	r.GET("/webhooks", listWebhooks(a))                                                    // Метод для получения вебхуков
+	r.POST("/webhooks", createWebhook(a))                                                  // Метод для создания вебхука
+	r.GET("/webhooks/:webhook_id", getWebhook(a))                                          // Метод для получения вебхука
+	r.PUT("/webhooks/:webhook_id", updateWebhook(a))                                       // Метод для изменения вебхука
+	r.DELETE("/webhooks/:webhook_id", deleteWebhook(a))                                    // Метод для удаления вебхука
+	r.GET("/webhooks/:webhook_id/deliveries", listWebhookDeliveries(a))                    // Метод для получения журнала доставок вебхука
+	r.POST("/webhooks/:webhook_id/deliveries/:delivery_id/retry", retryWebhookDelivery(a)) // Метод для повторной отправки события
+
+	r.GET("/ads/:ad_id/revisions", listAdRevisions(a))                      // Метод для получения истории правок объявления
+	r.GET("/ads/:ad_id/revisions/diff", diffAdRevisions(a))                 // Метод для сравнения двух ревизий
+	r.GET("/ads/:ad_id/revisions/:revision", getAdRevision(a))              // Метод для получения ревизии
+	r.POST("/ads/:ad_id/revisions/:revision/restore", restoreAdRevision(a)) // Метод для возврата объявления к ревизии
 }
@@ -1,6 +1,7 @@
 package httpgin
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -14,6 +15,7 @@ import (
 type Server struct {
 	port string
 	app  *gin.Engine
+	srv  *http.Server
 }
 
 func MyLogger(param gin.LogFormatterParams) string {
@@ -52,18 +54,30 @@ func handlePanic(c *gin.Context, err interface{}) {
 func NewHTTPServer(port string, a app.App) Server {
 	gin.SetMode(gin.ReleaseMode)
 	s := Server{port: port, app: gin.New()}
+	// Значения из контекста запроса (например, пользователь) доступны через gin.Context
+	s.app.ContextWithFallback = true
 
 	s.app.Use(gin.LoggerWithFormatter(MyLogger))
 
 	s.app.Use(Recovery(handlePanic))
 
+	// Открытые ключи для проверки токенов на стороне шлюза
+	s.app.GET("/.well-known/jwks.json", jwks(a))
+
 	api := s.app.Group("/api/v1")
+	api.Use(Authenticate(a))
 	AppRouter(api, a)
+
+	s.srv = &http.Server{Addr: port, Handler: s.app}
 	return s
 }
 
 func (s *Server) Listen() error {
-	return s.app.Run(s.port)
+	return s.srv.ListenAndServe()
+}
+
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
 }
 
 func (s *Server) Handler() http.Handler {
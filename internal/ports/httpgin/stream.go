@@ -0,0 +1,100 @@
+package httpgin
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+
+	"adflow/internal/app"
+	"adflow/internal/pubsub"
+)
+
+const (
+	// Сколько ждём записи одного кадра, прежде чем считать клиента отвалившимся
+	streamWriteWait = 10 * time.Second
+	// Клиент должен ответить на ping за это время
+	streamPongWait   = 60 * time.Second
+	streamPingPeriod = streamPongWait * 9 / 10
+	// Клиент ничего не пишет в поток, кроме управляющих кадров
+	streamReadLimit = 512
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// Метод для подписки на события по WebSocket: темы передаются параметрами topic.
+// Права проверяются до установки соединения, поэтому ошибки приходят обычным JSON-ответом.
+func subscribe(a app.App) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		sub, err := a.Subscribe(c, c.QueryArray("topic"))
+		if err != nil {
+			c.JSON(errorStatus(err), ErrorResponse(err))
+			return
+		}
+		defer sub.Close()
+
+		// Upgrade сам отвечает клиенту, если запрос не WebSocket
+		conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		closed := make(chan struct{})
+		go readStream(conn, closed)
+		writeStream(conn, sub, closed)
+	}
+}
+
+// readStream обрабатывает pong и закрытие соединения клиентом
+func readStream(conn *websocket.Conn, closed chan<- struct{}) {
+	defer close(closed)
+
+	conn.SetReadLimit(streamReadLimit)
+	_ = conn.SetReadDeadline(time.Now().Add(streamPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(streamPongWait))
+	})
+
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+func writeStream(conn *websocket.Conn, sub *pubsub.Subscription, closed <-chan struct{}) {
+	ticker := time.NewTicker(streamPingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event := <-sub.Events():
+			_ = conn.SetWriteDeadline(time.Now().Add(streamWriteWait))
+			if err := conn.WriteJSON(newEventResponse(event)); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteWait)); err != nil {
+				return
+			}
+		case <-sub.Done():
+			// Медленного подписчика шина отключила: клиенту стоит перечитать состояние и подписаться снова
+			code, text := websocket.CloseNormalClosure, ""
+			switch err := sub.Err(); {
+			case errors.Is(err, pubsub.ErrSlowConsumer):
+				code, text = websocket.CloseTryAgainLater, err.Error()
+			case errors.Is(err, pubsub.ErrClosed):
+				code, text = websocket.CloseGoingAway, err.Error()
+			}
+			_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(streamWriteWait))
+			return
+		case <-closed:
+			return
+		}
+	}
+}
@@ -0,0 +1,218 @@
+// Package pubsub - шина событий внутри процесса: приложение публикует в неё события домена,
+// а транспорты доставляют их подписчикам по WebSocket и gRPC.
+package pubsub
+
+import (
+	"adflow/internal/ads"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type EventType string
+
+const (
+	EventAdUpdated    EventType = "ad.updated"
+	EventAdStatus     EventType = "ad.status_changed"
+	EventAdDeleted    EventType = "ad.deleted"
+	EventMessage      EventType = "message.created"
+	EventMessagesRead EventType = "message.read"
+)
+
+// Event - событие темы; заполнено одно из полей Ad, Message, Receipt в зависимости от Type
+type Event struct {
+	// ID растёт в пределах процесса, по нему видно, что события пропущены
+	ID      int64
+	Topic   string
+	Type    EventType
+	Time    time.Time
+	Ad      *ads.Ad
+	Message *ads.Message
+	Receipt *ads.ReadReceipt
+}
+
+// Темы: ads/<id> - изменения объявления, users/<id> - сообщения пользователя и его объявления
+const (
+	TopicAds   = "ads"
+	TopicUsers = "users"
+)
+
+func AdTopic(id int64) string {
+	return fmt.Sprintf("%s/%d", TopicAds, id)
+}
+
+func UserTopic(id int64) string {
+	return fmt.Sprintf("%s/%d", TopicUsers, id)
+}
+
+// ParseTopic разбирает тему на вид и ID
+func ParseTopic(topic string) (string, int64, error) {
+	kind, value, ok := strings.Cut(topic, "/")
+	if !ok || (kind != TopicAds && kind != TopicUsers) {
+		return "", 0, ads.ErrBadRequest
+	}
+
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id <= 0 {
+		return "", 0, ads.ErrBadRequest
+	}
+	return kind, id, nil
+}
+
+// ErrSlowConsumer - подписчик не успевал забирать события, и шина его отключила.
+// Клиенту нужно перечитать состояние через API и подписаться снова.
+var ErrSlowConsumer = errors.New("pubsub: slow consumer")
+
+// ErrClosed - шину остановили вместе с сервисом; подписчику стоит переподключиться позже
+var ErrClosed = errors.New("pubsub: hub is closed")
+
+// DefaultBuffer - сколько событий ждут подписчика, прежде чем шина его отключит
+const DefaultBuffer = 64
+
+// Hub рассылает события подписчикам тем. Publish никогда не блокируется:
+// подписчик с переполненным буфером отключается с ErrSlowConsumer.
+type Hub struct {
+	buffer int
+
+	mu     sync.RWMutex
+	seq    int64
+	topics map[string]map[*Subscription]struct{}
+	closed bool
+}
+
+func NewHub(buffer int) *Hub {
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+	return &Hub{
+		buffer: buffer,
+		topics: make(map[string]map[*Subscription]struct{}),
+	}
+}
+
+func (h *Hub) Publish(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+
+	var slow []*Subscription
+
+	h.mu.Lock()
+	h.seq += 1
+	event.ID = h.seq
+	for s := range h.topics[event.Topic] {
+		select {
+		case s.events <- event:
+		default:
+			slow = append(slow, s)
+		}
+	}
+	h.mu.Unlock()
+
+	for _, s := range slow {
+		s.close(ErrSlowConsumer)
+	}
+}
+
+// Subscribe подписывает на темы; права на них проверяет вызывающий
+func (h *Hub) Subscribe(topics ...string) *Subscription {
+	s := &Subscription{
+		hub:    h,
+		topics: topics,
+		events: make(chan Event, h.buffer),
+		done:   make(chan struct{}),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		s.err = ErrClosed
+		s.once.Do(func() { close(s.done) })
+		return s
+	}
+	for _, topic := range topics {
+		subs, ok := h.topics[topic]
+		if !ok {
+			subs = make(map[*Subscription]struct{})
+			h.topics[topic] = subs
+		}
+		subs[s] = struct{}{}
+	}
+	return s
+}
+
+// Close отключает всех подписчиков с ErrClosed; новые подписки сразу закрыты.
+// Без этого открытые потоки не дадут серверам остановиться.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	h.closed = true
+	var subs []*Subscription
+	for _, topic := range h.topics {
+		for s := range topic {
+			subs = append(subs, s)
+		}
+	}
+	h.mu.Unlock()
+
+	for _, s := range subs {
+		s.close(ErrClosed)
+	}
+}
+
+func (h *Hub) remove(s *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, topic := range s.topics {
+		delete(h.topics[topic], s)
+		if len(h.topics[topic]) == 0 {
+			delete(h.topics, topic)
+		}
+	}
+}
+
+type Subscription struct {
+	hub    *Hub
+	topics []string
+	events chan Event
+
+	once sync.Once
+	done chan struct{}
+	err  error
+}
+
+func (s *Subscription) Topics() []string {
+	return s.topics
+}
+
+// Events - события в порядке публикации. Канал не закрывается: конец подписки отмечает Done.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Done закрывается, когда подписку закрыли, шина отключила медленного подписчика или саму шину закрыли
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err - причина отключения; nil, если подписку закрыл сам подписчик
+func (s *Subscription) Err() error {
+	<-s.done
+	return s.err
+}
+
+func (s *Subscription) Close() {
+	s.close(nil)
+}
+
+func (s *Subscription) close(err error) {
+	s.once.Do(func() {
+		s.hub.remove(s)
+		s.err = err
+		close(s.done)
+	})
+}
@@ -0,0 +1,168 @@
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+
+	"github.com/kljensen/snowball/english"
+	"github.com/kljensen/snowball/russian"
+
+	"adflow/internal/ads"
+)
+
+func isCyrillic(word string) bool {
+	for _, r := range word {
+		if unicode.Is(unicode.Cyrillic, r) {
+			return true
+		}
+	}
+	return false
+}
+
+func isLatin(word string) bool {
+	for _, r := range word {
+		if unicode.Is(unicode.Latin, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// Stem приводит слово в нижнем регистре к основе; язык определяется по алфавиту.
+// Для стоп-слов возвращается пустая строка.
+func Stem(word string) string {
+	word = strings.ReplaceAll(word, "ё", "е")
+
+	switch {
+	case isCyrillic(word):
+		if russian.IsStopWord(word) {
+			return ""
+		}
+		return russian.Stem(word, false)
+	case isLatin(word):
+		if english.IsStopWord(word) {
+			return ""
+		}
+		return english.Stem(word, false)
+	}
+	return word
+}
+
+// Analyze разбивает текст на основы слов. Индексы и запросы разбираются одинаково,
+// поэтому "велосипеды" находит "велосипед", а "bikes" - "bike".
+func Analyze(text string) []string {
+	var stems []string
+	for _, word := range ads.Tokens(text) {
+		if stem := Stem(word); stem != "" {
+			stems = append(stems, stem)
+		}
+	}
+	return stems
+}
+
+// Terms возвращает различные основы запроса в порядке появления
+func Terms(query string) []string {
+	seen := make(map[string]struct{})
+	var terms []string
+	for _, stem := range Analyze(query) {
+		if _, ok := seen[stem]; !ok {
+			seen[stem] = struct{}{}
+			terms = append(terms, stem)
+		}
+	}
+	return terms
+}
+
+const (
+	SnippetSize  = 160
+	snippetLead  = 5
+	HighlightOn  = "<mark>"
+	HighlightOff = "</mark>"
+)
+
+type word struct {
+	start, end int
+	match      bool
+}
+
+func splitWords(text string, terms map[string]struct{}) []word {
+	var words []word
+	start := -1
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		_, match := terms[Stem(strings.ToLower(text[start:end]))]
+		words = append(words, word{start, end, match})
+		start = -1
+	}
+
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+		} else {
+			flush(i)
+		}
+	}
+	flush(len(text))
+
+	return words
+}
+
+// Snippet вырезает из текста фрагмент около первого найденного слова запроса
+// и выделяет совпадения тегами <mark>. Остальной текст экранируется для HTML.
+func Snippet(text, query string) string {
+	terms := make(map[string]struct{})
+	for _, term := range Terms(query) {
+		terms[term] = struct{}{}
+	}
+
+	words := splitWords(text, terms)
+	if len(words) == 0 {
+		return html.EscapeString(text)
+	}
+
+	first := 0
+	for i, w := range words {
+		if w.match {
+			first = i
+			break
+		}
+	}
+	if first -= snippetLead; first < 0 {
+		first = 0
+	}
+
+	from := words[first].start
+	if first == 0 {
+		from = 0
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+
+	pos, size := from, 0
+	for _, w := range words[first:] {
+		if size > 0 && size+len([]rune(text[pos:w.end])) > SnippetSize {
+			b.WriteString("…")
+			return b.String()
+		}
+		size += len([]rune(text[pos:w.end]))
+
+		b.WriteString(html.EscapeString(text[pos:w.start]))
+		if w.match {
+			b.WriteString(HighlightOn + html.EscapeString(text[w.start:w.end]) + HighlightOff)
+		} else {
+			b.WriteString(html.EscapeString(text[w.start:w.end]))
+		}
+		pos = w.end
+	}
+	b.WriteString(html.EscapeString(text[pos:]))
+
+	return b.String()
+}
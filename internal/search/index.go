@@ -0,0 +1,195 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"adflow/internal/ads"
+)
+
+// Hit - найденное объявление и его релевантность
+type Hit struct {
+	ID    int64
+	Score float64
+}
+
+// Поля документа и их веса: совпадение в заголовке важнее совпадения в тексте
+const (
+	FieldTitle = iota
+	FieldText
+	fieldCount
+)
+
+var FieldWeights = [fieldCount]float64{2, 1}
+
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// BM25 - вклад одного слова запроса в одном поле документа.
+// tf - число вхождений в поле, df - число документов, где поле содержит слово,
+// total - число документов, docLen и avgLen - длина поля в словах у документа и в среднем.
+func BM25(tf, df, total int, docLen, avgLen float64) float64 {
+	if tf == 0 || total == 0 {
+		return 0
+	}
+	idf := math.Log(1 + (float64(total-df)+0.5)/(float64(df)+0.5))
+	norm := 1 - bm25B
+	if avgLen > 0 {
+		norm += bm25B * docLen / avgLen
+	}
+	return idf * float64(tf) * (bm25K1 + 1) / (float64(tf) + bm25K1*norm)
+}
+
+// sortHits упорядочивает по убыванию релевантности, при равенстве - по ID
+func sortHits(hits []Hit) {
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+}
+
+func page(hits []Hit, limit, offset int) []Hit {
+	if offset >= len(hits) {
+		return nil
+	}
+	hits = hits[offset:]
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+type document struct {
+	tf  [fieldCount]map[string]int
+	len [fieldCount]int
+}
+
+// MemoryIndex - обратный индекс в памяти процесса
+type MemoryIndex struct {
+	docs     map[int64]*document
+	postings map[string]map[int64]struct{}
+	totalLen [fieldCount]int
+	m        sync.RWMutex
+}
+
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{
+		docs:     make(map[int64]*document),
+		postings: make(map[string]map[int64]struct{}),
+	}
+}
+
+func (idx *MemoryIndex) Index(ad *ads.Ad) error {
+	idx.m.Lock()
+	defer idx.m.Unlock()
+
+	idx.remove(ad.ID)
+
+	doc := &document{}
+	for field, text := range [fieldCount]string{ad.Title, ad.Text} {
+		doc.tf[field] = make(map[string]int)
+		for _, stem := range Analyze(text) {
+			doc.tf[field][stem]++
+			doc.len[field]++
+
+			if idx.postings[stem] == nil {
+				idx.postings[stem] = make(map[int64]struct{})
+			}
+			idx.postings[stem][ad.ID] = struct{}{}
+		}
+		idx.totalLen[field] += doc.len[field]
+	}
+	idx.docs[ad.ID] = doc
+
+	return nil
+}
+
+func (idx *MemoryIndex) remove(id int64) {
+	doc, ok := idx.docs[id]
+	if !ok {
+		return
+	}
+
+	for field := range doc.tf {
+		for stem := range doc.tf[field] {
+			delete(idx.postings[stem], id)
+			if len(idx.postings[stem]) == 0 {
+				delete(idx.postings, stem)
+			}
+		}
+		idx.totalLen[field] -= doc.len[field]
+	}
+	delete(idx.docs, id)
+}
+
+func (idx *MemoryIndex) Remove(id int64) error {
+	idx.m.Lock()
+	defer idx.m.Unlock()
+
+	idx.remove(id)
+	return nil
+}
+
+// Search находит документы, содержащие все слова запроса, и ранжирует их по BM25
+func (idx *MemoryIndex) Search(query string, limit, offset int) ([]Hit, error) {
+	idx.m.RLock()
+	defer idx.m.RUnlock()
+
+	terms := Terms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	// Кандидаты - документы самого редкого слова
+	rarest := idx.postings[terms[0]]
+	for _, term := range terms[1:] {
+		if len(idx.postings[term]) < len(rarest) {
+			rarest = idx.postings[term]
+		}
+	}
+
+	total := len(idx.docs)
+	// Средняя длина округляется вниз, как в matchinfo FTS4, чтобы оценки совпадали с SQLite
+	var avgLen [fieldCount]float64
+	for field := range avgLen {
+		if total > 0 {
+			avgLen[field] = float64(idx.totalLen[field] / total)
+		}
+	}
+
+	var df [fieldCount]map[string]int
+	for field := range df {
+		df[field] = make(map[string]int)
+		for _, term := range terms {
+			for id := range idx.postings[term] {
+				if idx.docs[id].tf[field][term] > 0 {
+					df[field][term]++
+				}
+			}
+		}
+	}
+
+	var hits []Hit
+candidates:
+	for id := range rarest {
+		doc := idx.docs[id]
+		score := 0.0
+		for _, term := range terms {
+			if _, ok := idx.postings[term][id]; !ok {
+				continue candidates
+			}
+			for field := range doc.tf {
+				score += FieldWeights[field] * BM25(doc.tf[field][term], df[field][term], total, float64(doc.len[field]), avgLen[field])
+			}
+		}
+		hits = append(hits, Hit{ID: id, Score: score})
+	}
+
+	sortHits(hits)
+	return page(hits, limit, offset), nil
+}
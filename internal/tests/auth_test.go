@@ -1,6 +1,9 @@
 package tests
 
 import (
+	"bytes"
+	"encoding/json"
+	"net/http"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -8,30 +11,65 @@ import (
 
 func TestBadToken(t *testing.T) {
 	client := getTestClient()
-	user, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	_, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
 	assert.NoError(t, err)
 
 	token, err := client.loginUser("skyberg11", "abacaba")
 	assert.NoError(t, err)
 
-	_, err = client.createAd(user.Data.ID, "hello", "world", "42istheanswerforanyquestion")
-	assert.ErrorIs(t, err, ErrBadRequest)
+	_, err = client.createAd("hello", "world", "42istheanswerforanyquestion")
+	assert.ErrorIs(t, err, ErrUnauthorized)
+
+	_, err = client.createAd("hello", "world", "")
+	assert.ErrorIs(t, err, ErrUnauthorized)
 
-	_, err = client.createAd(user.Data.ID, "hello", "world", token.Token)
+	_, err = client.createAd("hello", "world", token.Token)
 	assert.NoError(t, err)
 }
 
-func TestAnotherToken(t *testing.T) {
+func TestTokenWithoutBearer(t *testing.T) {
 	client := getTestClient()
 	_, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
 	assert.NoError(t, err)
 
+	token, err := client.loginUser("skyberg11", "abacaba")
+	assert.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodGet, client.baseURL+"/api/v1/ads", nil)
+	assert.NoError(t, err)
+	req.Header.Add("Authorization", token.Token)
+
+	var response adsResponse
+	err = client.getResponse(req, &response)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+}
+
+func TestAnotherToken(t *testing.T) {
+	client := getTestClient()
+	user, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	assert.NoError(t, err)
+
 	another, err := client.createUser("Andrew", "Ivanov", "abacaba", "12345678", "arr@mail.ru", "+79821233123")
 	assert.NoError(t, err)
 
 	token, err := client.loginUser("skyberg11", "abacaba")
 	assert.NoError(t, err)
 
-	_, err = client.createAd(another.Data.ID, "hello", "world", token.Token)
-	assert.ErrorIs(t, err, ErrForbidden)
+	// user_id из тела запроса игнорируется: автор берётся из токена
+	data, err := json.Marshal(map[string]any{
+		"user_id": another.Data.ID,
+		"title":   "hello",
+		"text":    "world",
+	})
+	assert.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPost, client.baseURL+"/api/v1/ads", bytes.NewReader(data))
+	assert.NoError(t, err)
+	req.Header.Add("Content-Type", "application/json")
+	setToken(req, token.Token)
+
+	var response adResponse
+	err = client.getResponse(req, &response)
+	assert.NoError(t, err)
+	assert.Equal(t, user.Data.ID, response.Data.AuthorID)
 }
@@ -15,7 +15,7 @@ func TestCreateAd(t *testing.T) {
 	token, err := client.loginUser("skyberg11", "abacaba")
 	assert.NoError(t, err)
 
-	response, err := client.createAd(user.Data.ID, "hello", "world", token.Token)
+	response, err := client.createAd("hello", "world", token.Token)
 	assert.NoError(t, err)
 	assert.Equal(t, response.Data.Title, "hello")
 	assert.Equal(t, response.Data.Text, "world")
@@ -43,7 +43,7 @@ func TestGetAd(t *testing.T) {
 		{Ad{"test", "1"}, "test"},
 	}
 
-	user, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	_, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
 	assert.NoError(t, err)
 
 	token, err := client.loginUser("skyberg11", "abacaba")
@@ -56,10 +56,10 @@ func TestGetAd(t *testing.T) {
 		t.Run(test.ExpectName, func(t *testing.T) {
 			t.Parallel()
 
-			response, err := client.createAd(user.Data.ID, test.In.Title, test.In.Text, token.Token)
+			response, err := client.createAd(test.In.Title, test.In.Text, token.Token)
 			assert.NoError(t, err)
 
-			got, err := client.getAd(response.Data.ID)
+			got, err := client.getAdAs(response.Data.ID, token.Token)
 			assert.NoError(t, err)
 
 			if got.Data.Title != test.ExpectName {
@@ -71,29 +71,29 @@ func TestGetAd(t *testing.T) {
 
 func BenchmarkCreateDeleteAd(b *testing.B) {
 	client := getTestClient()
-	user, _ := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	_, _ = client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
 
 	token, _ := client.loginUser("skyberg11", "abacaba")
 
 	for i := 0; i < b.N; i++ {
-		response, _ := client.createAd(user.Data.ID, "hello", "world", token.Token)
-		_, _ = client.deleteAd(user.Data.ID, response.Data.ID, token.Token)
+		response, _ := client.createAd("hello", "world", token.Token)
+		_, _ = client.deleteAd(response.Data.ID, token.Token)
 	}
 }
 
 func BenchmarkChangeAdStatus(b *testing.B) {
 	client := getTestClient()
-	user, _ := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	_, _ = client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
 
 	token, _ := client.loginUser("skyberg11", "abacaba")
 
-	response, _ := client.createAd(user.Data.ID, "hello", "world", token.Token)
+	response, _ := client.createAd("hello", "world", token.Token)
 
 	for i := 0; i < b.N; i++ {
-		_, _ = client.changeAdStatus(user.Data.ID, response.Data.ID, i%2 == 0, token.Token)
+		_, _ = client.changeAdStatus(response.Data.ID, i%2 == 0, token.Token)
 	}
 
-	_, _ = client.deleteAd(response.Data.ID, user.Data.ID, token.Token)
+	_, _ = client.deleteAd(response.Data.ID, token.Token)
 }
 
 func TestTimeTest(t *testing.T) {
@@ -101,17 +101,17 @@ func TestTimeTest(t *testing.T) {
 
 	start := time.Now().UTC()
 
-	user, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	_, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
 	assert.NoError(t, err)
 
 	token, err := client.loginUser("skyberg11", "abacaba")
 	assert.NoError(t, err)
 
-	response, err := client.createAd(user.Data.ID, "hello", "world", token.Token)
+	response, err := client.createAd("hello", "world", token.Token)
 	assert.NoError(t, err)
 	assert.Less(t, start, response.Data.CreationTime)
 
-	response, err = client.updateAd(user.Data.ID, response.Data.ID, "привет", "мир", token.Token)
+	response, err = client.updateAd(response.Data.ID, "привет", "мир", token.Token)
 	assert.NoError(t, err)
 
 	end := time.Now().UTC()
@@ -129,21 +129,21 @@ func TestChangeAdStatus(t *testing.T) {
 	token, err := client.loginUser("skyberg11", "abacaba")
 	assert.NoError(t, err)
 
-	response, err := client.createAd(user.Data.ID, "hello", "world", token.Token)
+	response, err := client.createAd("hello", "world", token.Token)
 	assert.NoError(t, err)
 	assert.Equal(t, response.Data.AuthorID, int64(user.Data.ID))
 
-	response, err = client.changeAdStatus(user.Data.ID, response.Data.ID, true, token.Token)
+	response, err = client.changeAdStatus(response.Data.ID, true, token.Token)
 	assert.NoError(t, err)
 	assert.True(t, response.Data.Published)
 	assert.Equal(t, response.Data.AuthorID, int64(user.Data.ID))
 
-	response, err = client.changeAdStatus(user.Data.ID, response.Data.ID, false, token.Token)
+	response, err = client.changeAdStatus(response.Data.ID, false, token.Token)
 	assert.NoError(t, err)
 	assert.False(t, response.Data.Published)
 	assert.Equal(t, response.Data.AuthorID, int64(user.Data.ID))
 
-	response, err = client.changeAdStatus(user.Data.ID, response.Data.ID, false, token.Token)
+	response, err = client.changeAdStatus(response.Data.ID, false, token.Token)
 	assert.NoError(t, err)
 	assert.False(t, response.Data.Published)
 	assert.Equal(t, response.Data.AuthorID, int64(user.Data.ID))
@@ -158,10 +158,10 @@ func TestUpdateAd(t *testing.T) {
 	token, err := client.loginUser("skyberg11", "abacaba")
 	assert.NoError(t, err)
 
-	response, err := client.createAd(user.Data.ID, "hello", "world", token.Token)
+	response, err := client.createAd("hello", "world", token.Token)
 	assert.NoError(t, err)
 
-	response, err = client.updateAd(user.Data.ID, response.Data.ID, "привет", "мир", token.Token)
+	response, err = client.updateAd(response.Data.ID, "привет", "мир", token.Token)
 	assert.NoError(t, err)
 	assert.Equal(t, response.Data.Title, "привет")
 	assert.Equal(t, response.Data.Text, "мир")
@@ -171,18 +171,18 @@ func TestUpdateAd(t *testing.T) {
 func TestListAds(t *testing.T) {
 	client := getTestClient()
 
-	user, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	_, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
 	assert.NoError(t, err)
 
 	token, err := client.loginUser("skyberg11", "abacaba")
 	assert.NoError(t, err)
 
-	response, err := client.createAd(user.Data.ID, "hello", "world", token.Token)
+	response, err := client.createAd("hello", "world", token.Token)
 	assert.NoError(t, err)
-	publishedAd, err := client.changeAdStatus(user.Data.ID, response.Data.ID, true, token.Token)
+	publishedAd, err := client.changeAdStatus(response.Data.ID, true, token.Token)
 	assert.NoError(t, err)
 
-	_, err = client.createAd(user.Data.ID, "best cat", "not for sale", token.Token)
+	_, err = client.createAd("best cat", "not for sale", token.Token)
 	assert.NoError(t, err)
 
 	ads, err := client.listAds(1, nil, nil, nil)
@@ -0,0 +1,149 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/golang/protobuf/ptypes/empty"
+	"github.com/stretchr/testify/assert"
+
+	"adflow/internal/ads"
+	service "adflow/internal/ports/grpc/service"
+)
+
+func findCategory(nodes []categoryNodeData, id int64) *categoryNodeData {
+	for i := range nodes {
+		if nodes[i].ID == id {
+			return &nodes[i]
+		}
+		if node := findCategory(nodes[i].Children, id); node != nil {
+			return node
+		}
+	}
+	return nil
+}
+
+func TestCategories(t *testing.T) {
+	client, users := getTestClientWithUsers()
+
+	_, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	assert.NoError(t, err)
+	admin, err := client.createUser("Andrew", "Ivanov", "admin", "12345678", "arr@mail.ru", "+79821233123")
+	assert.NoError(t, err)
+	assert.NoError(t, users.UpdateRole(admin.Data.ID, ads.RoleAdmin))
+
+	author, err := client.loginUser("skyberg11", "abacaba")
+	assert.NoError(t, err)
+	root, err := client.loginUser("admin", "12345678")
+	assert.NoError(t, err)
+
+	// Управляют деревом только администраторы
+	_, err = client.createCategory("Электроника", 0, author.Token)
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	electronics, err := client.createCategory("Электроника", 0, root.Token)
+	assert.NoError(t, err)
+	phones, err := client.createCategory("Телефоны", electronics.Data.ID, root.Token)
+	assert.NoError(t, err)
+	laptops, err := client.createCategory("Ноутбуки", electronics.Data.ID, root.Token)
+	assert.NoError(t, err)
+
+	// Объявление без категории попадает в «Другое», в неконечную категорию его поместить нельзя
+	other, err := client.createAd("Разное", "без категории", author.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, ads.DefaultCategoryID, other.Data.CategoryID)
+
+	_, err = client.createAdInCategory("Ноутбук", "почти новый", electronics.Data.ID, author.Token)
+	assert.ErrorIs(t, err, ErrBadRequest)
+	_, err = client.createAdInCategory("Ноутбук", "почти новый", 1000, author.Token)
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	phone, err := client.createAdInCategory("Телефон", "почти новый", phones.Data.ID, author.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, phones.Data.ID, phone.Data.CategoryID)
+	laptop, err := client.createAdInCategory("Ноутбук", "почти новый", laptops.Data.ID, author.Token)
+	assert.NoError(t, err)
+	_, err = client.changeAdStatus(phone.Data.ID, true, author.Token)
+	assert.NoError(t, err)
+	_, err = client.changeAdStatus(laptop.Data.ID, true, author.Token)
+	assert.NoError(t, err)
+	_, err = client.changeAdStatus(other.Data.ID, true, author.Token)
+	assert.NoError(t, err)
+
+	// Фильтр по категории включает подкатегории
+	list, err := client.listAds(nil, nil, nil, nil)
+	assert.NoError(t, err)
+	assert.Len(t, list.Data, 3)
+
+	filtered, err := client.filterAds(url.Values{"category": {fmt.Sprint(electronics.Data.ID)}}, "")
+	assert.NoError(t, err)
+	assert.Len(t, filtered.Data, 2)
+
+	filtered, err = client.filterAds(url.Values{"category": {fmt.Sprint(phones.Data.ID)}}, "")
+	assert.NoError(t, err)
+	assert.Len(t, filtered.Data, 1)
+	assert.Equal(t, phone.Data.ID, filtered.Data[0].ID)
+
+	tree, err := client.listCategories()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), findCategory(tree.Data, electronics.Data.ID).AdCount)
+	assert.Equal(t, int64(1), findCategory(tree.Data, phones.Data.ID).AdCount)
+	assert.Equal(t, int64(1), findCategory(tree.Data, ads.DefaultCategoryID).AdCount)
+	assert.Len(t, findCategory(tree.Data, electronics.Data.ID).Children, 2)
+
+	// Черновики не считаются
+	_, err = client.changeAdStatus(laptop.Data.ID, false, author.Token)
+	assert.NoError(t, err)
+	tree, err = client.listCategories()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), findCategory(tree.Data, electronics.Data.ID).AdCount)
+
+	// Перенос объявления в другую категорию
+	var moved adResponse
+	body := map[string]any{"title": "Ноутбук", "text": "почти новый", "category_id": phones.Data.ID}
+	err = client.do(http.MethodPut, fmt.Sprintf("/ads/%d", laptop.Data.ID), body, author.Token, &moved)
+	assert.NoError(t, err)
+	assert.Equal(t, phones.Data.ID, moved.Data.CategoryID)
+
+	// Подкатегорию нельзя создать там, где уже есть объявления, и внутри «Другого»
+	_, err = client.createCategory("Смартфоны", phones.Data.ID, root.Token)
+	assert.ErrorIs(t, err, ErrBadRequest)
+	_, err = client.createCategory("Прочее", ads.DefaultCategoryID, root.Token)
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	// Категорию нельзя перенести в её поддерево
+	_, err = client.updateCategory(electronics.Data.ID, "Электроника", laptops.Data.ID, root.Token)
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	renamed, err := client.updateCategory(laptops.Data.ID, "Ноутбуки и планшеты", 0, root.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, "Ноутбуки и планшеты", renamed.Data.Name)
+	assert.Equal(t, int64(0), renamed.Data.ParentID)
+
+	// Удалить можно только пустую категорию без подкатегорий
+	err = client.deleteCategory(phones.Data.ID, root.Token)
+	assert.ErrorIs(t, err, ErrBadRequest)
+	err = client.deleteCategory(ads.DefaultCategoryID, root.Token)
+	assert.ErrorIs(t, err, ErrBadRequest)
+	err = client.deleteCategory(laptops.Data.ID, root.Token)
+	assert.NoError(t, err)
+
+	tree, err = client.listCategories()
+	assert.NoError(t, err)
+	assert.Nil(t, findCategory(tree.Data, laptops.Data.ID))
+}
+
+func TestGRPCCategories(t *testing.T) {
+	client, ctx := getGRPCTestClient(t)
+
+	_, err := client.CreateCategory(ctx, &service.CreateCategoryRequest{Name: "Электроника"})
+	assert.ErrorIs(t, fromStatus(err), ErrUnauthorized)
+
+	tree, err := client.ListCategories(ctx, &empty.Empty{})
+	assert.NoError(t, err)
+	assert.Len(t, tree.Roots, 1)
+	assert.Equal(t, ads.DefaultCategoryID, tree.Roots[0].Category.Id)
+	assert.Equal(t, ads.DefaultCategoryName, tree.Roots[0].Category.Name)
+}
@@ -0,0 +1,175 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func conversationAdIDs(list []conversationData) []int64 {
+	ids := make([]int64, 0, len(list))
+	for _, conversation := range list {
+		ids = append(ids, conversation.AdID)
+	}
+	return ids
+}
+
+func TestParityConversations(t *testing.T) {
+	forEachTransport(t, func(t *testing.T, tr transport) {
+		seller, err := tr.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+		assert.NoError(t, err)
+		sellerToken, err := tr.loginUser("skyberg11", "abacaba")
+		assert.NoError(t, err)
+		buyer, err := tr.createUser("Other", "User", "buyer", "abacaba", "buyer@phystech.edu", "891428821XX")
+		assert.NoError(t, err)
+		buyerToken, err := tr.loginUser("buyer", "abacaba")
+		assert.NoError(t, err)
+		other, err := tr.createUser("Third", "User", "other", "abacaba", "other@phystech.edu", "891428821XX")
+		assert.NoError(t, err)
+		otherToken, err := tr.loginUser("other", "abacaba")
+		assert.NoError(t, err)
+		_, err = tr.createUser("Fourth", "User", "stranger", "abacaba", "stranger@phystech.edu", "891428821XX")
+		assert.NoError(t, err)
+		strangerToken, err := tr.loginUser("stranger", "abacaba")
+		assert.NoError(t, err)
+
+		var ids []int64
+		for _, title := range []string{"Велосипед", "Самокат"} {
+			ad, err := tr.createAd(title, "Почти новый", sellerToken)
+			assert.NoError(t, err)
+			_, err = tr.changeAdStatus(ad.ID, true, sellerToken)
+			assert.NoError(t, err)
+			ids = append(ids, ad.ID)
+		}
+		bike, scooter := ids[0], ids[1]
+		draft, err := tr.createAd("Черновик", "Не опубликован", sellerToken)
+		assert.NoError(t, err)
+
+		_, err = tr.contactAuthor(draft.ID, "Продаёте?", buyerToken)
+		assert.ErrorIs(t, err, ErrBadRequest)
+		_, err = tr.contactAuthor(bike, "Сам себе", sellerToken)
+		assert.ErrorIs(t, err, ErrBadRequest)
+		_, err = tr.contactAuthor(bike, "   ", buyerToken)
+		assert.ErrorIs(t, err, ErrBadRequest)
+		_, err = tr.contactAuthor(bike, "Продаёте?", "")
+		assert.ErrorIs(t, err, ErrUnauthorized)
+
+		// У покупателя одна переписка по объявлению
+		first, err := tr.contactAuthor(bike, "Здравствуйте, ещё продаёте?", buyerToken)
+		assert.NoError(t, err)
+		assert.Equal(t, buyer.ID, first.SenderID)
+		assert.Nil(t, first.ReadTime)
+		second, err := tr.contactAuthor(bike, "Торг уместен?", buyerToken)
+		assert.NoError(t, err)
+		assert.Equal(t, first.ConversationID, second.ConversationID)
+		conversationID := first.ConversationID
+
+		_, err = tr.contactAuthor(bike, "Добрый день", otherToken)
+		assert.NoError(t, err)
+		_, err = tr.contactAuthor(scooter, "А самокат?", otherToken)
+		assert.NoError(t, err)
+
+		// Переписки автора упорядочены по последнему сообщению
+		list, err := tr.listConversations(seller.ID, 0, 0, 0, sellerToken)
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{scooter, bike, bike}, conversationAdIDs(list))
+		assert.Equal(t, conversationID, list[2].ID)
+		assert.Equal(t, int64(2), list[2].Unread)
+		assert.Equal(t, "Торг уместен?", list[2].LastMessage.Text)
+		assert.Equal(t, seller.ID, list[2].AuthorID)
+		assert.Equal(t, buyer.ID, list[2].BuyerID)
+
+		page, err := tr.listConversations(seller.ID, bike, list[0].LastMessage.ID, 1, sellerToken)
+		assert.NoError(t, err)
+		assert.Len(t, page, 1)
+		assert.Equal(t, list[1].ID, page[0].ID)
+
+		// Входящие автора сгруппированы по объявлениям
+		inbox, err := tr.listInbox(seller.ID, 0, 0, sellerToken)
+		assert.NoError(t, err)
+		assert.Len(t, inbox, 2)
+		assert.Equal(t, scooter, inbox[0].AdID)
+		assert.Equal(t, int64(1), inbox[0].Conversations)
+		assert.Equal(t, int64(1), inbox[0].Unread)
+		assert.Equal(t, bike, inbox[1].AdID)
+		assert.Equal(t, int64(2), inbox[1].Conversations)
+		assert.Equal(t, int64(3), inbox[1].Unread)
+		assert.Equal(t, "Велосипед", inbox[1].Ad.Title)
+
+		inbox, err = tr.listInbox(seller.ID, inbox[0].LastMessageID, 1, sellerToken)
+		assert.NoError(t, err)
+		assert.Len(t, inbox, 1)
+		assert.Equal(t, bike, inbox[0].AdID)
+
+		// Чужие переписки закрыты
+		_, err = tr.listMessages(conversationID, 0, 0, strangerToken)
+		assert.ErrorIs(t, err, ErrForbidden)
+		_, err = tr.sendMessage(conversationID, "Привет", strangerToken)
+		assert.ErrorIs(t, err, ErrForbidden)
+		_, err = tr.markRead(conversationID, 0, strangerToken)
+		assert.ErrorIs(t, err, ErrForbidden)
+		_, err = tr.listConversations(seller.ID, 0, 0, 0, strangerToken)
+		assert.ErrorIs(t, err, ErrForbidden)
+		_, err = tr.listInbox(seller.ID, 0, 0, "")
+		assert.ErrorIs(t, err, ErrUnauthorized)
+
+		reply, err := tr.sendMessage(conversationID, "Да, продаю", sellerToken)
+		assert.NoError(t, err)
+		assert.Equal(t, seller.ID, reply.SenderID)
+
+		mine, err := tr.listConversations(buyer.ID, 0, 0, 0, buyerToken)
+		assert.NoError(t, err)
+		assert.Len(t, mine, 1)
+		assert.Equal(t, int64(1), mine[0].Unread)
+		assert.Equal(t, "Да, продаю", mine[0].LastMessage.Text)
+		assert.Equal(t, "Велосипед", mine[0].Ad.Title)
+
+		// Прочитанными отмечаются сообщения собеседника до указанного включительно
+		read, err := tr.markRead(conversationID, first.ID, sellerToken)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), read.Unread)
+		read, err = tr.markRead(conversationID, 0, sellerToken)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), read.Unread)
+
+		messages, err := tr.listMessages(conversationID, 0, 0, buyerToken)
+		assert.NoError(t, err)
+		assert.Len(t, messages, 3)
+		assert.Equal(t, reply.ID, messages[0].ID)
+		assert.Nil(t, messages[0].ReadTime)
+		assert.NotNil(t, messages[1].ReadTime)
+		assert.NotNil(t, messages[2].ReadTime)
+
+		messages, err = tr.listMessages(conversationID, messages[0].ID, 1, buyerToken)
+		assert.NoError(t, err)
+		assert.Len(t, messages, 1)
+		assert.Equal(t, second.ID, messages[0].ID)
+
+		inbox, err = tr.listInbox(seller.ID, 0, 0, sellerToken)
+		assert.NoError(t, err)
+		assert.Equal(t, bike, inbox[0].AdID)
+		assert.Equal(t, int64(1), inbox[0].Unread)
+
+		// Снятое с публикации объявление покупатель больше не видит, автор - видит
+		_, err = tr.changeAdStatus(bike, false, sellerToken)
+		assert.NoError(t, err)
+		mine, err = tr.listConversations(buyer.ID, 0, 0, 0, buyerToken)
+		assert.NoError(t, err)
+		assert.Nil(t, mine[0].Ad)
+		list, err = tr.listConversations(seller.ID, bike, 0, 0, sellerToken)
+		assert.NoError(t, err)
+		assert.NotNil(t, list[0].Ad)
+
+		// Переписки удаляются вместе с объявлением и с пользователем
+		assert.NoError(t, tr.deleteAd(scooter, sellerToken))
+		list, err = tr.listConversations(seller.ID, 0, 0, 0, sellerToken)
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{bike, bike}, conversationAdIDs(list))
+
+		assert.NoError(t, tr.deleteUser(other.ID, otherToken))
+		list, err = tr.listConversations(seller.ID, 0, 0, 0, sellerToken)
+		assert.NoError(t, err)
+		assert.Len(t, list, 1)
+		assert.Equal(t, conversationID, list[0].ID)
+	})
+}
@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func favoriteAdIDs(list []favoriteData) []int64 {
+	ids := make([]int64, 0, len(list))
+	for _, favorite := range list {
+		ids = append(ids, favorite.AdID)
+	}
+	return ids
+}
+
+func TestParityFavorites(t *testing.T) {
+	forEachTransport(t, func(t *testing.T, tr transport) {
+		_, err := tr.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+		assert.NoError(t, err)
+		seller, err := tr.loginUser("skyberg11", "abacaba")
+		assert.NoError(t, err)
+		user, err := tr.createUser("Other", "User", "buyer", "abacaba", "buyer@phystech.edu", "891428821XX")
+		assert.NoError(t, err)
+		buyer, err := tr.loginUser("buyer", "abacaba")
+		assert.NoError(t, err)
+
+		var ids []int64
+		for _, title := range []string{"Велосипед", "Самокат", "Ролики"} {
+			ad, err := tr.createAd(title, "Почти новый", seller)
+			assert.NoError(t, err)
+			_, err = tr.changeAdStatus(ad.ID, true, seller)
+			assert.NoError(t, err)
+			ids = append(ids, ad.ID)
+		}
+		draft, err := tr.createAd("Черновик", "Не опубликован", seller)
+		assert.NoError(t, err)
+
+		var favorites []favoriteData
+		for _, id := range ids {
+			favorite, err := tr.addFavorite(user.ID, id, buyer)
+			assert.NoError(t, err)
+			assert.Equal(t, id, favorite.AdID)
+			assert.Equal(t, int64(1), favorite.Ad.FavoritesCount)
+			favorites = append(favorites, favorite)
+		}
+
+		// Повторное добавление ничего не меняет
+		again, err := tr.addFavorite(user.ID, ids[0], buyer)
+		assert.NoError(t, err)
+		assert.Equal(t, favorites[0].ID, again.ID)
+
+		_, err = tr.addFavorite(user.ID, draft.ID, buyer)
+		assert.ErrorIs(t, err, ErrBadRequest)
+
+		// Избранное видит и меняет только владелец
+		_, err = tr.addFavorite(user.ID, ids[0], seller)
+		assert.ErrorIs(t, err, ErrForbidden)
+		_, err = tr.listFavorites(user.ID, 0, 0, seller)
+		assert.ErrorIs(t, err, ErrForbidden)
+		_, err = tr.listFavorites(user.ID, 0, 0, "")
+		assert.ErrorIs(t, err, ErrUnauthorized)
+
+		ad, err := tr.getAd(ids[1], "")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), ad.FavoritesCount)
+
+		// Новые первыми, за следующей страницей передают ID последней записи
+		page, err := tr.listFavorites(user.ID, 0, 2, buyer)
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{ids[2], ids[1]}, favoriteAdIDs(page))
+		page, err = tr.listFavorites(user.ID, page[1].ID, 2, buyer)
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{ids[0]}, favoriteAdIDs(page))
+
+		// Снятое с публикации скрыто и не занимает место на странице
+		_, err = tr.changeAdStatus(ids[1], false, seller)
+		assert.NoError(t, err)
+		page, err = tr.listFavorites(user.ID, 0, 2, buyer)
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{ids[2], ids[0]}, favoriteAdIDs(page))
+
+		_, err = tr.changeAdStatus(ids[1], true, seller)
+		assert.NoError(t, err)
+		page, err = tr.listFavorites(user.ID, 0, 0, buyer)
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{ids[2], ids[1], ids[0]}, favoriteAdIDs(page))
+
+		// Удалённое объявление пропадает из избранного
+		assert.NoError(t, tr.deleteAd(ids[2], seller))
+		assert.NoError(t, tr.removeFavorite(user.ID, ids[0], buyer))
+		assert.NoError(t, tr.removeFavorite(user.ID, ids[0], buyer))
+		page, err = tr.listFavorites(user.ID, 0, 0, buyer)
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{ids[1]}, favoriteAdIDs(page))
+
+		ad, err = tr.getAd(ids[0], "")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), ad.FavoritesCount)
+
+		// Вместе с пользователем удаляется и его избранное
+		assert.NoError(t, tr.deleteUser(user.ID, buyer))
+		ad, err = tr.getAd(ids[1], "")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), ad.FavoritesCount)
+	})
+}
@@ -2,9 +2,18 @@ package tests
 
 import (
 	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 
+	"adflow/internal/adapters"
+	"adflow/internal/adapters/adrepo"
+	"adflow/internal/ads"
+
+	"fmt"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestListPublishedAuthor(t *testing.T) {
@@ -16,22 +25,22 @@ func TestListPublishedAuthor(t *testing.T) {
 	token1, err := client.loginUser("skyberg11", "abacaba")
 	assert.NoError(t, err)
 
-	another, err := client.createUser("Andrew", "Ivanov", "abacaba", "12345678", "arr@mail.ru", "+79821233123")
+	_, err = client.createUser("Andrew", "Ivanov", "abacaba", "12345678", "arr@mail.ru", "+79821233123")
 	assert.NoError(t, err)
 
 	token2, err := client.loginUser("abacaba", "12345678")
 	assert.NoError(t, err)
 
-	response, err := client.createAd(user.Data.ID, "hello", "world", token1.Token)
+	response, err := client.createAd("hello", "world", token1.Token)
 	assert.NoError(t, err)
 
-	publishedAd1, err := client.changeAdStatus(user.Data.ID, response.Data.ID, true, token1.Token)
+	publishedAd1, err := client.changeAdStatus(response.Data.ID, true, token1.Token)
 	assert.NoError(t, err)
 
-	response, err = client.createAd(another.Data.ID, "best cat", "not for sale", token2.Token)
+	response, err = client.createAd("best cat", "not for sale", token2.Token)
 	assert.NoError(t, err)
 
-	_, err = client.changeAdStatus(another.Data.ID, response.Data.ID, true, token2.Token)
+	_, err = client.changeAdStatus(response.Data.ID, true, token2.Token)
 	assert.NoError(t, err)
 
 	ads, err := client.listAds(1, user.Data.ID, nil, nil)
@@ -48,19 +57,24 @@ func TestListPublishedAuthor(t *testing.T) {
 func TestListTitlePrefix(t *testing.T) {
 	client := getTestClient()
 
-	user, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	_, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
 	assert.NoError(t, err)
 
 	token1, err := client.loginUser("skyberg11", "abacaba")
 	assert.NoError(t, err)
 
-	response, err := client.createAd(user.Data.ID, "hello", "world", token1.Token)
+	response, err := client.createAd("hello", "world", token1.Token)
 	assert.NoError(t, err)
 
-	_, err = client.changeAdStatus(user.Data.ID, response.Data.ID, true, token1.Token)
+	_, err = client.changeAdStatus(response.Data.ID, true, token1.Token)
 	assert.NoError(t, err)
 
-	publishedAd, err := client.createAd(user.Data.ID, "best cat", "not for sale", token1.Token)
+	publishedAd, err := client.createAd("best cat", "not for sale", token1.Token)
+	assert.NoError(t, err)
+	_, err = client.changeAdStatus(publishedAd.Data.ID, true, token1.Token)
+	assert.NoError(t, err)
+	// Черновик с тем же префиксом виден только автору
+	_, err = client.createAd("best dog", "not for sale", token1.Token)
 	assert.NoError(t, err)
 
 	ads, err := client.listAds(nil, nil, "best", nil)
@@ -91,13 +105,13 @@ func FuzzGenFilter(f *testing.F) {
 
 	f.Fuzz(func(t *testing.T, published int, AuthorID int64, TitlePrefix string) {
 
-		response, err := client.createAd(user.Data.ID, "hello", "world", token1.Token)
+		response, err := client.createAd("hello", "world", token1.Token)
 		assert.NoError(t, err)
 
-		_, err = client.changeAdStatus(user.Data.ID, response.Data.ID, true, token1.Token)
+		_, err = client.changeAdStatus(response.Data.ID, true, token1.Token)
 		assert.NoError(t, err)
 
-		_, err = client.createAd(user.Data.ID, "best cat", "not for sale", token1.Token)
+		_, err = client.createAd("best cat", "not for sale", token1.Token)
 		assert.NoError(t, err)
 
 		ads, err := client.listAds(published, AuthorID, TitlePrefix, nil)
@@ -120,3 +134,216 @@ func FuzzGenFilter(f *testing.F) {
 		}
 	})
 }
+
+func TestRepositoryFilter(t *testing.T) {
+	repo, _ := newTestAds()
+
+	first := &ads.Ad{Title: "Hello", Text: "world", AuthorID: 1}
+	second := &ads.Ad{Title: "hello", Text: "world", AuthorID: 2}
+	assert.NoError(t, repo.Create(first))
+	assert.NoError(t, repo.Create(second))
+
+	_, err := repo.UpdateStatus(second.ID, ads.StatusDraft, ads.StatusPublished, nil)
+	assert.NoError(t, err)
+
+	// Префикс сравнивается с учётом регистра
+	list, _, err := repo.GetAds(ads.Filter{TitlePrefix: "he"}, ads.Page{})
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+	assert.Equal(t, second.ID, list[0].ID)
+
+	published, unpublished := true, false
+	list, _, err = repo.GetAds(ads.Filter{Published: &published, AuthorIDs: []int64{2}}, ads.Page{})
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+
+	list, _, err = repo.GetAds(ads.Filter{Published: &unpublished, AuthorIDs: []int64{2}}, ads.Page{})
+	assert.NoError(t, err)
+	assert.Len(t, list, 0)
+
+	// Граница по времени в другом часовом поясе
+	moscow := time.FixedZone("MSK", 3*60*60)
+	after := first.CreationTime.Add(-time.Minute).In(moscow)
+	list, _, err = repo.GetAds(ads.Filter{Created: ads.TimeRange{After: &after}}, ads.Page{})
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+
+	after = time.Now().Add(time.Minute).In(moscow)
+	list, _, err = repo.GetAds(ads.Filter{Created: ads.TimeRange{After: &after}}, ads.Page{})
+	assert.NoError(t, err)
+	assert.Len(t, list, 0)
+}
+
+func TestRepositoryFilterPredicates(t *testing.T) {
+	repo, _ := newTestAds()
+
+	first := &ads.Ad{Title: "Продам кота", Text: "Рыжий КОТ, 3 года", AuthorID: 1}
+	assert.NoError(t, repo.Create(first))
+	second := &ads.Ad{Title: "Куплю велосипед", Text: "Горный велосипед, недорого", AuthorID: 2}
+	assert.NoError(t, repo.Create(second))
+	third := &ads.Ad{Title: "Отдам кота", Text: "Кот-котёнок в добрые руки", AuthorID: 3}
+	assert.NoError(t, repo.Create(third))
+
+	ids := func(list []*ads.Ad) []int64 {
+		var res []int64
+		for _, ad := range list {
+			res = append(res, ad.ID)
+		}
+		return res
+	}
+
+	list, _, err := repo.GetAds(ads.Filter{AuthorIDs: []int64{1, 3}}, ads.Page{})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []int64{first.ID, third.ID}, ids(list))
+
+	list, _, err = repo.GetAds(ads.Filter{ExcludeAuthorIDs: []int64{1, 3}}, ads.Page{})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []int64{second.ID}, ids(list))
+
+	list, _, err = repo.GetAds(ads.Filter{AuthorIDs: []int64{1, 2}, ExcludeAuthorIDs: []int64{2}}, ads.Page{})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []int64{first.ID}, ids(list))
+
+	// Поиск подстроки в заголовке учитывает регистр
+	list, _, err = repo.GetAds(ads.Filter{TitleContains: "кота"}, ads.Page{})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []int64{first.ID, third.ID}, ids(list))
+
+	list, _, err = repo.GetAds(ads.Filter{TitleContains: "Кота"}, ads.Page{})
+	assert.NoError(t, err)
+	assert.Len(t, list, 0)
+
+	// Полнотекстовый поиск ищет слова целиком и без учёта регистра
+	list, _, err = repo.GetAds(ads.Filter{Text: "кот"}, ads.Page{})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []int64{first.ID, third.ID}, ids(list))
+
+	list, _, err = repo.GetAds(ads.Filter{Text: "ГОРНЫЙ велосипед"}, ads.Page{})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []int64{second.ID}, ids(list))
+
+	list, _, err = repo.GetAds(ads.Filter{Text: "велосипед кот"}, ads.Page{})
+	assert.NoError(t, err)
+	assert.Len(t, list, 0)
+
+	list, _, err = repo.GetAds(ads.Filter{Text: "вело"}, ads.Page{})
+	assert.NoError(t, err)
+	assert.Len(t, list, 0)
+
+	// Интервал [After, Before)
+	after, before := second.CreationTime, third.CreationTime
+	list, _, err = repo.GetAds(ads.Filter{Created: ads.TimeRange{After: &after, Before: &before}}, ads.Page{})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []int64{second.ID}, ids(list))
+
+	updated := time.Now().Add(time.Minute)
+	list, _, err = repo.GetAds(ads.Filter{Updated: ads.TimeRange{Before: &updated}}, ads.Page{})
+	assert.NoError(t, err)
+	assert.Len(t, list, 3)
+}
+
+func TestParityFilter(t *testing.T) {
+	forEachTransport(t, func(t *testing.T, tr transport) {
+		first, err := tr.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+		assert.NoError(t, err)
+		token1, err := tr.loginUser("skyberg11", "abacaba")
+		assert.NoError(t, err)
+
+		second, err := tr.createUser("Andrew", "Ivanov", "abacaba", "12345678", "arr@mail.ru", "+79821233123")
+		assert.NoError(t, err)
+		token2, err := tr.loginUser("abacaba", "12345678")
+		assert.NoError(t, err)
+
+		cat, err := tr.createAd("Продам кота", "Рыжий кот", token1)
+		assert.NoError(t, err)
+		bike, err := tr.createAd("Продам велосипед", "Горный велосипед", token2)
+		assert.NoError(t, err)
+		_, err = tr.changeAdStatus(cat.ID, true, token1)
+		assert.NoError(t, err)
+		_, err = tr.changeAdStatus(bike.ID, true, token2)
+		assert.NoError(t, err)
+
+		list, err := tr.filterAds(adFilter{AuthorIDs: []int64{first.ID, second.ID}, ExcludeAuthorIDs: []int64{first.ID}}, "")
+		assert.NoError(t, err)
+		assert.Len(t, list, 1)
+		assert.Equal(t, bike.ID, list[0].ID)
+
+		list, err = tr.filterAds(adFilter{TitleContains: "кота"}, "")
+		assert.NoError(t, err)
+		assert.Len(t, list, 1)
+		assert.Equal(t, cat.ID, list[0].ID)
+
+		list, err = tr.filterAds(adFilter{Text: "ГОРНЫЙ"}, "")
+		assert.NoError(t, err)
+		assert.Len(t, list, 1)
+		assert.Equal(t, bike.ID, list[0].ID)
+
+		list, err = tr.filterAds(adFilter{CreatedBefore: time.Now().Add(-time.Hour).Format(time.RFC3339)}, "")
+		assert.NoError(t, err)
+		assert.Len(t, list, 0)
+
+		_, err = tr.filterAds(adFilter{CreatedAfter: "yesterday"}, "")
+		assert.ErrorIs(t, err, ErrBadRequest)
+	})
+}
+
+// Прежняя реализация sqliteRepository.GetAds: вся таблица в память,
+// фильтр в Go и отдельный запрос на каждое подошедшее объявление
+func legacyGetAds(db *gorm.DB, filter ads.Filter) []*ads.Ad {
+	var all []ads.Ad
+	db.Find(&all)
+
+	var ids []int64
+	for i := range all {
+		if filter.Match(&all[i]) {
+			ids = append(ids, all[i].ID)
+		}
+	}
+
+	list := make([]*ads.Ad, len(ids))
+	for i := range ids {
+		db.Where("ID = ?", ids[i]).Find(&list[i])
+	}
+	return list
+}
+
+func BenchmarkListAdsSQLite(b *testing.B) {
+	const total, authors = 20000, 100
+
+	// База открывается так же, как в приложении: с драйвером, где зарегистрированы функции поиска.
+	// Журнал запросов отключён, чтобы не мерить его вывод.
+	db, err := adapters.NewSQLite(filepath.Join(b.TempDir(), "bench.db"))
+	assert.NoError(b, err)
+	db.Logger = logger.Default.LogMode(logger.Silent)
+	repo := adrepo.NewSQLiteAds(db)
+
+	start := time.Now().UTC()
+	seed := make([]ads.Ad, total)
+	for i := range seed {
+		seed[i] = ads.Ad{
+			ID:           int64(i + 1),
+			Title:        fmt.Sprintf("ad %d", i),
+			Text:         "text",
+			AuthorID:     int64(i % authors),
+			Published:    i%2 == 0,
+			CreationTime: start.Add(time.Duration(i) * time.Second),
+			UpdateTime:   start.Add(time.Duration(i) * time.Second),
+		}
+	}
+	assert.NoError(b, db.CreateInBatches(seed, 500).Error)
+
+	published := true
+	filter := ads.Filter{Published: &published, AuthorIDs: []int64{42}}
+
+	b.Run("legacy", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			legacyGetAds(db, filter)
+		}
+	})
+
+	b.Run("sql", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, _, _ = repo.GetAds(filter, ads.Page{})
+		}
+	})
+}
@@ -0,0 +1,186 @@
+package tests
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"adflow/internal/ads"
+)
+
+var (
+	moscow      = ads.Point{Latitude: 55.7558, Longitude: 37.6173}
+	petersburg  = ads.Point{Latitude: 59.9343, Longitude: 30.3351}
+	tver        = ads.Point{Latitude: 56.8587, Longitude: 35.9176}
+	vladivostok = ads.Point{Latitude: 43.1155, Longitude: 131.8855}
+)
+
+func TestGeo(t *testing.T) {
+	assert.InDelta(t, 634, ads.Distance(moscow, petersburg), 2)
+	assert.Equal(t, ads.Distance(moscow, petersburg), ads.Distance(petersburg, moscow))
+	assert.Equal(t, 0.0, ads.Distance(tver, tver))
+
+	near := ads.Near{Center: moscow, RadiusKm: 200}
+	assert.True(t, near.Contains(tver))
+	assert.False(t, near.Contains(petersburg))
+	boxes := near.Boxes()
+	assert.Len(t, boxes, 1)
+	assert.True(t, boxes[0].MinLat < tver.Latitude && tver.Latitude < boxes[0].MaxLat)
+	assert.True(t, boxes[0].MinLon < tver.Longitude && tver.Longitude < boxes[0].MaxLon)
+
+	// Круг через линию перемены дат
+	chukotka := ads.Near{Center: ads.Point{Latitude: 65, Longitude: 179.9}, RadiusKm: 100}
+	boxes = chukotka.Boxes()
+	assert.Len(t, boxes, 2)
+	assert.Equal(t, 180.0, boxes[0].MaxLon)
+	assert.Equal(t, -180.0, boxes[1].MinLon)
+	assert.True(t, chukotka.Contains(ads.Point{Latitude: 65, Longitude: -179.9}))
+
+	// Круг вокруг полюса захватывает все долготы
+	pole := ads.Near{Center: ads.Point{Latitude: 89.5, Longitude: 0}, RadiusKm: 100}
+	assert.Equal(t, []ads.Box{{MinLat: pole.Center.Latitude - 100/ads.EarthRadiusKm*180/3.141592653589793, MaxLat: 90, MinLon: -180, MaxLon: 180}}, pole.Boxes())
+	assert.True(t, pole.Contains(ads.Point{Latitude: 89.9, Longitude: 180}))
+
+	assert.Nil(t, ads.Near{Center: moscow}.Boxes())
+
+	n, err := ads.ParseNear("55.7558, 37.6173", "10.5")
+	assert.NoError(t, err)
+	assert.Equal(t, &ads.Near{Center: moscow, RadiusKm: 10.5}, n)
+	n, err = ads.ParseNear("", "")
+	assert.NoError(t, err)
+	assert.Nil(t, n)
+	for _, v := range [][2]string{{"", "10"}, {"55.7", ""}, {"55.7,north", ""}, {"55.7,37.6", "far"}} {
+		_, err = ads.ParseNear(v[0], v[1])
+		assert.ErrorIs(t, err, ads.ErrBadRequest, v)
+	}
+}
+
+func TestRepositoryNear(t *testing.T) {
+	repo, _ := newTestAds()
+
+	points := []ads.Point{petersburg, tver, moscow, vladivostok, tver, {Latitude: 65, Longitude: -179.9}}
+	var created []*ads.Ad
+	for _, p := range points {
+		ad := &ads.Ad{Title: "ad", Text: "text", AuthorID: 1, Currency: ads.DefaultCurrency}
+		ads.Draft{Title: "ad", Text: "text", Location: &ads.Location{Point: p}}.Apply(ad)
+		assert.NoError(t, repo.Create(ad))
+		created = append(created, ad)
+	}
+	// Объявление без места в поиск рядом с точкой не попадает
+	assert.NoError(t, repo.Create(&ads.Ad{Title: "ad", Text: "text", AuthorID: 1, Currency: ads.DefaultCurrency}))
+
+	// Место можно перенести
+	_, err := repo.Update(created[0].ID, ads.Draft{Title: "ad", Text: "text", Location: &ads.Location{Point: moscow, City: "Москва"}}, &ads.Revision{AuthorID: 1})
+	assert.NoError(t, err)
+
+	ids := func(list []*ads.Ad) []int64 {
+		var res []int64
+		for _, ad := range list {
+			res = append(res, ad.ID)
+		}
+		return res
+	}
+
+	filter := ads.Filter{Near: &ads.Near{Center: moscow, RadiusKm: 700}}
+	for _, desc := range []bool{false, true} {
+		s := ads.Sort{Field: ads.SortDistance, Desc: desc, Origin: moscow}
+
+		all, next, err := repo.GetAds(filter, ads.Page{Sort: s})
+		assert.NoError(t, err)
+		assert.Nil(t, next)
+		assert.Len(t, all, 4)
+		assert.True(t, sort.SliceIsSorted(all, func(i, j int) bool { return s.Less(all[i], all[j]) }))
+
+		var paged []*ads.Ad
+		page := ads.Page{Sort: s, Limit: 1}
+		for {
+			list, next, err := repo.GetAds(filter, page)
+			assert.NoError(t, err)
+			paged = append(paged, list...)
+			if next == nil {
+				break
+			}
+			page.After, err = ads.DecodeCursor(next.Encode())
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, ids(all), ids(paged))
+	}
+
+	all, _, err := repo.GetAds(filter, ads.Page{Sort: ads.Sort{Field: ads.SortDistance, Origin: moscow}})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{created[0].ID, created[2].ID, created[1].ID, created[4].ID}, ids(all))
+	assert.Equal(t, "Москва", all[0].City)
+
+	list, _, err := repo.GetAds(ads.Filter{Near: &ads.Near{Center: ads.Point{Latitude: 65, Longitude: 179.9}, RadiusKm: 50}}, ads.Page{})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{created[5].ID}, ids(list))
+
+	// Без радиуса - все объявления с местом
+	list, _, err = repo.GetAds(ads.Filter{Near: &ads.Near{Center: moscow}}, ads.Page{})
+	assert.NoError(t, err)
+	assert.Len(t, list, len(points))
+
+	assert.NoError(t, repo.DeleteAd(created[2].ID))
+	list, _, err = repo.GetAds(filter, ads.Page{})
+	assert.NoError(t, err)
+	assert.Len(t, list, 3)
+}
+
+func TestParityGeo(t *testing.T) {
+	forEachTransport(t, func(t *testing.T, tr transport) {
+		_, err := tr.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+		assert.NoError(t, err)
+		token, err := tr.loginUser("skyberg11", "abacaba")
+		assert.NoError(t, err)
+
+		bike, err := tr.createPlacedAd("Велосипед", "Горный", &adLocation{Latitude: tver.Latitude, Longitude: tver.Longitude, City: "Тверь"}, token)
+		assert.NoError(t, err)
+		assert.Equal(t, &adLocation{Latitude: tver.Latitude, Longitude: tver.Longitude, City: "Тверь"}, bike.Location)
+
+		sofa, err := tr.createPlacedAd("Диван", "Почти новый", &adLocation{Latitude: moscow.Latitude, Longitude: moscow.Longitude}, token)
+		assert.NoError(t, err)
+		wardrobe, err := tr.createPlacedAd("Шкаф", "Дубовый", &adLocation{Latitude: petersburg.Latitude, Longitude: petersburg.Longitude}, token)
+		assert.NoError(t, err)
+
+		noPlace, err := tr.createPlacedAd("Котёнок", "Отдам", nil, token)
+		assert.NoError(t, err)
+		assert.Nil(t, noPlace.Location)
+
+		_, err = tr.createPlacedAd("Лыжи", "Беговые", &adLocation{Latitude: 91, Longitude: 0}, token)
+		assert.ErrorIs(t, err, ErrBadRequest)
+
+		// Без места в запросе место остаётся прежним
+		bike, err = tr.updatePlacedAd(bike.ID, "Велосипед", "Горный, торг", nil, token)
+		assert.NoError(t, err)
+		assert.Equal(t, "Тверь", bike.Location.City)
+
+		_, err = tr.updatePlacedAd(bike.ID, "Велосипед", "Горный", &adLocation{Latitude: 0, Longitude: 181}, token)
+		assert.ErrorIs(t, err, ErrBadRequest)
+
+		for _, ad := range []adData{bike, sofa, wardrobe} {
+			_, err = tr.changeAdStatus(ad.ID, true, token)
+			assert.NoError(t, err)
+		}
+
+		// По умолчанию рядом с точкой выдача упорядочена по расстоянию
+		list, err := tr.filterAds(adFilter{Near: "55.7558,37.6173", RadiusKm: "200"}, "")
+		assert.NoError(t, err)
+		assert.Len(t, list, 2)
+		assert.Equal(t, []int64{sofa.ID, bike.ID}, []int64{list[0].ID, list[1].ID})
+
+		list, err = tr.filterAds(adFilter{Near: "55.7558,37.6173", Sort: "distance", Desc: true}, "")
+		assert.NoError(t, err)
+		assert.Len(t, list, 3)
+		assert.Equal(t, bike.ID, list[1].ID)
+
+		_, err = tr.filterAds(adFilter{Sort: "distance"}, "")
+		assert.ErrorIs(t, err, ErrBadRequest)
+		_, err = tr.filterAds(adFilter{RadiusKm: "10"}, "")
+		assert.ErrorIs(t, err, ErrBadRequest)
+		_, err = tr.filterAds(adFilter{Near: "95,0"}, "")
+		assert.ErrorIs(t, err, ErrBadRequest)
+		_, err = tr.filterAds(adFilter{Near: "55,37", RadiusKm: "-1"}, "")
+		assert.ErrorIs(t, err, ErrBadRequest)
+	})
+}
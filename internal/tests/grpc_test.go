@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"testing"
+
+	service "adflow/internal/ports/grpc/service"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPCCreateAd(t *testing.T) {
+	client, ctx := getGRPCTestClient(t)
+	user, err := client.CreateUser(ctx, &service.CreateUserRequest{FirstName: "Oleg", SecondName: "Ivanov", Nickname: "oleg",
+		Password: "abacaba", Email: "abacaba@aba.ru", Phone: "+79821233123"})
+	assert.NoError(t, err)
+
+	login, err := client.Login(ctx, &service.LoginRequest{Nickname: "oleg", Password: "abacaba"})
+	assert.NoError(t, err)
+
+	res, err := client.CreateAd(withToken(ctx, login.Token), &service.CreateAdRequest{Title: "Oleg", Text: "abacaba@aba.ru"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Oleg", res.Title)
+	assert.Equal(t, user.Id, res.AuthorId)
+
+	got, err := client.GetAd(withToken(ctx, login.Token), &service.GetAdRequest{Id: res.Id})
+	assert.NoError(t, err)
+	assert.Equal(t, "Oleg", got.Title)
+}
+
+func TestGRPCStatusCodes(t *testing.T) {
+	client, ctx := getGRPCTestClient(t)
+	_, err := client.CreateUser(ctx, &service.CreateUserRequest{FirstName: "Oleg", SecondName: "Ivanov", Nickname: "oleg",
+		Password: "abacaba", Email: "abacaba@aba.ru", Phone: "+79821233123"})
+	assert.NoError(t, err)
+
+	another, err := client.CreateUser(ctx, &service.CreateUserRequest{FirstName: "Andrew", SecondName: "Ivanov", Nickname: "andrew",
+		Password: "12345678", Email: "arr@mail.ru", Phone: "+79821233123"})
+	assert.NoError(t, err)
+
+	_, err = client.Login(ctx, &service.LoginRequest{Nickname: "oleg", Password: "wrong"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = client.CreateAd(ctx, &service.CreateAdRequest{Title: "Oleg", Text: "abacaba@aba.ru"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = client.CreateAd(withToken(ctx, "42istheanswerforanyquestion"), &service.CreateAdRequest{Title: "Oleg", Text: "abacaba@aba.ru"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	login, err := client.Login(ctx, &service.LoginRequest{Nickname: "oleg", Password: "abacaba"})
+	assert.NoError(t, err)
+
+	_, err = client.CreateAd(withToken(ctx, login.Token), &service.CreateAdRequest{Title: "", Text: "abacaba@aba.ru"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.DeleteUser(withToken(ctx, login.Token), &service.DeleteUserRequest{Id: another.Id})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = client.GetAd(ctx, &service.GetAdRequest{Id: 100})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestGRPCDeleteAdOfAnotherUser(t *testing.T) {
+	client, ctx := getGRPCTestClient(t)
+	_, err := client.CreateUser(ctx, &service.CreateUserRequest{FirstName: "Oleg", SecondName: "Ivanov", Nickname: "oleg",
+		Password: "abacaba", Email: "abacaba@aba.ru", Phone: "+79821233123"})
+	assert.NoError(t, err)
+
+	_, err = client.CreateUser(ctx, &service.CreateUserRequest{FirstName: "Andrew", SecondName: "Ivanov", Nickname: "andrew",
+		Password: "12345678", Email: "arr@mail.ru", Phone: "+79821233123"})
+	assert.NoError(t, err)
+
+	login1, err := client.Login(ctx, &service.LoginRequest{Nickname: "oleg", Password: "abacaba"})
+	assert.NoError(t, err)
+
+	login2, err := client.Login(ctx, &service.LoginRequest{Nickname: "andrew", Password: "12345678"})
+	assert.NoError(t, err)
+
+	response, err := client.CreateAd(withToken(ctx, login1.Token), &service.CreateAdRequest{Title: "Oleg", Text: "abacaba@aba.ru"})
+	assert.NoError(t, err)
+
+	_, err = client.DeleteAd(withToken(ctx, login2.Token), &service.DeleteAdRequest{AdId: response.Id})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = client.DeleteAd(withToken(ctx, login1.Token), &service.DeleteAdRequest{AdId: response.Id})
+	assert.NoError(t, err)
+
+	_, err = client.GetAd(ctx, &service.GetAdRequest{Id: response.Id})
+	assert.Error(t, err)
+}
@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"adflow/internal/app"
+	grpcPort "adflow/internal/ports/grpc"
+	service "adflow/internal/ports/grpc/service"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func getGRPCTestClient(t *testing.T) (service.AdServiceClient, context.Context) {
+	return getGRPCTestClientFor(t, newTestApp())
+}
+
+// getGRPCTestClientFor поднимает сервер поверх заданного приложения
+func getGRPCTestClientFor(t *testing.T, a app.App) (service.AdServiceClient, context.Context) {
+	lis := bufconn.Listen(1024 * 1024)
+	t.Cleanup(func() {
+		lis.Close()
+	})
+
+	srv := grpcPort.NewGRPCServer(a)
+	t.Cleanup(func() {
+		srv.Stop()
+	})
+
+	go func() {
+		assert.NoError(t, srv.Serve(lis), "srv.Serve")
+	}()
+
+	dialer := func(context.Context, string) (net.Conn, error) {
+		return lis.Dial()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	t.Cleanup(func() {
+		cancel()
+	})
+
+	conn, err := grpc.DialContext(ctx, "", grpc.WithContextDialer(dialer), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err, "grpc.DialContext")
+
+	t.Cleanup(func() {
+		conn.Close()
+	})
+	return service.NewAdServiceClient(conn), ctx
+}
+
+func withToken(ctx context.Context, token string) context.Context {
+	if token == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
+
+// Приводит статусы gRPC к тем же ошибкам, что возвращает HTTP-клиент
+func fromStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	switch status.Code(err) {
+	case codes.InvalidArgument:
+		return ErrBadRequest
+	case codes.PermissionDenied:
+		return ErrForbidden
+	case codes.Unauthenticated:
+		return ErrUnauthorized
+	}
+	return err
+}
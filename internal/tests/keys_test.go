@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"adflow/internal/app/auth"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func writePEM(t *testing.T, name, blockType string, der []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600)
+	assert.NoError(t, err)
+	return path
+}
+
+func TestJWKS(t *testing.T) {
+	client := getTestClient()
+	_, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	assert.NoError(t, err)
+
+	login, err := client.loginUser("skyberg11", "abacaba")
+	assert.NoError(t, err)
+
+	resp, err := http.Get(client.baseURL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var jwks auth.JWKS
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&jwks))
+	assert.Len(t, jwks.Keys, 1)
+	assert.Equal(t, "OKP", jwks.Keys[0].Kty)
+	assert.Equal(t, "EdDSA", jwks.Keys[0].Alg)
+
+	token, _, err := jwt.NewParser().ParseUnverified(login.Token, &auth.Claims{})
+	assert.NoError(t, err)
+	assert.Equal(t, jwks.Keys[0].Kid, token.Header["kid"])
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldKey, err := auth.GenerateKey()
+	assert.NoError(t, err)
+	newKey, err := auth.GenerateKey()
+	assert.NoError(t, err)
+
+	before, err := auth.NewKeySet(oldKey)
+	assert.NoError(t, err)
+	token, err := before.GenerateJWT(1, "user")
+	assert.NoError(t, err)
+
+	// Сразу после ротации старые токены ещё принимаются
+	rotated, err := auth.NewKeySet(newKey, oldKey)
+	assert.NoError(t, err)
+	claims, err := rotated.ParseToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), claims.ID)
+	assert.Len(t, rotated.JWKS().Keys, 2)
+
+	fresh, err := rotated.GenerateJWT(2, "user")
+	assert.NoError(t, err)
+	_, err = before.ParseToken(fresh)
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+
+	after, err := auth.NewKeySet(newKey)
+	assert.NoError(t, err)
+	_, err = after.ParseToken(token)
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	_, err = after.ParseToken(fresh)
+	assert.NoError(t, err)
+}
+
+func TestLoadRSAKeys(t *testing.T) {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	assert.NoError(t, err)
+	signing, err := auth.LoadPrivateKey(writePEM(t, "signing.pem", "PRIVATE KEY", der))
+	assert.NoError(t, err)
+	assert.Equal(t, "RS256", signing.Method.Alg())
+
+	der, err = x509.MarshalPKIXPublicKey(&private.PublicKey)
+	assert.NoError(t, err)
+	public, err := auth.LoadPublicKey(writePEM(t, "public.pem", "PUBLIC KEY", der))
+	assert.NoError(t, err)
+	assert.Equal(t, signing.ID, public.ID)
+
+	_, err = auth.NewKeySet(public)
+	assert.Error(t, err)
+
+	set, err := auth.NewKeySet(signing)
+	assert.NoError(t, err)
+	token, err := set.GenerateJWT(7, "user")
+	assert.NoError(t, err)
+
+	other, err := auth.GenerateKey()
+	assert.NoError(t, err)
+	verifier, err := auth.NewKeySet(other, public)
+	assert.NoError(t, err)
+	claims, err := verifier.ParseToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), claims.ID)
+
+	weak, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.NoError(t, err)
+	_, err = auth.LoadPrivateKey(writePEM(t, "weak.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(weak)))
+	assert.ErrorIs(t, err, auth.ErrUnsupportedKey)
+}
+
+func TestRejectHMACToken(t *testing.T) {
+	client := getTestClient()
+	_, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	assert.NoError(t, err)
+
+	login, err := client.loginUser("skyberg11", "abacaba")
+	assert.NoError(t, err)
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(login.Token, &auth.Claims{})
+	assert.NoError(t, err)
+
+	// Токен со старым общим секретом и подставленным kid не проходит проверку
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, &auth.Claims{
+		ID:               parsed.Claims.(*auth.Claims).ID,
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))},
+	})
+	forged.Header["kid"] = parsed.Header["kid"]
+	token, err := forged.SignedString([]byte("DEFUALT_SECRET"))
+	assert.NoError(t, err)
+
+	_, err = client.createAd("hello", "world", token)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+}
@@ -0,0 +1,23 @@
+//go:build !embedded_postgres
+
+package tests
+
+import (
+	"log"
+	"os"
+	"testing"
+)
+
+// Базы SQLite создаются во временном каталоге, чтобы прогон тестов не менял файлы в репозитории
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "adflow-tests")
+	if err != nil {
+		log.Fatal(err)
+	}
+	sqliteDir = dir
+
+	code := m.Run()
+
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
@@ -0,0 +1,269 @@
+package tests
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"adflow/internal/ads"
+	"adflow/internal/app"
+	"adflow/internal/moderation"
+	service "adflow/internal/ports/grpc/service"
+)
+
+func flagRules(flags []ads.Flag) []string {
+	var rules []string
+	for _, flag := range flags {
+		rules = append(rules, flag.Rule)
+	}
+	return rules
+}
+
+func TestModerationRules(t *testing.T) {
+	rules := moderation.Rules{moderation.BannedWords("оружие"), moderation.Links(), moderation.PhoneNumbers()}
+
+	flags := rules.Check(&ads.Ad{Title: "Велосипед", Text: "Горный, почти новый"})
+	assert.Empty(t, flags)
+
+	// Запрещённое слово находится в другой форме
+	flags = rules.Check(&ads.Ad{Title: "Продам", Text: "Коллекция оружия"})
+	assert.Equal(t, []string{"banned_word"}, flagRules(flags))
+	assert.Equal(t, "оружия", flags[0].Detail)
+
+	flags = rules.Check(&ads.Ad{Title: "Велосипед", Text: "Подробнее на https://example.com/bike или bikes.ru"})
+	assert.Equal(t, []string{"link", "link"}, flagRules(flags))
+	assert.Equal(t, "https://example.com/bike", flags[0].Detail)
+
+	flags = rules.Check(&ads.Ad{Title: "Велосипед", Text: "Звоните +7 (912) 345-67-89"})
+	assert.Equal(t, []string{"phone"}, flagRules(flags))
+	assert.Equal(t, "+7 (912) 345-67-89", flags[0].Detail)
+
+	// Короткие числа - не телефоны
+	assert.Empty(t, rules.Check(&ads.Ad{Title: "Велосипед", Text: "Рама 19 дюймов, 2021 год, 21 скорость"}))
+}
+
+func TestModerationQueue(t *testing.T) {
+	client, users := getTestClientWithUsers(app.WithModeration(app.ModerationAll, moderation.Default()...))
+
+	_, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	assert.NoError(t, err)
+	_, err = client.createUser("Other", "User", "other", "abacaba", "other@phystech.edu", "891428821XX")
+	assert.NoError(t, err)
+	moderator, err := client.createUser("Andrew", "Ivanov", "moder", "12345678", "arr@mail.ru", "+79821233123")
+	assert.NoError(t, err)
+	assert.NoError(t, users.UpdateRole(moderator.Data.ID, ads.RoleModerator))
+
+	author, err := client.loginUser("skyberg11", "abacaba")
+	assert.NoError(t, err)
+	other, err := client.loginUser("other", "abacaba")
+	assert.NoError(t, err)
+	moder, err := client.loginUser("moder", "12345678")
+	assert.NoError(t, err)
+
+	first, err := client.createAd("Велосипед", "Горный", author.Token)
+	assert.NoError(t, err)
+	second, err := client.createAd("Самокат", "Пишите на scooter.ru", author.Token)
+	assert.NoError(t, err)
+
+	// Публикация ставит объявление в очередь
+	ad, err := client.changeAdStatus(first.Data.ID, true, author.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, "pending_review", ad.Data.Status)
+	assert.False(t, ad.Data.Published)
+	_, err = client.changeAdStatus(second.Data.ID, true, author.Token)
+	assert.NoError(t, err)
+
+	_, err = client.moderationQueue(0, 0, author.Token)
+	assert.ErrorIs(t, err, ErrForbidden)
+	_, err = client.moderationQueue(0, 0, "")
+	assert.ErrorIs(t, err, ErrUnauthorized)
+
+	queue, err := client.moderationQueue(0, 0, moder.Token)
+	assert.NoError(t, err)
+	assert.Len(t, queue.Data, 2)
+	assert.Equal(t, first.Data.ID, queue.Data[0].AdID)
+	assert.Equal(t, "pending", queue.Data[0].Decision)
+	assert.Empty(t, queue.Data[0].Flags)
+	assert.NotNil(t, queue.Data[0].Ad)
+	assert.Equal(t, "Велосипед", queue.Data[0].Ad.Title)
+	assert.Equal(t, []flagData{{Rule: "link", Detail: "scooter.ru"}}, queue.Data[1].Flags)
+
+	// До решения объявление видят только автор и модераторы
+	_, err = client.getAd(first.Data.ID)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	_, err = client.getAdAs(first.Data.ID, other.Token)
+	assert.ErrorIs(t, err, ErrForbidden)
+	_, err = client.getAdAs(first.Data.ID, author.Token)
+	assert.NoError(t, err)
+	_, err = client.getAdAs(first.Data.ID, moder.Token)
+	assert.NoError(t, err)
+
+	list, err := client.filterAds(url.Values{}, "")
+	assert.NoError(t, err)
+	assert.Empty(t, list.Data)
+	list, err = client.filterAds(url.Values{"status": {"pending_review"}}, other.Token)
+	assert.NoError(t, err)
+	assert.Empty(t, list.Data)
+	list, err = client.filterAds(url.Values{"status": {"pending_review"}}, moder.Token)
+	assert.NoError(t, err)
+	assert.Len(t, list.Data, 2)
+
+	page, err := client.moderationQueue(queue.Data[0].ID, 1, moder.Token)
+	assert.NoError(t, err)
+	assert.Len(t, page.Data, 1)
+	assert.Equal(t, second.Data.ID, page.Data[0].AdID)
+
+	// Одобряет только модератор
+	_, err = client.approveAd(first.Data.ID, author.Token)
+	assert.ErrorIs(t, err, ErrForbidden)
+	approved, err := client.approveAd(first.Data.ID, moder.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, "published", approved.Data.Status)
+	assert.NotNil(t, approved.Data.ExpiresAt)
+	list, err = client.filterAds(url.Values{}, "")
+	assert.NoError(t, err)
+	assert.Len(t, list.Data, 1)
+	_, err = client.approveAd(first.Data.ID, moder.Token)
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	// Отклонение требует причину, поэтому модератор не возвращает заявку в черновики сменой статуса
+	_, err = client.transitionAd(second.Data.ID, "draft", moder.Token)
+	assert.ErrorIs(t, err, ErrBadRequest)
+	_, err = client.rejectAd(second.Data.ID, "  ", moder.Token)
+	assert.ErrorIs(t, err, ErrBadRequest)
+	_, err = client.rejectAd(second.Data.ID, strings.Repeat("я", app.MaxRejectReason+1), moder.Token)
+	assert.ErrorIs(t, err, ErrBadRequest)
+	rejected, err := client.rejectAd(second.Data.ID, "Ссылки на сторонние сайты запрещены", moder.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, "draft", rejected.Data.Status)
+
+	queue, err = client.moderationQueue(0, 0, moder.Token)
+	assert.NoError(t, err)
+	assert.Empty(t, queue.Data)
+
+	// Причину видит автор, но не посторонние
+	reviews, err := client.adReviews(second.Data.ID, author.Token)
+	assert.NoError(t, err)
+	assert.Len(t, reviews.Data, 1)
+	assert.Equal(t, "rejected", reviews.Data[0].Decision)
+	assert.Equal(t, "Ссылки на сторонние сайты запрещены", reviews.Data[0].Reason)
+	assert.Equal(t, moderator.Data.ID, reviews.Data[0].ModeratorID)
+	assert.NotNil(t, reviews.Data[0].DecisionTime)
+	assert.Nil(t, reviews.Data[0].Ad)
+	_, err = client.adReviews(second.Data.ID, other.Token)
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	// Правка опубликованного объявления снова отправляет его на проверку
+	ad, err = client.updateAd(first.Data.ID, "Велосипед", "Горный, новый", author.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, "pending_review", ad.Data.Status)
+
+	// Автор отзывает объявление из очереди, заявка закрывается
+	ad, err = client.transitionAd(first.Data.ID, "draft", author.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, "draft", ad.Data.Status)
+	reviews, err = client.adReviews(first.Data.ID, moder.Token)
+	assert.NoError(t, err)
+	assert.Len(t, reviews.Data, 2)
+	assert.Equal(t, "withdrawn", reviews.Data[0].Decision)
+	assert.Equal(t, "approved", reviews.Data[1].Decision)
+}
+
+func TestModerationFlagged(t *testing.T) {
+	client := getTestClient()
+
+	_, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	assert.NoError(t, err)
+	author, err := client.loginUser("skyberg11", "abacaba")
+	assert.NoError(t, err)
+
+	clean, err := client.createAd("Велосипед", "Горный", author.Token)
+	assert.NoError(t, err)
+	flagged, err := client.createAd("Самокат", "Звоните 8 912 345 67 89", author.Token)
+	assert.NoError(t, err)
+
+	// Без замечаний объявление публикуется сразу
+	ad, err := client.changeAdStatus(clean.Data.ID, true, author.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, "published", ad.Data.Status)
+
+	ad, err = client.changeAdStatus(flagged.Data.ID, true, author.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, "pending_review", ad.Data.Status)
+
+	// Ссылка в правке опубликованного объявления отправляет его на проверку
+	ad, err = client.updateAd(clean.Data.ID, "Велосипед", "Фото на www.example.com", author.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, "pending_review", ad.Data.Status)
+
+	reviews, err := client.adReviews(clean.Data.ID, author.Token)
+	assert.NoError(t, err)
+	assert.Len(t, reviews.Data, 1)
+	assert.Equal(t, []flagData{{Rule: "link", Detail: "www.example.com"}}, reviews.Data[0].Flags)
+
+	// Автор может удалить объявление, ждущее проверки
+	_, err = client.deleteAd(flagged.Data.ID, author.Token)
+	assert.NoError(t, err)
+}
+
+func TestGRPCModeration(t *testing.T) {
+	stores, _ := newTestStores()
+	users := stores.Users
+	a := app.NewApp(stores, app.WithPasswordHasher(cheapHasher))
+	client, ctx := getGRPCTestClientFor(t, a)
+	tr := &grpcTransport{client: client, ctx: ctx}
+
+	_, err := tr.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	assert.NoError(t, err)
+	moderator, err := tr.createUser("Andrew", "Ivanov", "moder", "12345678", "arr@mail.ru", "+79821233123")
+	assert.NoError(t, err)
+	assert.NoError(t, users.UpdateRole(moderator.ID, ads.RoleModerator))
+
+	author, err := tr.loginUser("skyberg11", "abacaba")
+	assert.NoError(t, err)
+	moder, err := tr.loginUser("moder", "12345678")
+	assert.NoError(t, err)
+
+	first, err := tr.createAd("Велосипед", "Горный", author)
+	assert.NoError(t, err)
+	second, err := tr.createAd("Самокат", "Электрический", author)
+	assert.NoError(t, err)
+	for _, id := range []int64{first.ID, second.ID} {
+		ad, err := tr.changeAdStatus(id, true, author)
+		assert.NoError(t, err)
+		assert.Equal(t, "pending_review", ad.Status)
+	}
+
+	_, err = client.ListModerationQueue(withToken(ctx, author), &service.ModerationQueueRequest{})
+	assert.ErrorIs(t, fromStatus(err), ErrForbidden)
+
+	queue, err := client.ListModerationQueue(withToken(ctx, moder), &service.ModerationQueueRequest{Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, queue.List, 2)
+	assert.Equal(t, "pending", queue.List[0].Decision)
+	assert.Equal(t, first.ID, queue.List[0].Ad.Id)
+	assert.Empty(t, queue.List[0].DecisionTime)
+
+	ad, err := client.ApproveAd(withToken(ctx, moder), &service.ApproveAdRequest{AdId: first.ID})
+	assert.NoError(t, err)
+	assert.Equal(t, "published", ad.Status)
+
+	_, err = client.RejectAd(withToken(ctx, moder), &service.RejectAdRequest{AdId: second.ID})
+	assert.ErrorIs(t, fromStatus(err), ErrBadRequest)
+	ad, err = client.RejectAd(withToken(ctx, moder), &service.RejectAdRequest{AdId: second.ID, Reason: "Нет фото"})
+	assert.NoError(t, err)
+	assert.Equal(t, "draft", ad.Status)
+
+	reviews, err := client.ListAdReviews(withToken(ctx, author), &service.ListAdReviewsRequest{AdId: second.ID})
+	assert.NoError(t, err)
+	assert.Len(t, reviews.List, 1)
+	assert.Equal(t, "rejected", reviews.List[0].Decision)
+	assert.Equal(t, "Нет фото", reviews.List[0].Reason)
+	assert.NotEmpty(t, reviews.List[0].DecisionTime)
+	assert.Nil(t, reviews.List[0].Ad)
+
+	_, err = client.ListAdReviews(ctx, &service.ListAdReviewsRequest{AdId: second.ID})
+	assert.ErrorIs(t, fromStatus(err), ErrUnauthorized)
+}
@@ -0,0 +1,246 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"adflow/internal/ads"
+	"adflow/internal/app"
+	"adflow/internal/outbox"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// webhookReceiver запоминает принятые пачки; пока fail, отвечает 500
+type webhookReceiver struct {
+	m      sync.Mutex
+	fail   bool
+	events []outbox.Envelope
+}
+
+func (rcv *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rcv.m.Lock()
+	defer rcv.m.Unlock()
+
+	if rcv.fail {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	var body struct {
+		Events []outbox.Envelope `json:"events"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	rcv.events = append(rcv.events, body.Events...)
+}
+
+func (rcv *webhookReceiver) setFail(fail bool) {
+	rcv.m.Lock()
+	defer rcv.m.Unlock()
+	rcv.fail = fail
+}
+
+func (rcv *webhookReceiver) types() []ads.EventType {
+	rcv.m.Lock()
+	defer rcv.m.Unlock()
+
+	types := make([]ads.EventType, 0, len(rcv.events))
+	for _, event := range rcv.events {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+func readEventFile(t *testing.T, path string) []outbox.Envelope {
+	file, err := os.Open(path)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	var events []outbox.Envelope
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event outbox.Envelope
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestOutboxRelay(t *testing.T) {
+	stores, index := newTestStores()
+	repo, users := stores.Ads, stores.Users
+	a := app.NewApp(stores, app.WithSearchIndex(index), app.WithPasswordHasher(cheapHasher), publishUnflagged)
+	client := getTestClientFor(a)
+
+	receiver := &webhookReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	relay := outbox.NewRelay([]outbox.Source{{Name: "ads", Outbox: repo}, {Name: "users", Outbox: users}},
+		outbox.NewFile(path), outbox.NewWebhook(server.URL, server.Client()))
+
+	user, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	assert.NoError(t, err)
+	token, err := client.loginUser("skyberg11", "abacaba")
+	assert.NoError(t, err)
+	_, err = client.updateUser("Timur", "Zykov", "new@phystech.edu", "891428821XX", user.Data.ID, token.Token)
+	assert.NoError(t, err)
+
+	ad, err := client.createAd("Велосипед", "Почти новый", token.Token)
+	assert.NoError(t, err)
+	_, err = client.changeAdStatus(ad.Data.ID, true, token.Token)
+	assert.NoError(t, err)
+	_, err = client.updateAd(ad.Data.ID, "Велосипед", "Совсем новый", token.Token)
+	assert.NoError(t, err)
+	_, err = client.changeAdStatus(ad.Data.ID, false, token.Token)
+	assert.NoError(t, err)
+	_, err = client.deleteAd(ad.Data.ID, token.Token)
+	assert.NoError(t, err)
+
+	n, err := relay.Flush(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 7, n)
+
+	expected := []ads.EventType{
+		ads.EventAdCreated, ads.EventAdPublished, ads.EventAdUpdated, ads.EventAdStatusChanged, ads.EventAdDeleted,
+		ads.EventUserCreated, ads.EventUserUpdated,
+	}
+	assert.Equal(t, expected, receiver.types())
+
+	events := readEventFile(t, path)
+	if assert.Len(t, events, 7) {
+		assert.Equal(t, "ads", events[0].Source)
+		assert.Equal(t, ad.Data.ID, events[0].AggregateID)
+
+		var created ads.AdSnapshot
+		assert.NoError(t, json.Unmarshal(events[0].Payload, &created))
+		assert.Equal(t, "Велосипед", created.Title)
+		assert.Equal(t, ads.StatusDraft, created.Status)
+
+		var updated ads.AdSnapshot
+		assert.NoError(t, json.Unmarshal(events[2].Payload, &updated))
+		assert.Equal(t, "Совсем новый", updated.Text)
+
+		var changed ads.AdStatusChanged
+		assert.NoError(t, json.Unmarshal(events[3].Payload, &changed))
+		assert.Equal(t, ads.StatusPublished, changed.From)
+		assert.Equal(t, ads.StatusDraft, changed.To)
+
+		// Контакты пользователя в журнал не попадают
+		assert.Equal(t, "users", events[5].Source)
+		assert.NotContains(t, string(events[5].Payload), "zykov.ta@phystech.edu")
+		assert.NotContains(t, string(events[6].Payload), "new@phystech.edu")
+	}
+
+	n, err = relay.Flush(context.Background())
+	assert.NoError(t, err)
+	assert.Zero(t, n)
+
+	// Пока вебхук отказывает, событие остаётся в outbox, но файл получает его сразу и только один раз
+	receiver.setFail(true)
+	_, err = client.createAd("Самокат", "Почти новый", token.Token)
+	assert.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		n, err = relay.Flush(context.Background())
+		assert.Error(t, err)
+		assert.Zero(t, n)
+		assert.Len(t, readEventFile(t, path), 8)
+	}
+
+	receiver.setFail(false)
+	n, err = relay.Flush(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Len(t, receiver.types(), 8)
+	assert.Len(t, readEventFile(t, path), 8)
+}
+
+// recordingSink запоминает принятые события; пока fail, отказывает
+type recordingSink struct {
+	fail   bool
+	events []outbox.Envelope
+}
+
+func (s *recordingSink) Deliver(_ context.Context, events []outbox.Envelope) error {
+	if s.fail {
+		return errors.New("sink is down")
+	}
+	s.events = append(s.events, events...)
+	return nil
+}
+
+func TestOutboxSinkFailureIsolated(t *testing.T) {
+	repo, _ := newTestAds()
+	down, up := &recordingSink{fail: true}, &recordingSink{}
+	relay := outbox.NewRelay([]outbox.Source{{Name: "ads", Outbox: repo}}, down, up)
+
+	create := func(title string) {
+		ad := &ads.Ad{Title: title, Text: "text", AuthorID: 1, Currency: ads.DefaultCurrency}
+		assert.NoError(t, repo.Create(ad, ads.AdCreated{Ad: ad}))
+	}
+
+	// Упавший первым приёмник не мешает второму, и второй не получает события повторно
+	create("first")
+	n, err := relay.Flush(context.Background())
+	assert.Error(t, err)
+	assert.Zero(t, n)
+	create("second")
+	n, err = relay.Flush(context.Background())
+	assert.Error(t, err)
+	assert.Zero(t, n)
+	assert.Len(t, up.events, 2)
+
+	pending, err := repo.PendingEvents(0, 10)
+	assert.NoError(t, err)
+	assert.Len(t, pending, 2)
+
+	// Приёмник поднялся: получает всё пропущенное по порядку, а события отмечаются доставленными
+	down.fail = false
+	n, err = relay.Flush(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Len(t, up.events, 2)
+	if assert.Len(t, down.events, 2) {
+		assert.Less(t, down.events[0].ID, down.events[1].ID)
+	}
+
+	pending, err = repo.PendingEvents(0, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestOutboxSkipsFailedWrites(t *testing.T) {
+	repo, _ := newTestAds()
+
+	ad := &ads.Ad{Title: "ad", Text: "text", AuthorID: 1, Currency: ads.DefaultCurrency}
+	assert.NoError(t, repo.Create(ad, ads.AdCreated{Ad: ad}))
+
+	// Переход из неверного статуса не меняет объявление и не оставляет события
+	_, err := repo.UpdateStatus(ad.ID, ads.StatusPublished, ads.StatusDraft, nil, ads.AdStatusChanged{AdID: ad.ID, From: ads.StatusPublished, To: ads.StatusDraft})
+	assert.ErrorIs(t, err, ads.ErrBadRequest)
+	assert.ErrorIs(t, repo.DeleteAd(ad.ID+1, ads.AdDeleted{AdID: ad.ID + 1}), ads.ErrBadRequest)
+
+	pending, err := repo.PendingEvents(0, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, pending, 1) {
+		assert.Equal(t, ads.EventAdCreated, pending[0].Type)
+		assert.Equal(t, ad.ID, pending[0].AggregateID)
+	}
+
+	assert.NoError(t, repo.MarkDelivered([]int64{pending[0].ID}, pending[0].OccurredAt))
+	pending, err = repo.PendingEvents(0, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+}
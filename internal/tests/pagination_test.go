@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"adflow/internal/ads"
+)
+
+func TestRepositoryPagination(t *testing.T) {
+	repo, _ := newTestAds()
+
+	// Одинаковые заголовки проверяют, что при равенстве порядок задаёт ID
+	titles := []string{"кот", "Bike", "apple", "кот", "Zebra", "bike", "apple"}
+	prices := []int64{500, 0, 1500, 500, 99, 1500, 0}
+	var created []*ads.Ad
+	for i, title := range titles {
+		ad := &ads.Ad{Title: title, Text: "text", AuthorID: 1, Price: prices[i], Currency: ads.DefaultCurrency}
+		assert.NoError(t, repo.Create(ad))
+		created = append(created, ad)
+	}
+	_, err := repo.Update(created[0].ID, ads.Draft{Title: "кот", Text: "updated"}, &ads.Revision{AuthorID: 1})
+	assert.NoError(t, err)
+
+	for _, field := range []ads.SortField{ads.SortCreationTime, ads.SortUpdateTime, ads.SortTitle, ads.SortPrice} {
+		for _, desc := range []bool{false, true} {
+			s := ads.Sort{Field: field, Desc: desc}
+
+			all, next, err := repo.GetAds(ads.Filter{}, ads.Page{Sort: s})
+			assert.NoError(t, err)
+			assert.Nil(t, next)
+			assert.Len(t, all, len(titles))
+			assert.True(t, sort.SliceIsSorted(all, func(i, j int) bool { return s.Less(all[i], all[j]) }), "%v", s)
+
+			var paged []*ads.Ad
+			page := ads.Page{Sort: s, Limit: 3}
+			for i := 0; ; i++ {
+				list, next, err := repo.GetAds(ads.Filter{}, page)
+				assert.NoError(t, err)
+				assert.LessOrEqual(t, len(list), 3)
+				paged = append(paged, list...)
+				if next == nil {
+					break
+				}
+				assert.Less(t, i, len(titles))
+
+				// Курсор доходит до клиента строкой
+				page.After, err = ads.DecodeCursor(next.Encode())
+				assert.NoError(t, err)
+			}
+
+			assert.Len(t, paged, len(all))
+			for i := range all {
+				assert.Equal(t, all[i].ID, paged[i].ID, "%v", s)
+			}
+		}
+	}
+
+	// Заголовки сравниваются побайтово: заглавные латинские буквы раньше строчных
+	list, _, err := repo.GetAds(ads.Filter{}, ads.Page{Sort: ads.Sort{Field: ads.SortTitle}, Limit: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Bike", "Zebra"}, []string{list[0].Title, list[1].Title})
+}
+
+func TestParityPagination(t *testing.T) {
+	forEachTransport(t, func(t *testing.T, tr transport) {
+		user, err := tr.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+		assert.NoError(t, err)
+		token, err := tr.loginUser("skyberg11", "abacaba")
+		assert.NoError(t, err)
+
+		// Черновики автор видит, когда ищет среди своих объявлений
+		for _, title := range []string{"c", "a", "d", "b", "e"} {
+			_, err := tr.createAd(title, "text", token)
+			assert.NoError(t, err)
+		}
+
+		var titles []string
+		filter := adFilter{AuthorIDs: []int64{user.ID}, Limit: 2, Sort: "title", Desc: true}
+		for {
+			list, next, err := tr.pageAds(filter, token)
+			assert.NoError(t, err)
+			for _, ad := range list {
+				titles = append(titles, ad.Title)
+			}
+			if next == "" {
+				break
+			}
+			filter.Cursor = next
+		}
+		assert.Equal(t, []string{"e", "d", "c", "b", "a"}, titles)
+
+		// Без сортировки объявления идут в порядке создания
+		list, next, err := tr.pageAds(adFilter{AuthorIDs: []int64{user.ID}, Limit: 3}, token)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, next)
+		assert.Equal(t, []string{"c", "a", "d"}, []string{list[0].Title, list[1].Title, list[2].Title})
+
+		// Курсор другой сортировки не подходит
+		_, _, err = tr.pageAds(adFilter{Limit: 3, Sort: "title", Cursor: next}, "")
+		assert.ErrorIs(t, err, ErrBadRequest)
+
+		_, _, err = tr.pageAds(adFilter{Cursor: "not a cursor"}, "")
+		assert.ErrorIs(t, err, ErrBadRequest)
+
+		_, _, err = tr.pageAds(adFilter{Sort: "author_id"}, "")
+		assert.ErrorIs(t, err, ErrBadRequest)
+
+		_, _, err = tr.pageAds(adFilter{Limit: -1}, "")
+		assert.ErrorIs(t, err, ErrBadRequest)
+	})
+}
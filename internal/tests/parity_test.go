@@ -0,0 +1,189 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func forEachTransport(t *testing.T, scenario func(t *testing.T, tr transport)) {
+	for name, newTransport := range transports {
+		newTransport := newTransport
+		t.Run(name, func(t *testing.T) {
+			scenario(t, newTransport(t))
+		})
+	}
+}
+
+func TestParityAdLifecycle(t *testing.T) {
+	forEachTransport(t, func(t *testing.T, tr transport) {
+		user, err := tr.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+		assert.NoError(t, err)
+
+		token, err := tr.loginUser("skyberg11", "abacaba")
+		assert.NoError(t, err)
+
+		ad, err := tr.createAd("hello", "world", token)
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", ad.Title)
+		assert.Equal(t, user.ID, ad.AuthorID)
+		assert.False(t, ad.Published)
+
+		ad, err = tr.changeAdStatus(ad.ID, true, token)
+		assert.NoError(t, err)
+		assert.True(t, ad.Published)
+
+		ad, err = tr.updateAd(ad.ID, "привет", "мир", token)
+		assert.NoError(t, err)
+		assert.Equal(t, "привет", ad.Title)
+		assert.Equal(t, "мир", ad.Text)
+
+		got, err := tr.getAd(ad.ID, "")
+		assert.NoError(t, err)
+		assert.Equal(t, ad.Title, got.Title)
+		assert.True(t, got.Published)
+
+		list, err := tr.listAds(true, user.ID, nil)
+		assert.NoError(t, err)
+		assert.Len(t, list, 1)
+
+		err = tr.deleteAd(ad.ID, token)
+		assert.NoError(t, err)
+
+		_, err = tr.getAd(ad.ID, "")
+		assert.ErrorIs(t, err, ErrBadRequest)
+	})
+}
+
+func TestParityValidation(t *testing.T) {
+	forEachTransport(t, func(t *testing.T, tr transport) {
+		_, err := tr.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+		assert.NoError(t, err)
+
+		_, err = tr.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+		assert.ErrorIs(t, err, ErrBadRequest)
+
+		_, err = tr.createUser("", "Zykov", "empty", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+		assert.ErrorIs(t, err, ErrBadRequest)
+
+		_, err = tr.loginUser("skyberg11", "wrong")
+		assert.ErrorIs(t, err, ErrForbidden)
+
+		token, err := tr.loginUser("skyberg11", "abacaba")
+		assert.NoError(t, err)
+
+		_, err = tr.createAd("", "world", token)
+		assert.ErrorIs(t, err, ErrBadRequest)
+
+		_, err = tr.createAd("hello", strings.Repeat("a", 501), token)
+		assert.ErrorIs(t, err, ErrBadRequest)
+
+		ad, err := tr.createAd("hello", "world", token)
+		assert.NoError(t, err)
+
+		_, err = tr.updateAd(ad.ID, strings.Repeat("a", 101), "world", token)
+		assert.ErrorIs(t, err, ErrBadRequest)
+
+		got, err := tr.getAd(ad.ID, token)
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", got.Title)
+	})
+}
+
+func TestParityAccessRules(t *testing.T) {
+	forEachTransport(t, func(t *testing.T, tr transport) {
+		user, err := tr.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+		assert.NoError(t, err)
+
+		token1, err := tr.loginUser("skyberg11", "abacaba")
+		assert.NoError(t, err)
+
+		intruder, err := tr.createUser("Andrew", "Ivanov", "abacaba", "12345678", "arr@mail.ru", "+79821233123")
+		assert.NoError(t, err)
+
+		token2, err := tr.loginUser("abacaba", "12345678")
+		assert.NoError(t, err)
+
+		ad, err := tr.createAd("hello", "world", token1)
+		assert.NoError(t, err)
+
+		_, err = tr.createAd("hello", "world", "")
+		assert.ErrorIs(t, err, ErrUnauthorized)
+
+		_, err = tr.createAd("hello", "world", "42istheanswerforanyquestion")
+		assert.ErrorIs(t, err, ErrUnauthorized)
+
+		foreign, err := tr.createAd("hello", "world", token2)
+		assert.NoError(t, err)
+		assert.Equal(t, intruder.ID, foreign.AuthorID)
+
+		_, err = tr.changeAdStatus(ad.ID, true, token2)
+		assert.ErrorIs(t, err, ErrForbidden)
+
+		_, err = tr.updateAd(ad.ID, "title", "text", token2)
+		assert.ErrorIs(t, err, ErrForbidden)
+
+		err = tr.deleteAd(ad.ID, token2)
+		assert.ErrorIs(t, err, ErrForbidden)
+
+		err = tr.deleteUser(user.ID, token2)
+		assert.ErrorIs(t, err, ErrForbidden)
+
+		// Черновик видит только автор, в том числе в выдаче
+		_, err = tr.getAd(ad.ID, "")
+		assert.ErrorIs(t, err, ErrUnauthorized)
+		_, err = tr.getAd(ad.ID, token2)
+		assert.ErrorIs(t, err, ErrForbidden)
+		_, err = tr.getAd(ad.ID, token1)
+		assert.NoError(t, err)
+
+		list, err := tr.filterAds(adFilter{AuthorIDs: []int64{user.ID}}, token2)
+		assert.NoError(t, err)
+		assert.Empty(t, list)
+		list, err = tr.filterAds(adFilter{AuthorIDs: []int64{user.ID}, Statuses: []string{"draft"}}, "")
+		assert.NoError(t, err)
+		assert.Empty(t, list)
+		list, err = tr.filterAds(adFilter{AuthorIDs: []int64{user.ID}}, token1)
+		assert.NoError(t, err)
+		assert.Len(t, list, 1)
+
+		_, err = tr.changeAdStatus(ad.ID, true, token1)
+		assert.NoError(t, err)
+		_, err = tr.getAd(ad.ID, "")
+		assert.NoError(t, err)
+	})
+}
+
+func TestParityUsers(t *testing.T) {
+	forEachTransport(t, func(t *testing.T, tr transport) {
+		user, err := tr.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+		assert.NoError(t, err)
+
+		token, err := tr.loginUser("skyberg11", "abacaba")
+		assert.NoError(t, err)
+
+		updated, err := tr.updateUser("Tim", "Zykov", "new@phystech.edu", "891428821XX", user.ID, token)
+		assert.NoError(t, err)
+		assert.Equal(t, "Tim", updated.FirstName)
+		assert.Equal(t, "new@phystech.edu", updated.Email)
+
+		got, err := tr.getUser(user.ID, token)
+		assert.NoError(t, err)
+		assert.Equal(t, "Tim", got.FirstName)
+		assert.Equal(t, "new@phystech.edu", got.Email)
+
+		// Контакты видят только сам пользователь и администратор
+		got, err = tr.getUser(user.ID, "")
+		assert.NoError(t, err)
+		assert.Equal(t, "Tim", got.FirstName)
+		assert.Empty(t, got.Email)
+		assert.Empty(t, got.Phone)
+
+		err = tr.deleteUser(user.ID, token)
+		assert.NoError(t, err)
+
+		_, err = tr.getUser(user.ID, "")
+		assert.ErrorIs(t, err, ErrBadRequest)
+	})
+}
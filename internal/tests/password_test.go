@@ -0,0 +1,193 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"adflow/internal/adapters"
+	"adflow/internal/adapters/aduser"
+	"adflow/internal/ads"
+	"adflow/internal/app"
+	"adflow/internal/app/auth"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPasswordIsHashed(t *testing.T) {
+	stores, _ := newTestStores()
+	users := stores.Users
+	a := app.NewApp(stores)
+	ctx := context.Background()
+
+	user, err := a.CreateUser(ctx, "Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	assert.NoError(t, err)
+
+	stored, err := users.Get(user.ID)
+	assert.NoError(t, err)
+	assert.NotEqual(t, "abacaba", stored.Password)
+	assert.True(t, strings.HasPrefix(stored.Password, "$argon2id$"))
+
+	_, err = a.LoginUser(ctx, "skyberg11", "abacaba")
+	assert.NoError(t, err)
+
+	_, err = a.LoginUser(ctx, "skyberg11", "abacab")
+	assert.ErrorIs(t, err, ads.ErrAccessDenied)
+}
+
+func TestPasswordSalted(t *testing.T) {
+	first, err := cheapHasher.Hash("abacaba")
+	assert.NoError(t, err)
+
+	second, err := cheapHasher.Hash("abacaba")
+	assert.NoError(t, err)
+
+	assert.NotEqual(t, first, second)
+
+	ok, rehash := cheapHasher.Verify(first, "abacaba")
+	assert.True(t, ok)
+	assert.False(t, rehash)
+}
+
+func TestPasswordRehashOnLogin(t *testing.T) {
+	stores, _ := newTestStores()
+	users := stores.Users
+	ctx := context.Background()
+
+	old := app.NewApp(stores, app.WithPasswordHasher(cheapHasher))
+	user, err := old.CreateUser(ctx, "Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	assert.NoError(t, err)
+
+	stronger := cheapHasher
+	stronger.Time = 2
+	a := app.NewApp(stores, app.WithPasswordHasher(stronger))
+
+	_, err = a.LoginUser(ctx, "skyberg11", "wrong")
+	assert.ErrorIs(t, err, ads.ErrAccessDenied)
+
+	before, err := users.Get(user.ID)
+	assert.NoError(t, err)
+	assert.Contains(t, before.Password, "t=1")
+
+	_, err = a.LoginUser(ctx, "skyberg11", "abacaba")
+	assert.NoError(t, err)
+
+	after, err := users.Get(user.ID)
+	assert.NoError(t, err)
+	assert.Contains(t, after.Password, "t=2")
+
+	ok, rehash := stronger.Verify(after.Password, "abacaba")
+	assert.True(t, ok)
+	assert.False(t, rehash)
+}
+
+func TestPasswordRehashFromBcrypt(t *testing.T) {
+	stores, _ := newTestStores()
+	users := stores.Users
+	ctx := context.Background()
+
+	legacy, err := bcrypt.GenerateFromPassword([]byte("abacaba"), bcrypt.MinCost)
+	assert.NoError(t, err)
+
+	user := &ads.User{FirstName: "Timur", SecondName: "Zykov", Nickname: "skyberg11", Password: string(legacy),
+		Email: "zykov.ta@phystech.edu", Phone: "891428821XX"}
+	assert.NoError(t, users.Create(user))
+
+	a := app.NewApp(stores, app.WithPasswordHasher(cheapHasher))
+	_, err = a.LoginUser(ctx, "skyberg11", "abacaba")
+	assert.NoError(t, err)
+
+	stored, err := users.Get(user.ID)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(stored.Password, "$argon2id$"))
+}
+
+func TestMigratePlaintextPasswords(t *testing.T) {
+	stores, _ := newTestStores()
+	users := stores.Users
+	ctx := context.Background()
+
+	plain := &ads.User{FirstName: "Timur", SecondName: "Zykov", Nickname: "skyberg11", Password: "abacaba",
+		Email: "zykov.ta@phystech.edu", Phone: "891428821XX"}
+	assert.NoError(t, users.Create(plain))
+
+	a := app.NewApp(stores, app.WithPasswordHasher(cheapHasher))
+	_, err := a.CreateUser(ctx, "Andrew", "Ivanov", "abacaba", "12345678", "arr@mail.ru", "+79821233123")
+	assert.NoError(t, err)
+
+	usersDSN, _ := testDSNs()
+	db, err := adapters.Open(usersDSN)
+	if errors.Is(err, adapters.ErrNoDatabase) {
+		t.Skip("migration works on SQL backends only")
+	}
+	assert.NoError(t, err)
+
+	migrated, err := aduser.MigratePasswords(db, cheapHasher)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, migrated)
+
+	stored, err := users.Get(plain.ID)
+	assert.NoError(t, err)
+	assert.True(t, auth.IsHashed(stored.Password))
+
+	migrated, err = aduser.MigratePasswords(db, cheapHasher)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, migrated)
+
+	_, err = a.LoginUser(ctx, "skyberg11", "abacaba")
+	assert.NoError(t, err)
+
+	_, err = a.LoginUser(ctx, "abacaba", "12345678")
+	assert.NoError(t, err)
+}
+
+func TestUpdateUserWithLongPasswordHash(t *testing.T) {
+	client, users := getTestClientWithUsers()
+
+	user, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	assert.NoError(t, err)
+	token, err := client.loginUser("skyberg11", "abacaba")
+	assert.NoError(t, err)
+
+	// Хеш с большими параметрами длиннее ограничения на пароль из запроса, правку профиля он не блокирует
+	long := "$argon2id$v=19$m=4194304,t=10,p=255$" + strings.Repeat("s", 22) + "$" + strings.Repeat("k", 43)
+	assert.Greater(t, len(long), 100)
+	assert.NoError(t, users.UpdatePassword(user.Data.ID, long))
+
+	updated, err := client.updateUser("Timur", "Petrov", "new@phystech.edu", "891428821XX", user.Data.ID, token.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, "Petrov", updated.Data.SecondName)
+
+	_, err = client.updateUser("", "Petrov", "new@phystech.edu", "891428821XX", user.Data.ID, token.Token)
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	stored, err := users.Get(user.Data.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Timur", stored.FirstName)
+	assert.Equal(t, long, stored.Password)
+}
+
+func TestHasherFromEnv(t *testing.T) {
+	t.Setenv("AUTH_PASSWORD_TIME", "3")
+	t.Setenv("AUTH_PASSWORD_MEMORY_KB", "32768")
+	hasher, err := auth.HasherFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(3), hasher.Time)
+	assert.Equal(t, uint32(32768), hasher.Memory)
+	assert.Equal(t, auth.DefaultPasswordHasher.Threads, hasher.Threads)
+
+	for _, env := range []struct{ time, memory string }{
+		{"0", "32768"},
+		{"fast", "32768"},
+		{"1", "1024"},
+		{"1", "8388608"},
+		{"1", "-1"},
+	} {
+		t.Setenv("AUTH_PASSWORD_TIME", env.time)
+		t.Setenv("AUTH_PASSWORD_MEMORY_KB", env.memory)
+		_, err := auth.HasherFromEnv()
+		assert.Error(t, err, "time=%s memory=%s", env.time, env.memory)
+	}
+}
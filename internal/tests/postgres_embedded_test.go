@@ -0,0 +1,37 @@
+//go:build embedded_postgres
+
+package tests
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+)
+
+// Прогон всего набора тестов на PostgreSQL без установленного сервера:
+//
+//	go test -tags embedded_postgres ./internal/tests
+//
+// При первом запуске скачиваются бинарники PostgreSQL (кешируются в ~/.embedded-postgres-go).
+func TestMain(m *testing.M) {
+	config := embeddedpostgres.DefaultConfig().
+		Port(15432).
+		Database("adflow_test").
+		RuntimePath(filepath.Join(os.TempDir(), "adflow-embedded-postgres"))
+
+	pg := embeddedpostgres.NewDatabase(config)
+	if err := pg.Start(); err != nil {
+		log.Fatal(err)
+	}
+
+	os.Setenv("TEST_DB_DSN", config.GetConnectionURL()+"?sslmode=disable")
+	code := m.Run()
+
+	if err := pg.Stop(); err != nil {
+		log.Print(err)
+	}
+	os.Exit(code)
+}
@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func price(v int64) *int64 {
+	return &v
+}
+
+func flag(v bool) *bool {
+	return &v
+}
+
+func TestParityPrice(t *testing.T) {
+	forEachTransport(t, func(t *testing.T, tr transport) {
+		_, err := tr.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+		assert.NoError(t, err)
+		token, err := tr.loginUser("skyberg11", "abacaba")
+		assert.NoError(t, err)
+
+		// Без цены объявление бесплатное и в валюте по умолчанию
+		free, err := tr.createAd("Котёнок", "Отдам в добрые руки", token)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), free.Price)
+		assert.Equal(t, "RUB", free.Currency)
+		assert.False(t, free.Negotiable)
+
+		bike, err := tr.createPricedAd("Велосипед", "Горный", adPrice{Price: price(1250000), Currency: "rub", Negotiable: flag(true)}, token)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1250000), bike.Price)
+		assert.Equal(t, "RUB", bike.Currency)
+		assert.True(t, bike.Negotiable)
+
+		scooter, err := tr.createPricedAd("Самокат", "Электрический", adPrice{Price: price(30000), Currency: "USD"}, token)
+		assert.NoError(t, err)
+
+		skates, err := tr.createPricedAd("Ролики", "Размер 42", adPrice{Price: price(350000)}, token)
+		assert.NoError(t, err)
+		assert.Equal(t, "RUB", skates.Currency)
+
+		_, err = tr.createPricedAd("Лыжи", "Беговые", adPrice{Price: price(-1)}, token)
+		assert.ErrorIs(t, err, ErrBadRequest)
+		_, err = tr.createPricedAd("Лыжи", "Беговые", adPrice{Price: price(100), Currency: "XYZ"}, token)
+		assert.ErrorIs(t, err, ErrBadRequest)
+
+		// Незаданные поля цены при изменении остаются прежними
+		bike, err = tr.updatePricedAd(bike.ID, "Велосипед", "Горный, торг", adPrice{Price: price(1100000)}, token)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1100000), bike.Price)
+		assert.Equal(t, "RUB", bike.Currency)
+		assert.True(t, bike.Negotiable)
+
+		bike, err = tr.updatePricedAd(bike.ID, "Велосипед", "Горный", adPrice{Negotiable: flag(false)}, token)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1100000), bike.Price)
+		assert.False(t, bike.Negotiable)
+
+		_, err = tr.updatePricedAd(bike.ID, "Велосипед", "Горный", adPrice{Currency: "rubles"}, token)
+		assert.ErrorIs(t, err, ErrBadRequest)
+		bike, err = tr.getAd(bike.ID, token)
+		assert.NoError(t, err)
+		assert.Equal(t, "RUB", bike.Currency)
+
+		for _, ad := range []adData{free, bike, scooter, skates} {
+			_, err = tr.changeAdStatus(ad.ID, true, token)
+			assert.NoError(t, err)
+		}
+
+		list, err := tr.filterAds(adFilter{Currency: "RUB", MinPrice: "1", MaxPrice: "1100000", Sort: "price", Desc: true}, "")
+		assert.NoError(t, err)
+		assert.Len(t, list, 2)
+		assert.Equal(t, []int64{bike.ID, skates.ID}, []int64{list[0].ID, list[1].ID})
+
+		list, err = tr.filterAds(adFilter{Currency: "usd"}, "")
+		assert.NoError(t, err)
+		assert.Len(t, list, 1)
+		assert.Equal(t, scooter.ID, list[0].ID)
+
+		// Постраничная выдача по цене
+		var ids []int64
+		filter := adFilter{Sort: "price", Limit: 1, Currency: "RUB"}
+		for {
+			list, next, err := tr.pageAds(filter, "")
+			assert.NoError(t, err)
+			for _, ad := range list {
+				ids = append(ids, ad.ID)
+			}
+			if next == "" {
+				break
+			}
+			filter.Cursor = next
+		}
+		assert.Equal(t, []int64{free.ID, skates.ID, bike.ID}, ids)
+
+		// Цены разных валют несравнимы: диапазон требует валюту
+		_, err = tr.filterAds(adFilter{MinPrice: "100"}, "")
+		assert.ErrorIs(t, err, ErrBadRequest)
+		_, err = tr.filterAds(adFilter{Currency: "XYZ"}, "")
+		assert.ErrorIs(t, err, ErrBadRequest)
+	})
+}
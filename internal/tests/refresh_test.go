@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"testing"
+
+	service "adflow/internal/ports/grpc/service"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefreshRotation(t *testing.T) {
+	client := getTestClient()
+	_, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	assert.NoError(t, err)
+
+	login, err := client.loginUser("skyberg11", "abacaba")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, login.RefreshToken)
+	assert.Equal(t, int64(300), login.ExpiresIn)
+
+	refreshed, err := client.refreshToken(login.RefreshToken)
+	assert.NoError(t, err)
+	assert.NotEqual(t, login.RefreshToken, refreshed.RefreshToken)
+	assert.NotEqual(t, login.Token, refreshed.Token)
+
+	_, err = client.createAd("hello", "world", refreshed.Token)
+	assert.NoError(t, err)
+
+	_, err = client.refreshToken("42istheanswerforanyquestion")
+	assert.ErrorIs(t, err, ErrUnauthorized)
+}
+
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+	client := getTestClient()
+	_, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	assert.NoError(t, err)
+
+	login, err := client.loginUser("skyberg11", "abacaba")
+	assert.NoError(t, err)
+
+	refreshed, err := client.refreshToken(login.RefreshToken)
+	assert.NoError(t, err)
+
+	// Повторное использование старого токена отзывает и выданный взамен
+	_, err = client.refreshToken(login.RefreshToken)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+
+	_, err = client.refreshToken(refreshed.RefreshToken)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+
+	// Другие сессии пользователя не затрагиваются
+	another, err := client.loginUser("skyberg11", "abacaba")
+	assert.NoError(t, err)
+
+	_, err = client.refreshToken(another.RefreshToken)
+	assert.NoError(t, err)
+}
+
+func TestLogout(t *testing.T) {
+	client := getTestClient()
+	_, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	assert.NoError(t, err)
+
+	_, err = client.createUser("Andrew", "Ivanov", "abacaba", "12345678", "arr@mail.ru", "+79821233123")
+	assert.NoError(t, err)
+
+	login, err := client.loginUser("skyberg11", "abacaba")
+	assert.NoError(t, err)
+
+	another, err := client.loginUser("abacaba", "12345678")
+	assert.NoError(t, err)
+
+	_, err = client.logoutUser(login.RefreshToken, another.Token)
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	_, err = client.logoutUser(login.RefreshToken, login.Token)
+	assert.NoError(t, err)
+
+	// Access-токен отозван до истечения срока, refresh-токен больше не работает
+	_, err = client.createAd("hello", "world", login.Token)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+
+	_, err = client.refreshToken(login.RefreshToken)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+
+	_, err = client.createAd("hello", "world", another.Token)
+	assert.NoError(t, err)
+}
+
+func TestGRPCRefreshAndLogout(t *testing.T) {
+	client, ctx := getGRPCTestClient(t)
+	_, err := client.CreateUser(ctx, &service.CreateUserRequest{FirstName: "Oleg", SecondName: "Ivanov", Nickname: "oleg",
+		Password: "abacaba", Email: "abacaba@aba.ru", Phone: "+79821233123"})
+	assert.NoError(t, err)
+
+	login, err := client.Login(ctx, &service.LoginRequest{Nickname: "oleg", Password: "abacaba"})
+	assert.NoError(t, err)
+
+	refreshed, err := client.Refresh(ctx, &service.RefreshRequest{RefreshToken: login.RefreshToken})
+	assert.NoError(t, err)
+
+	_, err = client.Logout(withToken(ctx, refreshed.Token), &service.RefreshRequest{RefreshToken: refreshed.RefreshToken})
+	assert.NoError(t, err)
+
+	_, err = client.CreateAd(withToken(ctx, refreshed.Token), &service.CreateAdRequest{Title: "Oleg", Text: "abacaba@aba.ru"})
+	assert.ErrorIs(t, fromStatus(err), ErrUnauthorized)
+}
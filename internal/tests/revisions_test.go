@@ -0,0 +1,276 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"adflow/internal/ads"
+	"adflow/internal/app"
+	service "adflow/internal/ports/grpc/service"
+)
+
+func revisionNumbers(list []revisionData) []int64 {
+	var numbers []int64
+	for _, rev := range list {
+		numbers = append(numbers, rev.Number)
+	}
+	return numbers
+}
+
+func TestAdRevisions(t *testing.T) {
+	client, users := getTestClientWithUsers()
+
+	author, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	assert.NoError(t, err)
+	_, err = client.createUser("Other", "User", "other", "abacaba", "other@phystech.edu", "891428821XX")
+	assert.NoError(t, err)
+	moderator, err := client.createUser("Andrew", "Ivanov", "moder", "12345678", "arr@mail.ru", "+79821233123")
+	assert.NoError(t, err)
+	assert.NoError(t, users.UpdateRole(moderator.Data.ID, ads.RoleModerator))
+
+	token, err := client.loginUser("skyberg11", "abacaba")
+	assert.NoError(t, err)
+	other, err := client.loginUser("other", "abacaba")
+	assert.NoError(t, err)
+	moder, err := client.loginUser("moder", "12345678")
+	assert.NoError(t, err)
+
+	ad, err := client.createAd("Велосипед", "Почти новый", token.Token)
+	assert.NoError(t, err)
+	_, err = client.updateAd(ad.Data.ID, "Велосипед горный", "Почти новый", token.Token)
+	assert.NoError(t, err)
+	price := int64(1500000)
+	_, err = client.updatePricedAd(ad.Data.ID, "Велосипед горный", "Совсем новый", adPrice{Price: &price}, token.Token)
+	assert.NoError(t, err)
+	_, err = client.updatePlacedAd(ad.Data.ID, "Велосипед горный", "Совсем новый", &adLocation{Latitude: 55.75, Longitude: 37.62, City: "Москва"}, token.Token)
+	assert.NoError(t, err)
+
+	// Последние ревизии первыми; ревизия 1 - объявление при создании
+	list, err := client.listAdRevisions(ad.Data.ID, 0, 0, token.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{4, 3, 2, 1}, revisionNumbers(list.Data))
+	assert.Equal(t, "Велосипед", list.Data[3].Title)
+	assert.Equal(t, author.Data.ID, list.Data[3].AuthorID)
+	assert.Nil(t, list.Data[3].Location)
+	if assert.NotNil(t, list.Data[0].Location) {
+		assert.Equal(t, "Москва", list.Data[0].Location.City)
+	}
+
+	page, err := client.listAdRevisions(ad.Data.ID, 3, 1, token.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{2}, revisionNumbers(page.Data))
+
+	rev, err := client.getAdRevision(ad.Data.ID, 3, token.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, "Совсем новый", rev.Data.Text)
+	assert.Equal(t, price, rev.Data.Price)
+	assert.Equal(t, author.Data.ID, rev.Data.AuthorID)
+	assert.Zero(t, rev.Data.RestoredFrom)
+
+	_, err = client.getAdRevision(ad.Data.ID, 5, token.Token)
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	// Сравнение показывает только изменившиеся поля
+	diff, err := client.diffAdRevisions(ad.Data.ID, 1, 4, token.Token)
+	assert.NoError(t, err)
+	var fields []string
+	for _, change := range diff.Data.Changes {
+		fields = append(fields, change.Field)
+	}
+	assert.Equal(t, []string{"title", "text", "price", "location"}, fields)
+	if assert.Len(t, diff.Data.Changes, 4) {
+		assert.JSONEq(t, `"Велосипед"`, string(diff.Data.Changes[0].From))
+		assert.JSONEq(t, `"Велосипед горный"`, string(diff.Data.Changes[0].To))
+		assert.JSONEq(t, `0`, string(diff.Data.Changes[2].From))
+		assert.JSONEq(t, `null`, string(diff.Data.Changes[3].From))
+		assert.JSONEq(t, `{"latitude": 55.75, "longitude": 37.62, "city": "Москва"}`, string(diff.Data.Changes[3].To))
+	}
+
+	same, err := client.diffAdRevisions(ad.Data.ID, 3, 3, token.Token)
+	assert.NoError(t, err)
+	assert.Empty(t, same.Data.Changes)
+
+	// Возврат к ревизии 1 снимает и место, и цену; сам возврат - новая ревизия
+	restored, err := client.restoreAdRevision(ad.Data.ID, 1, token.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, "Велосипед", restored.Data.Title)
+	assert.Equal(t, "Почти новый", restored.Data.Text)
+	assert.Zero(t, restored.Data.Price)
+	assert.Nil(t, restored.Data.Location)
+
+	got, err := client.getAdAs(ad.Data.ID, token.Token)
+	assert.NoError(t, err)
+	assert.Nil(t, got.Data.Location)
+
+	rev, err = client.getAdRevision(ad.Data.ID, 5, token.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), rev.Data.RestoredFrom)
+	diff, err = client.diffAdRevisions(ad.Data.ID, 1, 5, token.Token)
+	assert.NoError(t, err)
+	assert.Empty(t, diff.Data.Changes)
+
+	// История видна автору и модератору, возвращать ревизии может только автор
+	_, err = client.listAdRevisions(ad.Data.ID, 0, 0, other.Token)
+	assert.ErrorIs(t, err, ErrForbidden)
+	_, err = client.diffAdRevisions(ad.Data.ID, 1, 2, other.Token)
+	assert.ErrorIs(t, err, ErrForbidden)
+	_, err = client.listAdRevisions(ad.Data.ID, 0, 0, "")
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	list, err = client.listAdRevisions(ad.Data.ID, 0, 0, moder.Token)
+	assert.NoError(t, err)
+	assert.Len(t, list.Data, 5)
+	_, err = client.restoreAdRevision(ad.Data.ID, 2, other.Token)
+	assert.ErrorIs(t, err, ErrForbidden)
+	_, err = client.restoreAdRevision(ad.Data.ID, 2, moder.Token)
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	// История уходит вместе с объявлением
+	_, err = client.deleteAd(ad.Data.ID, token.Token)
+	assert.NoError(t, err)
+	_, err = client.listAdRevisions(ad.Data.ID, 0, 0, token.Token)
+	assert.ErrorIs(t, err, ErrBadRequest)
+}
+
+func TestAdRestoreChecks(t *testing.T) {
+	client, users := getTestClientWithUsers()
+
+	admin, err := client.createUser("Admin", "Admin", "admin", "abacaba", "admin@phystech.edu", "891428821XX")
+	assert.NoError(t, err)
+	assert.NoError(t, users.UpdateRole(admin.Data.ID, ads.RoleAdmin))
+	_, err = client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	assert.NoError(t, err)
+
+	adminToken, err := client.loginUser("admin", "abacaba")
+	assert.NoError(t, err)
+	token, err := client.loginUser("skyberg11", "abacaba")
+	assert.NoError(t, err)
+
+	category, err := client.createCategory("Велосипеды", 0, adminToken.Token)
+	assert.NoError(t, err)
+
+	ad, err := client.createAdInCategory("Велосипед", "Почти новый", category.Data.ID, token.Token)
+	assert.NoError(t, err)
+	_, err = client.updateAd(ad.Data.ID, "Велосипед горный", "Почти новый", token.Token)
+	assert.NoError(t, err)
+
+	// Ревизия в удалённой категории не возвращается и новой ревизии не оставляет
+	var moved adResponse
+	err = client.do(http.MethodPut, fmt.Sprintf("/ads/%d", ad.Data.ID), map[string]any{"title": "Велосипед горный", "text": "Почти новый", "category_id": ads.DefaultCategoryID}, token.Token, &moved)
+	assert.NoError(t, err)
+	assert.NoError(t, client.deleteCategory(category.Data.ID, adminToken.Token))
+	_, err = client.restoreAdRevision(ad.Data.ID, 1, token.Token)
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	list, err := client.listAdRevisions(ad.Data.ID, 0, 0, token.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{3, 2, 1}, revisionNumbers(list.Data))
+
+	// Проданное объявление не меняется, в том числе возвратом
+	_, err = client.transitionAd(ad.Data.ID, string(ads.StatusPublished), token.Token)
+	assert.NoError(t, err)
+	_, err = client.transitionAd(ad.Data.ID, string(ads.StatusSold), token.Token)
+	assert.NoError(t, err)
+	_, err = client.restoreAdRevision(ad.Data.ID, 2, token.Token)
+	assert.ErrorIs(t, err, ErrBadRequest)
+}
+
+func TestUpdateValidatesBeforeWrite(t *testing.T) {
+	stores, index := newTestStores()
+	repo, users := stores.Ads, stores.Users
+	a := app.NewApp(stores, app.WithSearchIndex(index), app.WithPasswordHasher(cheapHasher), publishUnflagged)
+	client := getTestClientFor(a)
+
+	user, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	assert.NoError(t, err)
+	token, err := client.loginUser("skyberg11", "abacaba")
+	assert.NoError(t, err)
+
+	ad, err := client.createAd("Велосипед", "Почти новый", token.Token)
+	assert.NoError(t, err)
+
+	// Неверная правка отклоняется до записи: ни изменений, ни ревизии, ни события
+	_, err = client.updateAd(ad.Data.ID, strings.Repeat("a", 101), "Почти новый", token.Token)
+	assert.ErrorIs(t, err, ErrBadRequest)
+	_, err = client.updateAd(ad.Data.ID, "Велосипед", "", token.Token)
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	got, err := client.getAdAs(ad.Data.ID, token.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, "Велосипед", got.Data.Title)
+	assert.Equal(t, "Почти новый", got.Data.Text)
+
+	list, err := client.listAdRevisions(ad.Data.ID, 0, 0, token.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1}, revisionNumbers(list.Data))
+
+	events, err := repo.PendingEvents(0, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, ads.EventAdCreated, events[0].Type)
+	}
+
+	_, err = client.updateUser("", "Zykov", "new@phystech.edu", "891428821XX", user.Data.ID, token.Token)
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	saved, err := client.getUser(user.Data.ID, token.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, "Timur", saved.Data.FirstName)
+	assert.Equal(t, "zykov.ta@phystech.edu", saved.Data.Email)
+
+	events, err = users.PendingEvents(0, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, ads.EventUserCreated, events[0].Type)
+	}
+}
+
+func TestGRPCAdRevisions(t *testing.T) {
+	client, ctx := getGRPCTestClient(t)
+
+	_, err := client.CreateUser(ctx, &service.CreateUserRequest{FirstName: "Oleg", SecondName: "Ivanov", Nickname: "oleg",
+		Password: "abacaba", Email: "abacaba@aba.ru", Phone: "+79821233123"})
+	assert.NoError(t, err)
+	login, err := client.Login(ctx, &service.LoginRequest{Nickname: "oleg", Password: "abacaba"})
+	assert.NoError(t, err)
+	authed := withToken(ctx, login.Token)
+
+	ad, err := client.CreateAd(authed, &service.CreateAdRequest{Title: "Велосипед", Text: "Почти новый",
+		Location: &service.Location{Latitude: 55.75, Longitude: 37.62, City: "Москва"}})
+	assert.NoError(t, err)
+	_, err = client.UpdateAd(authed, &service.UpdateAdRequest{AdId: ad.Id, Title: "Самокат", Text: "Почти новый"})
+	assert.NoError(t, err)
+
+	list, err := client.ListAdRevisions(authed, &service.ListAdRevisionsRequest{AdId: ad.Id})
+	assert.NoError(t, err)
+	if assert.Len(t, list.List, 2) {
+		assert.Equal(t, int64(2), list.List[0].Number)
+		assert.Equal(t, "Самокат", list.List[0].Title)
+		assert.Equal(t, "Москва", list.List[1].Location.City)
+	}
+
+	diff, err := client.DiffAdRevisions(authed, &service.DiffAdRevisionsRequest{AdId: ad.Id, From: 2, To: 1})
+	assert.NoError(t, err)
+	if assert.Len(t, diff.Changes, 1) {
+		assert.Equal(t, "title", diff.Changes[0].Field)
+		assert.JSONEq(t, `"Самокат"`, diff.Changes[0].From)
+		assert.JSONEq(t, `"Велосипед"`, diff.Changes[0].To)
+	}
+
+	restored, err := client.RestoreAdRevision(authed, &service.AdRevisionRequest{AdId: ad.Id, Number: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, "Велосипед", restored.Title)
+
+	rev, err := client.GetAdRevision(authed, &service.AdRevisionRequest{AdId: ad.Id, Number: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), rev.RestoredFrom)
+
+	_, err = client.GetAdRevision(authed, &service.AdRevisionRequest{AdId: ad.Id, Number: 4})
+	assert.ErrorIs(t, fromStatus(err), ErrBadRequest)
+	_, err = client.ListAdRevisions(context.Background(), &service.ListAdRevisionsRequest{AdId: ad.Id})
+	assert.ErrorIs(t, fromStatus(err), ErrUnauthorized)
+}
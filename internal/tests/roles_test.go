@@ -0,0 +1,152 @@
+package tests
+
+import (
+	"testing"
+
+	"adflow/internal/ads"
+	"adflow/internal/app"
+	"adflow/internal/app/auth"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModeratorActions(t *testing.T) {
+	client, users := getTestClientWithUsers()
+
+	_, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	assert.NoError(t, err)
+	moderator, err := client.createUser("Andrew", "Ivanov", "moder", "12345678", "arr@mail.ru", "+79821233123")
+	assert.NoError(t, err)
+	assert.Equal(t, "user", moderator.Data.Role)
+	assert.NoError(t, users.UpdateRole(moderator.Data.ID, ads.RoleModerator))
+
+	author, err := client.loginUser("skyberg11", "abacaba")
+	assert.NoError(t, err)
+	moder, err := client.loginUser("moder", "12345678")
+	assert.NoError(t, err)
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(moder.Token, &auth.Claims{})
+	assert.NoError(t, err)
+	assert.Equal(t, "moderator", parsed.Claims.(*auth.Claims).Role)
+
+	ad, err := client.createAd("hello", "world", author.Token)
+	assert.NoError(t, err)
+	_, err = client.changeAdStatus(ad.Data.ID, true, author.Token)
+	assert.NoError(t, err)
+
+	unpublished, err := client.changeAdStatus(ad.Data.ID, false, moder.Token)
+	assert.NoError(t, err)
+	assert.False(t, unpublished.Data.Published)
+
+	// Публиковать и редактировать чужие объявления модератор не может
+	_, err = client.changeAdStatus(ad.Data.ID, true, moder.Token)
+	assert.ErrorIs(t, err, ErrForbidden)
+	_, err = client.updateAd(ad.Data.ID, "bye", "world", moder.Token)
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	_, err = client.deleteAd(ad.Data.ID, moder.Token)
+	assert.NoError(t, err)
+
+	_, err = client.getAd(ad.Data.ID)
+	assert.ErrorIs(t, err, ErrBadRequest)
+}
+
+func TestAdminManagesUsers(t *testing.T) {
+	client, users := getTestClientWithUsers()
+
+	admin, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	assert.NoError(t, err)
+	assert.NoError(t, users.UpdateRole(admin.Data.ID, ads.RoleAdmin))
+
+	user, err := client.createUser("Andrew", "Ivanov", "abacaba", "12345678", "arr@mail.ru", "+79821233123")
+	assert.NoError(t, err)
+
+	adminToken, err := client.loginUser("skyberg11", "abacaba")
+	assert.NoError(t, err)
+	userToken, err := client.loginUser("abacaba", "12345678")
+	assert.NoError(t, err)
+
+	_, err = client.setUserRole(user.Data.ID, "admin", userToken.Token)
+	assert.ErrorIs(t, err, ErrForbidden)
+	_, err = client.setUserRole(user.Data.ID, "admin", "")
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	_, err = client.setUserRole(user.Data.ID, "superuser", adminToken.Token)
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	promoted, err := client.setUserRole(user.Data.ID, "moderator", adminToken.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, "moderator", promoted.Data.Role)
+
+	// Модератор не управляет пользователями
+	_, err = client.updateUser("Timur", "Zykov", "new@mail.ru", "123", admin.Data.ID, userToken.Token)
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	updated, err := client.updateUser("Andrew", "Petrov", "arr@mail.ru", "+79821233123", user.Data.ID, adminToken.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, "Petrov", updated.Data.SecondName)
+
+	// Контакты пользователя администратору видны, модератору - нет
+	got, err := client.getUser(user.Data.ID, adminToken.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, "arr@mail.ru", got.Data.Email)
+	got, err = client.getUser(admin.Data.ID, userToken.Token)
+	assert.NoError(t, err)
+	assert.Empty(t, got.Data.Email)
+	assert.Empty(t, got.Data.Phone)
+
+	_, err = client.deleteUser(user.Data.ID, adminToken.Token)
+	assert.NoError(t, err)
+
+	_, err = client.getUser(user.Data.ID, "")
+	assert.ErrorIs(t, err, ErrBadRequest)
+}
+
+func TestRolePolicy(t *testing.T) {
+	policy := app.DefaultPolicy
+	owner := &ads.User{ID: 1}
+	legacy := &ads.User{ID: 2}
+	moderator := &ads.User{ID: 3, Role: ads.RoleModerator}
+	admin := &ads.User{ID: 4, Role: ads.RoleAdmin}
+
+	assert.NoError(t, policy.Authorize(nil, app.ActionReadAd, 1))
+	assert.ErrorIs(t, policy.Authorize(nil, app.ActionCreateAd, 0), ads.ErrUnauthorized)
+	assert.NoError(t, policy.Authorize(legacy, app.ActionCreateAd, 0))
+
+	assert.NoError(t, policy.Authorize(owner, app.ActionUpdateAd, 1))
+	assert.ErrorIs(t, policy.Authorize(legacy, app.ActionUpdateAd, 1), ads.ErrAccessDenied)
+	assert.ErrorIs(t, policy.Authorize(admin, app.ActionUpdateAd, 1), ads.ErrAccessDenied)
+
+	assert.NoError(t, policy.Authorize(moderator, app.ActionUnpublishAd, 1))
+	assert.NoError(t, policy.Authorize(admin, app.ActionDeleteAd, 1))
+	assert.ErrorIs(t, policy.Authorize(moderator, app.ActionDeleteUser, 1), ads.ErrAccessDenied)
+	assert.NoError(t, policy.Authorize(admin, app.ActionDeleteUser, 1))
+
+	assert.ErrorIs(t, policy.Authorize(admin, app.Action("ad:unknown"), 1), ads.ErrAccessDenied)
+}
+
+func TestContactsHiddenWithoutChangingStoredUser(t *testing.T) {
+	// Хранилище в памяти держит указатели на пользователей, скрытие контактов не должно их затирать
+	stores, index := openTestStores("memory://", "memory://")
+	client := getTestClientFor(app.NewApp(stores, app.WithSearchIndex(index), app.WithPasswordHasher(cheapHasher)))
+
+	user, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	assert.NoError(t, err)
+	_, err = client.createUser("Andrew", "Ivanov", "abacaba", "12345678", "arr@mail.ru", "+79821233123")
+	assert.NoError(t, err)
+
+	ownerToken, err := client.loginUser("skyberg11", "abacaba")
+	assert.NoError(t, err)
+	strangerToken, err := client.loginUser("abacaba", "12345678")
+	assert.NoError(t, err)
+
+	got, err := client.getUser(user.Data.ID, strangerToken.Token)
+	assert.NoError(t, err)
+	assert.Empty(t, got.Data.Email)
+	assert.Empty(t, got.Data.Phone)
+
+	got, err = client.getUser(user.Data.ID, ownerToken.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, "zykov.ta@phystech.edu", got.Data.Email)
+	assert.Equal(t, "891428821XX", got.Data.Phone)
+}
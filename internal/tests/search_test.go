@@ -0,0 +1,166 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"adflow/internal/adapters"
+	"adflow/internal/ads"
+	"adflow/internal/app"
+	"adflow/internal/search"
+)
+
+var searchCorpus = []*ads.Ad{
+	{ID: 1, Title: "Продаю велосипед", Text: "Горный велосипед Stels, рама 18 дюймов"},
+	{ID: 2, Title: "Детское кресло", Text: "Кресло для велосипедов и колясок, крепление на раму"},
+	{ID: 3, Title: "Mountain bikes for sale", Text: "Two bikes in good condition, running smoothly"},
+	{ID: 4, Title: "Котёнок", Text: "Отдам котёнка в добрые руки"},
+}
+
+func searchIDs(hits []search.Hit) []int64 {
+	var ids []int64
+	for _, hit := range hits {
+		ids = append(ids, hit.ID)
+	}
+	return ids
+}
+
+func testSearchIndex(t *testing.T, index app.SearchIndex) {
+	for _, ad := range searchCorpus {
+		assert.NoError(t, index.Index(ad))
+	}
+
+	// Совпадение в заголовке важнее совпадения только в тексте
+	hits, err := index.Search("велосипеды", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2}, searchIDs(hits))
+	assert.Greater(t, hits[0].Score, hits[1].Score)
+
+	hits, err = index.Search("BIKE run", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{3}, searchIDs(hits))
+
+	// Все слова запроса должны встретиться в объявлении
+	hits, err = index.Search("велосипед кресло", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{2}, searchIDs(hits))
+
+	hits, err = index.Search("котенок", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{4}, searchIDs(hits))
+
+	hits, err = index.Search("велосипед", 1, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{2}, searchIDs(hits))
+
+	hits, err = index.Search("и в на", 0, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, hits)
+
+	// Переиндексация заменяет документ, удаление убирает его из выдачи
+	assert.NoError(t, index.Index(&ads.Ad{ID: 1, Title: "Продаю самокат", Text: "Почти новый"}))
+	assert.NoError(t, index.Remove(2))
+
+	hits, err = index.Search("велосипед", 0, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, hits)
+
+	hits, err = index.Search("самокат", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1}, searchIDs(hits))
+}
+
+func TestSearchIndex(t *testing.T) {
+	t.Run("memory", func(t *testing.T) {
+		testSearchIndex(t, search.NewMemoryIndex())
+	})
+
+	t.Run("backend", func(t *testing.T) {
+		_, index := newTestAds()
+		testSearchIndex(t, index)
+	})
+}
+
+// Индекс базы ранжирует так же, как индекс в памяти
+func TestSearchScoresMatchMemory(t *testing.T) {
+	_, adsDSN := testDSNs()
+	if backend, _ := adapters.Backend(adsDSN); backend != adapters.BackendSQLite {
+		t.Skip("scores are compared for SQLite only")
+	}
+
+	memory := search.NewMemoryIndex()
+	_, index := newTestAds()
+	for _, ad := range searchCorpus {
+		assert.NoError(t, memory.Index(ad))
+		assert.NoError(t, index.Index(ad))
+	}
+
+	for _, query := range []string{"велосипед", "bikes", "рама велосипеда", "котёнок"} {
+		expected, err := memory.Search(query, 0, 0)
+		assert.NoError(t, err)
+		actual, err := index.Search(query, 0, 0)
+		assert.NoError(t, err)
+
+		assert.Equal(t, searchIDs(expected), searchIDs(actual), query)
+		for i := range expected {
+			assert.InDelta(t, expected[i].Score, actual[i].Score, 1e-9, query)
+		}
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	snippet := search.Snippet("Горный велосипед <Stels> & шлем", "велосипеды")
+	assert.Equal(t, "Горный <mark>велосипед</mark> &lt;Stels&gt; &amp; шлем", snippet)
+}
+
+func TestParitySearch(t *testing.T) {
+	forEachTransport(t, func(t *testing.T, tr transport) {
+		_, err := tr.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+		assert.NoError(t, err)
+		token, err := tr.loginUser("skyberg11", "abacaba")
+		assert.NoError(t, err)
+
+		bike, err := tr.createAd("Продаю велосипед", "Горный велосипед, почти новый", token)
+		assert.NoError(t, err)
+		draft, err := tr.createAd("Велосипед", "Черновик", token)
+		assert.NoError(t, err)
+		_, err = tr.changeAdStatus(bike.ID, true, token)
+		assert.NoError(t, err)
+
+		// Черновики в поиск не попадают
+		list, err := tr.searchAds("велосипеды", 0, 0)
+		assert.NoError(t, err)
+		assert.Len(t, list, 1)
+		assert.Equal(t, bike.ID, list[0].ID)
+		assert.Equal(t, "Горный <mark>велосипед</mark>, почти новый", list[0].Snippet)
+		assert.Greater(t, list[0].Score, 0.0)
+
+		_, err = tr.changeAdStatus(draft.ID, true, token)
+		assert.NoError(t, err)
+		_, err = tr.updateAd(bike.ID, "Продаю самокат", "Почти новый", token)
+		assert.NoError(t, err)
+
+		list, err = tr.searchAds("велосипед", 0, 0)
+		assert.NoError(t, err)
+		assert.Len(t, list, 1)
+		assert.Equal(t, draft.ID, list[0].ID)
+
+		_, err = tr.changeAdStatus(draft.ID, false, token)
+		assert.NoError(t, err)
+
+		list, err = tr.searchAds("велосипед", 0, 0)
+		assert.NoError(t, err)
+		assert.Empty(t, list)
+
+		err = tr.deleteAd(bike.ID, token)
+		assert.NoError(t, err)
+
+		list, err = tr.searchAds("самокат", 0, 0)
+		assert.NoError(t, err)
+		assert.Empty(t, list)
+
+		_, err = tr.searchAds("самокат", -1, 0)
+		assert.ErrorIs(t, err, ErrBadRequest)
+	})
+}
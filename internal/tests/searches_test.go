@@ -0,0 +1,358 @@
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"mime"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"adflow/internal/ads"
+	"adflow/internal/app"
+	"adflow/internal/notify"
+	"adflow/internal/outbox"
+	service "adflow/internal/ports/grpc/service"
+)
+
+func mailSubject(t *testing.T, mail mailData) string {
+	header, err := textproto.NewReader(bufio.NewReader(strings.NewReader(mail.Data))).ReadMIMEHeader()
+	assert.NoError(t, err)
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(header.Get("Subject"))
+	assert.NoError(t, err)
+	return subject
+}
+
+// processNotifications делает то же, что relay и RunNotifications в сервисе: передаёт события публикации
+// сопоставлению с сохранёнными поисками и отправляет заведённые уведомления. Возвращает время отправки.
+func processNotifications(t *testing.T, a app.App, stores app.Stores) time.Time {
+	relay := outbox.NewRelay([]outbox.Source{{Name: "ads", Outbox: stores.Ads}}, notify.NewMatcher(a))
+	_, err := relay.Flush(context.Background())
+	assert.NoError(t, err)
+
+	now := time.Now().UTC()
+	_, err = a.DeliverNotifications(context.Background(), now)
+	assert.NoError(t, err)
+	return now
+}
+
+// newSearchesApp - приложение с заданной доставкой уведомлений и его хранилища
+func newSearchesApp(notifier app.Notifier) (app.App, app.Stores) {
+	stores, index := newTestStores()
+	a := app.NewApp(stores, app.WithSearchIndex(index), app.WithPasswordHasher(cheapHasher), publishUnflagged,
+		app.WithNotifier(notifier))
+	return a, stores
+}
+
+func TestSavedSearches(t *testing.T) {
+	mail := newSMTPServer(t)
+	a, stores := newSearchesApp(notify.NewSMTP(notify.SMTPConfig{Addr: mail.Addr, From: "adflow@localhost"}))
+	client := getTestClientFor(a)
+
+	seller, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	assert.NoError(t, err)
+	sellerToken, err := client.loginUser("skyberg11", "abacaba")
+	assert.NoError(t, err)
+	buyer, err := client.createUser("Other", "User", "buyer", "abacaba", "buyer@phystech.edu", "891428821XX")
+	assert.NoError(t, err)
+	buyerToken, err := client.loginUser("buyer", "abacaba")
+	assert.NoError(t, err)
+
+	_, err = client.createSavedSearch(buyer.Data.ID, "  ", searchFilter{}, buyerToken.Token)
+	assert.ErrorIs(t, err, ErrBadRequest)
+	_, err = client.createSavedSearch(buyer.Data.ID, "Цены", searchFilter{MinPrice: new(int64)}, buyerToken.Token)
+	assert.ErrorIs(t, err, ErrBadRequest)
+	_, err = client.createSavedSearch(buyer.Data.ID, "Рядом", searchFilter{RadiusKm: 5}, buyerToken.Token)
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	search, err := client.createSavedSearch(buyer.Data.ID, " Самокаты ", searchFilter{TitleContains: "Самокат", Near: "55.75,37.61"}, buyerToken.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, "Самокаты", search.Data.Name)
+	assert.Equal(t, "55.75,37.61", search.Data.Filter.Near)
+
+	// Поиски видит и меняет только владелец
+	_, err = client.listSavedSearches(buyer.Data.ID, sellerToken.Token)
+	assert.ErrorIs(t, err, ErrForbidden)
+	_, err = client.listSavedSearches(buyer.Data.ID, "")
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	_, err = client.updateSavedSearch(seller.Data.ID, search.Data.ID, "Чужой", searchFilter{}, sellerToken.Token)
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	search, err = client.updateSavedSearch(buyer.Data.ID, search.Data.ID, "Велосипеды", searchFilter{TitleContains: "Велосипед"}, buyerToken.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, "Велосипеды", search.Data.Name)
+	assert.Equal(t, "Велосипед", search.Data.Filter.TitleContains)
+	assert.Empty(t, search.Data.Filter.Near)
+
+	searches, err := client.listSavedSearches(buyer.Data.ID, buyerToken.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, []savedSearchData{search.Data}, searches.Data)
+
+	// Автор не получает уведомлений о своих объявлениях
+	_, err = client.createSavedSearch(seller.Data.ID, "Свои", searchFilter{TitleContains: "Велосипед"}, sellerToken.Token)
+	assert.NoError(t, err)
+
+	bike, err := client.createAd("Велосипед горный", "Почти новый", sellerToken.Token)
+	assert.NoError(t, err)
+	scooter, err := client.createAd("Самокат", "Электрический", sellerToken.Token)
+	assert.NoError(t, err)
+
+	// Черновик не подходит никому
+	notifications, err := client.listNotifications(buyer.Data.ID, 0, 0, buyerToken.Token)
+	assert.NoError(t, err)
+	assert.Empty(t, notifications.Data)
+
+	for _, id := range []int64{bike.Data.ID, scooter.Data.ID} {
+		_, err = client.changeAdStatus(id, true, sellerToken.Token)
+		assert.NoError(t, err)
+	}
+
+	// Публикация только пишет событие, поиски сопоставляются уже из outbox
+	notifications, err = client.listNotifications(buyer.Data.ID, 0, 0, buyerToken.Token)
+	assert.NoError(t, err)
+	assert.Empty(t, notifications.Data)
+	processNotifications(t, a, stores)
+
+	notifications, err = client.listNotifications(buyer.Data.ID, 0, 0, buyerToken.Token)
+	assert.NoError(t, err)
+	assert.Len(t, notifications.Data, 1)
+	assert.Equal(t, bike.Data.ID, notifications.Data[0].AdID)
+	assert.Equal(t, search.Data.ID, notifications.Data[0].SearchID)
+	assert.Equal(t, "Велосипед горный", notifications.Data[0].Title)
+	assert.NotNil(t, notifications.Data[0].DeliveryTime)
+	assert.Empty(t, notifications.Data[0].Error)
+
+	notifications, err = client.listNotifications(seller.Data.ID, 0, 0, sellerToken.Token)
+	assert.NoError(t, err)
+	assert.Empty(t, notifications.Data)
+
+	mails := mail.Mails()
+	assert.Len(t, mails, 1)
+	assert.Equal(t, "adflow@localhost", mails[0].From)
+	assert.Equal(t, []string{"buyer@phystech.edu"}, mails[0].To)
+	assert.Equal(t, "Новое объявление по запросу «Велосипеды»", mailSubject(t, mails[0]))
+	assert.Contains(t, mails[0].Data, "Велосипед горный")
+
+	// Повторная публикация не дублирует уведомление
+	_, err = client.changeAdStatus(bike.Data.ID, false, sellerToken.Token)
+	assert.NoError(t, err)
+	_, err = client.changeAdStatus(bike.Data.ID, true, sellerToken.Token)
+	assert.NoError(t, err)
+	processNotifications(t, a, stores)
+	notifications, err = client.listNotifications(buyer.Data.ID, 0, 0, buyerToken.Token)
+	assert.NoError(t, err)
+	assert.Len(t, notifications.Data, 1)
+	assert.Len(t, mail.Mails(), 1)
+
+	// Удалённый поиск больше не уведомляет
+	_, err = client.deleteSavedSearch(buyer.Data.ID, search.Data.ID, buyerToken.Token)
+	assert.NoError(t, err)
+	_, err = client.deleteSavedSearch(buyer.Data.ID, search.Data.ID, buyerToken.Token)
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	other, err := client.createAd("Велосипед детский", "С боковыми колёсами", sellerToken.Token)
+	assert.NoError(t, err)
+	_, err = client.changeAdStatus(other.Data.ID, true, sellerToken.Token)
+	assert.NoError(t, err)
+	processNotifications(t, a, stores)
+	notifications, err = client.listNotifications(buyer.Data.ID, 0, 0, buyerToken.Token)
+	assert.NoError(t, err)
+	assert.Len(t, notifications.Data, 1)
+
+	searches, err = client.listSavedSearches(buyer.Data.ID, buyerToken.Token)
+	assert.NoError(t, err)
+	assert.Empty(t, searches.Data)
+}
+
+func TestSavedSearchDeliveryError(t *testing.T) {
+	// Адрес, на котором никто не слушает
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := lis.Addr().String()
+	lis.Close()
+
+	a, stores := newSearchesApp(notify.NewSMTP(notify.SMTPConfig{Addr: addr, From: "adflow@localhost"}))
+	client := getTestClientFor(a)
+
+	_, err = client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	assert.NoError(t, err)
+	seller, err := client.loginUser("skyberg11", "abacaba")
+	assert.NoError(t, err)
+	buyer, err := client.createUser("Other", "User", "buyer", "abacaba", "buyer@phystech.edu", "891428821XX")
+	assert.NoError(t, err)
+	buyerToken, err := client.loginUser("buyer", "abacaba")
+	assert.NoError(t, err)
+
+	_, err = client.createSavedSearch(buyer.Data.ID, "Всё", searchFilter{}, buyerToken.Token)
+	assert.NoError(t, err)
+
+	// Сбой почты не мешает публикации, а причина остаётся в уведомлении
+	ad, err := client.createAd("Велосипед", "Горный", seller.Token)
+	assert.NoError(t, err)
+	published, err := client.changeAdStatus(ad.Data.ID, true, seller.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, "published", published.Data.Status)
+	processNotifications(t, a, stores)
+
+	notifications, err := client.listNotifications(buyer.Data.ID, 0, 0, buyerToken.Token)
+	assert.NoError(t, err)
+	assert.Len(t, notifications.Data, 1)
+	assert.Nil(t, notifications.Data[0].DeliveryTime)
+	assert.NotEmpty(t, notifications.Data[0].Error)
+}
+
+func TestGRPCSavedSearches(t *testing.T) {
+	var logged bytes.Buffer
+	a, stores := newSearchesApp(notify.Log{Logger: log.New(&logged, "", 0)})
+	client, ctx := getGRPCTestClientFor(t, a)
+	tr := &grpcTransport{client: client, ctx: ctx}
+
+	_, err := tr.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	assert.NoError(t, err)
+	seller, err := tr.loginUser("skyberg11", "abacaba")
+	assert.NoError(t, err)
+	buyer, err := tr.createUser("Other", "User", "buyer", "abacaba", "buyer@phystech.edu", "891428821XX")
+	assert.NoError(t, err)
+	buyerToken, err := tr.loginUser("buyer", "abacaba")
+	assert.NoError(t, err)
+
+	_, err = client.CreateSavedSearch(withToken(ctx, buyerToken), &service.CreateSavedSearchRequest{
+		UserId: buyer.ID,
+		Name:   "Дешёвые",
+		Filter: &service.Filter{MinPrice: "abc", Currency: "RUB"},
+	})
+	assert.ErrorIs(t, fromStatus(err), ErrBadRequest)
+
+	search, err := client.CreateSavedSearch(withToken(ctx, buyerToken), &service.CreateSavedSearchRequest{
+		UserId: buyer.ID,
+		Name:   "Дешёвые",
+		Filter: &service.Filter{MaxPrice: "20000", Currency: "rub", Published: "false"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "20000", search.Filter.MaxPrice)
+	assert.Equal(t, "RUB", search.Filter.Currency)
+	assert.Empty(t, search.Filter.Published)
+
+	_, err = client.ListSavedSearches(withToken(ctx, seller), &service.ListSavedSearchesRequest{UserId: buyer.ID})
+	assert.ErrorIs(t, fromStatus(err), ErrForbidden)
+
+	search, err = client.UpdateSavedSearch(withToken(ctx, buyerToken), &service.UpdateSavedSearchRequest{
+		UserId:   buyer.ID,
+		SearchId: search.Id,
+		Name:     "До 10000",
+		Filter:   &service.Filter{MaxPrice: "10000", Currency: "RUB"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "До 10000", search.Name)
+
+	list, err := client.ListSavedSearches(withToken(ctx, buyerToken), &service.ListSavedSearchesRequest{UserId: buyer.ID})
+	assert.NoError(t, err)
+	assert.Len(t, list.List, 1)
+	assert.Equal(t, "10000", list.List[0].Filter.MaxPrice)
+
+	cheap, err := tr.createPricedAd("Велосипед", "Горный", adPrice{Price: price(9000), Currency: "RUB"}, seller)
+	assert.NoError(t, err)
+	expensive, err := tr.createPricedAd("Самокат", "Электрический", adPrice{Price: price(30000), Currency: "RUB"}, seller)
+	assert.NoError(t, err)
+	for _, id := range []int64{cheap.ID, expensive.ID} {
+		_, err = tr.changeAdStatus(id, true, seller)
+		assert.NoError(t, err)
+	}
+	processNotifications(t, a, stores)
+
+	notifications, err := client.ListNotifications(withToken(ctx, buyerToken), &service.ListNotificationsRequest{UserId: buyer.ID})
+	assert.NoError(t, err)
+	assert.Len(t, notifications.List, 1)
+	assert.Equal(t, cheap.ID, notifications.List[0].AdId)
+	assert.NotEmpty(t, notifications.List[0].DeliveryTime)
+	assert.Contains(t, logged.String(), "buyer@phystech.edu")
+
+	_, err = client.DeleteSavedSearch(withToken(ctx, buyerToken), &service.DeleteSavedSearchRequest{UserId: buyer.ID, SearchId: search.Id})
+	assert.NoError(t, err)
+	_, err = client.ListNotifications(ctx, &service.ListNotificationsRequest{UserId: buyer.ID})
+	assert.ErrorIs(t, fromStatus(err), ErrUnauthorized)
+}
+
+// flakyNotifier отказывает первые fails раз, потом запоминает доставленные уведомления
+type flakyNotifier struct {
+	fails int
+	sent  []int64
+	m     sync.Mutex
+}
+
+func (n *flakyNotifier) Notify(_ context.Context, _ *ads.User, notification *ads.Notification) error {
+	n.m.Lock()
+	defer n.m.Unlock()
+
+	if n.fails > 0 {
+		n.fails--
+		return errors.New("mail server is down")
+	}
+	n.sent = append(n.sent, notification.AdID)
+	return nil
+}
+
+func TestSavedSearchDeliveryRetry(t *testing.T) {
+	notifier := &flakyNotifier{fails: 2}
+	a, stores := newSearchesApp(notifier)
+	client := getTestClientFor(a)
+
+	_, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	assert.NoError(t, err)
+	seller, err := client.loginUser("skyberg11", "abacaba")
+	assert.NoError(t, err)
+	buyer, err := client.createUser("Other", "User", "buyer", "abacaba", "buyer@phystech.edu", "891428821XX")
+	assert.NoError(t, err)
+	buyerToken, err := client.loginUser("buyer", "abacaba")
+	assert.NoError(t, err)
+
+	_, err = client.createSavedSearch(buyer.Data.ID, "Всё", searchFilter{}, buyerToken.Token)
+	assert.NoError(t, err)
+	ad, err := client.createAd("Велосипед", "Горный", seller.Token)
+	assert.NoError(t, err)
+	_, err = client.changeAdStatus(ad.Data.ID, true, seller.Token)
+	assert.NoError(t, err)
+
+	now := processNotifications(t, a, stores)
+
+	notifications, err := client.listNotifications(buyer.Data.ID, 0, 0, buyerToken.Token)
+	assert.NoError(t, err)
+	assert.Len(t, notifications.Data, 1)
+	assert.Nil(t, notifications.Data[0].DeliveryTime)
+	assert.Equal(t, "mail server is down", notifications.Data[0].Error)
+
+	// До срока повтора уведомление не отправляется, затем паузы растут: минута, две
+	n, err := a.DeliverNotifications(context.Background(), now.Add(30*time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+	n, err = a.DeliverNotifications(context.Background(), now.Add(2*time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+	n, err = a.DeliverNotifications(context.Background(), now.Add(3*time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+	n, err = a.DeliverNotifications(context.Background(), now.Add(4*time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []int64{ad.Data.ID}, notifier.sent)
+
+	notifications, err = client.listNotifications(buyer.Data.ID, 0, 0, buyerToken.Token)
+	assert.NoError(t, err)
+	assert.NotNil(t, notifications.Data[0].DeliveryTime)
+	assert.Empty(t, notifications.Data[0].Error)
+
+	// Доставленное уведомление больше не отправляется
+	n, err = a.DeliverNotifications(context.Background(), now.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+	assert.Len(t, notifier.sent, 1)
+}
@@ -0,0 +1,251 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"adflow/internal/ads"
+	"adflow/internal/app"
+	"adflow/internal/app/auth"
+)
+
+func TestAdStatusTransitions(t *testing.T) {
+	allowed := [][2]ads.Status{
+		{ads.StatusDraft, ads.StatusPendingReview},
+		{ads.StatusPendingReview, ads.StatusPublished},
+		{ads.StatusPendingReview, ads.StatusDraft},
+		{ads.StatusPublished, ads.StatusExpired},
+		{ads.StatusPublished, ads.StatusSold},
+		{ads.StatusExpired, ads.StatusPublished},
+		{ads.StatusExpired, ads.StatusArchived},
+		{ads.StatusSold, ads.StatusArchived},
+	}
+	for _, v := range allowed {
+		assert.True(t, v[0].CanTransition(v[1]), v)
+	}
+
+	forbidden := [][2]ads.Status{
+		{ads.StatusDraft, ads.StatusExpired},
+		{ads.StatusDraft, ads.StatusSold},
+		{ads.StatusSold, ads.StatusPublished},
+		{ads.StatusArchived, ads.StatusPublished},
+		{ads.StatusArchived, ads.StatusDraft},
+		{ads.StatusPublished, ads.StatusPublished},
+		{ads.StatusPublished, ads.StatusPendingReview},
+	}
+	for _, v := range forbidden {
+		assert.False(t, v[0].CanTransition(v[1]), v)
+	}
+
+	list, err := ads.ParseStatuses("published, sold")
+	assert.NoError(t, err)
+	assert.Equal(t, []ads.Status{ads.StatusPublished, ads.StatusSold}, list)
+	_, err = ads.ParseStatuses("published,gone")
+	assert.ErrorIs(t, err, ads.ErrBadRequest)
+
+	// Записи до появления статусов
+	assert.Equal(t, ads.StatusPublished, (&ads.Ad{Published: true}).AdStatus())
+	assert.Equal(t, ads.StatusDraft, (&ads.Ad{}).AdStatus())
+}
+
+func TestParityAdStatus(t *testing.T) {
+	forEachTransport(t, func(t *testing.T, tr transport) {
+		user, err := tr.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+		assert.NoError(t, err)
+		token, err := tr.loginUser("skyberg11", "abacaba")
+		assert.NoError(t, err)
+		_, err = tr.createUser("Other", "User", "other", "abacaba", "other@phystech.edu", "891428821XX")
+		assert.NoError(t, err)
+		other, err := tr.loginUser("other", "abacaba")
+		assert.NoError(t, err)
+
+		ad, err := tr.createAd("Велосипед", "Горный", token)
+		assert.NoError(t, err)
+		assert.Equal(t, "draft", ad.Status)
+		assert.Nil(t, ad.ExpiresAt)
+
+		ad, err = tr.transitionAd(ad.ID, "pending_review", token)
+		assert.NoError(t, err)
+		assert.Equal(t, "pending_review", ad.Status)
+		assert.False(t, ad.Published)
+
+		// Одобряет модератор, а не автор
+		_, err = tr.transitionAd(ad.ID, "published", token)
+		assert.ErrorIs(t, err, ErrForbidden)
+
+		ad, err = tr.transitionAd(ad.ID, "draft", token)
+		assert.NoError(t, err)
+
+		before := time.Now().Add(ads.PublicationTTL - time.Minute)
+		ad, err = tr.transitionAd(ad.ID, "published", token)
+		assert.NoError(t, err)
+		assert.Equal(t, "published", ad.Status)
+		assert.True(t, ad.Published)
+		assert.NotNil(t, ad.ExpiresAt)
+		assert.True(t, ad.ExpiresAt.After(before))
+
+		ad, err = tr.renewAd(ad.ID, token)
+		assert.NoError(t, err)
+		assert.Equal(t, "published", ad.Status)
+		_, err = tr.renewAd(ad.ID, other)
+		assert.ErrorIs(t, err, ErrForbidden)
+
+		draft, err := tr.createAd("Самокат", "Электрический", token)
+		assert.NoError(t, err)
+
+		list, err := tr.filterAds(adFilter{Statuses: []string{"published"}}, "")
+		assert.NoError(t, err)
+		assert.Len(t, list, 1)
+		assert.Equal(t, ad.ID, list[0].ID)
+
+		_, err = tr.transitionAd(ad.ID, "sold", other)
+		assert.ErrorIs(t, err, ErrForbidden)
+		ad, err = tr.transitionAd(ad.ID, "sold", token)
+		assert.NoError(t, err)
+		assert.False(t, ad.Published)
+
+		// Проданное не публикуется снова, не продлевается и не редактируется
+		_, err = tr.transitionAd(ad.ID, "published", token)
+		assert.ErrorIs(t, err, ErrBadRequest)
+		_, err = tr.changeAdStatus(ad.ID, true, token)
+		assert.ErrorIs(t, err, ErrBadRequest)
+		_, err = tr.renewAd(ad.ID, token)
+		assert.ErrorIs(t, err, ErrBadRequest)
+		_, err = tr.updateAd(ad.ID, "Велосипед", "Продан", token)
+		assert.ErrorIs(t, err, ErrBadRequest)
+
+		ad, err = tr.transitionAd(ad.ID, "archived", token)
+		assert.NoError(t, err)
+		assert.Equal(t, "archived", ad.Status)
+
+		// Черновик и архив видит только автор
+		list, err = tr.filterAds(adFilter{Statuses: []string{"draft", "archived"}}, "")
+		assert.NoError(t, err)
+		assert.Empty(t, list)
+		list, err = tr.filterAds(adFilter{Statuses: []string{"draft", "archived"}, AuthorIDs: []int64{user.ID}}, token)
+		assert.NoError(t, err)
+		assert.Len(t, list, 2)
+
+		// В expired переводит только планировщик
+		_, err = tr.transitionAd(draft.ID, "expired", token)
+		assert.ErrorIs(t, err, ErrBadRequest)
+		_, err = tr.transitionAd(draft.ID, "unknown", token)
+		assert.ErrorIs(t, err, ErrBadRequest)
+		_, err = tr.filterAds(adFilter{Statuses: []string{"unknown"}}, "")
+		assert.ErrorIs(t, err, ErrBadRequest)
+	})
+}
+
+func TestModeratorApprovesAd(t *testing.T) {
+	client, users := getTestClientWithUsers()
+
+	_, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	assert.NoError(t, err)
+	moderator, err := client.createUser("Andrew", "Ivanov", "moder", "12345678", "arr@mail.ru", "+79821233123")
+	assert.NoError(t, err)
+	assert.NoError(t, users.UpdateRole(moderator.Data.ID, ads.RoleModerator))
+
+	author, err := client.loginUser("skyberg11", "abacaba")
+	assert.NoError(t, err)
+	moder, err := client.loginUser("moder", "12345678")
+	assert.NoError(t, err)
+
+	ad, err := client.createAd("hello", "world", author.Token)
+	assert.NoError(t, err)
+
+	// Отправить на проверку может только автор
+	_, err = client.transitionAd(ad.Data.ID, "pending_review", moder.Token)
+	assert.ErrorIs(t, err, ErrForbidden)
+	_, err = client.transitionAd(ad.Data.ID, "pending_review", author.Token)
+	assert.NoError(t, err)
+
+	approved, err := client.transitionAd(ad.Data.ID, "published", moder.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, "published", approved.Data.Status)
+	assert.NotNil(t, approved.Data.ExpiresAt)
+
+	archived, err := client.transitionAd(ad.Data.ID, "archived", moder.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, "archived", archived.Data.Status)
+}
+
+func TestExpireAds(t *testing.T) {
+	stores, _ := newTestStores()
+	a := app.NewApp(stores, app.WithPasswordHasher(cheapHasher), app.WithAdTTL(time.Hour), publishUnflagged)
+
+	user, err := a.CreateUser(context.Background(), "Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	assert.NoError(t, err)
+	ctx := auth.NewContext(context.Background(), auth.Principal{UserID: user.ID, Role: ads.RoleUser})
+
+	var published []*ads.Ad
+	for _, title := range []string{"first", "second", "third"} {
+		ad, err := a.CreateAd(ctx, ads.Draft{Title: title, Text: "text"})
+		assert.NoError(t, err)
+		ad, err = a.ChangeAdStatus(ctx, ad.ID, true)
+		assert.NoError(t, err)
+		published = append(published, ad)
+	}
+	draft, err := a.CreateAd(ctx, ads.Draft{Title: "draft", Text: "text"})
+	assert.NoError(t, err)
+
+	n, err := a.ExpireAds(ctx, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	later := time.Now().Add(2 * time.Hour)
+	n, err = a.ExpireAds(ctx, later)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	expired, err := a.GetAd(ctx, published[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, ads.StatusExpired, expired.Status)
+	assert.False(t, expired.Published)
+
+	// Снятые объявления пропадают из поиска
+	results, err := a.SearchAds(ctx, "text", 10, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+
+	// Продлённое объявление публикуется снова, остальные через ArchiveAfter уходят в архив
+	renewed, err := a.RenewAd(ctx, published[1].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, ads.StatusPublished, renewed.Status)
+	assert.True(t, renewed.ExpiresAt.After(time.Now()))
+
+	n, err = a.ExpireAds(ctx, time.Now().Add(30*time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	// Через ArchiveAfter продлённое снова истекает и сразу архивируется вместе с двумя другими
+	n, err = a.ExpireAds(ctx, later.Add(ads.ArchiveAfter))
+	assert.NoError(t, err)
+	assert.Equal(t, 4, n)
+
+	list, _, err := a.ListAds(ctx, ads.Filter{Statuses: []ads.Status{ads.StatusArchived}, AuthorIDs: []int64{user.ID}}, ads.Page{})
+	assert.NoError(t, err)
+	assert.Len(t, list, 3)
+
+	_, err = a.RenewAd(ctx, published[0].ID)
+	assert.ErrorIs(t, err, ads.ErrBadRequest)
+
+	got, err := a.GetAd(ctx, draft.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, ads.StatusDraft, got.Status)
+}
+
+// Планировщик останавливается вместе с контекстом
+func TestRunScheduler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- app.RunScheduler(ctx, newTestApp(), time.Millisecond)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	assert.NoError(t, <-done)
+}
@@ -0,0 +1,263 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"adflow/internal/app"
+	service "adflow/internal/ports/grpc/service"
+	"adflow/internal/pubsub"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type receiptData struct {
+	ConversationID int64 `json:"conversation_id"`
+	ReaderID       int64 `json:"reader_id"`
+	UpToID         int64 `json:"up_to_id"`
+}
+
+type eventData struct {
+	ID      int64        `json:"id"`
+	Topic   string       `json:"topic"`
+	Type    string       `json:"type"`
+	Ad      *adData      `json:"ad"`
+	Message *messageData `json:"message"`
+	Receipt *receiptData `json:"receipt"`
+}
+
+func (tc *testClient) dialStream(token string, topics ...string) (*websocket.Conn, error) {
+	query := url.Values{"topic": topics}
+	header := http.Header{}
+	if token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+
+	conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(tc.baseURL, "http")+"/api/v1/stream?"+query.Encode(), header)
+	if err != nil && resp != nil {
+		switch resp.StatusCode {
+		case http.StatusBadRequest:
+			return nil, ErrBadRequest
+		case http.StatusForbidden:
+			return nil, ErrForbidden
+		case http.StatusUnauthorized:
+			return nil, ErrUnauthorized
+		}
+	}
+	return conn, err
+}
+
+func readEvent(t *testing.T, conn *websocket.Conn) eventData {
+	var event eventData
+	assert.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	assert.NoError(t, conn.ReadJSON(&event))
+	return event
+}
+
+func TestHubDropsSlowConsumer(t *testing.T) {
+	hub := pubsub.NewHub(2)
+	slow := hub.Subscribe(pubsub.AdTopic(1))
+	fast := hub.Subscribe(pubsub.AdTopic(1), pubsub.UserTopic(1))
+	defer fast.Close()
+
+	for i := 0; i < 3; i++ {
+		hub.Publish(pubsub.Event{Topic: pubsub.AdTopic(1), Type: pubsub.EventAdUpdated})
+		event := <-fast.Events()
+		assert.Equal(t, int64(i+1), event.ID)
+	}
+
+	// Издатель не ждёт: третье событие не влезло, и подписчика отключили
+	<-slow.Done()
+	assert.ErrorIs(t, slow.Err(), pubsub.ErrSlowConsumer)
+	assert.Len(t, slow.Events(), 2)
+
+	hub.Publish(pubsub.Event{Topic: pubsub.UserTopic(1), Type: pubsub.EventMessage})
+	event := <-fast.Events()
+	assert.Equal(t, pubsub.UserTopic(1), event.Topic)
+	assert.Equal(t, int64(4), event.ID)
+
+	fast.Close()
+	assert.NoError(t, fast.Err())
+
+	_, _, err := pubsub.ParseTopic("ads/abc")
+	assert.Error(t, err)
+	_, _, err = pubsub.ParseTopic("orders/1")
+	assert.Error(t, err)
+}
+
+func TestStreamWebSocket(t *testing.T) {
+	client, _ := getTestClientWithUsers()
+
+	seller, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	assert.NoError(t, err)
+	sellerToken, err := client.loginUser("skyberg11", "abacaba")
+	assert.NoError(t, err)
+	buyer, err := client.createUser("Other", "User", "buyer", "abacaba", "buyer@phystech.edu", "891428821XX")
+	assert.NoError(t, err)
+	buyerToken, err := client.loginUser("buyer", "abacaba")
+	assert.NoError(t, err)
+
+	ad, err := client.createAd("Велосипед", "Почти новый", sellerToken.Token)
+	assert.NoError(t, err)
+	draft, err := client.createAd("Черновик", "Не опубликован", sellerToken.Token)
+	assert.NoError(t, err)
+	_, err = client.changeAdStatus(ad.Data.ID, true, sellerToken.Token)
+	assert.NoError(t, err)
+
+	// Права проверяются до установки соединения
+	_, err = client.dialStream("", pubsub.UserTopic(seller.Data.ID))
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	_, err = client.dialStream(buyerToken.Token, pubsub.UserTopic(seller.Data.ID))
+	assert.ErrorIs(t, err, ErrForbidden)
+	_, err = client.dialStream(buyerToken.Token, pubsub.AdTopic(draft.Data.ID))
+	assert.ErrorIs(t, err, ErrForbidden)
+	_, err = client.dialStream(buyerToken.Token, "ads/first")
+	assert.ErrorIs(t, err, ErrBadRequest)
+	_, err = client.dialStream(buyerToken.Token)
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	sellerConn, err := client.dialStream(sellerToken.Token, pubsub.UserTopic(seller.Data.ID), pubsub.AdTopic(draft.Data.ID))
+	assert.NoError(t, err)
+	defer sellerConn.Close()
+	buyerConn, err := client.dialStream(buyerToken.Token, pubsub.UserTopic(buyer.Data.ID))
+	assert.NoError(t, err)
+	defer buyerConn.Close()
+	// Тему опубликованного объявления слушает и аноним
+	anonConn, err := client.dialStream("", pubsub.AdTopic(ad.Data.ID))
+	assert.NoError(t, err)
+	defer anonConn.Close()
+
+	message, err := client.contactAuthor(ad.Data.ID, "Здравствуйте, ещё продаёте?", buyerToken.Token)
+	assert.NoError(t, err)
+	for _, conn := range []*websocket.Conn{sellerConn, buyerConn} {
+		event := readEvent(t, conn)
+		assert.Equal(t, string(pubsub.EventMessage), event.Type)
+		if assert.NotNil(t, event.Message) {
+			assert.Equal(t, message.Data.ID, event.Message.ID)
+			assert.Equal(t, "Здравствуйте, ещё продаёте?", event.Message.Text)
+		}
+	}
+
+	_, err = client.markRead(message.Data.ConversationID, 0, sellerToken.Token)
+	assert.NoError(t, err)
+	event := readEvent(t, buyerConn)
+	assert.Equal(t, string(pubsub.EventMessagesRead), event.Type)
+	if assert.NotNil(t, event.Receipt) {
+		assert.Equal(t, seller.Data.ID, event.Receipt.ReaderID)
+		assert.Equal(t, message.Data.ConversationID, event.Receipt.ConversationID)
+	}
+	assert.Equal(t, string(pubsub.EventMessagesRead), readEvent(t, sellerConn).Type)
+
+	// Снятое с публикации объявление слушатели видят без содержимого, автор - целиком
+	_, err = client.changeAdStatus(ad.Data.ID, false, sellerToken.Token)
+	assert.NoError(t, err)
+	event = readEvent(t, anonConn)
+	assert.Equal(t, string(pubsub.EventAdStatus), event.Type)
+	if assert.NotNil(t, event.Ad) {
+		assert.Equal(t, "draft", event.Ad.Status)
+		assert.Empty(t, event.Ad.Title)
+	}
+	event = readEvent(t, sellerConn)
+	assert.Equal(t, pubsub.UserTopic(seller.Data.ID), event.Topic)
+	if assert.NotNil(t, event.Ad) {
+		assert.Equal(t, "Велосипед", event.Ad.Title)
+	}
+
+	_, err = client.updateAd(draft.Data.ID, "Черновик", "Поправленный текст", sellerToken.Token)
+	assert.NoError(t, err)
+	topics := map[string]string{}
+	for i := 0; i < 2; i++ {
+		event := readEvent(t, sellerConn)
+		assert.Equal(t, string(pubsub.EventAdUpdated), event.Type)
+		topics[event.Topic] = event.Type
+	}
+	assert.Contains(t, topics, pubsub.AdTopic(draft.Data.ID))
+	assert.Contains(t, topics, pubsub.UserTopic(seller.Data.ID))
+}
+
+func TestStreamGRPC(t *testing.T) {
+	client, ctx := getGRPCTestClient(t)
+	tr := &grpcTransport{client: client, ctx: ctx}
+
+	seller, err := tr.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	assert.NoError(t, err)
+	sellerToken, err := tr.loginUser("skyberg11", "abacaba")
+	assert.NoError(t, err)
+	_, err = tr.createUser("Other", "User", "buyer", "abacaba", "buyer@phystech.edu", "891428821XX")
+	assert.NoError(t, err)
+	buyerToken, err := tr.loginUser("buyer", "abacaba")
+	assert.NoError(t, err)
+
+	ad, err := tr.createAd("Велосипед", "Почти новый", sellerToken)
+	assert.NoError(t, err)
+	_, err = tr.changeAdStatus(ad.ID, true, sellerToken)
+	assert.NoError(t, err)
+
+	forbidden, err := client.Subscribe(withToken(ctx, buyerToken), &service.SubscribeRequest{Topics: []string{pubsub.UserTopic(seller.ID)}})
+	assert.NoError(t, err)
+	_, err = forbidden.Recv()
+	assert.ErrorIs(t, fromStatus(err), ErrForbidden)
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	stream, err := client.Subscribe(withToken(streamCtx, sellerToken), &service.SubscribeRequest{Topics: []string{pubsub.UserTopic(seller.ID)}})
+	assert.NoError(t, err)
+	_, err = stream.Header()
+	assert.NoError(t, err)
+
+	message, err := tr.contactAuthor(ad.ID, "Здравствуйте, ещё продаёте?", buyerToken)
+	assert.NoError(t, err)
+
+	event, err := stream.Recv()
+	if assert.NoError(t, err) {
+		assert.Equal(t, string(pubsub.EventMessage), event.Type)
+		assert.Equal(t, pubsub.UserTopic(seller.ID), event.Topic)
+		assert.Equal(t, message.ID, event.Message.GetId())
+	}
+
+	err = tr.deleteAd(ad.ID, sellerToken)
+	assert.NoError(t, err)
+	event, err = stream.Recv()
+	if assert.NoError(t, err) {
+		assert.Equal(t, string(pubsub.EventAdDeleted), event.Type)
+		assert.Equal(t, ad.ID, event.Ad.GetId())
+	}
+
+	cancel()
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Canceled, status.Code(err))
+}
+
+func TestStreamEndsWhenHubClosed(t *testing.T) {
+	hub := pubsub.NewHub(pubsub.DefaultBuffer)
+	stores, index := newTestStores()
+	a := app.NewApp(stores, app.WithSearchIndex(index), app.WithPasswordHasher(cheapHasher), app.WithHub(hub))
+	client, ctx := getGRPCTestClientFor(t, a)
+	tr := &grpcTransport{client: client, ctx: ctx}
+
+	seller, err := tr.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	assert.NoError(t, err)
+	token, err := tr.loginUser("skyberg11", "abacaba")
+	assert.NoError(t, err)
+
+	stream, err := client.Subscribe(withToken(ctx, token), &service.SubscribeRequest{Topics: []string{pubsub.UserTopic(seller.ID)}})
+	assert.NoError(t, err)
+	_, err = stream.Header()
+	assert.NoError(t, err)
+
+	// Остановка сервиса закрывает шину: поток завершается сам, не дожидаясь клиента
+	hub.Close()
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	stream, err = client.Subscribe(withToken(ctx, token), &service.SubscribeRequest{Topics: []string{pubsub.UserTopic(seller.ID)}})
+	assert.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
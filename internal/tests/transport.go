@@ -0,0 +1,656 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	service "adflow/internal/ports/grpc/service"
+)
+
+// transport - общий интерфейс клиентов HTTP и gRPC,
+// позволяющий прогонять одни и те же сценарии через оба транспорта
+type transport interface {
+	createUser(first_name, second_name, nickname, password, email, phone string) (userSecureData, error)
+	loginUser(nickname, password string) (string, error)
+	getUser(id int64, token string) (userSecureData, error)
+	updateUser(first_name, second_name, email, phone string, id int64, token string) (userSecureData, error)
+	deleteUser(id int64, token string) error
+
+	createAd(title string, text string, token string) (adData, error)
+	createPricedAd(title, text string, price adPrice, token string) (adData, error)
+	updatePricedAd(adID int64, title, text string, price adPrice, token string) (adData, error)
+	createPlacedAd(title, text string, location *adLocation, token string) (adData, error)
+	updatePlacedAd(adID int64, title, text string, location *adLocation, token string) (adData, error)
+	getAd(id int64, token string) (adData, error)
+	changeAdStatus(adID int64, published bool, token string) (adData, error)
+	transitionAd(adID int64, status string, token string) (adData, error)
+	renewAd(adID int64, token string) (adData, error)
+	updateAd(adID int64, title string, text string, token string) (adData, error)
+	deleteAd(adID int64, token string) error
+	listAds(published bool, authorID any, titlePrefix any) ([]adData, error)
+	// Черновики и объявления на проверке в выдаче видят только автор и модераторы
+	filterAds(filter adFilter, token string) ([]adData, error)
+	pageAds(filter adFilter, token string) ([]adData, string, error)
+	searchAds(query string, limit, offset int) ([]searchResultData, error)
+
+	addFavorite(userID, adID int64, token string) (favoriteData, error)
+	removeFavorite(userID, adID int64, token string) error
+	listFavorites(userID, beforeID int64, limit int, token string) ([]favoriteData, error)
+
+	contactAuthor(adID int64, text string, token string) (messageData, error)
+	sendMessage(conversationID int64, text string, token string) (messageData, error)
+	listConversations(userID, adID, beforeID int64, limit int, token string) ([]conversationData, error)
+	listMessages(conversationID, beforeID int64, limit int, token string) ([]messageData, error)
+	markRead(conversationID, messageID int64, token string) (conversationData, error)
+	listInbox(userID, beforeID int64, limit int, token string) ([]inboxEntryData, error)
+}
+
+// adFilter - условия выборки, которые оба транспорта передают одинаково
+type adFilter struct {
+	AuthorIDs        []int64
+	ExcludeAuthorIDs []int64
+	TitleContains    string
+	Text             string
+	CreatedAfter     string
+	CreatedBefore    string
+	Currency         string
+	MinPrice         string
+	MaxPrice         string
+	Near             string
+	RadiusKm         string
+	Statuses         []string
+
+	Limit  int
+	Cursor string
+	Sort   string
+	Desc   bool
+}
+
+var transports = map[string]func(t *testing.T) transport{
+	"http": func(t *testing.T) transport {
+		return &httpTransport{getTestClient()}
+	},
+	"grpc": func(t *testing.T) transport {
+		client, ctx := getGRPCTestClient(t)
+		return &grpcTransport{client: client, ctx: ctx}
+	},
+}
+
+type httpTransport struct {
+	tc *testClient
+}
+
+func (h *httpTransport) createUser(first_name, second_name, nickname, password, email, phone string) (userSecureData, error) {
+	resp, err := h.tc.createUser(first_name, second_name, nickname, password, email, phone)
+	return resp.Data, err
+}
+
+func (h *httpTransport) loginUser(nickname, password string) (string, error) {
+	resp, err := h.tc.loginUser(nickname, password)
+	return resp.Token, err
+}
+
+func (h *httpTransport) getUser(id int64, token string) (userSecureData, error) {
+	resp, err := h.tc.getUser(id, token)
+	return resp.Data, err
+}
+
+func (h *httpTransport) updateUser(first_name, second_name, email, phone string, id int64, token string) (userSecureData, error) {
+	resp, err := h.tc.updateUser(first_name, second_name, email, phone, id, token)
+	return resp.Data, err
+}
+
+func (h *httpTransport) deleteUser(id int64, token string) error {
+	_, err := h.tc.deleteUser(id, token)
+	return err
+}
+
+func (h *httpTransport) createAd(title string, text string, token string) (adData, error) {
+	resp, err := h.tc.createAd(title, text, token)
+	return resp.Data, err
+}
+
+func (h *httpTransport) createPricedAd(title, text string, price adPrice, token string) (adData, error) {
+	resp, err := h.tc.createPricedAd(title, text, price, token)
+	return resp.Data, err
+}
+
+func (h *httpTransport) updatePricedAd(adID int64, title, text string, price adPrice, token string) (adData, error) {
+	resp, err := h.tc.updatePricedAd(adID, title, text, price, token)
+	return resp.Data, err
+}
+
+func (h *httpTransport) createPlacedAd(title, text string, location *adLocation, token string) (adData, error) {
+	resp, err := h.tc.createPlacedAd(title, text, location, token)
+	return resp.Data, err
+}
+
+func (h *httpTransport) updatePlacedAd(adID int64, title, text string, location *adLocation, token string) (adData, error) {
+	resp, err := h.tc.updatePlacedAd(adID, title, text, location, token)
+	return resp.Data, err
+}
+
+func (h *httpTransport) getAd(id int64, token string) (adData, error) {
+	resp, err := h.tc.getAdAs(id, token)
+	return resp.Data, err
+}
+
+func (h *httpTransport) transitionAd(adID int64, status string, token string) (adData, error) {
+	resp, err := h.tc.transitionAd(adID, status, token)
+	return resp.Data, err
+}
+
+func (h *httpTransport) renewAd(adID int64, token string) (adData, error) {
+	resp, err := h.tc.renewAd(adID, token)
+	return resp.Data, err
+}
+
+func (h *httpTransport) changeAdStatus(adID int64, published bool, token string) (adData, error) {
+	resp, err := h.tc.changeAdStatus(adID, published, token)
+	return resp.Data, err
+}
+
+func (h *httpTransport) updateAd(adID int64, title string, text string, token string) (adData, error) {
+	resp, err := h.tc.updateAd(adID, title, text, token)
+	return resp.Data, err
+}
+
+func (h *httpTransport) deleteAd(adID int64, token string) error {
+	_, err := h.tc.deleteAd(adID, token)
+	return err
+}
+
+func (h *httpTransport) listAds(published bool, authorID any, titlePrefix any) ([]adData, error) {
+	flag := 0
+	if published {
+		flag = 1
+	}
+	resp, err := h.tc.listAds(flag, authorID, titlePrefix, nil)
+	return resp.Data, err
+}
+
+func joinIDs(ids []int64) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.FormatInt(id, 10)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (h *httpTransport) filterAds(filter adFilter, token string) ([]adData, error) {
+	list, _, err := h.pageAds(filter, token)
+	return list, err
+}
+
+func (h *httpTransport) pageAds(filter adFilter, token string) ([]adData, string, error) {
+	query := url.Values{}
+	set := func(key, value string) {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	set("author", joinIDs(filter.AuthorIDs))
+	set("exclude_author", joinIDs(filter.ExcludeAuthorIDs))
+	set("title_contains", filter.TitleContains)
+	set("text", filter.Text)
+	set("created_after", filter.CreatedAfter)
+	set("created_before", filter.CreatedBefore)
+	set("currency", filter.Currency)
+	set("min_price", filter.MinPrice)
+	set("max_price", filter.MaxPrice)
+	set("status", strings.Join(filter.Statuses, ","))
+	set("near", filter.Near)
+	set("radius_km", filter.RadiusKm)
+	set("cursor", filter.Cursor)
+	set("sort", filter.Sort)
+	if filter.Limit != 0 {
+		query.Set("limit", strconv.Itoa(filter.Limit))
+	}
+	if filter.Desc {
+		query.Set("order", "desc")
+	}
+
+	resp, err := h.tc.filterAds(query, token)
+	return resp.Data, resp.NextCursor, err
+}
+
+func (h *httpTransport) searchAds(query string, limit, offset int) ([]searchResultData, error) {
+	resp, err := h.tc.searchAds(query, limit, offset)
+	return resp.Data, err
+}
+
+func (h *httpTransport) addFavorite(userID, adID int64, token string) (favoriteData, error) {
+	resp, err := h.tc.addFavorite(userID, adID, token)
+	return resp.Data, err
+}
+
+func (h *httpTransport) removeFavorite(userID, adID int64, token string) error {
+	_, err := h.tc.removeFavorite(userID, adID, token)
+	return err
+}
+
+func (h *httpTransport) listFavorites(userID, beforeID int64, limit int, token string) ([]favoriteData, error) {
+	resp, err := h.tc.listFavorites(userID, beforeID, limit, token)
+	return resp.Data, err
+}
+
+type grpcTransport struct {
+	client service.AdServiceClient
+	ctx    context.Context
+}
+
+func (h *httpTransport) contactAuthor(adID int64, text string, token string) (messageData, error) {
+	resp, err := h.tc.contactAuthor(adID, text, token)
+	return resp.Data, err
+}
+
+func (h *httpTransport) sendMessage(conversationID int64, text string, token string) (messageData, error) {
+	resp, err := h.tc.sendMessage(conversationID, text, token)
+	return resp.Data, err
+}
+
+func (h *httpTransport) listConversations(userID, adID, beforeID int64, limit int, token string) ([]conversationData, error) {
+	resp, err := h.tc.listConversations(userID, adID, beforeID, limit, token)
+	return resp.Data, err
+}
+
+func (h *httpTransport) listMessages(conversationID, beforeID int64, limit int, token string) ([]messageData, error) {
+	resp, err := h.tc.listMessages(conversationID, beforeID, limit, token)
+	return resp.Data, err
+}
+
+func (h *httpTransport) markRead(conversationID, messageID int64, token string) (conversationData, error) {
+	resp, err := h.tc.markRead(conversationID, messageID, token)
+	return resp.Data, err
+}
+
+func (h *httpTransport) listInbox(userID, beforeID int64, limit int, token string) ([]inboxEntryData, error) {
+	resp, err := h.tc.listInbox(userID, beforeID, limit, token)
+	return resp.Data, err
+}
+
+func adFromProto(ad *service.AdResponse) adData {
+	if ad == nil {
+		return adData{}
+	}
+	var location *adLocation
+	if loc := ad.Location; loc != nil {
+		location = &adLocation{Latitude: loc.Latitude, Longitude: loc.Longitude, City: loc.City}
+	}
+	var expiresAt *time.Time
+	if t, err := time.Parse(time.RFC3339, ad.ExpiresAt); err == nil {
+		expiresAt = &t
+	}
+	return adData{
+		ID:             ad.Id,
+		Title:          ad.Title,
+		Text:           ad.Text,
+		AuthorID:       ad.AuthorId,
+		Published:      ad.Published,
+		CategoryID:     ad.CategoryId,
+		Price:          ad.Price,
+		Currency:       ad.Currency,
+		Negotiable:     ad.Negotiable,
+		Location:       location,
+		Status:         ad.Status,
+		ExpiresAt:      expiresAt,
+		FavoritesCount: ad.FavoritesCount,
+	}
+}
+
+func userFromProto(user *service.UserResponse) userSecureData {
+	if user == nil {
+		return userSecureData{}
+	}
+	return userSecureData{
+		FirstName:  user.FirstName,
+		SecondName: user.SecondName,
+		Email:      user.Email,
+		Phone:      user.Phone,
+		ID:         user.Id,
+	}
+}
+
+func (g *grpcTransport) createUser(first_name, second_name, nickname, password, email, phone string) (userSecureData, error) {
+	resp, err := g.client.CreateUser(g.ctx, &service.CreateUserRequest{
+		FirstName:  first_name,
+		SecondName: second_name,
+		Nickname:   nickname,
+		Password:   password,
+		Email:      email,
+		Phone:      phone,
+	})
+	return userFromProto(resp), fromStatus(err)
+}
+
+func (g *grpcTransport) loginUser(nickname, password string) (string, error) {
+	resp, err := g.client.Login(g.ctx, &service.LoginRequest{Nickname: nickname, Password: password})
+	return resp.GetToken(), fromStatus(err)
+}
+
+func (g *grpcTransport) getUser(id int64, token string) (userSecureData, error) {
+	resp, err := g.client.GetUser(withToken(g.ctx, token), &service.GetUserRequest{Id: id})
+	return userFromProto(resp), fromStatus(err)
+}
+
+func (g *grpcTransport) updateUser(first_name, second_name, email, phone string, id int64, token string) (userSecureData, error) {
+	resp, err := g.client.UpdateUser(withToken(g.ctx, token), &service.UpdateUserRequest{
+		Id:         id,
+		FirstName:  first_name,
+		SecondName: second_name,
+		Email:      email,
+		Phone:      phone,
+	})
+	return userFromProto(resp), fromStatus(err)
+}
+
+func (g *grpcTransport) deleteUser(id int64, token string) error {
+	_, err := g.client.DeleteUser(withToken(g.ctx, token), &service.DeleteUserRequest{Id: id})
+	return fromStatus(err)
+}
+
+func (g *grpcTransport) createAd(title string, text string, token string) (adData, error) {
+	resp, err := g.client.CreateAd(withToken(g.ctx, token), &service.CreateAdRequest{Title: title, Text: text})
+	return adFromProto(resp), fromStatus(err)
+}
+
+func priceToProto(price adPrice) (*wrapperspb.Int64Value, *wrapperspb.BoolValue) {
+	var amount *wrapperspb.Int64Value
+	var negotiable *wrapperspb.BoolValue
+	if price.Price != nil {
+		amount = wrapperspb.Int64(*price.Price)
+	}
+	if price.Negotiable != nil {
+		negotiable = wrapperspb.Bool(*price.Negotiable)
+	}
+	return amount, negotiable
+}
+
+func (g *grpcTransport) createPricedAd(title, text string, price adPrice, token string) (adData, error) {
+	amount, negotiable := priceToProto(price)
+	resp, err := g.client.CreateAd(withToken(g.ctx, token), &service.CreateAdRequest{
+		Title:      title,
+		Text:       text,
+		Price:      amount,
+		Currency:   price.Currency,
+		Negotiable: negotiable,
+	})
+	return adFromProto(resp), fromStatus(err)
+}
+
+func (g *grpcTransport) updatePricedAd(adID int64, title, text string, price adPrice, token string) (adData, error) {
+	amount, negotiable := priceToProto(price)
+	resp, err := g.client.UpdateAd(withToken(g.ctx, token), &service.UpdateAdRequest{
+		AdId:       adID,
+		Title:      title,
+		Text:       text,
+		Price:      amount,
+		Currency:   price.Currency,
+		Negotiable: negotiable,
+	})
+	return adFromProto(resp), fromStatus(err)
+}
+
+func locationToProto(location *adLocation) *service.Location {
+	if location == nil {
+		return nil
+	}
+	return &service.Location{Latitude: location.Latitude, Longitude: location.Longitude, City: location.City}
+}
+
+func (g *grpcTransport) createPlacedAd(title, text string, location *adLocation, token string) (adData, error) {
+	resp, err := g.client.CreateAd(withToken(g.ctx, token), &service.CreateAdRequest{
+		Title:    title,
+		Text:     text,
+		Location: locationToProto(location),
+	})
+	return adFromProto(resp), fromStatus(err)
+}
+
+func (g *grpcTransport) updatePlacedAd(adID int64, title, text string, location *adLocation, token string) (adData, error) {
+	resp, err := g.client.UpdateAd(withToken(g.ctx, token), &service.UpdateAdRequest{
+		AdId:     adID,
+		Title:    title,
+		Text:     text,
+		Location: locationToProto(location),
+	})
+	return adFromProto(resp), fromStatus(err)
+}
+
+func (g *grpcTransport) getAd(id int64, token string) (adData, error) {
+	resp, err := g.client.GetAd(withToken(g.ctx, token), &service.GetAdRequest{Id: id})
+	return adFromProto(resp), fromStatus(err)
+}
+
+func (g *grpcTransport) changeAdStatus(adID int64, published bool, token string) (adData, error) {
+	resp, err := g.client.ChangeAdStatus(withToken(g.ctx, token), &service.ChangeAdStatusRequest{AdId: adID, Published: published})
+	return adFromProto(resp), fromStatus(err)
+}
+
+func (g *grpcTransport) transitionAd(adID int64, status string, token string) (adData, error) {
+	resp, err := g.client.ChangeAdStatus(withToken(g.ctx, token), &service.ChangeAdStatusRequest{AdId: adID, Status: status})
+	return adFromProto(resp), fromStatus(err)
+}
+
+func (g *grpcTransport) renewAd(adID int64, token string) (adData, error) {
+	resp, err := g.client.RenewAd(withToken(g.ctx, token), &service.RenewAdRequest{AdId: adID})
+	return adFromProto(resp), fromStatus(err)
+}
+
+func (g *grpcTransport) updateAd(adID int64, title string, text string, token string) (adData, error) {
+	resp, err := g.client.UpdateAd(withToken(g.ctx, token), &service.UpdateAdRequest{AdId: adID, Title: title, Text: text})
+	return adFromProto(resp), fromStatus(err)
+}
+
+func (g *grpcTransport) deleteAd(adID int64, token string) error {
+	_, err := g.client.DeleteAd(withToken(g.ctx, token), &service.DeleteAdRequest{AdId: adID})
+	return fromStatus(err)
+}
+
+func (g *grpcTransport) listAds(published bool, authorID any, titlePrefix any) ([]adData, error) {
+	filter := &service.Filter{Published: fmt.Sprint(published)}
+	if authorID != nil {
+		filter.AuthorId = fmt.Sprint(authorID)
+	}
+	if titlePrefix != nil {
+		filter.Prefix = fmt.Sprint(titlePrefix)
+	}
+
+	list, _, err := g.list("", filter)
+	return list, err
+}
+
+func (g *grpcTransport) filterAds(filter adFilter, token string) ([]adData, error) {
+	list, _, err := g.pageAds(filter, token)
+	return list, err
+}
+
+func (g *grpcTransport) pageAds(filter adFilter, token string) ([]adData, string, error) {
+	return g.list(token, &service.Filter{
+		AuthorIds:        filter.AuthorIDs,
+		ExcludeAuthorIds: filter.ExcludeAuthorIDs,
+		TitleContains:    filter.TitleContains,
+		Text:             filter.Text,
+		CreatedAfter:     filter.CreatedAfter,
+		CreatedBefore:    filter.CreatedBefore,
+		Currency:         filter.Currency,
+		MinPrice:         filter.MinPrice,
+		MaxPrice:         filter.MaxPrice,
+		Near:             filter.Near,
+		Statuses:         filter.Statuses,
+		RadiusKm:         filter.RadiusKm,
+		Limit:            int32(filter.Limit),
+		Cursor:           filter.Cursor,
+		Sort:             filter.Sort,
+		Desc:             filter.Desc,
+	})
+}
+
+func (g *grpcTransport) list(token string, filter *service.Filter) ([]adData, string, error) {
+	resp, err := g.client.ListAds(withToken(g.ctx, token), filter)
+	if err != nil {
+		return nil, "", fromStatus(err)
+	}
+
+	var list []adData
+	for _, ad := range resp.List {
+		list = append(list, adFromProto(ad))
+	}
+	return list, resp.NextCursor, nil
+}
+
+func (g *grpcTransport) searchAds(query string, limit, offset int) ([]searchResultData, error) {
+	resp, err := g.client.SearchAds(g.ctx, &service.SearchAdsRequest{Query: query, Limit: int32(limit), Offset: int32(offset)})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+
+	var list []searchResultData
+	for _, v := range resp.List {
+		list = append(list, searchResultData{adData: adFromProto(v.Ad), Score: v.Score, Snippet: v.Snippet})
+	}
+	return list, nil
+}
+
+func (g *grpcTransport) addFavorite(userID, adID int64, token string) (favoriteData, error) {
+	resp, err := g.client.AddFavorite(withToken(g.ctx, token), &service.FavoriteRequest{UserId: userID, AdId: adID})
+	if err != nil {
+		return favoriteData{}, fromStatus(err)
+	}
+	return favoriteData{ID: resp.Id, AdID: resp.AdId, Ad: adFromProto(resp.Ad)}, nil
+}
+
+func (g *grpcTransport) removeFavorite(userID, adID int64, token string) error {
+	_, err := g.client.RemoveFavorite(withToken(g.ctx, token), &service.FavoriteRequest{UserId: userID, AdId: adID})
+	return fromStatus(err)
+}
+
+func (g *grpcTransport) listFavorites(userID, beforeID int64, limit int, token string) ([]favoriteData, error) {
+	req := &service.ListFavoritesRequest{UserId: userID, BeforeId: beforeID, Limit: int32(limit)}
+	resp, err := g.client.ListFavorites(withToken(g.ctx, token), req)
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+
+	var list []favoriteData
+	for _, favorite := range resp.List {
+		list = append(list, favoriteData{ID: favorite.Id, AdID: favorite.AdId, Ad: adFromProto(favorite.Ad)})
+	}
+	return list, nil
+}
+
+func timeFromProto(value string) *time.Time {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
+func messageFromProto(message *service.Message) messageData {
+	if message == nil {
+		return messageData{}
+	}
+	data := messageData{
+		ID:             message.Id,
+		ConversationID: message.ConversationId,
+		SenderID:       message.SenderId,
+		Text:           message.Text,
+		ReadTime:       timeFromProto(message.ReadTime),
+	}
+	if t := timeFromProto(message.CreationTime); t != nil {
+		data.CreationTime = *t
+	}
+	return data
+}
+
+func conversationFromProto(conversation *service.Conversation) conversationData {
+	if conversation == nil {
+		return conversationData{}
+	}
+	data := conversationData{
+		ID:       conversation.Id,
+		AdID:     conversation.AdId,
+		AuthorID: conversation.AuthorId,
+		BuyerID:  conversation.BuyerId,
+		Unread:   conversation.Unread,
+	}
+	if conversation.LastMessage != nil {
+		message := messageFromProto(conversation.LastMessage)
+		data.LastMessage = &message
+	}
+	if conversation.Ad != nil {
+		ad := adFromProto(conversation.Ad)
+		data.Ad = &ad
+	}
+	return data
+}
+
+func (g *grpcTransport) contactAuthor(adID int64, text string, token string) (messageData, error) {
+	resp, err := g.client.ContactAuthor(withToken(g.ctx, token), &service.ContactAuthorRequest{AdId: adID, Text: text})
+	return messageFromProto(resp), fromStatus(err)
+}
+
+func (g *grpcTransport) sendMessage(conversationID int64, text string, token string) (messageData, error) {
+	resp, err := g.client.SendMessage(withToken(g.ctx, token), &service.SendMessageRequest{ConversationId: conversationID, Text: text})
+	return messageFromProto(resp), fromStatus(err)
+}
+
+func (g *grpcTransport) listConversations(userID, adID, beforeID int64, limit int, token string) ([]conversationData, error) {
+	req := &service.ListConversationsRequest{UserId: userID, AdId: adID, BeforeId: beforeID, Limit: int32(limit)}
+	resp, err := g.client.ListConversations(withToken(g.ctx, token), req)
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+
+	var list []conversationData
+	for _, conversation := range resp.List {
+		list = append(list, conversationFromProto(conversation))
+	}
+	return list, nil
+}
+
+func (g *grpcTransport) listMessages(conversationID, beforeID int64, limit int, token string) ([]messageData, error) {
+	req := &service.ListMessagesRequest{ConversationId: conversationID, BeforeId: beforeID, Limit: int32(limit)}
+	resp, err := g.client.ListMessages(withToken(g.ctx, token), req)
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+
+	var list []messageData
+	for _, message := range resp.List {
+		list = append(list, messageFromProto(message))
+	}
+	return list, nil
+}
+
+func (g *grpcTransport) markRead(conversationID, messageID int64, token string) (conversationData, error) {
+	resp, err := g.client.MarkRead(withToken(g.ctx, token), &service.MarkReadRequest{ConversationId: conversationID, MessageId: messageID})
+	return conversationFromProto(resp), fromStatus(err)
+}
+
+func (g *grpcTransport) listInbox(userID, beforeID int64, limit int, token string) ([]inboxEntryData, error) {
+	req := &service.ListInboxRequest{UserId: userID, BeforeId: beforeID, Limit: int32(limit)}
+	resp, err := g.client.ListInbox(withToken(g.ctx, token), req)
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+
+	var list []inboxEntryData
+	for _, v := range resp.List {
+		entry := inboxEntryData{AdID: v.AdId, LastMessageID: v.LastMessageId, Conversations: v.Conversations, Unread: v.Unread}
+		if v.Ad != nil {
+			ad := adFromProto(v.Ad)
+			entry.Ad = &ad
+		}
+		list = append(list, entry)
+	}
+	return list, nil
+}
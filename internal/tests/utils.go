@@ -3,28 +3,65 @@ package tests
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
 	"time"
 
 	"adflow/internal/adapters"
-	"adflow/internal/adapters/adrepo"
-	"adflow/internal/adapters/aduser"
 	"adflow/internal/ads"
 	"adflow/internal/app"
+	"adflow/internal/app/auth"
+	"adflow/internal/moderation"
 	"adflow/internal/ports/httpgin"
 )
 
 type adData struct {
-	ID           int64     `json:"id"`
-	Title        string    `json:"title"`
-	Text         string    `json:"text"`
-	AuthorID     int64     `json:"author_id"`
-	Published    bool      `json:"published"`
-	CreationTime time.Time `json:"creation_time"`
-	UpdateTime   time.Time `json:"update_time"`
+	ID             int64       `json:"id"`
+	Title          string      `json:"title"`
+	Text           string      `json:"text"`
+	AuthorID       int64       `json:"author_id"`
+	Published      bool        `json:"published"`
+	Status         string      `json:"status"`
+	ExpiresAt      *time.Time  `json:"expires_at"`
+	CreationTime   time.Time   `json:"creation_time"`
+	UpdateTime     time.Time   `json:"update_time"`
+	CategoryID     int64       `json:"category_id"`
+	Price          int64       `json:"price"`
+	Currency       string      `json:"currency"`
+	Negotiable     bool        `json:"negotiable"`
+	Location       *adLocation `json:"location"`
+	Images         []imageData `json:"images"`
+	FavoritesCount int64       `json:"favorites_count"`
+}
+
+type adLocation struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	City      string  `json:"city"`
+}
+
+type imageData struct {
+	ID           int64  `json:"id"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+	ContentType  string `json:"content_type"`
+	Size         int64  `json:"size"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+}
+
+// adPrice - цена в запросе на создание или изменение; незаданные поля не передаются
+type adPrice struct {
+	Price      *int64 `json:"price,omitempty"`
+	Currency   string `json:"currency,omitempty"`
+	Negotiable *bool  `json:"negotiable,omitempty"`
 }
 
 type userData struct {
@@ -44,6 +81,7 @@ type userSecureData struct {
 	Email      string `json:"email"`
 	Phone      string `json:"phone"`
 	ID         int64  `json:"user_id"`
+	Role       string `json:"role"`
 }
 
 type adResponse struct {
@@ -53,18 +91,254 @@ type userResponse struct {
 	Data userSecureData `json:"data"`
 }
 type userResponseToken struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
 }
 type adsResponse struct {
-	Data []adData `json:"data"`
+	Data       []adData `json:"data"`
+	NextCursor string   `json:"next_cursor"`
+}
+type searchResultData struct {
+	adData
+	Score   float64 `json:"score"`
+	Snippet string  `json:"snippet"`
+}
+
+type searchResponse struct {
+	Data []searchResultData `json:"data"`
+}
+
+type categoryData struct {
+	ID       int64  `json:"id"`
+	ParentID int64  `json:"parent_id"`
+	Name     string `json:"name"`
+}
+
+type categoryNodeData struct {
+	categoryData
+	AdCount  int64              `json:"ad_count"`
+	Children []categoryNodeData `json:"children"`
+}
+
+type categoryResponse struct {
+	Data categoryData `json:"data"`
+}
+
+type categoryTreeResponse struct {
+	Data []categoryNodeData `json:"data"`
+}
+
+type flagData struct {
+	Rule   string `json:"rule"`
+	Detail string `json:"detail"`
+}
+
+type reviewData struct {
+	ID           int64      `json:"id"`
+	AdID         int64      `json:"ad_id"`
+	Decision     string     `json:"decision"`
+	Flags        []flagData `json:"flags"`
+	Reason       string     `json:"reason"`
+	ModeratorID  int64      `json:"moderator_id"`
+	CreationTime time.Time  `json:"creation_time"`
+	DecisionTime *time.Time `json:"decision_time"`
+	Ad           *adData    `json:"ad"`
+}
+
+type reviewsResponse struct {
+	Data []reviewData `json:"data"`
+}
+
+type favoriteData struct {
+	ID           int64     `json:"id"`
+	AdID         int64     `json:"ad_id"`
+	CreationTime time.Time `json:"creation_time"`
+	Ad           adData    `json:"ad"`
+}
+
+type favoriteResponse struct {
+	Data favoriteData `json:"data"`
+}
+
+type favoritesResponse struct {
+	Data []favoriteData `json:"data"`
+}
+
+type searchFilter struct {
+	AuthorIDs     []int64 `json:"author,omitempty"`
+	CategoryIDs   []int64 `json:"category,omitempty"`
+	Title         string  `json:"title,omitempty"`
+	TitleContains string  `json:"title_contains,omitempty"`
+	Text          string  `json:"text,omitempty"`
+	Currency      string  `json:"currency,omitempty"`
+	MinPrice      *int64  `json:"min_price,omitempty"`
+	MaxPrice      *int64  `json:"max_price,omitempty"`
+	Near          string  `json:"near,omitempty"`
+	RadiusKm      float64 `json:"radius_km,omitempty"`
+}
+
+type savedSearchData struct {
+	ID           int64        `json:"id"`
+	Name         string       `json:"name"`
+	Filter       searchFilter `json:"filter"`
+	CreationTime time.Time    `json:"creation_time"`
+}
+
+type savedSearchResponse struct {
+	Data savedSearchData `json:"data"`
+}
+
+type savedSearchesResponse struct {
+	Data []savedSearchData `json:"data"`
+}
+
+type notificationData struct {
+	ID           int64      `json:"id"`
+	SearchID     int64      `json:"search_id"`
+	AdID         int64      `json:"ad_id"`
+	Title        string     `json:"title"`
+	CreationTime time.Time  `json:"creation_time"`
+	DeliveryTime *time.Time `json:"delivery_time"`
+	Error        string     `json:"error"`
+}
+
+type notificationsResponse struct {
+	Data []notificationData `json:"data"`
+}
+
+type webhookData struct {
+	ID     int64    `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Active bool     `json:"active"`
+	Secret string   `json:"secret"`
+}
+
+type webhookResponse struct {
+	Data webhookData `json:"data"`
+}
+
+type webhooksResponse struct {
+	Data []webhookData `json:"data"`
+}
+
+type webhookDeliveryData struct {
+	ID              int64           `json:"id"`
+	WebhookID       int64           `json:"webhook_id"`
+	Source          string          `json:"source"`
+	EventID         int64           `json:"event_id"`
+	EventType       string          `json:"event_type"`
+	Payload         json.RawMessage `json:"payload"`
+	Status          string          `json:"status"`
+	Attempts        int             `json:"attempts"`
+	NextAttemptTime *time.Time      `json:"next_attempt_time"`
+	ResponseStatus  int             `json:"response_status"`
+	Error           string          `json:"error"`
+	DeliveryTime    *time.Time      `json:"delivery_time"`
+}
+
+type webhookDeliveryResponse struct {
+	Data webhookDeliveryData `json:"data"`
+}
+
+type webhookDeliveriesResponse struct {
+	Data []webhookDeliveryData `json:"data"`
+}
+
+type revisionData struct {
+	Number       int64       `json:"number"`
+	AdID         int64       `json:"ad_id"`
+	AuthorID     int64       `json:"author_id"`
+	RestoredFrom int64       `json:"restored_from"`
+	CreationTime time.Time   `json:"creation_time"`
+	Title        string      `json:"title"`
+	Text         string      `json:"text"`
+	CategoryID   int64       `json:"category_id"`
+	Price        int64       `json:"price"`
+	Currency     string      `json:"currency"`
+	Negotiable   bool        `json:"negotiable"`
+	Location     *adLocation `json:"location"`
+}
+
+type revisionResponse struct {
+	Data revisionData `json:"data"`
+}
+
+type revisionsResponse struct {
+	Data []revisionData `json:"data"`
+}
+
+// from и to оставлены в JSON: тип значения зависит от поля
+type changeData struct {
+	Field string          `json:"field"`
+	From  json.RawMessage `json:"from"`
+	To    json.RawMessage `json:"to"`
+}
+
+type revisionDiffResponse struct {
+	Data struct {
+		From    int64        `json:"from"`
+		To      int64        `json:"to"`
+		Changes []changeData `json:"changes"`
+	} `json:"data"`
+}
+
+type messageData struct {
+	ID             int64      `json:"id"`
+	ConversationID int64      `json:"conversation_id"`
+	SenderID       int64      `json:"sender_id"`
+	Text           string     `json:"text"`
+	CreationTime   time.Time  `json:"creation_time"`
+	ReadTime       *time.Time `json:"read_time"`
+}
+
+type messageResponse struct {
+	Data messageData `json:"data"`
+}
+
+type messagesResponse struct {
+	Data []messageData `json:"data"`
+}
+
+type conversationData struct {
+	ID          int64        `json:"id"`
+	AdID        int64        `json:"ad_id"`
+	AuthorID    int64        `json:"author_id"`
+	BuyerID     int64        `json:"buyer_id"`
+	LastMessage *messageData `json:"last_message"`
+	Unread      int64        `json:"unread"`
+	Ad          *adData      `json:"ad"`
+}
+
+type conversationResponse struct {
+	Data conversationData `json:"data"`
+}
+
+type conversationsResponse struct {
+	Data []conversationData `json:"data"`
+}
+
+type inboxEntryData struct {
+	AdID          int64   `json:"ad_id"`
+	LastMessageID int64   `json:"last_message_id"`
+	Conversations int64   `json:"conversations"`
+	Unread        int64   `json:"unread"`
+	Ad            *adData `json:"ad"`
 }
+
+type inboxResponse struct {
+	Data []inboxEntryData `json:"data"`
+}
+
 type deleteResponse struct {
 	Data string `json:"data"`
 }
 
 var (
-	ErrBadRequest = fmt.Errorf("bad request")
-	ErrForbidden  = fmt.Errorf("forbidden")
+	ErrBadRequest   = fmt.Errorf("bad request")
+	ErrForbidden    = fmt.Errorf("forbidden")
+	ErrUnauthorized = fmt.Errorf("unauthorized")
 )
 
 type testClient struct {
@@ -72,24 +346,140 @@ type testClient struct {
 	baseURL string
 }
 
-func getTestClient() *testClient {
-	db_users, err := adapters.NewSQLite("test_users.db")
-	db_users.Migrator().DropTable(&ads.User{})
+// sqliteDir - временный каталог для баз SQLite, его создаёт TestMain
+var sqliteDir string
 
+// Бэкенд для тестов задаётся переменной TEST_DB_DSN (memory://, sqlite://<файл>, postgres://...),
+// по умолчанию - файлы SQLite во временном каталоге
+func testDSNs() (string, string) {
+	if dsn, ok := os.LookupEnv("TEST_DB_DSN"); ok {
+		return dsn, dsn
+	}
+	return "sqlite://" + filepath.Join(sqliteDir, "test_users.db"), "sqlite://" + filepath.Join(sqliteDir, "test_ads.db")
+}
+
+// Удаляет таблицы, чтобы каждый тест начинал с пустой базы
+func dropTables(dsn string, models ...any) {
+	db, err := adapters.Open(dsn)
+	if errors.Is(err, adapters.ErrNoDatabase) {
+		return
+	}
 	if err != nil {
 		panic(err)
 	}
 
-	db_ads, err := adapters.NewSQLite("test_ads.db")
-	db_ads.Migrator().DropTable(&ads.Ad{})
+	if err := db.Migrator().DropTable(models...); err != nil {
+		panic(err)
+	}
+
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+}
+
+// newTestStore очищает таблицы models и открывает на той же базе хранилище через open
+func newTestStore[T any](dsn string, models []any, open func(dsn string) (T, error)) T {
+	dropTables(dsn, models...)
 
+	store, err := open(dsn)
 	if err != nil {
 		panic(err)
 	}
 
-	repo, users := adrepo.NewSQLiteAds(db_ads), aduser.NewSQLiteUsers(db_users)
+	return store
+}
+
+type usersAndTokens struct {
+	users  app.Users
+	tokens app.Tokens
+}
 
-	server := httpgin.NewHTTPServer(":18080", app.NewApp(repo, users))
+type adsAndIndex struct {
+	repo  app.Repository
+	index app.SearchIndex
+}
+
+func newTestUsers() (app.Users, app.Tokens) {
+	usersDSN, _ := testDSNs()
+	return openTestUsers(usersDSN)
+}
+
+func openTestUsers(dsn string) (app.Users, app.Tokens) {
+	s := newTestStore(dsn, []any{&ads.User{}, &auth.RefreshToken{}, &auth.RevokedToken{}, &ads.OutboxRecord{}},
+		func(dsn string) (usersAndTokens, error) {
+			users, tokens, err := adapters.NewUsers(dsn)
+			return usersAndTokens{users, tokens}, err
+		})
+
+	return s.users, s.tokens
+}
+
+func newTestAds() (app.Repository, app.SearchIndex) {
+	_, adsDSN := testDSNs()
+	return openTestAds(adsDSN)
+}
+
+func openTestAds(dsn string) (app.Repository, app.SearchIndex) {
+	s := newTestStore(dsn, []any{&ads.Ad{}, &ads.Category{}, &ads.Image{}, &ads.Review{}, &ads.Revision{}, &ads.OutboxRecord{}, "ad_search"},
+		func(dsn string) (adsAndIndex, error) {
+			repo, index, err := adapters.NewAds(dsn)
+			return adsAndIndex{repo, index}, err
+		})
+
+	return s.repo, s.index
+}
+
+// newTestStores открывает все хранилища приложения на пустых тестовых базах
+func newTestStores() (app.Stores, app.SearchIndex) {
+	return openTestStores(testDSNs())
+}
+
+func openTestStores(usersDSN, adsDSN string) (app.Stores, app.SearchIndex) {
+	users, tokens := openTestUsers(usersDSN)
+	repo, index := openTestAds(adsDSN)
+
+	stores := app.Stores{
+		Ads:           repo,
+		Users:         users,
+		Tokens:        tokens,
+		Favorites:     newTestStore(adsDSN, []any{&ads.Favorite{}}, adapters.NewFavorites),
+		Searches:      newTestStore(usersDSN, []any{&ads.SavedSearch{}, &ads.Notification{}}, adapters.NewSavedSearches),
+		Conversations: newTestStore(adsDSN, []any{&ads.Conversation{}, &ads.Message{}}, adapters.NewConversations),
+		Webhooks:      newTestStore(adsDSN, []any{&ads.Webhook{}, &ads.WebhookDelivery{}}, adapters.NewWebhooks),
+	}
+
+	return stores, index
+}
+
+// Дешёвые параметры хеширования, чтобы не замедлять тесты
+var cheapHasher = auth.PasswordHasher{Time: 1, Memory: 8 * 1024, Threads: 1}
+
+// Большинство тестов проверяет не модерацию, поэтому объявления без замечаний правил публикуются сразу
+var publishUnflagged = app.WithModeration(app.ModerationFlagged, moderation.Default()...)
+
+func newTestApp() app.App {
+	stores, index := newTestStores()
+	return app.NewApp(stores, app.WithSearchIndex(index), app.WithPasswordHasher(cheapHasher), publishUnflagged)
+}
+
+func getTestClient() *testClient {
+	client, _ := getTestClientWithUsers()
+	return client
+}
+
+// Клиент вместе с хранилищем пользователей, чтобы тесты могли назначать роли напрямую.
+// opts заменяют настройки приложения по умолчанию.
+func getTestClientWithUsers(opts ...app.Option) (*testClient, app.Users) {
+	stores, index := newTestStores()
+	opts = append([]app.Option{app.WithSearchIndex(index), app.WithPasswordHasher(cheapHasher), publishUnflagged}, opts...)
+	a := app.NewApp(stores, opts...)
+
+	return getTestClientFor(a), stores.Users
+}
+
+// getTestClientFor поднимает HTTP-сервер поверх заданного приложения
+func getTestClientFor(a app.App) *testClient {
+	server := httpgin.NewHTTPServer(":18080", a)
 	testServer := httptest.NewServer(server.Handler())
 
 	return &testClient{
@@ -98,6 +488,12 @@ func getTestClient() *testClient {
 	}
 }
 
+func setToken(req *http.Request, token string) {
+	if token != "" {
+		req.Header.Add("Authorization", "Bearer "+token)
+	}
+}
+
 func (tc *testClient) getResponse(req *http.Request, out any) error {
 	resp, err := tc.client.Do(req)
 	if err != nil {
@@ -111,6 +507,9 @@ func (tc *testClient) getResponse(req *http.Request, out any) error {
 		if resp.StatusCode == http.StatusForbidden {
 			return ErrForbidden
 		}
+		if resp.StatusCode == http.StatusUnauthorized {
+			return ErrUnauthorized
+		}
 		return fmt.Errorf("unexpected status code: %s", resp.Status)
 	}
 
@@ -129,6 +528,325 @@ func (tc *testClient) getResponse(req *http.Request, out any) error {
 	return nil
 }
 
+// do отправляет JSON-запрос к API и разбирает ответ в out
+func (tc *testClient) do(method, path string, body any, token string, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("unable to marshal: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, tc.baseURL+"/api/v1"+path, reader)
+	if err != nil {
+		return fmt.Errorf("unable to create request: %w", err)
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+	setToken(req, token)
+
+	return tc.getResponse(req, out)
+}
+
+func (tc *testClient) createAdInCategory(title, text string, categoryID int64, token string) (adResponse, error) {
+	var response adResponse
+	err := tc.do(http.MethodPost, "/ads", map[string]any{"title": title, "text": text, "category_id": categoryID}, token, &response)
+	return response, err
+}
+
+type pricedAdRequest struct {
+	Title string `json:"title"`
+	Text  string `json:"text"`
+	adPrice
+}
+
+func (tc *testClient) createPricedAd(title, text string, price adPrice, token string) (adResponse, error) {
+	var response adResponse
+	err := tc.do(http.MethodPost, "/ads", pricedAdRequest{title, text, price}, token, &response)
+	return response, err
+}
+
+func (tc *testClient) updatePricedAd(adID int64, title, text string, price adPrice, token string) (adResponse, error) {
+	var response adResponse
+	err := tc.do(http.MethodPut, fmt.Sprintf("/ads/%d", adID), pricedAdRequest{title, text, price}, token, &response)
+	return response, err
+}
+
+type placedAdRequest struct {
+	Title    string      `json:"title"`
+	Text     string      `json:"text"`
+	Location *adLocation `json:"location,omitempty"`
+}
+
+func (tc *testClient) createPlacedAd(title, text string, location *adLocation, token string) (adResponse, error) {
+	var response adResponse
+	err := tc.do(http.MethodPost, "/ads", placedAdRequest{title, text, location}, token, &response)
+	return response, err
+}
+
+func (tc *testClient) updatePlacedAd(adID int64, title, text string, location *adLocation, token string) (adResponse, error) {
+	var response adResponse
+	err := tc.do(http.MethodPut, fmt.Sprintf("/ads/%d", adID), placedAdRequest{title, text, location}, token, &response)
+	return response, err
+}
+
+func (tc *testClient) transitionAd(adID int64, status string, token string) (adResponse, error) {
+	var response adResponse
+	err := tc.do(http.MethodPut, fmt.Sprintf("/ads/%d/status", adID), map[string]string{"status": status}, token, &response)
+	return response, err
+}
+
+func (tc *testClient) renewAd(adID int64, token string) (adResponse, error) {
+	var response adResponse
+	err := tc.do(http.MethodPost, fmt.Sprintf("/ads/%d/renew", adID), nil, token, &response)
+	return response, err
+}
+
+func (tc *testClient) moderationQueue(afterID int64, limit int, token string) (reviewsResponse, error) {
+	var response reviewsResponse
+	err := tc.do(http.MethodGet, fmt.Sprintf("/moderation/queue?after=%d&limit=%d", afterID, limit), nil, token, &response)
+	return response, err
+}
+
+func (tc *testClient) approveAd(adID int64, token string) (adResponse, error) {
+	var response adResponse
+	err := tc.do(http.MethodPost, fmt.Sprintf("/moderation/ads/%d/approve", adID), nil, token, &response)
+	return response, err
+}
+
+func (tc *testClient) rejectAd(adID int64, reason string, token string) (adResponse, error) {
+	var response adResponse
+	err := tc.do(http.MethodPost, fmt.Sprintf("/moderation/ads/%d/reject", adID), map[string]string{"reason": reason}, token, &response)
+	return response, err
+}
+
+func (tc *testClient) adReviews(adID int64, token string) (reviewsResponse, error) {
+	var response reviewsResponse
+	err := tc.do(http.MethodGet, fmt.Sprintf("/ads/%d/reviews", adID), nil, token, &response)
+	return response, err
+}
+
+func (tc *testClient) addFavorite(userID, adID int64, token string) (favoriteResponse, error) {
+	var response favoriteResponse
+	err := tc.do(http.MethodPost, fmt.Sprintf("/users/%d/favorites/%d", userID, adID), nil, token, &response)
+	return response, err
+}
+
+func (tc *testClient) removeFavorite(userID, adID int64, token string) (deleteResponse, error) {
+	var response deleteResponse
+	err := tc.do(http.MethodDelete, fmt.Sprintf("/users/%d/favorites/%d", userID, adID), nil, token, &response)
+	return response, err
+}
+
+func (tc *testClient) listFavorites(userID, beforeID int64, limit int, token string) (favoritesResponse, error) {
+	var response favoritesResponse
+	err := tc.do(http.MethodGet, fmt.Sprintf("/users/%d/favorites?before=%d&limit=%d", userID, beforeID, limit), nil, token, &response)
+	return response, err
+}
+
+type savedSearchRequest struct {
+	Name   string       `json:"name"`
+	Filter searchFilter `json:"filter"`
+}
+
+func (tc *testClient) createSavedSearch(userID int64, name string, filter searchFilter, token string) (savedSearchResponse, error) {
+	var response savedSearchResponse
+	err := tc.do(http.MethodPost, fmt.Sprintf("/users/%d/searches", userID), savedSearchRequest{name, filter}, token, &response)
+	return response, err
+}
+
+func (tc *testClient) listSavedSearches(userID int64, token string) (savedSearchesResponse, error) {
+	var response savedSearchesResponse
+	err := tc.do(http.MethodGet, fmt.Sprintf("/users/%d/searches", userID), nil, token, &response)
+	return response, err
+}
+
+func (tc *testClient) updateSavedSearch(userID, searchID int64, name string, filter searchFilter, token string) (savedSearchResponse, error) {
+	var response savedSearchResponse
+	err := tc.do(http.MethodPut, fmt.Sprintf("/users/%d/searches/%d", userID, searchID), savedSearchRequest{name, filter}, token, &response)
+	return response, err
+}
+
+func (tc *testClient) deleteSavedSearch(userID, searchID int64, token string) (deleteResponse, error) {
+	var response deleteResponse
+	err := tc.do(http.MethodDelete, fmt.Sprintf("/users/%d/searches/%d", userID, searchID), nil, token, &response)
+	return response, err
+}
+
+func (tc *testClient) listNotifications(userID, beforeID int64, limit int, token string) (notificationsResponse, error) {
+	var response notificationsResponse
+	err := tc.do(http.MethodGet, fmt.Sprintf("/users/%d/notifications?before=%d&limit=%d", userID, beforeID, limit), nil, token, &response)
+	return response, err
+}
+
+func (tc *testClient) createWebhook(url string, events []string, token string) (webhookResponse, error) {
+	var response webhookResponse
+	err := tc.do(http.MethodPost, "/webhooks", map[string]any{"url": url, "events": events}, token, &response)
+	return response, err
+}
+
+func (tc *testClient) listWebhooks(token string) (webhooksResponse, error) {
+	var response webhooksResponse
+	err := tc.do(http.MethodGet, "/webhooks", nil, token, &response)
+	return response, err
+}
+
+func (tc *testClient) updateWebhook(id int64, url string, events []string, active bool, token string) (webhookResponse, error) {
+	var response webhookResponse
+	err := tc.do(http.MethodPut, fmt.Sprintf("/webhooks/%d", id), map[string]any{"url": url, "events": events, "active": active}, token, &response)
+	return response, err
+}
+
+func (tc *testClient) deleteWebhook(id int64, token string) (deleteResponse, error) {
+	var response deleteResponse
+	err := tc.do(http.MethodDelete, fmt.Sprintf("/webhooks/%d", id), nil, token, &response)
+	return response, err
+}
+
+func (tc *testClient) listWebhookDeliveries(id int64, status string, token string) (webhookDeliveriesResponse, error) {
+	var response webhookDeliveriesResponse
+	err := tc.do(http.MethodGet, fmt.Sprintf("/webhooks/%d/deliveries?status=%s", id, status), nil, token, &response)
+	return response, err
+}
+
+func (tc *testClient) retryWebhookDelivery(id, deliveryID int64, token string) (webhookDeliveryResponse, error) {
+	var response webhookDeliveryResponse
+	err := tc.do(http.MethodPost, fmt.Sprintf("/webhooks/%d/deliveries/%d/retry", id, deliveryID), nil, token, &response)
+	return response, err
+}
+
+func (tc *testClient) listAdRevisions(adID, before int64, limit int, token string) (revisionsResponse, error) {
+	var response revisionsResponse
+	err := tc.do(http.MethodGet, fmt.Sprintf("/ads/%d/revisions?before=%d&limit=%d", adID, before, limit), nil, token, &response)
+	return response, err
+}
+
+func (tc *testClient) getAdRevision(adID, number int64, token string) (revisionResponse, error) {
+	var response revisionResponse
+	err := tc.do(http.MethodGet, fmt.Sprintf("/ads/%d/revisions/%d", adID, number), nil, token, &response)
+	return response, err
+}
+
+func (tc *testClient) diffAdRevisions(adID, from, to int64, token string) (revisionDiffResponse, error) {
+	var response revisionDiffResponse
+	err := tc.do(http.MethodGet, fmt.Sprintf("/ads/%d/revisions/diff?from=%d&to=%d", adID, from, to), nil, token, &response)
+	return response, err
+}
+
+func (tc *testClient) restoreAdRevision(adID, number int64, token string) (adResponse, error) {
+	var response adResponse
+	err := tc.do(http.MethodPost, fmt.Sprintf("/ads/%d/revisions/%d/restore", adID, number), nil, token, &response)
+	return response, err
+}
+
+func (tc *testClient) contactAuthor(adID int64, text string, token string) (messageResponse, error) {
+	var response messageResponse
+	err := tc.do(http.MethodPost, fmt.Sprintf("/ads/%d/messages", adID), map[string]string{"text": text}, token, &response)
+	return response, err
+}
+
+func (tc *testClient) sendMessage(conversationID int64, text string, token string) (messageResponse, error) {
+	var response messageResponse
+	err := tc.do(http.MethodPost, fmt.Sprintf("/conversations/%d/messages", conversationID), map[string]string{"text": text}, token, &response)
+	return response, err
+}
+
+func (tc *testClient) listConversations(userID, adID, beforeID int64, limit int, token string) (conversationsResponse, error) {
+	var response conversationsResponse
+	err := tc.do(http.MethodGet, fmt.Sprintf("/users/%d/conversations?ad_id=%d&before=%d&limit=%d", userID, adID, beforeID, limit), nil, token, &response)
+	return response, err
+}
+
+func (tc *testClient) listMessages(conversationID, beforeID int64, limit int, token string) (messagesResponse, error) {
+	var response messagesResponse
+	err := tc.do(http.MethodGet, fmt.Sprintf("/conversations/%d/messages?before=%d&limit=%d", conversationID, beforeID, limit), nil, token, &response)
+	return response, err
+}
+
+func (tc *testClient) markRead(conversationID, messageID int64, token string) (conversationResponse, error) {
+	var response conversationResponse
+	err := tc.do(http.MethodPost, fmt.Sprintf("/conversations/%d/read", conversationID), map[string]int64{"message_id": messageID}, token, &response)
+	return response, err
+}
+
+func (tc *testClient) listInbox(userID, beforeID int64, limit int, token string) (inboxResponse, error) {
+	var response inboxResponse
+	err := tc.do(http.MethodGet, fmt.Sprintf("/users/%d/inbox?before=%d&limit=%d", userID, beforeID, limit), nil, token, &response)
+	return response, err
+}
+
+// uploadImages отправляет файлы в полях image одного multipart-запроса
+func (tc *testClient) uploadImages(adID int64, files [][]byte, token string) (adResponse, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for i, data := range files {
+		part, err := writer.CreateFormFile("image", fmt.Sprintf("photo%d.bin", i))
+		if err != nil {
+			return adResponse{}, err
+		}
+		if _, err := part.Write(data); err != nil {
+			return adResponse{}, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return adResponse{}, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf(tc.baseURL+"/api/v1/ads/%d/images", adID), &body)
+	if err != nil {
+		return adResponse{}, fmt.Errorf("unable to create request: %w", err)
+	}
+	req.Header.Add("Content-Type", writer.FormDataContentType())
+	setToken(req, token)
+
+	var response adResponse
+	err = tc.getResponse(req, &response)
+	return response, err
+}
+
+func (tc *testClient) deleteAdImage(adID, imageID int64, token string) (adResponse, error) {
+	var response adResponse
+	err := tc.do(http.MethodDelete, fmt.Sprintf("/ads/%d/images/%d", adID, imageID), nil, token, &response)
+	return response, err
+}
+
+// getImage скачивает файл по ссылке из ответа; ссылка начинается с /api/v1
+func (tc *testClient) getImage(url string) ([]byte, *http.Response, error) {
+	resp, err := tc.client.Get(tc.baseURL + url)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	return data, resp, err
+}
+
+func (tc *testClient) listCategories() (categoryTreeResponse, error) {
+	var response categoryTreeResponse
+	err := tc.do(http.MethodGet, "/categories", nil, "", &response)
+	return response, err
+}
+
+func (tc *testClient) createCategory(name string, parentID int64, token string) (categoryResponse, error) {
+	var response categoryResponse
+	err := tc.do(http.MethodPost, "/categories", map[string]any{"name": name, "parent_id": parentID}, token, &response)
+	return response, err
+}
+
+func (tc *testClient) updateCategory(id int64, name string, parentID int64, token string) (categoryResponse, error) {
+	var response categoryResponse
+	err := tc.do(http.MethodPut, fmt.Sprintf("/categories/%d", id), map[string]any{"name": name, "parent_id": parentID}, token, &response)
+	return response, err
+}
+
+func (tc *testClient) deleteCategory(id int64, token string) error {
+	var response deleteResponse
+	return tc.do(http.MethodDelete, fmt.Sprintf("/categories/%d", id), nil, token, &response)
+}
+
 func (tc *testClient) getAd(id int64) (adResponse, error) {
 	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(tc.baseURL+"/api/v1/ads/%d", id), nil)
 	if err != nil {
@@ -145,11 +863,19 @@ func (tc *testClient) getAd(id int64) (adResponse, error) {
 	return response, nil
 }
 
-func (tc *testClient) getUser(id int64) (userResponse, error) {
+// getAdAs читает объявление от имени пользователя: черновики видят только автор и модераторы
+func (tc *testClient) getAdAs(id int64, token string) (adResponse, error) {
+	var response adResponse
+	err := tc.do(http.MethodGet, fmt.Sprintf("/ads/%d", id), nil, token, &response)
+	return response, err
+}
+
+func (tc *testClient) getUser(id int64, token string) (userResponse, error) {
 	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(tc.baseURL+"/api/v1/users/%d", id), nil)
 	if err != nil {
 		return userResponse{}, fmt.Errorf("unable to create request: %w", err)
 	}
+	setToken(req, token)
 
 	var response userResponse
 	err = tc.getResponse(req, &response)
@@ -161,11 +887,10 @@ func (tc *testClient) getUser(id int64) (userResponse, error) {
 	return response, nil
 }
 
-func (tc *testClient) createAd(userID int64, title string, text string, token string) (adResponse, error) {
+func (tc *testClient) createAd(title string, text string, token string) (adResponse, error) {
 	body := map[string]any{
-		"user_id": userID,
-		"title":   title,
-		"text":    text,
+		"title": title,
+		"text":  text,
 	}
 
 	data, err := json.Marshal(body)
@@ -179,7 +904,7 @@ func (tc *testClient) createAd(userID int64, title string, text string, token st
 	}
 
 	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Authorization", token)
+	setToken(req, token)
 
 	var response adResponse
 	err = tc.getResponse(req, &response)
@@ -248,39 +973,89 @@ func (tc *testClient) loginUser(nickname, password string) (userResponseToken, e
 	return response, nil
 }
 
-func (tc *testClient) changeAdStatus(userID int64, adID int64, published bool, token string) (adResponse, error) {
+func (tc *testClient) refreshToken(refreshToken string) (userResponseToken, error) {
 	body := map[string]any{
-		"user_id":   userID,
-		"published": published,
+		"refresh_token": refreshToken,
 	}
 
 	data, err := json.Marshal(body)
 	if err != nil {
-		return adResponse{}, fmt.Errorf("unable to marshal: %w", err)
+		return userResponseToken{}, fmt.Errorf("unable to marshal: %w", err)
 	}
 
-	req, err := http.NewRequest(http.MethodPut, fmt.Sprintf(tc.baseURL+"/api/v1/ads/%d/status", adID), bytes.NewReader(data))
+	req, err := http.NewRequest(http.MethodPost, tc.baseURL+"/api/v1/users/refresh", bytes.NewReader(data))
 	if err != nil {
-		return adResponse{}, fmt.Errorf("unable to create request: %w", err)
+		return userResponseToken{}, fmt.Errorf("unable to create request: %w", err)
 	}
 
 	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Authorization", token)
 
-	var response adResponse
+	var response userResponseToken
 	err = tc.getResponse(req, &response)
 	if err != nil {
-		return adResponse{}, err
+		return userResponseToken{}, err
+	}
+
+	return response, nil
+}
+
+func (tc *testClient) logoutUser(refreshToken, token string) (deleteResponse, error) {
+	body := map[string]any{
+		"refresh_token": refreshToken,
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return deleteResponse{}, fmt.Errorf("unable to marshal: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, tc.baseURL+"/api/v1/users/logout", bytes.NewReader(data))
+	if err != nil {
+		return deleteResponse{}, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+	setToken(req, token)
+
+	var response deleteResponse
+	err = tc.getResponse(req, &response)
+	if err != nil {
+		return deleteResponse{}, err
+	}
+
+	return response, nil
+}
+
+func (tc *testClient) setUserRole(id int64, role string, token string) (userResponse, error) {
+	body := map[string]any{
+		"role": role,
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return userResponse{}, fmt.Errorf("unable to marshal: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPut, fmt.Sprintf(tc.baseURL+"/api/v1/users/%d/role", id), bytes.NewReader(data))
+	if err != nil {
+		return userResponse{}, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+	setToken(req, token)
+
+	var response userResponse
+	err = tc.getResponse(req, &response)
+	if err != nil {
+		return userResponse{}, err
 	}
 
 	return response, nil
 }
 
-func (tc *testClient) updateAd(userID int64, adID int64, title string, text string, token string) (adResponse, error) {
+func (tc *testClient) changeAdStatus(adID int64, published bool, token string) (adResponse, error) {
 	body := map[string]any{
-		"user_id": userID,
-		"title":   title,
-		"text":    text,
+		"published": published,
 	}
 
 	data, err := json.Marshal(body)
@@ -288,13 +1063,13 @@ func (tc *testClient) updateAd(userID int64, adID int64, title string, text stri
 		return adResponse{}, fmt.Errorf("unable to marshal: %w", err)
 	}
 
-	req, err := http.NewRequest(http.MethodPut, fmt.Sprintf(tc.baseURL+"/api/v1/ads/%d", adID), bytes.NewReader(data))
+	req, err := http.NewRequest(http.MethodPut, fmt.Sprintf(tc.baseURL+"/api/v1/ads/%d/status", adID), bytes.NewReader(data))
 	if err != nil {
 		return adResponse{}, fmt.Errorf("unable to create request: %w", err)
 	}
 
 	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Authorization", token)
+	setToken(req, token)
 
 	var response adResponse
 	err = tc.getResponse(req, &response)
@@ -305,49 +1080,57 @@ func (tc *testClient) updateAd(userID int64, adID int64, title string, text stri
 	return response, nil
 }
 
-func (tc *testClient) deleteAd(adID int64, userID int64, token string) (deleteResponse, error) {
+func (tc *testClient) updateAd(adID int64, title string, text string, token string) (adResponse, error) {
 	body := map[string]any{
-		"user_id": userID,
+		"title": title,
+		"text":  text,
 	}
 
 	data, err := json.Marshal(body)
 	if err != nil {
-		return deleteResponse{}, fmt.Errorf("unable to marshal: %w", err)
+		return adResponse{}, fmt.Errorf("unable to marshal: %w", err)
 	}
 
-	req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf(tc.baseURL+"/api/v1/ads/%d", adID), bytes.NewReader(data))
+	req, err := http.NewRequest(http.MethodPut, fmt.Sprintf(tc.baseURL+"/api/v1/ads/%d", adID), bytes.NewReader(data))
 	if err != nil {
-		return deleteResponse{}, fmt.Errorf("unable to create request: %w", err)
+		return adResponse{}, fmt.Errorf("unable to create request: %w", err)
 	}
 
 	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Authorization", token)
+	setToken(req, token)
 
-	var response deleteResponse
+	var response adResponse
 	err = tc.getResponse(req, &response)
 	if err != nil {
-		return deleteResponse{}, err
+		return adResponse{}, err
 	}
-	return response, err
+
+	return response, nil
 }
 
-func (tc *testClient) deleteUser(id int64, UserID int64, token string) (deleteResponse, error) {
-	body := map[string]any{
-		"user_id": id,
+func (tc *testClient) deleteAd(adID int64, token string) (deleteResponse, error) {
+	req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf(tc.baseURL+"/api/v1/ads/%d", adID), nil)
+	if err != nil {
+		return deleteResponse{}, fmt.Errorf("unable to create request: %w", err)
 	}
 
-	data, err := json.Marshal(body)
+	setToken(req, token)
+
+	var response deleteResponse
+	err = tc.getResponse(req, &response)
 	if err != nil {
-		return deleteResponse{}, fmt.Errorf("unable to marshal: %w", err)
+		return deleteResponse{}, err
 	}
+	return response, err
+}
 
-	req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf(tc.baseURL+"/api/v1/users/%d", id), bytes.NewReader(data))
+func (tc *testClient) deleteUser(id int64, token string) (deleteResponse, error) {
+	req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf(tc.baseURL+"/api/v1/users/%d", id), nil)
 	if err != nil {
 		return deleteResponse{}, fmt.Errorf("unable to create request: %w", err)
 	}
 
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Authorization", token)
+	setToken(req, token)
 
 	var response deleteResponse
 	err = tc.getResponse(req, &response)
@@ -376,7 +1159,7 @@ func (tc *testClient) updateUser(first_name, second_name, email, phone string, i
 	}
 
 	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Authorization", token)
+	setToken(req, token)
 
 	var response userResponse
 	err = tc.getResponse(req, &response)
@@ -387,6 +1170,45 @@ func (tc *testClient) updateUser(first_name, second_name, email, phone string, i
 	return response, nil
 }
 
+func (tc *testClient) searchAds(query string, limit, offset int) (searchResponse, error) {
+	params := url.Values{"q": {query}}
+	if limit != 0 {
+		params.Set("limit", fmt.Sprint(limit))
+	}
+	if offset != 0 {
+		params.Set("offset", fmt.Sprint(offset))
+	}
+
+	req, err := http.NewRequest(http.MethodGet, tc.baseURL+"/api/v1/ads/search?"+params.Encode(), nil)
+	if err != nil {
+		return searchResponse{}, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	var response searchResponse
+	err = tc.getResponse(req, &response)
+	if err != nil {
+		return searchResponse{}, err
+	}
+
+	return response, nil
+}
+
+func (tc *testClient) filterAds(query url.Values, token string) (adsResponse, error) {
+	req, err := http.NewRequest(http.MethodGet, tc.baseURL+"/api/v1/ads?"+query.Encode(), nil)
+	if err != nil {
+		return adsResponse{}, fmt.Errorf("unable to create request: %w", err)
+	}
+	setToken(req, token)
+
+	var response adsResponse
+	err = tc.getResponse(req, &response)
+	if err != nil {
+		return adsResponse{}, err
+	}
+
+	return response, nil
+}
+
 func (tc *testClient) listAds(Published, AuthorID, TitlePrefix, CreationTime any) (adsResponse, error) {
 	str := tc.baseURL + "/api/v1/ads"
 	pref := "?"
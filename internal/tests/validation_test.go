@@ -10,13 +10,13 @@ import (
 func TestCreateAd_EmptyTitle(t *testing.T) {
 	client := getTestClient()
 
-	user, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	_, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
 	assert.NoError(t, err)
 
 	token, err := client.loginUser("skyberg11", "abacaba")
 	assert.NoError(t, err)
 
-	_, err = client.createAd(user.Data.ID, "", "world", token.Token)
+	_, err = client.createAd("", "world", token.Token)
 	assert.ErrorIs(t, err, ErrBadRequest)
 }
 
@@ -25,26 +25,26 @@ func TestCreateAd_TooLongTitle(t *testing.T) {
 
 	title := strings.Repeat("a", 101)
 
-	user, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	_, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
 	assert.NoError(t, err)
 
 	token, err := client.loginUser("skyberg11", "abacaba")
 	assert.NoError(t, err)
 
-	_, err = client.createAd(user.Data.ID, title, "world", token.Token)
+	_, err = client.createAd(title, "world", token.Token)
 	assert.ErrorIs(t, err, ErrBadRequest)
 }
 
 func TestCreateAd_EmptyText(t *testing.T) {
 	client := getTestClient()
 
-	user, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	_, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
 	assert.NoError(t, err)
 
 	token, err := client.loginUser("skyberg11", "abacaba")
 	assert.NoError(t, err)
 
-	_, err = client.createAd(user.Data.ID, "title", "", token.Token)
+	_, err = client.createAd("title", "", token.Token)
 	assert.ErrorIs(t, err, ErrBadRequest)
 }
 
@@ -53,63 +53,63 @@ func TestCreateAd_TooLongText(t *testing.T) {
 
 	text := strings.Repeat("a", 501)
 
-	user, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	_, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
 	assert.NoError(t, err)
 
 	token, err := client.loginUser("skyberg11", "abacaba")
 	assert.NoError(t, err)
 
-	_, err = client.createAd(user.Data.ID, "title", text, token.Token)
+	_, err = client.createAd("title", text, token.Token)
 	assert.ErrorIs(t, err, ErrBadRequest)
 }
 
 func TestUpdateAd_EmptyTitle(t *testing.T) {
 	client := getTestClient()
 
-	user, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	_, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
 	assert.NoError(t, err)
 
 	token, err := client.loginUser("skyberg11", "abacaba")
 	assert.NoError(t, err)
 
-	resp, err := client.createAd(user.Data.ID, "hello", "world", token.Token)
+	resp, err := client.createAd("hello", "world", token.Token)
 	assert.NoError(t, err)
 
-	_, err = client.updateAd(user.Data.ID, resp.Data.ID, "", "new_world", token.Token)
+	_, err = client.updateAd(resp.Data.ID, "", "new_world", token.Token)
 	assert.ErrorIs(t, err, ErrBadRequest)
 }
 
 func TestUpdateAd_TooLongTitle(t *testing.T) {
 	client := getTestClient()
 
-	user, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	_, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
 	assert.NoError(t, err)
 
 	token, err := client.loginUser("skyberg11", "abacaba")
 	assert.NoError(t, err)
 
-	resp, err := client.createAd(user.Data.ID, "hello", "world", token.Token)
+	resp, err := client.createAd("hello", "world", token.Token)
 	assert.NoError(t, err)
 
 	title := strings.Repeat("a", 101)
 
-	_, err = client.updateAd(user.Data.ID, resp.Data.ID, title, "world", token.Token)
+	_, err = client.updateAd(resp.Data.ID, title, "world", token.Token)
 	assert.ErrorIs(t, err, ErrBadRequest)
 }
 
 func TestUpdateAd_EmptyText(t *testing.T) {
 	client := getTestClient()
 
-	user, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	_, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
 	assert.NoError(t, err)
 
 	token, err := client.loginUser("skyberg11", "abacaba")
 	assert.NoError(t, err)
 
-	resp, err := client.createAd(user.Data.ID, "hello", "world", token.Token)
+	resp, err := client.createAd("hello", "world", token.Token)
 	assert.NoError(t, err)
 
-	_, err = client.updateAd(user.Data.ID, resp.Data.ID, "title", "", token.Token)
+	_, err = client.updateAd(resp.Data.ID, "title", "", token.Token)
 	assert.ErrorIs(t, err, ErrBadRequest)
 }
 
@@ -118,15 +118,15 @@ func TestUpdateAd_TooLongText(t *testing.T) {
 
 	text := strings.Repeat("a", 501)
 
-	user, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	_, err := client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
 	assert.NoError(t, err)
 
 	token, err := client.loginUser("skyberg11", "abacaba")
 	assert.NoError(t, err)
 
-	resp, err := client.createAd(user.Data.ID, "hello", "world", token.Token)
+	resp, err := client.createAd("hello", "world", token.Token)
 	assert.NoError(t, err)
 
-	_, err = client.updateAd(user.Data.ID, resp.Data.ID, "title", text, token.Token)
+	_, err = client.updateAd(resp.Data.ID, "title", text, token.Token)
 	assert.ErrorIs(t, err, ErrBadRequest)
 }
@@ -0,0 +1,534 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"adflow/internal/ads"
+	"adflow/internal/app"
+	"adflow/internal/outbox"
+	service "adflow/internal/ports/grpc/service"
+	"adflow/internal/webhook"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// partner - приёмник вебхуков: проверяет подпись и отвечает 500 на первые failures запросов
+type partner struct {
+	m        sync.Mutex
+	secret   string
+	failures int
+	events   []outbox.Envelope
+	bad      int
+}
+
+func (p *partner) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+	err := webhook.Verify(p.secret, r.Header.Get(webhook.HeaderTimestamp), r.Header.Get(webhook.HeaderSignature), body, time.Now(), 0)
+	if err != nil {
+		p.bad++
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if p.failures != 0 {
+		p.failures--
+		http.Error(w, "try later", http.StatusInternalServerError)
+		return
+	}
+
+	var event outbox.Envelope
+	if json.Unmarshal(body, &event) != nil || r.Header.Get(webhook.HeaderEvent) != string(event.Type) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	p.events = append(p.events, event)
+}
+
+func (p *partner) setup(secret string, failures int) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	p.secret, p.failures = secret, failures
+}
+
+func (p *partner) received() ([]ads.EventType, int) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	types := make([]ads.EventType, 0, len(p.events))
+	for _, event := range p.events {
+		types = append(types, event.Type)
+	}
+	return types, p.bad
+}
+
+// clock - часы диспетчера, которые тест двигает вручную
+type clock struct {
+	m   sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.now = c.now.Add(d)
+}
+
+type webhookEnv struct {
+	app        app.App
+	client     *testClient
+	hooks      app.Webhooks
+	relay      *outbox.Relay
+	dispatcher *webhook.Dispatcher
+	clock      *clock
+	partner    *partner
+	url        string
+	admin      string
+	author     string
+}
+
+func newWebhookEnv(t *testing.T) *webhookEnv {
+	stores, index := newTestStores()
+	repo, users := stores.Ads, stores.Users
+	hooks := stores.Webhooks
+	a := app.NewApp(stores,
+		app.WithSearchIndex(index), app.WithPasswordHasher(cheapHasher), publishUnflagged)
+
+	env := &webhookEnv{app: a, client: getTestClientFor(a), hooks: hooks, partner: &partner{}, clock: &clock{now: time.Now()}}
+
+	server := httptest.NewServer(env.partner)
+	t.Cleanup(server.Close)
+	env.url = server.URL + "/hooks"
+
+	env.dispatcher = webhook.NewDispatcher(hooks,
+		webhook.WithClient(server.Client()),
+		webhook.WithClock(env.clock.Now),
+		webhook.WithBackoff(webhook.Backoff{Base: time.Minute, Max: 10 * time.Minute, MaxAttempts: 3}))
+	env.relay = outbox.NewRelay([]outbox.Source{{Name: "ads", Outbox: repo}, {Name: "users", Outbox: users}}, env.dispatcher)
+
+	admin, err := env.client.createUser("Admin", "Admin", "admin", "abacaba", "admin@phystech.edu", "8900")
+	assert.NoError(t, err)
+	assert.NoError(t, users.UpdateRole(admin.Data.ID, ads.RoleAdmin))
+	_, err = env.client.createUser("Timur", "Zykov", "skyberg11", "abacaba", "zykov.ta@phystech.edu", "891428821XX")
+	assert.NoError(t, err)
+
+	token, err := env.client.loginUser("admin", "abacaba")
+	assert.NoError(t, err)
+	env.admin = token.Token
+	token, err = env.client.loginUser("skyberg11", "abacaba")
+	assert.NoError(t, err)
+	env.author = token.Token
+
+	return env
+}
+
+// flush переносит события из outbox в доставки и делает одну попытку отправки
+func (env *webhookEnv) flush(t *testing.T) int {
+	_, err := env.relay.Flush(context.Background())
+	assert.NoError(t, err)
+
+	n, err := env.dispatcher.Flush(context.Background())
+	assert.NoError(t, err)
+	return n
+}
+
+func TestWebhookManagement(t *testing.T) {
+	env := newWebhookEnv(t)
+	client := env.client
+
+	_, err := client.createWebhook(env.url, []string{"ad.published"}, env.author)
+	assert.ErrorIs(t, err, ErrForbidden)
+	_, err = client.createWebhook(env.url, []string{"ad.published"}, "")
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	_, err = client.createWebhook("ftp://partner.example/hooks", []string{"ad.published"}, env.admin)
+	assert.ErrorIs(t, err, ErrBadRequest)
+	_, err = client.createWebhook(env.url, []string{"ad.sold"}, env.admin)
+	assert.ErrorIs(t, err, ErrBadRequest)
+	_, err = client.createWebhook(env.url, nil, env.admin)
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	hook, err := client.createWebhook(env.url, []string{"ad.published", "ad.deleted", "ad.published"}, env.admin)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ad.published", "ad.deleted"}, hook.Data.Events)
+	assert.True(t, hook.Data.Active)
+	assert.Len(t, hook.Data.Secret, 64)
+
+	// Ключ подписи больше нигде не показывается
+	list, err := client.listWebhooks(env.admin)
+	assert.NoError(t, err)
+	if assert.Len(t, list.Data, 1) {
+		assert.Equal(t, hook.Data.ID, list.Data[0].ID)
+		assert.Empty(t, list.Data[0].Secret)
+	}
+	_, err = client.listWebhooks(env.author)
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	updated, err := client.updateWebhook(hook.Data.ID, env.url, []string{"ad.status_changed"}, false, env.admin)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ad.status_changed"}, updated.Data.Events)
+	assert.False(t, updated.Data.Active)
+	assert.Empty(t, updated.Data.Secret)
+
+	_, err = client.listWebhookDeliveries(hook.Data.ID, "unknown", env.admin)
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	_, err = client.deleteWebhook(hook.Data.ID, env.admin)
+	assert.NoError(t, err)
+	_, err = client.listWebhookDeliveries(hook.Data.ID, "", env.admin)
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	// Через gRPC - те же правила
+	grpcClient, ctx := getGRPCTestClientFor(t, env.app)
+	_, err = grpcClient.CreateWebhook(withToken(ctx, env.author), &service.CreateWebhookRequest{Url: env.url, Events: []string{"ad.deleted"}})
+	assert.ErrorIs(t, fromStatus(err), ErrForbidden)
+
+	created, err := grpcClient.CreateWebhook(withToken(ctx, env.admin), &service.CreateWebhookRequest{Url: env.url, Events: []string{"ad.deleted"}})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, created.Secret)
+
+	got, err := grpcClient.GetWebhook(withToken(ctx, env.admin), &service.WebhookRequest{Id: created.Id})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ad.deleted"}, got.Events)
+	assert.Empty(t, got.Secret)
+}
+
+func TestWebhookRetries(t *testing.T) {
+	env := newWebhookEnv(t)
+	client := env.client
+
+	hook, err := client.createWebhook(env.url, []string{"ad.published", "ad.deleted"}, env.admin)
+	assert.NoError(t, err)
+	// Первый запрос партнёр не примет
+	env.partner.setup(hook.Data.Secret, 1)
+
+	ad, err := client.createAd("Велосипед", "Почти новый", env.author)
+	assert.NoError(t, err)
+	_, err = client.changeAdStatus(ad.Data.ID, true, env.author)
+	assert.NoError(t, err)
+	_, err = client.deleteAd(ad.Data.ID, env.author)
+	assert.NoError(t, err)
+
+	// ad.created подписке не нужен; ad.published получил 500, ad.deleted доставлен
+	assert.Equal(t, 1, env.flush(t))
+	types, bad := env.partner.received()
+	assert.Equal(t, []ads.EventType{ads.EventAdDeleted}, types)
+	assert.Zero(t, bad)
+
+	pending, err := client.listWebhookDeliveries(hook.Data.ID, "pending", env.admin)
+	assert.NoError(t, err)
+	if assert.Len(t, pending.Data, 1) {
+		d := pending.Data[0]
+		assert.Equal(t, "ad.published", d.EventType)
+		assert.Equal(t, 1, d.Attempts)
+		assert.Equal(t, http.StatusInternalServerError, d.ResponseStatus)
+		assert.Contains(t, d.Error, "try later")
+		if assert.NotNil(t, d.NextAttemptTime) {
+			assert.WithinDuration(t, env.clock.Now().Add(time.Minute), *d.NextAttemptTime, time.Second)
+		}
+	}
+
+	// Повтор - только когда подойдёт срок
+	assert.Zero(t, env.flush(t))
+	env.clock.Advance(time.Minute)
+	assert.Equal(t, 1, env.flush(t))
+
+	types, _ = env.partner.received()
+	assert.Equal(t, []ads.EventType{ads.EventAdDeleted, ads.EventAdPublished}, types)
+
+	history, err := client.listWebhookDeliveries(hook.Data.ID, "", env.admin)
+	assert.NoError(t, err)
+	if assert.Len(t, history.Data, 2) {
+		// Новые первыми
+		assert.Equal(t, "ad.deleted", history.Data[0].EventType)
+		published := history.Data[1]
+		assert.Equal(t, "delivered", published.Status)
+		assert.Equal(t, 2, published.Attempts)
+		assert.Equal(t, http.StatusOK, published.ResponseStatus)
+		assert.Empty(t, published.Error)
+		assert.NotNil(t, published.DeliveryTime)
+
+		var event outbox.Envelope
+		assert.NoError(t, json.Unmarshal(published.Payload, &event))
+		assert.Equal(t, "ads", event.Source)
+		assert.Equal(t, ad.Data.ID, event.AggregateID)
+	}
+}
+
+func TestWebhookDeadLetter(t *testing.T) {
+	env := newWebhookEnv(t)
+	client := env.client
+
+	hook, err := client.createWebhook(env.url, []string{"ad.created"}, env.admin)
+	assert.NoError(t, err)
+	env.partner.setup(hook.Data.Secret, -1)
+
+	_, err = client.createAd("Велосипед", "Почти новый", env.author)
+	assert.NoError(t, err)
+
+	// Три попытки с паузами в 1 и 2 минуты, потом доставка уходит в dead
+	assert.Zero(t, env.flush(t))
+	env.clock.Advance(time.Minute)
+	assert.Zero(t, env.flush(t))
+	env.clock.Advance(time.Minute)
+	assert.Zero(t, env.flush(t))
+	env.clock.Advance(time.Minute)
+	assert.Zero(t, env.flush(t))
+
+	dead, err := client.listWebhookDeliveries(hook.Data.ID, "dead", env.admin)
+	assert.NoError(t, err)
+	if !assert.Len(t, dead.Data, 1) {
+		return
+	}
+	assert.Equal(t, 3, dead.Data[0].Attempts)
+	assert.Nil(t, dead.Data[0].NextAttemptTime)
+
+	env.clock.Advance(time.Hour)
+	assert.Zero(t, env.flush(t))
+
+	// Партнёр починился: доставку отправляют заново вручную
+	env.partner.setup(hook.Data.Secret, 0)
+	_, err = client.retryWebhookDelivery(hook.Data.ID, dead.Data[0].ID, env.author)
+	assert.ErrorIs(t, err, ErrForbidden)
+	_, err = client.retryWebhookDelivery(hook.Data.ID+1, dead.Data[0].ID, env.admin)
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	retried, err := client.retryWebhookDelivery(hook.Data.ID, dead.Data[0].ID, env.admin)
+	assert.NoError(t, err)
+	assert.Equal(t, "pending", retried.Data.Status)
+	assert.Zero(t, retried.Data.Attempts)
+
+	// Срок повтора ставится по настоящим часам, а часы диспетчера ушли вперёд
+	assert.Equal(t, 1, env.flush(t))
+	types, _ := env.partner.received()
+	assert.Equal(t, []ads.EventType{ads.EventAdCreated}, types)
+
+	_, err = client.retryWebhookDelivery(hook.Data.ID, dead.Data[0].ID, env.admin)
+	assert.ErrorIs(t, err, ErrBadRequest)
+}
+
+func TestWebhookSubscriptionFilters(t *testing.T) {
+	env := newWebhookEnv(t)
+	client := env.client
+
+	hook, err := client.createWebhook(env.url, []string{"ad.created"}, env.admin)
+	assert.NoError(t, err)
+	env.partner.setup(hook.Data.Secret, 0)
+
+	// Выключенной подписке новые события не достаются
+	_, err = client.updateWebhook(hook.Data.ID, env.url, []string{"ad.created"}, false, env.admin)
+	assert.NoError(t, err)
+	_, err = client.createAd("Самокат", "Почти новый", env.author)
+	assert.NoError(t, err)
+	assert.Zero(t, env.flush(t))
+
+	_, err = client.updateWebhook(hook.Data.ID, env.url, []string{"ad.created"}, true, env.admin)
+	assert.NoError(t, err)
+	_, err = client.createAd("Велосипед", "Почти новый", env.author)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, env.flush(t))
+
+	// Relay может передать событие повторно, но доставка на него одна
+	event := outbox.Envelope{Source: "ads", ID: 1000, Type: ads.EventAdCreated, AggregateID: 1, OccurredAt: time.Now().UTC(), Payload: json.RawMessage(`{}`)}
+	assert.NoError(t, env.dispatcher.Deliver(context.Background(), []outbox.Envelope{event}))
+	assert.NoError(t, env.dispatcher.Deliver(context.Background(), []outbox.Envelope{event}))
+	assert.Equal(t, 1, env.flush(t))
+
+	history, err := client.listWebhookDeliveries(hook.Data.ID, "delivered", env.admin)
+	assert.NoError(t, err)
+	assert.Len(t, history.Data, 2)
+
+	// Подпись с чужим ключом партнёр отвергает
+	env.partner.setup("another secret", 0)
+	_, err = client.createAd("Ролики", "Почти новые", env.author)
+	assert.NoError(t, err)
+	assert.Zero(t, env.flush(t))
+	_, bad := env.partner.received()
+	assert.Equal(t, 1, bad)
+}
+
+// slowPartner отвечает 200 не раньше чем через delay; с delay < 0 не отвечает до конца теста
+type slowPartner struct {
+	delay    time.Duration
+	release  chan struct{}
+	m        sync.Mutex
+	requests int
+}
+
+func (p *slowPartner) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.m.Lock()
+	p.requests++
+	p.m.Unlock()
+
+	if p.delay < 0 {
+		<-p.release
+		return
+	}
+	time.Sleep(p.delay)
+}
+
+func (p *slowPartner) received() int {
+	p.m.Lock()
+	defer p.m.Unlock()
+	return p.requests
+}
+
+// newSlowHook заводит подписку на ad.created с адресом медленного партнёра
+func (env *webhookEnv) newSlowHook(t *testing.T, slow *slowPartner) int64 {
+	slow.release = make(chan struct{})
+	server := httptest.NewServer(slow)
+	t.Cleanup(server.Close)
+	// Отпускает зависшие запросы до остановки сервера
+	t.Cleanup(func() { close(slow.release) })
+
+	hook, err := env.client.createWebhook(server.URL, []string{"ad.created"}, env.admin)
+	assert.NoError(t, err)
+	return hook.Data.ID
+}
+
+func TestWebhookSlowPartnerDoesNotBlockOthers(t *testing.T) {
+	env := newWebhookEnv(t)
+	client := env.client
+
+	slow := &slowPartner{delay: 300 * time.Millisecond}
+	slowID := env.newSlowHook(t, slow)
+	hook, err := client.createWebhook(env.url, []string{"ad.created"}, env.admin)
+	assert.NoError(t, err)
+	env.partner.setup(hook.Data.Secret, 0)
+
+	for i := 0; i < 3; i++ {
+		_, err := client.createAd("Велосипед", "Почти новый", env.author)
+		assert.NoError(t, err)
+	}
+	_, err = env.relay.Flush(context.Background())
+	assert.NoError(t, err)
+
+	// Медленный партнёр тратит бюджет на первый же запрос, остальные его доставки ждут следующего Flush
+	dispatcher := webhook.NewDispatcher(env.hooks, webhook.WithClock(env.clock.Now), webhook.WithHookBudget(100*time.Millisecond))
+	n, err := dispatcher.Flush(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, 1, slow.received())
+	types, _ := env.partner.received()
+	assert.Len(t, types, 3)
+
+	pending, err := client.listWebhookDeliveries(slowID, "pending", env.admin)
+	assert.NoError(t, err)
+	if assert.Len(t, pending.Data, 2) {
+		assert.Zero(t, pending.Data[0].Attempts)
+		assert.Zero(t, pending.Data[1].Attempts)
+	}
+}
+
+func TestWebhookCancelledAttemptNotCounted(t *testing.T) {
+	env := newWebhookEnv(t)
+
+	hookID := env.newSlowHook(t, &slowPartner{delay: -1})
+	_, err := env.client.createAd("Велосипед", "Почти новый", env.author)
+	assert.NoError(t, err)
+	_, err = env.relay.Flush(context.Background())
+	assert.NoError(t, err)
+
+	// Остановка сервиса посреди запроса - не вина партнёра
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	n, err := env.dispatcher.Flush(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Zero(t, n)
+
+	pending, err := env.client.listWebhookDeliveries(hookID, "pending", env.admin)
+	assert.NoError(t, err)
+	if assert.Len(t, pending.Data, 1) {
+		assert.Zero(t, pending.Data[0].Attempts)
+		assert.Empty(t, pending.Data[0].Error)
+	}
+}
+
+func TestWebhookRetryNotOverwrittenByFlush(t *testing.T) {
+	env := newWebhookEnv(t)
+	client := env.client
+
+	hook, err := client.createWebhook(env.url, []string{"ad.created"}, env.admin)
+	assert.NoError(t, err)
+	env.partner.setup(hook.Data.Secret, -1)
+
+	_, err = client.createAd("Велосипед", "Почти новый", env.author)
+	assert.NoError(t, err)
+	assert.Zero(t, env.flush(t))
+
+	// Диспетчер прочитал доставку, а пока шёл запрос, администратор запросил повтор
+	due, err := env.hooks.DueDeliveries(env.clock.Now().Add(time.Hour), 0, 10)
+	assert.NoError(t, err)
+	if !assert.Len(t, due, 1) {
+		return
+	}
+	stale := due[0]
+	assert.Equal(t, 1, stale.Attempts)
+
+	_, err = client.retryWebhookDelivery(hook.Data.ID, stale.ID, env.admin)
+	assert.NoError(t, err)
+
+	stale.Attempts++
+	assert.ErrorIs(t, env.hooks.SaveDelivery(stale, ads.DeliveryPending, 1), ads.ErrBadRequest)
+
+	pending, err := client.listWebhookDeliveries(hook.Data.ID, "pending", env.admin)
+	assert.NoError(t, err)
+	if assert.Len(t, pending.Data, 1) {
+		assert.Zero(t, pending.Data[0].Attempts)
+	}
+}
+
+// deletingWebhooks удаляет подписку deleteID сразу после того, как диспетчер забрал её доставки
+type deletingWebhooks struct {
+	app.Webhooks
+	deleteID int64
+}
+
+func (w *deletingWebhooks) DueDeliveries(now time.Time, afterID int64, limit int) ([]*ads.WebhookDelivery, error) {
+	list, err := w.Webhooks.DueDeliveries(now, afterID, limit)
+	if err == nil && w.deleteID != 0 {
+		err = w.Webhooks.DeleteWebhook(w.deleteID)
+		w.deleteID = 0
+	}
+	return list, err
+}
+
+func TestWebhookDeletedDuringFlush(t *testing.T) {
+	env := newWebhookEnv(t)
+	client := env.client
+
+	hook, err := client.createWebhook(env.url, []string{"ad.created"}, env.admin)
+	assert.NoError(t, err)
+	env.partner.setup(hook.Data.Secret, 0)
+	// Доставка удалённой подписки - последняя в пачке
+	deletedID := env.newSlowHook(t, &slowPartner{})
+
+	_, err = client.createAd("Велосипед", "Почти новый", env.author)
+	assert.NoError(t, err)
+	_, err = env.relay.Flush(context.Background())
+	assert.NoError(t, err)
+
+	// Доставки удалённой подписки пропускаются, остальные уходят
+	hooks := &deletingWebhooks{Webhooks: env.hooks, deleteID: deletedID}
+	dispatcher := webhook.NewDispatcher(hooks, webhook.WithClock(env.clock.Now))
+	n, err := dispatcher.Flush(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	types, _ := env.partner.received()
+	assert.Equal(t, []ads.EventType{ads.EventAdCreated}, types)
+}
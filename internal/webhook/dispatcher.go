@@ -0,0 +1,377 @@
+// Package webhook рассылает события домена подпискам партнёров.
+//
+// Dispatcher подключается к outbox.Relay как приёмник: на каждое событие он заводит доставку для всех включённых
+// подписок на этот тип. Flush отправляет доставки, подошедшие по сроку; после неудачи попытка повторяется
+// через растущие интервалы, а когда попытки кончились, доставка переходит в ads.DeliveryDead.
+//
+// Подписки обслуживаются параллельно, доставки одной подписки - по очереди и не дольше её бюджета времени,
+// поэтому медленный партнёр не задерживает остальных.
+package webhook
+
+import (
+	"adflow/internal/ads"
+	"adflow/internal/app"
+	"adflow/internal/outbox"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Заголовки запроса с событием
+const (
+	HeaderEvent     = "X-Adflow-Event"
+	HeaderDelivery  = "X-Adflow-Delivery"
+	HeaderTimestamp = "X-Adflow-Timestamp"
+	HeaderSignature = "X-Adflow-Signature"
+)
+
+// Сколько ждать ответа партнёра, если в контексте нет своего срока
+const requestTimeout = 10 * time.Second
+
+// Сколько символов ответа или ошибки сохраняется в журнале доставок
+const maxErrorLength = 512
+
+// DefaultBatchSize - сколько доставок забирается из хранилища за раз
+const DefaultBatchSize = 100
+
+// DefaultConcurrency - сколько подписок обслуживается одновременно
+const DefaultConcurrency = 8
+
+// DefaultHookBudget - сколько времени за один Flush можно потратить на доставки одной подписки
+const DefaultHookBudget = 30 * time.Second
+
+// Backoff - расписание повторов: после n-й неудачи следующая попытка через Base·2^(n-1), но не позже чем через Max
+type Backoff struct {
+	Base        time.Duration
+	Max         time.Duration
+	MaxAttempts int
+}
+
+// DefaultBackoff - 10 попыток примерно за 4 часа
+var DefaultBackoff = Backoff{Base: 30 * time.Second, Max: time.Hour, MaxAttempts: 10}
+
+// Delay возвращает паузу после attempts неудачных попыток
+func (b Backoff) Delay(attempts int) time.Duration {
+	delay := b.Base
+	for i := 1; i < attempts && delay < b.Max; i++ {
+		delay *= 2
+	}
+	if delay > b.Max {
+		delay = b.Max
+	}
+	return delay
+}
+
+type Dispatcher struct {
+	hooks       app.Webhooks
+	client      *http.Client
+	backoff     Backoff
+	batch       int
+	concurrency int
+	budget      time.Duration
+	now         func() time.Time
+}
+
+type Option func(d *Dispatcher)
+
+// WithClient задаёт HTTP-клиент для запросов к партнёрам
+func WithClient(client *http.Client) Option {
+	return func(d *Dispatcher) {
+		d.client = client
+	}
+}
+
+// WithBackoff заменяет расписание повторов по умолчанию
+func WithBackoff(b Backoff) Option {
+	return func(d *Dispatcher) {
+		d.backoff = b
+	}
+}
+
+// WithConcurrency задаёт, сколько подписок обслуживается одновременно
+func WithConcurrency(n int) Option {
+	return func(d *Dispatcher) {
+		d.concurrency = n
+	}
+}
+
+// WithHookBudget задаёт, сколько времени за один Flush можно потратить на одну подписку;
+// доставки, до которых не дошла очередь, ждут следующего Flush
+func WithHookBudget(budget time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.budget = budget
+	}
+}
+
+// WithClock подменяет часы; нужен тестам, чтобы не ждать повторов
+func WithClock(now func() time.Time) Option {
+	return func(d *Dispatcher) {
+		d.now = now
+	}
+}
+
+func NewDispatcher(hooks app.Webhooks, opts ...Option) *Dispatcher {
+	d := &Dispatcher{
+		hooks:       hooks,
+		client:      &http.Client{Timeout: requestTimeout},
+		backoff:     DefaultBackoff,
+		batch:       DefaultBatchSize,
+		concurrency: DefaultConcurrency,
+		budget:      DefaultHookBudget,
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Deliver заводит доставки событий для включённых подписок; реализует outbox.Sink.
+// Сами запросы к партнёрам отправляет Flush, поэтому недоступный партнёр не задерживает relay.
+func (d *Dispatcher) Deliver(_ context.Context, events []outbox.Envelope) error {
+	hooks, err := d.hooks.ListWebhooks()
+	if err != nil {
+		return err
+	}
+
+	now := d.now().UTC()
+	var deliveries []*ads.WebhookDelivery
+	for _, event := range events {
+		var payload []byte
+		for _, hook := range hooks {
+			if !hook.Active || !hook.Subscribed(event.Type) {
+				continue
+			}
+			if payload == nil {
+				if payload, err = json.Marshal(event); err != nil {
+					return err
+				}
+			}
+
+			deliveries = append(deliveries, &ads.WebhookDelivery{
+				WebhookID:       hook.ID,
+				Source:          event.Source,
+				EventID:         event.ID,
+				EventType:       event.Type,
+				Payload:         string(payload),
+				Status:          ads.DeliveryPending,
+				NextAttemptTime: &now,
+				CreationTime:    now,
+			})
+		}
+	}
+
+	return d.hooks.EnqueueDeliveries(deliveries)
+}
+
+// Flush делает по одной попытке для всех доставок, срок которых подошёл, и возвращает, сколько доставлено.
+// Доставки выключенных подписок и подписок, исчерпавших бюджет, пропускаются до следующего Flush.
+func (d *Dispatcher) Flush(ctx context.Context) (int, error) {
+	now := d.now().UTC()
+	hooks := make(map[int64]*ads.Webhook)
+	spent := make(map[int64]time.Duration)
+	delivered := 0
+
+	var afterID int64
+	for {
+		list, err := d.hooks.DueDeliveries(now, afterID, d.batch)
+		if err != nil {
+			return delivered, err
+		}
+
+		var order []int64
+		groups := make(map[int64][]*ads.WebhookDelivery)
+		for _, delivery := range list {
+			afterID = delivery.ID
+
+			hook, ok := hooks[delivery.WebhookID]
+			if !ok {
+				found, getErr := d.hooks.GetWebhook(delivery.WebhookID)
+				if getErr != nil {
+					// Подписку удалили вместе с доставками, пока шёл обход
+					continue
+				}
+				hook = found
+				hooks[hook.ID] = hook
+			}
+			if !hook.Active || spent[hook.ID] >= d.budget {
+				continue
+			}
+
+			if groups[hook.ID] == nil {
+				order = append(order, hook.ID)
+			}
+			groups[hook.ID] = append(groups[hook.ID], delivery)
+		}
+
+		results := make([]hookResult, len(order))
+		sem := make(chan struct{}, d.concurrency)
+		var wg sync.WaitGroup
+		for i, id := range order {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(i int, hook *ads.Webhook, list []*ads.WebhookDelivery, budget time.Duration) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				results[i] = d.flushHook(ctx, hook, list, budget)
+			}(i, hooks[id], groups[id], d.budget-spent[id])
+		}
+		wg.Wait()
+
+		var flushErr error
+		for i, res := range results {
+			delivered += res.delivered
+			spent[order[i]] += res.spent
+			if res.err != nil {
+				flushErr = res.err
+			}
+		}
+		if flushErr != nil {
+			return delivered, flushErr
+		}
+
+		if len(list) < d.batch || ctx.Err() != nil {
+			return delivered, ctx.Err()
+		}
+	}
+}
+
+// hookResult - итог обхода доставок одной подписки
+type hookResult struct {
+	delivered int
+	spent     time.Duration
+	err       error
+}
+
+// flushHook по очереди отправляет доставки одной подписки, пока не кончится budget.
+// Бюджет считается по настоящим часам, а не по d.now: его тратят реальные запросы.
+func (d *Dispatcher) flushHook(ctx context.Context, hook *ads.Webhook, list []*ads.WebhookDelivery, budget time.Duration) hookResult {
+	var res hookResult
+	start := time.Now()
+	for _, delivery := range list {
+		if ctx.Err() != nil || time.Since(start) >= budget {
+			break
+		}
+
+		ok, err := d.attempt(ctx, hook, delivery)
+		if err != nil {
+			res.err = err
+			break
+		}
+		if ok {
+			res.delivered++
+		}
+	}
+	res.spent = time.Since(start)
+	return res
+}
+
+// attempt отправляет событие и сохраняет итог; true - партнёр его принял.
+// Итог записывается, только если доставку не изменили, пока шёл запрос: повтор, запрошенный
+// через RetryWebhookDelivery, не перезаписывается.
+func (d *Dispatcher) attempt(ctx context.Context, hook *ads.Webhook, delivery *ads.WebhookDelivery) (bool, error) {
+	status, attempts := delivery.Status, delivery.Attempts
+	code, err := d.send(ctx, hook, delivery)
+	if err != nil && ctx.Err() != nil {
+		// Запрос прервала остановка сервиса, а не партнёр: попытка не считается
+		return false, nil
+	}
+
+	now := d.now().UTC()
+	delivery.Attempts++
+	delivery.LastAttemptTime = &now
+	delivery.ResponseStatus = code
+
+	if err == nil {
+		delivery.Status = ads.DeliveryDelivered
+		delivery.NextAttemptTime = nil
+		delivery.DeliveryTime = &now
+		delivery.Error = ""
+	} else {
+		delivery.Error = truncate(err.Error())
+		if delivery.Attempts >= d.backoff.MaxAttempts {
+			delivery.Status = ads.DeliveryDead
+			delivery.NextAttemptTime = nil
+			log.Printf("webhook %d: delivery %d is dead after %d attempts: %v", hook.ID, delivery.ID, delivery.Attempts, err)
+		} else {
+			next := now.Add(d.backoff.Delay(delivery.Attempts))
+			delivery.NextAttemptTime = &next
+		}
+	}
+
+	if saveErr := d.hooks.SaveDelivery(delivery, status, attempts); saveErr != nil {
+		if errors.Is(saveErr, ads.ErrBadRequest) {
+			// Пока шёл запрос, доставку отправили заново или удалили вместе с подпиской
+			return false, nil
+		}
+		return false, saveErr
+	}
+	return err == nil, nil
+}
+
+// send делает один подписанный запрос и возвращает код ответа (0, если ответа не было)
+func (d *Dispatcher) send(ctx context.Context, hook *ads.Webhook, delivery *ads.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	timestamp := d.now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, string(delivery.EventType))
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(hook.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		text, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorLength))
+		return resp.StatusCode, fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(text))
+	}
+	return resp.StatusCode, nil
+}
+
+// truncate укорачивает текст ошибки; ответ партнёра может быть не в UTF-8, а такой текст не примет PostgreSQL
+func truncate(s string) string {
+	if len(s) > maxErrorLength {
+		s = s[:maxErrorLength]
+	}
+	return strings.ToValidUTF8(s, "")
+}
+
+// Run раз в interval вызывает Flush, пока не отменён ctx
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := d.Flush(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("webhook: %v", err)
+		} else if n > 0 {
+			log.Printf("webhook: %d events delivered", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrBadSignature  = errors.New("webhook: signature mismatch")
+	ErrStaleDelivery = errors.New("webhook: timestamp out of tolerance")
+)
+
+const signaturePrefix = "sha256="
+
+// Sign возвращает значение заголовка X-Adflow-Signature: HMAC-SHA256 от "<timestamp>.<тело>".
+// Метка времени входит в подпись, чтобы перехваченный запрос нельзя было повторить позже.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify проверяет подпись на стороне получателя; tolerance - допустимое расхождение часов, 0 - не проверять
+func Verify(secret, timestamp, signature string, body []byte, now time.Time, tolerance time.Duration) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrBadSignature
+	}
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return ErrBadSignature
+	}
+	if !hmac.Equal([]byte(signature), []byte(Sign(secret, ts, body))) {
+		return ErrBadSignature
+	}
+
+	if tolerance > 0 {
+		if skew := now.Sub(time.Unix(ts, 0)); skew > tolerance || skew < -tolerance {
+			return ErrStaleDelivery
+		}
+	}
+	return nil
+}